@@ -0,0 +1,71 @@
+// Command hismap tries the field mapping of a hospital's HIS adapter against a
+// sample lookup response, so a new hospital can be onboarded by editing
+// HIS_ADAPTERS_FILE and checking the result before deploying:
+//
+//	go run ./cmd/hismap -adapters his_adapters.json -hospital "Hospital B" -payload sample.json
+//
+// It prints the patient the middleware would save, or why the mapping or the
+// payload is invalid. Credentials in the file are not checked.
+package main
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/services"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	adapters := flag.String("adapters", os.Getenv("HIS_ADAPTERS_FILE"), "HIS adapter file")
+	hospital := flag.String("hospital", "", "hospital whose mapping to use")
+	payload := flag.String("payload", "-", "sample HIS lookup response, - for stdin")
+	flag.Parse()
+
+	if *adapters == "" || *hospital == "" {
+		log.Fatalf("-adapters and -hospital are required")
+	}
+
+	adapterConfigs, err := configs.LoadHISAdapterMappings(*adapters)
+	if err != nil {
+		log.Fatalf("Invalid HIS adapter file: %v", err)
+	}
+	config, ok := adapterConfigs[*hospital]
+	if !ok {
+		hospitals := make([]string, 0, len(adapterConfigs))
+		for name := range adapterConfigs {
+			hospitals = append(hospitals, name)
+		}
+		sort.Strings(hospitals)
+		log.Fatalf("No HIS adapter for %q; the file has %s", *hospital, strings.Join(hospitals, ", "))
+	}
+
+	var data []byte
+	if *payload == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*payload)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read payload: %v", err)
+	}
+
+	patient, err := services.MapHISPayload(config, data)
+	if err != nil {
+		log.Fatalf("Failed to map payload: %v", err)
+	}
+	if patient.Hospital == "" {
+		patient.Hospital = *hospital
+	}
+	if patient.PatientHN == "" {
+		log.Printf("Warning: the mapped patient has no patient_hn")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(patient)
+}
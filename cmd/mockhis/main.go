@@ -0,0 +1,45 @@
+// Command mockhis is a stand-in HIS for development and QA. It serves the HIS
+// REST contract from fixture files so the middleware can be pointed at it with
+// HIS_API_BASE_URL, or per hospital in HIS_ADAPTERS_FILE.
+package main
+
+import (
+	"agnos-middleware/internal/mockhis"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	fixtures := flag.String("fixtures", "cmd/mockhis/fixtures", "fixture file or directory of .json/.yaml files, one hospital per file")
+	latency := flag.Duration("latency", 0, "delay before every response, unless the hospital sets its own")
+	errorRate := flag.Float64("error-rate", 0, "share of requests, 0 to 1, answered with -error-status")
+	errorStatus := flag.Int("error-status", http.StatusServiceUnavailable, "HTTP status of injected errors")
+	flag.Parse()
+
+	if *errorRate < 0 || *errorRate > 1 {
+		log.Fatalf("-error-rate must be between 0 and 1")
+	}
+
+	hospitals, err := mockhis.LoadFixtures(*fixtures)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	server := mockhis.NewServer(hospitals, mockhis.Options{
+		Latency:     *latency,
+		ErrorRate:   *errorRate,
+		ErrorStatus: *errorStatus,
+	})
+
+	for _, hospital := range hospitals {
+		fmt.Printf(" %s: http://localhost%s/%s (%d patients)\n", hospital.Name, *addr, hospital.Prefix, len(hospital.Patients))
+	}
+	fmt.Printf(" All hospitals: http://localhost%s\n", *addr)
+
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("Failed to start mock HIS: %v", err)
+	}
+}
@@ -15,9 +15,13 @@ package main
 import (
 	"agnos-middleware/internal/configs"
 	"agnos-middleware/internal/controllers/api"
+	"agnos-middleware/internal/controllers/gql"
+	"agnos-middleware/internal/controllers/mllp"
+	"agnos-middleware/internal/mockhis"
 	"agnos-middleware/internal/repositories"
 	"agnos-middleware/internal/services"
 	"agnos-middleware/internal/utils"
+	"context"
 	"fmt"
 	"log"
 
@@ -38,17 +42,81 @@ func main() {
 
 	staffRepo := repositories.NewStaffRepository(db)
 	patientRepo := repositories.NewPatientRepository(db)
+	mpiRepo := repositories.NewMPIRepository(db)
+	patientMergeRepo := repositories.NewPatientMergeRepository(db)
+	patientHistoryRepo := repositories.NewPatientHistoryRepository(db)
+	patientRetentionRepo := repositories.NewPatientRetentionRepository(db)
+	hl7Repo := repositories.NewHL7Repository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	staffWorkspaceRepo := repositories.NewStaffWorkspaceRepository(db)
+	hisSyncRepo := repositories.NewHISSyncRepository(db)
+	hisEventRepo := repositories.NewHISEventRepository(db)
 	fmt.Println("Repositories initialized")
 
 	authService := services.NewAuthService(staffRepo, config)
+	if err := authService.PromoteBootstrapAdmins(); err != nil {
+		log.Fatalf("Failed to promote bootstrap admins: %v", err)
+	}
 	patientService := services.NewPatientService(patientRepo, config)
+	if config.HISAPI.FallbackMode == configs.HISFallbackMock {
+		hospitals, err := mockhis.LoadFixtures(config.HISAPI.MockFixtures)
+		if err != nil {
+			log.Fatalf("Failed to load mock HIS fixtures for HIS_FALLBACK_MODE=mock: %v", err)
+		}
+		patientService.SetMockHIS(mockhis.NewAdapter(hospitals, services.ErrPatientNotFound))
+	}
+	mpiService := services.NewMPIService(mpiRepo)
+	patientService.SetMPIService(mpiService)
+	patientMergeService := services.NewPatientMergeService(patientRepo, patientMergeRepo)
+	patientHistoryService := services.NewPatientHistoryService(patientRepo, patientHistoryRepo)
+	patientRetentionService := services.NewPatientRetentionService(patientRepo, patientRetentionRepo, config)
+	fhirService := services.NewFHIRService(patientService, config)
+	hl7Service := services.NewHL7Service(patientService, patientRepo, patientMergeService, hl7Repo, config)
+	patientExportService := services.NewPatientExportService(patientRepo, auditRepo, config)
+	staffWorkspaceService := services.NewStaffWorkspaceService(staffWorkspaceRepo, patientRepo, patientService, config)
+	patientService.SetWorkspaceService(staffWorkspaceService)
+	hisSyncService := services.NewHISSyncService(patientService, hisSyncRepo, config)
+	hisEventService := services.NewHISEventService(patientService, hisEventRepo, config)
 	fmt.Println("Services initialized")
 
 	staffController := api.NewStaffController(authService)
 	patientController := api.NewPatientController(patientService)
+	mpiController := api.NewMPIController(mpiService)
+	patientMergeController := api.NewPatientMergeController(patientMergeService)
+	patientHistoryController := api.NewPatientHistoryController(patientHistoryService)
+	patientRetentionController := api.NewPatientRetentionController(patientRetentionService)
+	fhirController := api.NewFHIRController(fhirService)
+	hl7Controller := api.NewHL7Controller(hl7Service)
+	patientExportController := api.NewPatientExportController(patientExportService)
+	staffWorkspaceController := api.NewStaffWorkspaceController(staffWorkspaceService)
+	hisEventController := api.NewHISEventController(hisEventService, config.HISWebhook.MaxBodyBytes)
+	graphqlHandler, err := gql.NewHandler(patientService, config.GraphQL.MaxDepth, config.GraphQL.MaxComplexity, config.GraphQL.MaxBodyBytes)
+	if err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
 	fmt.Println("Controllers initialized")
 
-	router := api.SetupRouter(staffController, patientController, authService)
+	scheduler := utils.NewScheduler()
+	scheduler.Register("patient-retention", config.Retention.JobInterval, patientRetentionService.PurgeExpired)
+	scheduler.Register("patient-erasure", config.Retention.ErasureInterval, patientRetentionService.ProcessErasures)
+	scheduler.Register("his-event-retention", config.Retention.JobInterval, hisEventService.PurgeExpired)
+	scheduler.Register(services.HISSyncJobName, config.HISSync.Interval, hisSyncService.Sync)
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+	fmt.Println("Background jobs started")
+
+	hisController := api.NewHISController(patientService, hisSyncService, scheduler)
+
+	if config.HL7.MLLPAddr != "" {
+		mllpListener := mllp.NewListener(config.HL7.MLLPAddr, config.HL7.IdleTimeout, hl7Service)
+		if err := mllpListener.Start(); err != nil {
+			log.Fatalf("Failed to start HL7 listener: %v", err)
+		}
+		defer mllpListener.Stop()
+		fmt.Printf("HL7 MLLP listener on %s\n", mllpListener.Addr())
+	}
+
+	router := api.SetupRouter(staffController, patientController, mpiController, patientMergeController, patientHistoryController, patientRetentionController, fhirController, hl7Controller, patientExportController, staffWorkspaceController, hisController, hisEventController, graphqlHandler, authService)
 	fmt.Println("Routes configured")
 
 	port := config.App.Port
@@ -58,6 +126,9 @@ func main() {
 	fmt.Printf(" Create staff: POST http://localhost:%s/staff/create\n", port)
 	fmt.Printf(" Login: POST http://localhost:%s/staff/login\n", port)
 	fmt.Printf(" Search patient: GET http://localhost:%s/patient/search?id=HN001\n", port)
+	fmt.Printf(" Get patient: GET http://localhost:%s/patient/HN001\n", port)
+	fmt.Printf(" FHIR: GET http://localhost:%s/fhir/metadata\n", port)
+	fmt.Printf(" GraphQL: POST http://localhost:%s/graphql\n", port)
 
 	if err := router.Run(":" + port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
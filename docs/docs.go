@@ -15,105 +15,221 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/patient/search": {
+        "/erasure-requests": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Search for patients by optional criteria. Requires JWT authentication. Staff can only access patients from their own hospital.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "List PDPA erasure requests in the staff member's hospital. Requires the DataSteward or Admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Patient"
+                    "Patient Retention"
                 ],
-                "summary": "Search for patients",
+                "summary": "List erasure requests",
                 "parameters": [
                     {
                         "type": "string",
-                        "default": "1234567890123",
-                        "description": "Patient ID (must be national_id or passport_id). Examples: Hospital A - 1234567890123, 9876543210987, AB1234567; Hospital B - 1111222233334, 4455667788990",
-                        "name": "id",
+                        "description": "Filter by status (pending, approved, rejected, erased, failed)",
+                        "name": "status",
                         "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Erasure requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/erasure-requests/{id}/approve": {
+            "post": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Hospital Number",
-                        "name": "patient_hn",
-                        "in": "query"
-                    },
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Approve a pending erasure request filed by another staff member. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Retention"
+                ],
+                "summary": "Approve an erasure request",
+                "parameters": [
                     {
-                        "type": "string",
-                        "description": "National ID",
-                        "name": "national_id",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Erasure request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Erasure approved",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientErasureRequest"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role or own request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Erasure request not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     },
+                    "409": {
+                        "description": "Erasure request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/erasure-requests/{id}/reject": {
+            "post": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Passport ID",
-                        "name": "passport_id",
-                        "in": "query"
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reject a pending erasure request. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Retention"
+                ],
+                "summary": "Reject an erasure request",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Erasure request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Erasure rejected",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientErasureRequest"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Erasure request not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     },
+                    "409": {
+                        "description": "Erasure request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/fhir/Patient": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Search patients with the FHIR R4 search parameters identifier, family, given, birthdate and gender, and return a searchset Bundle. At least one parameter is required. total counts every match; entries are the page selected by _count and _offset, with a next link while more follow.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "FHIR"
+                ],
+                "summary": "Search FHIR Patients",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "First name (partial match)",
-                        "name": "first_name",
+                        "default": "1234567890123",
+                        "description": "system|value or value (national ID, passport ID or hospital number)",
+                        "name": "identifier",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Middle name (partial match)",
-                        "name": "middle_name",
+                        "description": "Family name",
+                        "name": "family",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Last name (partial match)",
-                        "name": "last_name",
+                        "description": "Given name",
+                        "name": "given",
                         "in": "query"
                     },
                     {
                         "type": "string",
                         "description": "Date of birth (YYYY-MM-DD)",
-                        "name": "date_of_birth",
+                        "name": "birthdate",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Phone number",
-                        "name": "phone_number",
+                        "description": "male or female",
+                        "name": "gender",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Email",
-                        "name": "email",
+                        "type": "integer",
+                        "description": "Maximum number of entries (max 100)",
+                        "name": "_count",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Gender (M/F)",
-                        "name": "gender",
+                        "type": "integer",
+                        "description": "Number of matches to skip; follow the next link to page through the results",
+                        "name": "_offset",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Patients found",
+                        "description": "searchset Bundle",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.FHIRBundle"
                         }
                     },
                     "400": {
-                        "description": "Bad request - at least one search criteria must be provided",
+                        "description": "Invalid search parameter",
                         "schema": {
-                            "$ref": "#/definitions/utils.PatientSearchErrorResponse"
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
                         }
                     },
                     "401": {
@@ -125,74 +241,105 @@ const docTemplate = `{
                     "403": {
                         "description": "Access denied - patient does not belong to your hospital",
                         "schema": {
-                            "$ref": "#/definitions/utils.AccessDeniedErrorResponse"
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
                         }
                     },
-                    "404": {
-                        "description": "Patient not found",
+                    "502": {
+                        "description": "HIS returned an invalid response",
                         "schema": {
-                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
+                        }
+                    },
+                    "503": {
+                        "description": "HIS is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
+                        }
+                    },
+                    "504": {
+                        "description": "HIS did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
                         }
                     }
                 }
             }
         },
-        "/staff/create": {
-            "post": {
-                "description": "Create a new hospital staff account with employee details. All fields will be pre-filled with example values in Swagger UI.",
-                "consumes": [
-                    "application/json"
+        "/fhir/Patient/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Read a patient as a FHIR R4 Patient resource. The logical id is the hospital number. Only patients from the staff member's own hospital are visible.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Staff"
+                    "FHIR"
                 ],
-                "summary": "Create a new staff member",
+                "summary": "Read a FHIR Patient",
                 "parameters": [
                     {
-                        "description": "Staff creation request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.CreateStaffRequest"
-                        }
+                        "type": "string",
+                        "default": "HN001",
+                        "description": "Hospital Number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Staff created successfully",
+                    "200": {
+                        "description": "Patient",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.FHIRPatient"
                         }
                     },
-                    "400": {
-                        "description": "Bad request - validation error",
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
                         "schema": {
-                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict - username/email/employee_id already exists",
+                    "404": {
+                        "description": "Patient not found",
                         "schema": {
-                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                            "$ref": "#/definitions/models.FHIROperationOutcome"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                    }
+                }
+            }
+        },
+        "/fhir/metadata": {
+            "get": {
+                "description": "Describe the FHIR R4 interactions and search parameters supported by this server.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "FHIR"
+                ],
+                "summary": "FHIR capability statement",
+                "responses": {
+                    "200": {
+                        "description": "CapabilityStatement",
                         "schema": {
-                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                            "$ref": "#/definitions/models.FHIRCapabilityStatement"
                         }
                     }
                 }
             }
         },
-        "/staff/login": {
+        "/graphql": {
             "post": {
-                "description": "Authenticate staff member and receive JWT token",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Run a GraphQL query. Queries: me, patient(hn) and patients(filter, first, after). Results go through the same hospital scoping as the REST endpoints. Queries deeper than GRAPHQL_MAX_DEPTH or more complex than GRAPHQL_MAX_COMPLEXITY, introspection fields included, or selecting __schema or __type more than once are rejected with 400; requests over GRAPHQL_MAX_BODY_BYTES get 413. Only queries are supported.",
                 "consumes": [
                     "application/json"
                 ],
@@ -200,149 +347,3177 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Staff"
+                    "GraphQL"
                 ],
-                "summary": "Staff login",
+                "summary": "GraphQL query",
                 "parameters": [
                     {
-                        "description": "Login credentials",
+                        "description": "GraphQL request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.LoginRequest"
+                            "$ref": "#/definitions/gql.Request"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Login successful",
+                        "description": "GraphQL result with data and errors",
                         "schema": {
-                            "$ref": "#/definitions/models.LoginResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad request - validation error",
+                        "description": "Invalid query or limits exceeded",
                         "schema": {
-                            "$ref": "#/definitions/utils.LoginErrorResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid credentials",
+                        "description": "Unauthorized - authorization header required or invalid token",
                         "schema": {
-                            "$ref": "#/definitions/utils.LoginErrorResponse"
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.CreateStaffRequest": {
-            "type": "object",
-            "required": [
-                "email",
-                "employee_id",
-                "first_name",
-                "hospital",
-                "last_name",
-                "password",
-                "role",
-                "username"
-            ],
-            "properties": {
-                "department": {
-                    "type": "string",
-                    "example": "Cardiology"
-                },
-                "email": {
-                    "type": "string",
-                    "example": "john.doe@hospital.com"
-                },
-                "employee_id": {
-                    "type": "string",
-                    "example": "EMP001"
-                },
+        },
+        "/his/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List calls to the HIS event endpoint, newest first, with their outcome (applied, ignored, duplicate, rejected or failed) and error, for troubleshooting a HIS integration, and how many deliveries were turned away unrecorded for an unknown hospital or a bad signature since the service started. Deliveries hold patient data, so this requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "List HIS event deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only deliveries of this hospital",
+                        "name": "hospital",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only deliveries with this outcome",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deliveries",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/his/status": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Show the circuit breaker of every hospital's HIS. While a breaker is open, HIS calls for that hospital fail fast until retry_at, then one trial call decides whether it closes. Requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "HIS circuit breaker status",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breakers",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/his/sync": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Show the background HIS sync job (running, paused, last run and error) and the checkpoint of every HIS with a change feed: changes before since are synced, and a cursor means a run stopped part way and resumes there. Requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "HIS sync status",
+                "responses": {
+                    "200": {
+                        "description": "Sync job and checkpoints",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/his/sync/pause": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop the background HIS sync from running until it is resumed. A run in progress stops after the batch it is saving; the next run continues from the checkpoint. The pause lasts until resumed or the service restarts. Requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "Pause the HIS sync",
+                "responses": {
+                    "200": {
+                        "description": "Sync job",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/his/sync/resume": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Let a paused background HIS sync run again from its next interval or trigger. Requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "Resume the HIS sync",
+                "responses": {
+                    "200": {
+                        "description": "Sync job",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/his/sync/trigger": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Run the background HIS sync now instead of waiting for HIS_SYNC_INTERVAL. A run already in progress is followed by one more. Requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "Trigger a HIS sync",
+                "responses": {
+                    "202": {
+                        "description": "Sync queued",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - sync is paused",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/hl7/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List inbound HL7 v2 messages that could not be parsed or applied, newest first, with the reason. Dead letters hold raw messages, so this requires the Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HL7"
+                ],
+                "summary": "List HL7 dead letters",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dead letters",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/integrations/his/{hospital}/events": {
+            "post": {
+                "description": "Endpoint a HIS pushes patient change notifications to. The raw body is signed with the hospital's webhook secret: X-HIS-Signature is sha256= and the hex HMAC-SHA256 of \"\u003cX-HIS-Timestamp\u003e.\u003cbody\u003e\", and X-HIS-Timestamp (Unix seconds) must be within HIS_WEBHOOK_TOLERANCE of now. patient.created and patient.updated events carrying a patient (in the vendor's format, mapped with the hospital's adapter fields) save it; events with only patient_hn refresh the cached patient from HIS. Redelivering an event_id that was applied answers duplicate without applying it again; failed events may be retried. Every authenticated delivery is recorded; deliveries for an unknown hospital or with a bad signature are only logged and counted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "HIS"
+                ],
+                "summary": "Receive a HIS event",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hospital sending the event",
+                        "name": "hospital",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unix seconds the event was signed at",
+                        "name": "X-HIS-Timestamp",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "sha256=\u003chex HMAC-SHA256 of timestamp.body\u003e",
+                        "name": "X-HIS-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Event",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.HISEvent"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome: applied, ignored or duplicate",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid event or patient",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid signature or timestamp outside the allowed window",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Hospital does not push events",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Event larger than HIS_WEBHOOK_MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "HIS returned an unusable response while refreshing",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "HIS unavailable while refreshing; retry later",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "HIS timed out while refreshing; retry later",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/candidates": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List cross-hospital patient pairs that may be the same person, highest score first. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "List match candidates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "pending",
+                        "description": "Filter by status (pending, linked, rejected)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Match candidates",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/candidates/{id}/link": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Confirm that both registrations are the same person. They will share one enterprise patient ID. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Link a match candidate",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Match candidate ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Candidate linked",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientMatchCandidate"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Match candidate not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Match candidate has already been reviewed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/candidates/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark both registrations as different people so they are never linked automatically. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Reject a match candidate",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Match candidate ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Candidate rejected",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientMatchCandidate"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Match candidate not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Match candidate has already been reviewed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/enterprise/{eid}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every hospital registration linked to an enterprise patient ID. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Get an enterprise patient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Enterprise patient ID",
+                        "name": "eid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Linked registrations",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/patients/{id}/unlink": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Split a registration off into its own enterprise patient. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Unlink a patient registration",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Patient ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patient unlinked",
+                        "schema": {
+                            "$ref": "#/definitions/models.Patient"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/reindex": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Assign enterprise IDs and compute match candidates for every registration that has not been indexed yet. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Reindex unindexed patients",
+                "responses": {
+                    "200": {
+                        "description": "Number of patients indexed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mpi/stats": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Count registrations, distinct people and pending match candidates across the network. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MPI"
+                ],
+                "summary": "Master patient index statistics",
+                "responses": {
+                    "200": {
+                        "description": "Statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.MPIStatsResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Register a patient locally in the staff member's hospital. A hospital number is allocated automatically. Either national_id or passport_id and a first and last name (Thai or English) are required.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Register a patient",
+                "parameters": [
+                    {
+                        "description": "Patient registration request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreatePatientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Patient registered",
+                        "schema": {
+                            "$ref": "#/definitions/models.Patient"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - patient already registered",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/batch-lookup": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Resolve a list of hospital numbers, national IDs or passport IDs in one call. Local hits are served from the database and misses are fetched from HIS concurrently. Each item reports its own status: found, not_found, forbidden or error. Items that could not be checked in HIS because it is unavailable report error, or not_found with source stale-cache in cache-only fallback mode.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Look up many patients at once",
+                "parameters": [
+                    {
+                        "description": "IDs to look up (max 500)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchLookupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Per-item lookup results",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchLookupResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download every patient in the staff member's hospital that matches the search criteria as CSV (UTF-8 with BOM), NDJSON or a FHIR searchset Bundle. The format comes from the format parameter or, when it is missing, the Accept header. Results are streamed and each export is recorded in the audit log with its row count.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/fhir+json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Export patients",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "csv",
+                        "description": "csv, ndjson or fhir",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "patient_hn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "National ID",
+                        "name": "national_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passport ID",
+                        "name": "passport_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First name (partial match)",
+                        "name": "first_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Middle name (partial match)",
+                        "name": "middle_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last name (partial match)",
+                        "name": "last_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date of birth (YYYY-MM-DD)",
+                        "name": "date_of_birth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Gender (M/F)",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Advanced filter, same grammar as /patient/search",
+                        "name": "q",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exported patients",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - unsupported format or invalid q",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/merge": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Merge two HNs of the same person in the staff member's hospital. Lookups by the retired HN resolve to the survivor afterwards. Requires the DataSteward or Admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Merge"
+                ],
+                "summary": "Merge duplicate patients",
+                "parameters": [
+                    {
+                        "description": "Merge request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.MergePatientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Patients merged",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientMerge"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - patient has already been merged",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/merges": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List merges in the staff member's hospital, newest first, including reversed ones. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Merge"
+                ],
+                "summary": "List patient merges",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only merges involving this HN",
+                        "name": "hn",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Merge history",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/merges/{id}/unmerge": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore the retired HN as a patient of its own. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Merge"
+                ],
+                "summary": "Reverse a patient merge",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Merge ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Merge reversed",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientMerge"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Merge not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Merge has already been reversed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Search for patients by optional criteria. Requires JWT authentication. Staff can only access patients from their own hospital. With Accept: application/x-ndjson the matches are streamed one JSON object per line as they are read from the database, followed by a summary line; no matches then returns 200 with a summary count of 0. Each patient has a source: local when read from the database, his when just fetched from HIS. When the id is not found locally and HIS fails, the answer is 502, 503 or 504, or with HIS_FALLBACK_MODE=cache-only a 404 with source stale-cache.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Search for patients",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "1234567890123",
+                        "description": "Patient ID (must be national_id or passport_id). Examples: Hospital A - 1234567890123, 9876543210987, AB1234567; Hospital B - 1111222233334, 4455667788990",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "patient_hn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "National ID",
+                        "name": "national_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passport ID",
+                        "name": "passport_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First name (partial match)",
+                        "name": "first_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Middle name (partial match)",
+                        "name": "middle_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last name (partial match)",
+                        "name": "last_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date of birth (YYYY-MM-DD)",
+                        "name": "date_of_birth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Gender (M/F)",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Look the id up in every configured HIS at once instead (needs id); returns a models.HISFanOutResponse with per-HIS status and timing, 200 even when nothing is found",
+                        "name": "fanout",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Advanced filter, e.g. last_name~jai AND (gender=F OR dob\u003e=1990-01-01). Fields: hn, national_id, passport_id, first_name, middle_name, last_name, dob, gender, phone, email. Operators: = != ~ !~ (contains) and \u003c \u003c= \u003e \u003e= for dob; combine with AND, OR, NOT and parentheses. Also applied to a patient found in HIS for id",
+                        "name": "q",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patients found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - at least one search criteria must be provided or invalid q",
+                        "schema": {
+                            "$ref": "#/definitions/utils.PatientSearchErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - patient does not belong to your hospital",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AccessDeniedErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "HIS returned an invalid response",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "HIS is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "HIS did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/{hn}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a single patient by hospital number (HN). Requires JWT authentication. Only patients from the staff member's own hospital are visible. An HN retired by a merge returns the surviving patient with a Content-Location header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Get patient by hospital number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "HN001",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patient found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Patient"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete a patient in the staff member's hospital. The patient disappears from all lookups and is purged permanently after the soft delete retention period. Requires the DataSteward or Admin role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Retention"
+                ],
+                "summary": "Delete a patient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Patient deleted"
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Partially update a patient in the staff member's hospital. Send the ETag from GET /patient/{hn} in the If-Match header (or the patient's updated_at in the body). A stale version is rejected with 412. Patients cached from HIS can only be updated when write back to that HIS is on; otherwise correct them in HIS.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Update a patient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being updated",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Fields to update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdatePatientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patient updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Patient"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - national_id or passport_id already registered, or patient managed by HIS without write back",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition failed - patient has been modified",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition required - If-Match header or updated_at missing",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/{hn}/erasure": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "File a request to erase a patient's personal data. Another staff member with the DataSteward or Admin role must approve it; the erasure job then scrubs the data and keeps a tombstone.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient Retention"
+                ],
+                "summary": "Request PDPA erasure",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Erasure request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateErasureRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Erasure requested",
+                        "schema": {
+                            "$ref": "#/definitions/models.PatientErasureRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "An erasure request for this patient is already open",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/{hn}/history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every recorded change to a patient, newest first, with its source (his, local, merge, unmerge), the staff member who made it and a field-level diff. Only patients from the staff member's own hospital are visible.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Get patient version history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "HN001",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patient history",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/patient/{hn}/refresh": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Fetch the patient from HIS now and replace the cached copy, however fresh it is. HIS failures are returned as errors; no fallback applies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Patient"
+                ],
+                "summary": "Refresh a patient from HIS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hospital Number",
+                        "name": "hn",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Patient refreshed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Patient"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Patient not found, or not known to HIS",
+                        "schema": {
+                            "$ref": "#/definitions/utils.NotFoundErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "HIS returned an invalid response",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "HIS is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "HIS timed out",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/create": {
+            "post": {
+                "description": "Create a new hospital staff account with employee details. The role is one of Doctor, Nurse or Staff; the Admin and DataSteward roles are granted by an Admin. All fields will be pre-filled with example values in Swagger UI.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff"
+                ],
+                "summary": "Create a new staff member",
+                "parameters": [
+                    {
+                        "description": "Staff creation request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateStaffRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Staff created successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - username/email/employee_id already exists",
+                        "schema": {
+                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.CreateStaffErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/login": {
+            "post": {
+                "description": "Authenticate staff member and receive JWT token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff"
+                ],
+                "summary": "Staff login",
+                "parameters": [
+                    {
+                        "description": "Login credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login successful",
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.LoginErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/utils.LoginErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/me/recent-patients": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the patients the current staff member opened most recently through GET /patient/{hn}, newest first. Only patients of the staff member's hospital are listed; the length is set by RECENT_PATIENTS_LIMIT.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff Workspace"
+                ],
+                "summary": "List recently viewed patients",
+                "responses": {
+                    "200": {
+                        "description": "Recently viewed patients",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/me/saved-searches": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the current staff member's saved searches in their hospital, by name.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff Workspace"
+                ],
+                "summary": "List saved searches",
+                "responses": {
+                    "200": {
+                        "description": "Saved searches",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Save named patient search criteria for the current staff member. Criteria use the same fields as GET /patient/search, including q.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff Workspace"
+                ],
+                "summary": "Save a patient search",
+                "parameters": [
+                    {
+                        "description": "Saved search",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SaveSearchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Search saved",
+                        "schema": {
+                            "$ref": "#/definitions/models.SavedSearch"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - name already used or saved search limit reached",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/me/saved-searches/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete one of the current staff member's saved searches.",
+                "tags": [
+                    "Staff Workspace"
+                ],
+                "summary": "Delete a saved search",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved search ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Saved search deleted"
+                    },
+                    "400": {
+                        "description": "Bad request - invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Saved search not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/me/saved-searches/{id}/run": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Run a saved search exactly like GET /patient/search. An empty result returns an empty list.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff Workspace"
+                ],
+                "summary": "Run a saved search",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved search ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Matching patients",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - authorization header required or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.AuthErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Saved search not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "HIS returned an invalid response",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "HIS is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "HIS did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/staff/{id}/role": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Give a staff member of the caller's hospital a new role, including the privileged Admin and DataSteward roles. Requires the Admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff"
+                ],
+                "summary": "Change a staff member's role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Staff ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New role",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateStaffRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Role changed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Staff not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "gql.Request": {
+            "type": "object",
+            "required": [
+                "query"
+            ],
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "models.BatchLookupRequest": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "ids": {
+                    "description": "IDs can be hospital numbers, national IDs or passport IDs",
+                    "type": "array",
+                    "maxItems": 500,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "HN001",
+                        "1234567890123"
+                    ]
+                }
+            }
+        },
+        "models.BatchLookupResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchLookupResult"
+                    }
+                }
+            }
+        },
+        "models.BatchLookupResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "patient": {
+                    "$ref": "#/definitions/models.Patient"
+                },
+                "source": {
+                    "description": "Source is stale-cache when the item was not found locally and HIS could\nnot be reached to check.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "found"
+                }
+            }
+        },
+        "models.CreateErasureRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 500,
+                    "example": "Patient request under PDPA section 33"
+                }
+            }
+        },
+        "models.CreatePatientRequest": {
+            "type": "object",
+            "required": [
+                "date_of_birth",
+                "gender"
+            ],
+            "properties": {
+                "date_of_birth": {
+                    "type": "string",
+                    "example": "1985-03-15"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "somchai@email.com"
+                },
+                "first_name_en": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Somchai"
+                },
+                "first_name_th": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "สมชาย"
+                },
+                "gender": {
+                    "type": "string",
+                    "enum": [
+                        "M",
+                        "F"
+                    ],
+                    "example": "M"
+                },
+                "last_name_en": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Jaidee"
+                },
+                "last_name_th": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "ใจดี"
+                },
+                "middle_name_en": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "middle_name_th": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "national_id": {
+                    "type": "string",
+                    "example": "1234567890123"
+                },
+                "passport_id": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "example": "AB1234567"
+                },
+                "phone_number": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "example": "0891234567"
+                }
+            }
+        },
+        "models.CreateStaffRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "employee_id",
+                "first_name",
+                "hospital",
+                "last_name",
+                "password",
+                "role",
+                "username"
+            ],
+            "properties": {
+                "department": {
+                    "type": "string",
+                    "example": "Cardiology"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "john.doe@hospital.com"
+                },
+                "employee_id": {
+                    "type": "string",
+                    "example": "EMP001"
+                },
+                "first_name": {
+                    "type": "string",
+                    "example": "John"
+                },
+                "hospital": {
+                    "type": "string",
+                    "example": "Hospital A"
+                },
+                "last_name": {
+                    "type": "string",
+                    "example": "Doe"
+                },
+                "password": {
+                    "type": "string",
+                    "minLength": 6,
+                    "example": "password123"
+                },
+                "phone_number": {
+                    "type": "string",
+                    "example": "0891234567"
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "Doctor",
+                        "Nurse",
+                        "Staff"
+                    ],
+                    "example": "Doctor"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "doctor1"
+                }
+            }
+        },
+        "models.FHIRBundle": {
+            "type": "object",
+            "properties": {
+                "entry": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRBundleEntry"
+                    }
+                },
+                "link": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRBundleLink"
+                    }
+                },
+                "resourceType": {
+                    "type": "string",
+                    "example": "Bundle"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "searchset"
+                }
+            }
+        },
+        "models.FHIRBundleEntry": {
+            "type": "object",
+            "properties": {
+                "fullUrl": {
+                    "type": "string"
+                },
+                "resource": {
+                    "$ref": "#/definitions/models.FHIRPatient"
+                },
+                "search": {
+                    "$ref": "#/definitions/models.FHIRBundleSearch"
+                }
+            }
+        },
+        "models.FHIRBundleLink": {
+            "type": "object",
+            "properties": {
+                "relation": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRBundleSearch": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRCapabilityResource": {
+            "type": "object",
+            "properties": {
+                "interaction": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRInteraction"
+                    }
+                },
+                "searchParam": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRSearchParam"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRCapabilityRest": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRCapabilityResource"
+                    }
+                },
+                "security": {
+                    "$ref": "#/definitions/models.FHIRCapabilitySecurity"
+                }
+            }
+        },
+        "models.FHIRCapabilitySecurity": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "service": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRCodeableConcept"
+                    }
+                }
+            }
+        },
+        "models.FHIRCapabilitySoftware": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRCapabilityStatement": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string"
+                },
+                "fhirVersion": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "resourceType": {
+                    "type": "string",
+                    "example": "CapabilityStatement"
+                },
+                "rest": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRCapabilityRest"
+                    }
+                },
+                "software": {
+                    "$ref": "#/definitions/models.FHIRCapabilitySoftware"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRCodeableConcept": {
+            "type": "object",
+            "properties": {
+                "coding": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRCoding"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRCoding": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "display": {
+                    "type": "string"
+                },
+                "system": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRContactPoint": {
+            "type": "object",
+            "properties": {
+                "system": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRExtension": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                },
+                "valueCode": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRHumanName": {
+            "type": "object",
+            "properties": {
+                "extension": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRExtension"
+                    }
+                },
+                "family": {
+                    "type": "string"
+                },
+                "given": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRIdentifier": {
+            "type": "object",
+            "properties": {
+                "assigner": {
+                    "$ref": "#/definitions/models.FHIRReference"
+                },
+                "system": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.FHIRCodeableConcept"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRInteraction": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRMeta": {
+            "type": "object",
+            "properties": {
+                "lastUpdated": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIROperationOutcome": {
+            "type": "object",
+            "properties": {
+                "issue": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIROperationOutcomeIssue"
+                    }
+                },
+                "resourceType": {
+                    "type": "string",
+                    "example": "OperationOutcome"
+                }
+            }
+        },
+        "models.FHIROperationOutcomeIssue": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "not-found"
+                },
+                "diagnostics": {
+                    "type": "string"
+                },
+                "severity": {
+                    "type": "string",
+                    "example": "error"
+                }
+            }
+        },
+        "models.FHIRPatient": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "birthDate": {
+                    "type": "string",
+                    "example": "1985-03-15"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "male"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "HN001"
+                },
+                "identifier": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRIdentifier"
+                    }
+                },
+                "meta": {
+                    "$ref": "#/definitions/models.FHIRMeta"
+                },
+                "name": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRHumanName"
+                    }
+                },
+                "resourceType": {
+                    "type": "string",
+                    "example": "Patient"
+                },
+                "telecom": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FHIRContactPoint"
+                    }
+                }
+            }
+        },
+        "models.FHIRReference": {
+            "type": "object",
+            "properties": {
+                "display": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FHIRSearchParam": {
+            "type": "object",
+            "properties": {
+                "documentation": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.HISEvent": {
+            "type": "object",
+            "properties": {
+                "event_id": {
+                    "type": "string",
+                    "example": "evt-20240315-0001"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "patient": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "patient_hn": {
+                    "type": "string",
+                    "example": "HN001"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "patient.updated"
+                }
+            }
+        },
+        "models.LoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "password123"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "doctor1"
+                }
+            }
+        },
+        "models.LoginResponse": {
+            "type": "object",
+            "properties": {
+                "department": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "employee_id": {
+                    "type": "string"
+                },
                 "first_name": {
+                    "type": "string"
+                },
+                "hospital": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.MPIStatsResponse": {
+            "type": "object",
+            "properties": {
+                "pending_candidates": {
+                    "type": "integer"
+                },
+                "people": {
+                    "type": "integer"
+                },
+                "registrations": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.MergePatientRequest": {
+            "type": "object",
+            "required": [
+                "reason",
+                "retired_hn",
+                "survivor_hn"
+            ],
+            "properties": {
+                "reason": {
                     "type": "string",
-                    "example": "John"
+                    "maxLength": 500,
+                    "example": "Duplicate walk-in registration"
                 },
-                "hospital": {
+                "retired_hn": {
                     "type": "string",
-                    "example": "Hospital A"
+                    "example": "HN000001"
                 },
-                "last_name": {
+                "survivor_hn": {
                     "type": "string",
-                    "example": "Doe"
+                    "example": "HN001"
+                }
+            }
+        },
+        "models.Patient": {
+            "type": "object",
+            "properties": {
+                "date_of_birth": {
+                    "type": "string"
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 6,
-                    "example": "password123"
+                "email": {
+                    "type": "string"
+                },
+                "enterprise_id": {
+                    "type": "string"
+                },
+                "erased_at": {
+                    "type": "string"
+                },
+                "first_name_en": {
+                    "type": "string"
+                },
+                "first_name_th": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "hospital": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_fetched_at": {
+                    "description": "LastFetchedAt is when the row was last refreshed from HIS; nil for rows only\never registered locally. Retention purges HIS-cached rows based on it.",
+                    "type": "string"
+                },
+                "last_name_en": {
+                    "type": "string"
+                },
+                "last_name_th": {
+                    "type": "string"
+                },
+                "merged_into_id": {
+                    "type": "integer"
+                },
+                "middle_name_en": {
+                    "type": "string"
+                },
+                "middle_name_th": {
+                    "type": "string"
+                },
+                "national_id": {
+                    "type": "string"
+                },
+                "passport_id": {
+                    "type": "string"
+                },
+                "patient_hn": {
+                    "type": "string"
                 },
                 "phone_number": {
-                    "type": "string",
-                    "example": "0891234567"
+                    "type": "string"
                 },
-                "role": {
+                "source": {
+                    "description": "Source tells where this copy of the patient came from; see PatientSource*.",
                     "type": "string",
-                    "example": "Doctor"
+                    "example": "local"
                 },
-                "username": {
-                    "type": "string",
-                    "example": "doctor1"
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "models.LoginRequest": {
+        "models.PatientErasureRequest": {
             "type": "object",
-            "required": [
-                "password",
-                "username"
-            ],
             "properties": {
-                "password": {
-                    "type": "string",
-                    "example": "password123"
+                "created_at": {
+                    "type": "string"
                 },
-                "username": {
-                    "type": "string",
-                    "example": "doctor1"
+                "erased_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "hospital": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "patient_hn": {
+                    "type": "string"
+                },
+                "patient_id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "integer"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "reviewed_by": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "models.LoginResponse": {
+        "models.PatientMatchCandidate": {
             "type": "object",
             "properties": {
-                "department": {
+                "candidate": {
+                    "$ref": "#/definitions/models.Patient"
+                },
+                "candidate_id": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "email": {
+                "id": {
+                    "type": "integer"
+                },
+                "patient": {
+                    "$ref": "#/definitions/models.Patient"
+                },
+                "patient_id": {
+                    "type": "integer"
+                },
+                "reasons": {
                     "type": "string"
                 },
-                "employee_id": {
+                "reviewed_at": {
                     "type": "string"
                 },
-                "first_name": {
+                "reviewed_by": {
+                    "type": "integer"
+                },
+                "score": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PatientMerge": {
+            "type": "object",
+            "properties": {
+                "chained_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "filled_fields": {
                     "type": "string"
                 },
                 "hospital": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
+                "merged_at": {
+                    "type": "string"
+                },
+                "merged_by": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "regrouped_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "retired_enterprise_id": {
+                    "type": "string"
+                },
+                "retired_hn": {
+                    "type": "string"
+                },
+                "retired_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "survivor_enterprise_id": {
+                    "type": "string"
+                },
+                "survivor_hn": {
+                    "type": "string"
+                },
+                "survivor_id": {
+                    "type": "integer"
+                },
+                "unmerged_at": {
+                    "type": "string"
+                },
+                "unmerged_by": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PatientSearchRequest": {
+            "type": "object",
+            "properties": {
+                "date_of_birth": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Can be either national_id or passport_id (per HIS API spec)",
+                    "type": "string",
+                    "example": "1234567890123"
+                },
                 "last_name": {
                     "type": "string"
                 },
-                "role": {
+                "middle_name": {
                     "type": "string"
                 },
-                "token": {
+                "national_id": {
                     "type": "string"
                 },
-                "username": {
+                "passport_id": {
+                    "type": "string"
+                },
+                "patient_hn": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "q": {
+                    "description": "Q is an advanced filter, e.g. last_name~\"jai\" AND (gender=F OR dob\u003e=1990-01-01)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SaveSearchRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "criteria": {
+                    "$ref": "#/definitions/models.PatientSearchRequest"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Cardiology follow-ups"
+                }
+            }
+        },
+        "models.SavedSearch": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "criteria": {
+                    "$ref": "#/definitions/models.PatientSearchRequest"
+                },
+                "hospital": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "staff_id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpdatePatientRequest": {
+            "type": "object",
+            "properties": {
+                "date_of_birth": {
+                    "type": "string",
+                    "example": "1985-03-15"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name_en": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "first_name_th": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "gender": {
+                    "type": "string",
+                    "enum": [
+                        "M",
+                        "F"
+                    ]
+                },
+                "last_name_en": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "last_name_th": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "middle_name_en": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "middle_name_th": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "national_id": {
+                    "type": "string"
+                },
+                "passport_id": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "phone_number": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "example": "0891234567"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
+        "models.UpdateStaffRoleRequest": {
+            "type": "object",
+            "required": [
+                "role"
+            ],
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "Doctor",
+                        "Nurse",
+                        "Staff",
+                        "DataSteward",
+                        "Admin"
+                    ],
+                    "example": "DataSteward"
+                }
+            }
+        },
         "utils.AccessDeniedErrorResponse": {
             "type": "object",
             "properties": {
@@ -370,6 +3545,43 @@ const docTemplate = `{
                 }
             }
         },
+        "utils.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.JobStatus": {
+            "type": "object",
+            "properties": {
+                "interval": {
+                    "type": "string"
+                },
+                "last_duration": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_run_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "runs": {
+                    "type": "integer"
+                }
+            }
+        },
         "utils.LoginErrorResponse": {
             "type": "object",
             "properties": {
@@ -1,15 +1,29 @@
 package configs
 
 import (
+	"fmt"
 	"log"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Deployment environments
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
 type ApplicationConfig struct {
 	App struct {
 		Port string
+		// Env is the deployment environment, production unless set; development
+		// features such as the mock HIS fallback need it to be development.
+		Env string
 	}
 	Database struct {
 		Host     string
@@ -21,9 +35,112 @@ type ApplicationConfig struct {
 	JWT struct {
 		Secret string
 	}
+	Staff struct {
+		// BootstrapAdmins maps usernames to the employee IDs they must be
+		// registered with to be promoted to Admin at startup. Privileged roles
+		// cannot be chosen when registering, so this is how the first Admin is
+		// made.
+		BootstrapAdmins map[string]string
+	}
 	HISAPI struct {
+		BaseURL          string
+		WriteBack        bool
+		BatchConcurrency int
+		// ChangesPath is the change feed of the HIS_API_BASE_URL adapter, used by
+		// the background sync. Empty means the HIS has none.
+		ChangesPath string
+		// Timeout bounds one attempt of the HIS_API_BASE_URL adapter; adapters
+		// from the file set their own.
+		Timeout time.Duration
+		// Lookups that fail because HIS is unreachable or overloaded are retried
+		// up to RetryMaxAttempts times in total with jittered exponential backoff.
+		RetryMaxAttempts int
+		RetryBaseDelay   time.Duration
+		RetryMaxDelay    time.Duration
+		// After BreakerFailureThreshold consecutive failures the hospital's
+		// circuit breaker opens and HIS calls fail fast for BreakerOpenTimeout.
+		BreakerFailureThreshold int
+		BreakerOpenTimeout      time.Duration
+		// CacheTTL is how long a patient cached from HIS counts as fresh. Older
+		// copies are still served but refreshed from HIS in the background, at most
+		// RefreshConcurrency at a time. 0 turns refreshing off. RefreshTimeout
+		// bounds one refresh, retries included.
+		CacheTTL           time.Duration
+		RefreshConcurrency int
+		RefreshTimeout     time.Duration
+		// NotFoundTTL is how long a patient HIS answered not found for is not
+		// asked for again. 0 turns the negative cache off.
+		NotFoundTTL time.Duration
+		// FanOutTimeout bounds a fan-out search across every HIS as a whole.
+		// Other hospitals' HIS are only searched when FanOutCrossHospital is on,
+		// and only for staff with one of FanOutRoles; everyone else only
+		// searches their own hospital.
+		FanOutTimeout       time.Duration
+		FanOutCrossHospital bool
+		FanOutRoles         []string
+		// FallbackMode decides what a search that missed locally does when HIS
+		// fails: strict, cache-only or mock. See HISFallback*.
+		FallbackMode string
+		// MockFixtures is the mock HIS fixture file or directory used by the mock
+		// fallback mode.
+		MockFixtures string
+		// Adapters holds one HIS per hospital, loaded from HIS_ADAPTERS_FILE.
+		// When empty every hospital uses BaseURL.
+		Adapters map[string]HISAdapterConfig
+	}
+	Patient struct {
+		HNPrefix        string
+		StreamFlushRows int
+	}
+	FHIR struct {
 		BaseURL string
 	}
+	GraphQL struct {
+		MaxDepth      int
+		MaxComplexity int
+		MaxBodyBytes  int64
+	}
+	Workspace struct {
+		RecentPatientsLimit int
+		SavedSearchLimit    int
+	}
+	HL7 struct {
+		MLLPAddr    string
+		IdleTimeout time.Duration
+		// Facilities maps an MSH-4 sending facility to the hospital it speaks for.
+		// Messages from unknown facilities are rejected.
+		Facilities map[string]string
+		// FacilitySources lists the addresses each facility may connect from, so
+		// a connection cannot send messages for another hospital by naming its
+		// facility. Facilities without sources are rejected.
+		FacilitySources map[string][]netip.Prefix
+	}
+	Retention struct {
+		HISCacheDays   int
+		SoftDeleteDays int
+		JobInterval    time.Duration
+		// ErasureInterval is how often approved erasure requests are carried out.
+		ErasureInterval time.Duration
+		// HISEventDays is how long HIS event deliveries and handled event IDs
+		// are kept.
+		HISEventDays int
+	}
+	HISSync struct {
+		// Interval is how often patients changed in HIS are pulled from every HIS
+		// with a change feed; 0 only syncs when triggered. Each batch of
+		// BatchSize patients is saved in one transaction with the checkpoint.
+		Interval  time.Duration
+		BatchSize int
+	}
+	HISWebhook struct {
+		// Tolerance is how far the signed timestamp of a pushed HIS event may be
+		// from now; older deliveries are refused as replays.
+		Tolerance    time.Duration
+		MaxBodyBytes int64
+		// Secrets maps a hospital to the HMAC secret of its events, for
+		// hospitals without a webhook secret in HIS_ADAPTERS_FILE.
+		Secrets map[string]string
+	}
 }
 
 func LoadConfig() *ApplicationConfig {
@@ -35,6 +152,8 @@ func LoadConfig() *ApplicationConfig {
 
 	// Application Configuration
 	config.App.Port = getEnv("SERVER_PORT", "8080")
+	// Development features have to be asked for: an unset APP_ENV is production
+	config.App.Env = getEnv("APP_ENV", EnvProduction)
 
 	// Database Configuration
 	config.Database.Host = getEnv("DB_HOST", "localhost")
@@ -46,8 +165,90 @@ func LoadConfig() *ApplicationConfig {
 	// JWT Configuration
 	config.JWT.Secret = getEnv("JWT_SECRET", "your-secret-key")
 
+	// Staff Configuration
+	config.Staff.BootstrapAdmins = getEnvMap("STAFF_BOOTSTRAP_ADMINS")
+
 	// External HIS API Configuration
 	config.HISAPI.BaseURL = getEnv("HIS_API_BASE_URL", "https://hospital-a.api.co.th")
+	config.HISAPI.WriteBack = getEnvBool("HIS_API_WRITE_BACK", false)
+	config.HISAPI.BatchConcurrency = getEnvInt("HIS_API_BATCH_CONCURRENCY", 8)
+	config.HISAPI.ChangesPath = getEnv("HIS_API_CHANGES_PATH", "")
+	config.HISAPI.Timeout = getEnvDuration("HIS_API_TIMEOUT", 10*time.Second)
+	config.HISAPI.RetryMaxAttempts = getEnvInt("HIS_API_RETRY_MAX_ATTEMPTS", 3)
+	config.HISAPI.RetryBaseDelay = getEnvDuration("HIS_API_RETRY_BASE_DELAY", 100*time.Millisecond)
+	config.HISAPI.RetryMaxDelay = getEnvDuration("HIS_API_RETRY_MAX_DELAY", 2*time.Second)
+	config.HISAPI.BreakerFailureThreshold = getEnvInt("HIS_API_BREAKER_FAILURE_THRESHOLD", 5)
+	config.HISAPI.BreakerOpenTimeout = getEnvDuration("HIS_API_BREAKER_OPEN_TIMEOUT", 30*time.Second)
+	config.HISAPI.CacheTTL = getEnvDuration("HIS_CACHE_TTL", 24*time.Hour)
+	config.HISAPI.RefreshConcurrency = getEnvInt("HIS_REFRESH_CONCURRENCY", 4)
+	config.HISAPI.RefreshTimeout = getEnvDuration("HIS_REFRESH_TIMEOUT", 30*time.Second)
+	config.HISAPI.NotFoundTTL = getEnvDuration("HIS_NOT_FOUND_TTL", 30*time.Second)
+	config.HISAPI.FanOutTimeout = getEnvDuration("HIS_FANOUT_TIMEOUT", 5*time.Second)
+	config.HISAPI.FanOutCrossHospital = getEnvBool("HIS_FANOUT_CROSS_HOSPITAL", false)
+	config.HISAPI.FanOutRoles = getEnvList("HIS_FANOUT_ROLES", "Admin,DataSteward")
+	config.HISAPI.FallbackMode = getEnv("HIS_FALLBACK_MODE", HISFallbackStrict)
+	config.HISAPI.MockFixtures = getEnv("HIS_MOCK_FIXTURES", "cmd/mockhis/fixtures")
+	switch config.HISAPI.FallbackMode {
+	case HISFallbackStrict, HISFallbackCacheOnly:
+	case HISFallbackMock:
+		if config.App.Env != EnvDevelopment {
+			log.Fatalf("HIS_FALLBACK_MODE=mock is for development only and needs APP_ENV=%s, got %q", EnvDevelopment, config.App.Env)
+		}
+	default:
+		log.Fatalf("Invalid HIS_FALLBACK_MODE %q: use strict, cache-only or mock", config.HISAPI.FallbackMode)
+	}
+	if path := getEnv("HIS_ADAPTERS_FILE", ""); path != "" {
+		adapters, err := LoadHISAdapters(path)
+		if err != nil {
+			log.Fatalf("Failed to load HIS adapters: %v", err)
+		}
+		config.HISAPI.Adapters = adapters
+	}
+
+	// Patient Registration Configuration
+	config.Patient.HNPrefix = getEnv("PATIENT_HN_PREFIX", "HN")
+	config.Patient.StreamFlushRows = getEnvInt("PATIENT_STREAM_FLUSH_ROWS", 100)
+
+	// GraphQL Configuration
+	config.GraphQL.MaxDepth = getEnvInt("GRAPHQL_MAX_DEPTH", 8)
+	config.GraphQL.MaxComplexity = getEnvInt("GRAPHQL_MAX_COMPLEXITY", 1000)
+	config.GraphQL.MaxBodyBytes = int64(getEnvInt("GRAPHQL_MAX_BODY_BYTES", 64<<10))
+
+	// Staff Workspace Configuration
+	config.Workspace.RecentPatientsLimit = getEnvInt("RECENT_PATIENTS_LIMIT", 20)
+	config.Workspace.SavedSearchLimit = getEnvInt("SAVED_SEARCH_LIMIT", 50)
+
+	// FHIR Configuration (public base URL used in Bundle fullUrl and links)
+	config.FHIR.BaseURL = getEnv("FHIR_BASE_URL", "http://localhost:8080/fhir")
+
+	// HL7 v2 MLLP Listener Configuration (empty address disables the listener)
+	config.HL7.MLLPAddr = getEnv("HL7_MLLP_ADDR", "")
+	config.HL7.IdleTimeout = getEnvDuration("HL7_MLLP_IDLE_TIMEOUT", 5*time.Minute)
+	config.HL7.Facilities = getEnvMap("HL7_FACILITIES")
+	config.HL7.FacilitySources = map[string][]netip.Prefix{}
+	for facility, sources := range getEnvMap("HL7_FACILITY_SOURCES") {
+		prefixes, err := parseSourcePrefixes(sources)
+		if err != nil {
+			log.Fatalf("Invalid HL7_FACILITY_SOURCES for %s: %v", facility, err)
+		}
+		config.HL7.FacilitySources[facility] = prefixes
+	}
+
+	// Data Retention Configuration (0 days disables the purge)
+	config.Retention.HISCacheDays = getEnvInt("RETENTION_HIS_CACHE_DAYS", 90)
+	config.Retention.SoftDeleteDays = getEnvInt("RETENTION_SOFT_DELETE_DAYS", 30)
+	config.Retention.JobInterval = getEnvDuration("RETENTION_JOB_INTERVAL", time.Hour)
+	config.Retention.ErasureInterval = getEnvDuration("RETENTION_ERASURE_INTERVAL", time.Minute)
+	config.Retention.HISEventDays = getEnvInt("RETENTION_HIS_EVENT_DAYS", 30)
+
+	// Background HIS Sync Configuration
+	config.HISSync.Interval = getEnvDuration("HIS_SYNC_INTERVAL", 15*time.Minute)
+	config.HISSync.BatchSize = getEnvInt("HIS_SYNC_BATCH_SIZE", 200)
+
+	// Inbound HIS Event Configuration
+	config.HISWebhook.Tolerance = getEnvDuration("HIS_WEBHOOK_TOLERANCE", 5*time.Minute)
+	config.HISWebhook.MaxBodyBytes = int64(getEnvInt("HIS_WEBHOOK_MAX_BODY_BYTES", 1<<20))
+	config.HISWebhook.Secrets = getEnvMap("HIS_WEBHOOK_SECRETS")
 
 	return config
 }
@@ -58,3 +259,68 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvMap parses "key=value,key=value" pairs.
+func getEnvMap(key string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// parseSourcePrefixes parses space separated IP addresses and CIDR ranges.
+func parseSourcePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, source := range strings.Fields(value) {
+		if addr, err := netip.ParseAddr(source); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(source)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an IP address nor a CIDR range", source)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// getEnvList parses a comma separated list, dropping empty items.
+func getEnvList(key string, defaultValue string) []string {
+	values := []string{}
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
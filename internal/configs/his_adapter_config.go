@@ -0,0 +1,284 @@
+package configs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// HIS adapter vendors
+const (
+	HISVendorREST = "rest"
+)
+
+// HIS fallback modes: what a search that missed locally does when HIS fails
+const (
+	// HISFallbackStrict reports the HIS failure to the caller.
+	HISFallbackStrict = "strict"
+	// HISFallbackCacheOnly answers from the local cache alone and says so.
+	HISFallbackCacheOnly = "cache-only"
+	// HISFallbackMock answers from the mock HIS fixtures. Development only.
+	HISFallbackMock = "mock"
+)
+
+// HIS outbound authentication types
+const (
+	HISAuthNone   = ""
+	HISAuthBearer = "bearer"
+	HISAuthBasic  = "basic"
+	HISAuthAPIKey = "api_key"
+	// HISAuthOAuth2 gets tokens with the OAuth2 client credentials grant.
+	HISAuthOAuth2 = "oauth2"
+	// HISAuthMTLS authenticates with a client certificate.
+	HISAuthMTLS = "mtls"
+)
+
+// HISAdapterConfig describes how to talk to one hospital's HIS. It is read from
+// the HIS_ADAPTERS_FILE JSON file, keyed by hospital name.
+type HISAdapterConfig struct {
+	Vendor  string        `json:"vendor"`
+	BaseURL string        `json:"base_url"`
+	Timeout time.Duration `json:"-"`
+	// SearchPath is the lookup URL path; {id} is replaced by the national ID,
+	// passport ID or HN being looked up. Defaults to /patient/search/{id}.
+	SearchPath string `json:"search_path"`
+	// WritePath is where patients are written back: POST to WritePath, PUT to
+	// WritePath/{hn}. Defaults to /patient.
+	WritePath string `json:"write_path"`
+	WriteBack bool   `json:"write_back"`
+	// ChangesPath is the change feed the background sync pages through with
+	// since, cursor and limit query parameters. Empty means the HIS has none.
+	ChangesPath string `json:"changes_path"`
+	// ResultPath is the JSONPath-style path of the patient object inside the
+	// response, e.g. data.patient or $.entry[0].resource. Empty means the whole
+	// body.
+	ResultPath string `json:"result_path"`
+	// Fields maps patient JSON fields (national_id, first_name_en, ...) to where
+	// the vendor field sits relative to ResultPath and how it is converted.
+	// Fields that are not listed are read under their own name.
+	Fields map[string]HISFieldMapping `json:"fields"`
+	Auth   HISAuthConfig              `json:"auth"`
+	// Webhook holds the secret the HIS signs pushed events with.
+	Webhook HISWebhookConfig `json:"webhook"`
+}
+
+// HISWebhookConfig holds the HMAC secret of events a HIS pushes to
+// POST /integrations/his/{hospital}/events, read like the auth secrets.
+type HISWebhookConfig struct {
+	SecretFile string `json:"secret_file"`
+	SecretEnv  string `json:"secret_env"`
+}
+
+// Enabled reports whether the HIS may push events.
+func (w HISWebhookConfig) Enabled() bool {
+	return w.SecretFile != "" || w.SecretEnv != ""
+}
+
+// HISAuthConfig holds the credentials sent with every HIS call. Secrets are
+// never written in the file itself: each is read from a *_file (re-read on use,
+// so rotated secrets are picked up) or, failing that, a *_env variable.
+type HISAuthConfig struct {
+	Type         string `json:"type"`
+	TokenFile    string `json:"token_file"`
+	TokenEnv     string `json:"token_env"`
+	Username     string `json:"username"`
+	PasswordFile string `json:"password_file"`
+	PasswordEnv  string `json:"password_env"`
+	Header       string `json:"header"`
+	KeyFile      string `json:"key_file"`
+	KeyEnv       string `json:"key_env"`
+	// TokenURL, ClientID, the client secret and Scopes configure the oauth2
+	// client credentials grant.
+	TokenURL         string   `json:"token_url"`
+	ClientID         string   `json:"client_id"`
+	ClientSecretFile string   `json:"client_secret_file"`
+	ClientSecretEnv  string   `json:"client_secret_env"`
+	Scopes           []string `json:"scopes"`
+	// ClientCertFile and ClientKeyFile are the PEM client certificate and key
+	// of mtls auth.
+	ClientCertFile string `json:"client_cert_file"`
+	ClientKeyFile  string `json:"client_key_file"`
+	// CAFile is a PEM bundle of the CAs trusted for the HIS server in place of
+	// the system roots. Any auth type may set it.
+	CAFile string `json:"ca_file"`
+}
+
+type hisAdaptersFile struct {
+	Hospitals map[string]struct {
+		HISAdapterConfig
+		Timeout string `json:"timeout"`
+	} `json:"hospitals"`
+}
+
+// LoadHISAdapters reads and validates the HIS adapter file.
+func LoadHISAdapters(path string) (map[string]HISAdapterConfig, error) {
+	return loadHISAdapters(path, true)
+}
+
+// LoadHISAdapterMappings reads and validates the HIS adapter file like
+// LoadHISAdapters, except for credentials, for tools that only map payloads.
+func LoadHISAdapterMappings(path string) (map[string]HISAdapterConfig, error) {
+	return loadHISAdapters(path, false)
+}
+
+func loadHISAdapters(path string, checkAuth bool) (map[string]HISAdapterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HIS adapter file: %w", err)
+	}
+
+	var file hisAdaptersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse HIS adapter file %s: %w", path, err)
+	}
+	if len(file.Hospitals) == 0 {
+		return nil, fmt.Errorf("HIS adapter file %s has no hospitals", path)
+	}
+
+	adapters := make(map[string]HISAdapterConfig, len(file.Hospitals))
+	for hospital, entry := range file.Hospitals {
+		adapter := entry.HISAdapterConfig
+		adapter.Timeout = 10 * time.Second
+		if entry.Timeout != "" {
+			timeout, err := time.ParseDuration(entry.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("HIS adapter %q: invalid timeout %q", hospital, entry.Timeout)
+			}
+			adapter.Timeout = timeout
+		}
+
+		if err := adapter.validate(checkAuth); err != nil {
+			return nil, fmt.Errorf("HIS adapter %q: %w", hospital, err)
+		}
+		adapters[hospital] = adapter
+	}
+
+	return adapters, nil
+}
+
+func (c *HISAdapterConfig) validate(checkAuth bool) error {
+	if c.Vendor == "" {
+		c.Vendor = HISVendorREST
+	}
+	if c.Vendor != HISVendorREST {
+		return fmt.Errorf("unknown vendor %q", c.Vendor)
+	}
+
+	baseURL, err := url.Parse(c.BaseURL)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return fmt.Errorf("base_url %q must be an absolute URL", c.BaseURL)
+	}
+
+	if err := c.validateMapping(); err != nil {
+		return err
+	}
+	if !checkAuth {
+		return nil
+	}
+	if c.Webhook.Enabled() {
+		if err := validateSecret("webhook", "secret", c.Webhook.SecretFile, c.Webhook.SecretEnv); err != nil {
+			return err
+		}
+	}
+	return c.Auth.validate()
+}
+
+func (a *HISAuthConfig) validate() error {
+	switch a.Type {
+	case HISAuthNone:
+	case HISAuthBearer:
+		if err := validateSecret("bearer", "token", a.TokenFile, a.TokenEnv); err != nil {
+			return err
+		}
+	case HISAuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic auth needs username")
+		}
+		if err := validateSecret("basic", "password", a.PasswordFile, a.PasswordEnv); err != nil {
+			return err
+		}
+	case HISAuthAPIKey:
+		if a.Header == "" {
+			return fmt.Errorf("api_key auth needs header")
+		}
+		if err := validateSecret("api_key", "key", a.KeyFile, a.KeyEnv); err != nil {
+			return err
+		}
+	case HISAuthOAuth2:
+		tokenURL, err := url.Parse(a.TokenURL)
+		if err != nil || tokenURL.Scheme == "" || tokenURL.Host == "" {
+			return fmt.Errorf("oauth2 auth needs token_url as an absolute URL")
+		}
+		if a.ClientID == "" {
+			return fmt.Errorf("oauth2 auth needs client_id")
+		}
+		if err := validateSecret("oauth2", "client_secret", a.ClientSecretFile, a.ClientSecretEnv); err != nil {
+			return err
+		}
+	case HISAuthMTLS:
+		if a.ClientCertFile == "" || a.ClientKeyFile == "" {
+			return fmt.Errorf("mtls auth needs client_cert_file and client_key_file")
+		}
+		if _, err := tls.LoadX509KeyPair(a.ClientCertFile, a.ClientKeyFile); err != nil {
+			return fmt.Errorf("mtls auth: failed to load client certificate: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown auth type %q", a.Type)
+	}
+
+	if a.CAFile != "" {
+		if _, err := LoadCertPool(a.CAFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSecret checks that a secret has a source and that its file can be
+// read.
+func validateSecret(authType string, name string, file string, env string) error {
+	if file == "" && env == "" {
+		return fmt.Errorf("%s auth needs %s_file or %s_env", authType, name, name)
+	}
+	if file != "" {
+		if _, err := ReadSecret(file, env); err != nil {
+			return fmt.Errorf("%s auth: %w", authType, err)
+		}
+	}
+	return nil
+}
+
+// ReadSecret returns the secret in file, or in the environment variable env
+// when no file is set. Surrounding whitespace, such as the trailing newline of
+// a mounted secret, is trimmed.
+func ReadSecret(file string, env string) (string, error) {
+	if file == "" {
+		return os.Getenv(env), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA file %s has no PEM certificates", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,250 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HIS field transforms
+const (
+	// HISTransformNone passes the vendor value through as is.
+	HISTransformNone = ""
+	// HISTransformString turns numbers and booleans into text and trims
+	// surrounding whitespace, e.g. for national IDs sent as numbers.
+	HISTransformString = "string"
+	// HISTransformDate parses a date written in Format, in the Calendar.
+	HISTransformDate = "date"
+	// HISTransformMap replaces vendor codes with patient values, e.g. gender
+	// codes 1 and 2 with M and F.
+	HISTransformMap = "map"
+)
+
+// HIS date calendars
+const (
+	HISCalendarGregorian = "gregorian"
+	// HISCalendarBuddhist counts years in the Thai Buddhist Era, 543 years
+	// ahead of the Gregorian calendar.
+	HISCalendarBuddhist = "buddhist"
+)
+
+// BuddhistEraOffset is the number of years the Buddhist Era is ahead.
+const BuddhistEraOffset = 543
+
+// DefaultHISDateFormat is the date format of the date transform when the
+// mapping sets none.
+const DefaultHISDateFormat = "2006-01-02"
+
+// hisMappableFields are the patient JSON fields a HIS mapping may fill.
+var hisMappableFields = []string{
+	"patient_hn", "hospital", "national_id", "passport_id",
+	"first_name_th", "middle_name_th", "last_name_th",
+	"first_name_en", "middle_name_en", "last_name_en",
+	"date_of_birth", "gender", "phone_number", "email",
+}
+
+// HISFieldMapping says where one patient field sits in a vendor payload and how
+// its value is converted. In the adapter file it is either the path alone or an
+// object:
+//
+//	"national_id": "cid"
+//	"date_of_birth": {"path": "$.birth.date", "transform": "date", "format": "02/01/2006", "calendar": "buddhist"}
+//	"gender": {"path": "sex", "transform": "map", "values": {"1": "M", "2": "F"}}
+type HISFieldMapping struct {
+	// Path is JSONPath-style, relative to ResultPath: $.name.given,
+	// identifiers[0].value or the dot form identifiers.0.value.
+	Path      string `json:"path"`
+	Transform string `json:"transform"`
+	// Format is the Go time layout of a date transform, e.g. 02/01/2006 or
+	// 20060102. Defaults to DefaultHISDateFormat.
+	Format string `json:"format"`
+	// Calendar of a date transform: gregorian (the default) or buddhist.
+	Calendar string `json:"calendar"`
+	// Values maps vendor codes to patient values for the map transform. Codes
+	// not listed get Default, or fail the mapping when it is unset.
+	Values  map[string]string `json:"values"`
+	Default *string           `json:"default"`
+}
+
+func (m *HISFieldMapping) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*m = HISFieldMapping{Path: path}
+		return nil
+	}
+
+	type fieldMapping HISFieldMapping
+	var mapping fieldMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return fmt.Errorf("a field mapping is a path or an object: %w", err)
+	}
+	*m = HISFieldMapping(mapping)
+	return nil
+}
+
+// validateMapping checks ResultPath and Fields and rewrites their paths to the
+// dot form the adapter reads.
+func (c *HISAdapterConfig) validateMapping() error {
+	resultPath, err := NormalizeJSONPath(c.ResultPath)
+	if err != nil {
+		return fmt.Errorf("result_path: %w", err)
+	}
+	c.ResultPath = resultPath
+
+	fields := make([]string, 0, len(c.Fields))
+	for field := range c.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		mapping := c.Fields[field]
+		if !isHISMappableField(field) {
+			return fmt.Errorf("fields: unknown patient field %q", field)
+		}
+		if err := mapping.validate(); err != nil {
+			return fmt.Errorf("fields.%s: %w", field, err)
+		}
+		c.Fields[field] = mapping
+	}
+
+	return nil
+}
+
+func isHISMappableField(field string) bool {
+	for _, mappable := range hisMappableFields {
+		if field == mappable {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *HISFieldMapping) validate() error {
+	if m.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	path, err := NormalizeJSONPath(m.Path)
+	if err != nil {
+		return fmt.Errorf("path: %w", err)
+	}
+	m.Path = path
+
+	if m.Transform != HISTransformDate && (m.Format != "" || m.Calendar != "") {
+		return fmt.Errorf("format and calendar only apply to the date transform")
+	}
+	if m.Transform != HISTransformMap && (m.Values != nil || m.Default != nil) {
+		return fmt.Errorf("values and default only apply to the map transform")
+	}
+
+	switch m.Transform {
+	case HISTransformNone, HISTransformString:
+	case HISTransformDate:
+		if m.Format == "" {
+			m.Format = DefaultHISDateFormat
+		}
+		if err := validateDateFormat(m.Format); err != nil {
+			return err
+		}
+		switch m.Calendar {
+		case "", HISCalendarGregorian:
+		case HISCalendarBuddhist:
+			if _, err := BuddhistYearIndex(m.Format); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown calendar %q: use gregorian or buddhist", m.Calendar)
+		}
+	case HISTransformMap:
+		if len(m.Values) == 0 {
+			return fmt.Errorf("map transform needs values")
+		}
+	default:
+		return fmt.Errorf("unknown transform %q: use string, date or map", m.Transform)
+	}
+
+	return nil
+}
+
+// validateDateFormat checks that a layout writes and reads back a full date.
+func validateDateFormat(format string) error {
+	sample := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(format, sample.Format(format))
+	if err != nil || parsed.Year() != 2001 || parsed.Month() != 2 || parsed.Day() != 3 {
+		return fmt.Errorf("format %q is not a Go date layout with a year, month and day, e.g. 02/01/2006", format)
+	}
+	return nil
+}
+
+// BuddhistYearIndex returns where the four digit year sits in dates written in
+// format. Buddhist years are converted before parsing, since a Buddhist year
+// is not a leap year when its Gregorian year is, so format must put the year
+// at a fixed position.
+func BuddhistYearIndex(format string) (int, error) {
+	long := time.Date(4321, 12, 22, 22, 44, 55, 0, time.UTC).Format(format)
+	short := time.Date(4321, 1, 3, 4, 5, 6, 0, time.UTC).Format(format)
+	index := strings.Index(long, "4321")
+	if index < 0 || len(long) != len(short) || strings.Index(short, "4321") != index {
+		return 0, fmt.Errorf("the buddhist calendar needs a fixed width format with a four digit year, e.g. 02/01/2006")
+	}
+	return index, nil
+}
+
+// NormalizeJSONPath turns a JSONPath-style path such as $.data[0].name or
+// data[0]['name'] into the dot form data.0.name. Dot form paths are returned
+// as they are; an empty path or $ is the whole document.
+func NormalizeJSONPath(path string) (string, error) {
+	rest := strings.TrimPrefix(path, "$")
+	var segments []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return "", fmt.Errorf("empty segment in %q", path)
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed [ in %q", path)
+			}
+			segment := rest[1:end]
+			if unquoted, ok := unquoteJSONPathKey(segment); ok {
+				segment = unquoted
+			} else if index, err := strconv.Atoi(segment); err != nil || index < 0 {
+				return "", fmt.Errorf("[%s] in %q is neither an array index nor a quoted key", segment, path)
+			}
+			if segment == "" || strings.Contains(segment, ".") {
+				return "", fmt.Errorf("key %q in %q cannot be empty or contain a dot", segment, path)
+			}
+			segments = append(segments, segment)
+			rest = rest[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			continue
+		}
+		if strings.ContainsAny(rest[:end], "]*") {
+			return "", fmt.Errorf("unsupported segment %q in %q", rest[:end], path)
+		}
+		segments = append(segments, rest[:end])
+		rest = rest[end:]
+	}
+
+	return strings.Join(segments, "."), nil
+}
+
+func unquoteJSONPathKey(segment string) (string, bool) {
+	if len(segment) >= 2 && (segment[0] == '\'' || segment[0] == '"') && segment[len(segment)-1] == segment[0] {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
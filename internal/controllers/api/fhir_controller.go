@@ -0,0 +1,112 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FHIRController struct {
+	fhirService *services.FHIRService
+}
+
+func NewFHIRController(fhirService *services.FHIRService) *FHIRController {
+	return &FHIRController{
+		fhirService: fhirService,
+	}
+}
+
+// @Summary      FHIR capability statement
+// @Description  Describe the FHIR R4 interactions and search parameters supported by this server.
+// @Tags         FHIR
+// @Produce      json
+// @Success      200  {object}  models.FHIRCapabilityStatement  "CapabilityStatement"
+// @Router       /fhir/metadata [get]
+func (ctrl *FHIRController) Metadata(ctx *gin.Context) {
+	writeFHIR(ctx, http.StatusOK, ctrl.fhirService.CapabilityStatement())
+}
+
+// @Summary      Read a FHIR Patient
+// @Description  Read a patient as a FHIR R4 Patient resource. The logical id is the hospital number. Only patients from the staff member's own hospital are visible.
+// @Tags         FHIR
+// @Produce      json
+// @Param        id path string true "Hospital Number" default(HN001)
+// @Security     BearerAuth
+// @Success      200  {object}  models.FHIRPatient  "Patient"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  models.FHIROperationOutcome  "Patient not found"
+// @Router       /fhir/Patient/{id} [get]
+func (ctrl *FHIRController) ReadPatient(ctx *gin.Context) {
+	patient, err := ctrl.fhirService.ReadPatient(ctx.Param("id"), ctx.GetString("staff_hospital"))
+	if err != nil {
+		writeFHIRError(ctx, err)
+		return
+	}
+
+	writeFHIR(ctx, http.StatusOK, patient)
+}
+
+// @Summary      Search FHIR Patients
+// @Description  Search patients with the FHIR R4 search parameters identifier, family, given, birthdate and gender, and return a searchset Bundle. At least one parameter is required. total counts every match; entries are the page selected by _count and _offset, with a next link while more follow.
+// @Tags         FHIR
+// @Produce      json
+// @Param        identifier query string false "system|value or value (national ID, passport ID or hospital number)" default(1234567890123)
+// @Param        family query string false "Family name"
+// @Param        given query string false "Given name"
+// @Param        birthdate query string false "Date of birth (YYYY-MM-DD)"
+// @Param        gender query string false "male or female"
+// @Param        _count query int false "Maximum number of entries (max 100)"
+// @Param        _offset query int false "Number of matches to skip; follow the next link to page through the results"
+// @Security     BearerAuth
+// @Success      200  {object}  models.FHIRBundle  "searchset Bundle"
+// @Failure      400  {object}  models.FHIROperationOutcome  "Invalid search parameter"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  models.FHIROperationOutcome  "Access denied - patient does not belong to your hospital"
+// @Failure      502  {object}  models.FHIROperationOutcome  "HIS returned an invalid response"
+// @Failure      503  {object}  models.FHIROperationOutcome  "HIS is unavailable"
+// @Failure      504  {object}  models.FHIROperationOutcome  "HIS did not respond in time"
+// @Router       /fhir/Patient [get]
+func (ctrl *FHIRController) SearchPatients(ctx *gin.Context) {
+	bundle, err := ctrl.fhirService.SearchPatients(ctx.Request.URL.Query(), ctx.GetString("staff_hospital"))
+	if err != nil {
+		writeFHIRError(ctx, err)
+		return
+	}
+
+	writeFHIR(ctx, http.StatusOK, bundle)
+}
+
+func writeFHIR(ctx *gin.Context, status int, resource interface{}) {
+	ctx.Header("Content-Type", models.FHIRContentType)
+	ctx.JSON(status, resource)
+}
+
+func writeFHIRError(ctx *gin.Context, err error) {
+	status, code := http.StatusInternalServerError, "exception"
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound):
+		status, code = http.StatusNotFound, "not-found"
+	case errors.Is(err, services.ErrAccessDenied):
+		status, code = http.StatusForbidden, "forbidden"
+	case errors.Is(err, services.ErrInvalidSearchParameter):
+		status, code = http.StatusBadRequest, "invalid"
+	case errors.Is(err, services.ErrNotFoundInCache):
+		status, code = http.StatusNotFound, "not-found"
+	case errors.Is(err, services.ErrHISTimeout):
+		status, code = http.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, services.ErrHISUnavailable):
+		status, code = http.StatusServiceUnavailable, "transient"
+	case errors.Is(err, services.ErrHISBadResponse):
+		status, code = http.StatusBadGateway, "exception"
+	}
+
+	writeFHIR(ctx, status, models.FHIROperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue: []models.FHIROperationOutcomeIssue{
+			{Severity: "error", Code: code, Diagnostics: err.Error()},
+		},
+	})
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"agnos-middleware/internal/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupFHIRTestRouter(t *testing.T, staffHospital string) (*gin.Engine, *repositories.PatientRepository) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientHistory{})
+
+	repo := repositories.NewPatientRepository(db)
+	config := &configs.ApplicationConfig{}
+	config.FHIR.BaseURL = "http://localhost:8080/fhir"
+	fhirController := NewFHIRController(services.NewFHIRService(services.NewPatientService(repo, config), config))
+
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("staff_hospital", staffHospital)
+		ctx.Next()
+	})
+	router.GET("/fhir/metadata", fhirController.Metadata)
+	router.GET("/fhir/Patient", fhirController.SearchPatients)
+	router.GET("/fhir/Patient/:id", fhirController.ReadPatient)
+
+	repo.UpsertPatient(&models.Patient{
+		PatientHN:   "HN100",
+		Hospital:    "Hospital A",
+		NationalID:  stringPtr("5555555555555"),
+		FirstNameTH: stringPtr("สมชาย"),
+		LastNameTH:  stringPtr("ใจดี"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		PhoneNumber: stringPtr("0891234567"),
+		Gender:      "M",
+		DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC),
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	return router, repo
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestFHIRReadPatient_Positive(t *testing.T) {
+	router, _ := setupFHIRTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/fhir/Patient/HN100", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.FHIRContentType, w.Header().Get("Content-Type"))
+
+	var response models.FHIRPatient
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Patient", response.ResourceType)
+	assert.Equal(t, "HN100", response.ID)
+	assert.Equal(t, "male", response.Gender)
+	assert.Equal(t, "1985-03-15", response.BirthDate)
+	assert.Len(t, response.Name, 2)
+	assert.Equal(t, "Jaidee", response.Name[1].Family)
+	assert.Contains(t, response.Identifier, models.FHIRIdentifier{Use: "official", System: models.FHIRSystemNationalID, Value: "5555555555555"})
+}
+
+func TestFHIRReadPatient_Negative_NotFound(t *testing.T) {
+	router, _ := setupFHIRTestRouter(t, "Hospital B")
+
+	req, _ := http.NewRequest("GET", "/fhir/Patient/HN100", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response models.FHIROperationOutcome
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "OperationOutcome", response.ResourceType)
+	assert.Equal(t, "not-found", response.Issue[0].Code)
+}
+
+func TestFHIRSearchPatients_Positive_Bundle(t *testing.T) {
+	router, _ := setupFHIRTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/fhir/Patient?identifier="+models.FHIRSystemNationalID+"|5555555555555&gender=male", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FHIRBundle
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "searchset", response.Type)
+	assert.Equal(t, 1, response.Total)
+	assert.Len(t, response.Entry, 1)
+	assert.Equal(t, "http://localhost:8080/fhir/Patient/HN100", response.Entry[0].FullURL)
+}
+
+func TestFHIRSearchPatients_Negative_UnsupportedParameter(t *testing.T) {
+	router, _ := setupFHIRTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/fhir/Patient?address=Bangkok", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFHIRMetadata_Positive(t *testing.T) {
+	router, _ := setupFHIRTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/fhir/metadata", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FHIRCapabilityStatement
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "CapabilityStatement", response.ResourceType)
+	assert.Equal(t, models.FHIRVersion, response.FHIRVersion)
+}
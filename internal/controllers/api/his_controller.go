@@ -0,0 +1,139 @@
+package api
+
+import (
+	"agnos-middleware/internal/services"
+	"agnos-middleware/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HISController struct {
+	patientService *services.PatientService
+	syncService    *services.HISSyncService
+	scheduler      *utils.Scheduler
+}
+
+func NewHISController(patientService *services.PatientService, syncService *services.HISSyncService, scheduler *utils.Scheduler) *HISController {
+	return &HISController{
+		patientService: patientService,
+		syncService:    syncService,
+		scheduler:      scheduler,
+	}
+}
+
+// @Summary      HIS circuit breaker status
+// @Description  Show the circuit breaker of every hospital's HIS. While a breaker is open, HIS calls for that hospital fail fast until retry_at, then one trial call decides whether it closes. Requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Circuit breakers"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /his/status [get]
+func (ctrl *HISController) GetStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"hospitals": ctrl.patientService.HISStatus(),
+	})
+}
+
+// @Summary      HIS sync status
+// @Description  Show the background HIS sync job (running, paused, last run and error) and the checkpoint of every HIS with a change feed: changes before since are synced, and a cursor means a run stopped part way and resumes there. Requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Sync job and checkpoints"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      500  {object}  utils.ErrorResponse  "Internal server error"
+// @Router       /his/sync [get]
+func (ctrl *HISController) GetSyncStatus(ctx *gin.Context) {
+	job, err := ctrl.scheduler.JobStatus(services.HISSyncJobName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkpoints, err := ctrl.syncService.Checkpoints()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"job":     job,
+		"sources": checkpoints,
+	})
+}
+
+// @Summary      Trigger a HIS sync
+// @Description  Run the background HIS sync now instead of waiting for HIS_SYNC_INTERVAL. A run already in progress is followed by one more. Requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  map[string]interface{}  "Sync queued"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      409  {object}  utils.ErrorResponse  "Conflict - sync is paused"
+// @Router       /his/sync/trigger [post]
+func (ctrl *HISController) TriggerSync(ctx *gin.Context) {
+	if err := ctrl.scheduler.Trigger(services.HISSyncJobName); err != nil {
+		ctrl.writeSyncError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "sync queued"})
+}
+
+// @Summary      Pause the HIS sync
+// @Description  Stop the background HIS sync from running until it is resumed. A run in progress stops after the batch it is saving; the next run continues from the checkpoint. The pause lasts until resumed or the service restarts. Requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.JobStatus  "Sync job"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /his/sync/pause [post]
+func (ctrl *HISController) PauseSync(ctx *gin.Context) {
+	if err := ctrl.scheduler.Pause(services.HISSyncJobName); err != nil {
+		ctrl.writeSyncError(ctx, err)
+		return
+	}
+	ctrl.writeSyncJob(ctx)
+}
+
+// @Summary      Resume the HIS sync
+// @Description  Let a paused background HIS sync run again from its next interval or trigger. Requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.JobStatus  "Sync job"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /his/sync/resume [post]
+func (ctrl *HISController) ResumeSync(ctx *gin.Context) {
+	if err := ctrl.scheduler.Resume(services.HISSyncJobName); err != nil {
+		ctrl.writeSyncError(ctx, err)
+		return
+	}
+	ctrl.writeSyncJob(ctx)
+}
+
+func (ctrl *HISController) writeSyncJob(ctx *gin.Context) {
+	job, err := ctrl.scheduler.JobStatus(services.HISSyncJobName)
+	if err != nil {
+		ctrl.writeSyncError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+func (ctrl *HISController) writeSyncError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrJobPaused):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
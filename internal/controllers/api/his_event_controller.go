@@ -0,0 +1,117 @@
+package api
+
+import (
+	"agnos-middleware/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HISEventController struct {
+	eventService *services.HISEventService
+	maxBodyBytes int64
+}
+
+func NewHISEventController(eventService *services.HISEventService, maxBodyBytes int64) *HISEventController {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = 1 << 20
+	}
+	return &HISEventController{
+		eventService: eventService,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// @Summary      Receive a HIS event
+// @Description  Endpoint a HIS pushes patient change notifications to. The raw body is signed with the hospital's webhook secret: X-HIS-Signature is sha256= and the hex HMAC-SHA256 of "<X-HIS-Timestamp>.<body>", and X-HIS-Timestamp (Unix seconds) must be within HIS_WEBHOOK_TOLERANCE of now. patient.created and patient.updated events carrying a patient (in the vendor's format, mapped with the hospital's adapter fields) save it; events with only patient_hn refresh the cached patient from HIS. Redelivering an event_id that was applied answers duplicate without applying it again; failed events may be retried. Every authenticated delivery is recorded; deliveries for an unknown hospital or with a bad signature are only logged and counted.
+// @Tags         HIS
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital sending the event"
+// @Param        X-HIS-Timestamp header string true "Unix seconds the event was signed at"
+// @Param        X-HIS-Signature header string true "sha256=<hex HMAC-SHA256 of timestamp.body>"
+// @Param        request body models.HISEvent true "Event"
+// @Success      200  {object}  map[string]interface{}  "Outcome: applied, ignored or duplicate"
+// @Failure      400  {object}  utils.ErrorResponse  "Invalid event or patient"
+// @Failure      401  {object}  utils.ErrorResponse  "Invalid signature or timestamp outside the allowed window"
+// @Failure      404  {object}  utils.ErrorResponse  "Hospital does not push events"
+// @Failure      413  {object}  utils.ErrorResponse  "Event larger than HIS_WEBHOOK_MAX_BODY_BYTES"
+// @Failure      502  {object}  utils.ErrorResponse  "HIS returned an unusable response while refreshing"
+// @Failure      503  {object}  utils.ErrorResponse  "HIS unavailable while refreshing; retry later"
+// @Failure      504  {object}  utils.ErrorResponse  "HIS timed out while refreshing; retry later"
+// @Router       /integrations/his/{hospital}/events [post]
+func (ctrl *HISEventController) ReceiveEvent(ctx *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, ctrl.maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	delivery, err := ctrl.eventService.HandleEvent(
+		ctx.Param("hospital"),
+		ctx.GetHeader("X-HIS-Timestamp"),
+		ctx.GetHeader("X-HIS-Signature"),
+		body,
+		ctx.ClientIP(),
+	)
+	if err != nil {
+		if writeHISError(ctx, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrHISEventUnknownHospital):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrHISEventSignature), errors.Is(err, services.ErrHISEventExpired):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidHISEvent), errors.Is(err, services.ErrInvalidHISPayload):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"event_id":   delivery.EventID,
+		"status":     delivery.Status,
+		"patient_hn": delivery.PatientHN,
+	})
+}
+
+// @Summary      List HIS event deliveries
+// @Description  List calls to the HIS event endpoint, newest first, with their outcome (applied, ignored, duplicate, rejected or failed) and error, for troubleshooting a HIS integration, and how many deliveries were turned away unrecorded for an unknown hospital or a bad signature since the service started. Deliveries hold patient data, so this requires the Admin role.
+// @Tags         HIS
+// @Produce      json
+// @Param        hospital query string false "Only deliveries of this hospital"
+// @Param        status query string false "Only deliveries with this outcome"
+// @Param        limit query int false "Page size (max 200)" default(50)
+// @Param        offset query int false "Offset" default(0)
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Deliveries"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /his/events [get]
+func (ctrl *HISEventController) ListDeliveries(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	deliveries, err := ctrl.eventService.ListDeliveries(ctx.Query("hospital"), ctx.Query("status"), limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"deliveries":      deliveries,
+		"count":           len(deliveries),
+		"unauthenticated": ctrl.eventService.UnauthenticatedDeliveries(),
+	})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"agnos-middleware/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HL7Controller struct {
+	hl7Service *services.HL7Service
+}
+
+func NewHL7Controller(hl7Service *services.HL7Service) *HL7Controller {
+	return &HL7Controller{
+		hl7Service: hl7Service,
+	}
+}
+
+// @Summary      List HL7 dead letters
+// @Description  List inbound HL7 v2 messages that could not be parsed or applied, newest first, with the reason. Dead letters hold raw messages, so this requires the Admin role.
+// @Tags         HL7
+// @Produce      json
+// @Param        limit query int false "Page size (max 200)" default(50)
+// @Param        offset query int false "Offset" default(0)
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Dead letters"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /hl7/dead-letters [get]
+func (ctrl *HL7Controller) ListDeadLetters(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	deadLetters, err := ctrl.hl7Service.ListDeadLetters(limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"dead_letters": deadLetters,
+		"count":        len(deadLetters),
+	})
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MPIController struct {
+	mpiService *services.MPIService
+}
+
+func NewMPIController(mpiService *services.MPIService) *MPIController {
+	return &MPIController{
+		mpiService: mpiService,
+	}
+}
+
+// @Summary      List match candidates
+// @Description  List cross-hospital patient pairs that may be the same person, highest score first. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Param        status query string false "Filter by status (pending, linked, rejected)" default(pending)
+// @Param        limit query int false "Page size (max 200)" default(50)
+// @Param        offset query int false "Offset" default(0)
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Match candidates"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /mpi/candidates [get]
+func (ctrl *MPIController) ListCandidates(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	candidates, err := ctrl.mpiService.ListCandidates(ctx.DefaultQuery("status", models.MatchStatusPending), limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"candidates": candidates,
+		"count":      len(candidates),
+	})
+}
+
+// @Summary      Link a match candidate
+// @Description  Confirm that both registrations are the same person. They will share one enterprise patient ID. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Param        id path int true "Match candidate ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.PatientMatchCandidate  "Candidate linked"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.ErrorResponse  "Match candidate not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Match candidate has already been reviewed"
+// @Router       /mpi/candidates/{id}/link [post]
+func (ctrl *MPIController) LinkCandidate(ctx *gin.Context) {
+	ctrl.reviewCandidate(ctx, ctrl.mpiService.LinkCandidate)
+}
+
+// @Summary      Reject a match candidate
+// @Description  Mark both registrations as different people so they are never linked automatically. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Param        id path int true "Match candidate ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.PatientMatchCandidate  "Candidate rejected"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.ErrorResponse  "Match candidate not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Match candidate has already been reviewed"
+// @Router       /mpi/candidates/{id}/reject [post]
+func (ctrl *MPIController) RejectCandidate(ctx *gin.Context) {
+	ctrl.reviewCandidate(ctx, ctrl.mpiService.RejectCandidate)
+}
+
+func (ctrl *MPIController) reviewCandidate(ctx *gin.Context, review func(int, int) (*models.PatientMatchCandidate, error)) {
+	candidateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid candidate id"})
+		return
+	}
+
+	candidate, err := review(candidateID, ctx.GetInt("staff_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMatchCandidateNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMatchAlreadyReviewed):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, candidate)
+}
+
+// @Summary      Unlink a patient registration
+// @Description  Split a registration off into its own enterprise patient. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Param        id path int true "Patient ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient  "Patient unlinked"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Router       /mpi/patients/{id}/unlink [post]
+func (ctrl *MPIController) UnlinkPatient(ctx *gin.Context) {
+	patientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return
+	}
+
+	patient, err := ctrl.mpiService.UnlinkPatient(patientID, ctx.GetInt("staff_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrPatientNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, patient)
+}
+
+// @Summary      Get an enterprise patient
+// @Description  List every hospital registration linked to an enterprise patient ID. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Param        eid path string true "Enterprise patient ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Linked registrations"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Router       /mpi/enterprise/{eid} [get]
+func (ctrl *MPIController) GetEnterprisePatient(ctx *gin.Context) {
+	enterpriseID := ctx.Param("eid")
+	patients, err := ctrl.mpiService.GetEnterprisePatient(enterpriseID)
+	if err != nil {
+		if errors.Is(err, services.ErrPatientNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"enterprise_id": enterpriseID,
+		"patients":      patients,
+		"count":         len(patients),
+	})
+}
+
+// @Summary      Master patient index statistics
+// @Description  Count registrations, distinct people and pending match candidates across the network. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.MPIStatsResponse  "Statistics"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /mpi/stats [get]
+func (ctrl *MPIController) GetStats(ctx *gin.Context) {
+	stats, err := ctrl.mpiService.GetStats()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
+
+// @Summary      Reindex unindexed patients
+// @Description  Assign enterprise IDs and compute match candidates for every registration that has not been indexed yet. Requires the DataSteward or Admin role.
+// @Tags         MPI
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Number of patients indexed"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /mpi/reindex [post]
+func (ctrl *MPIController) Reindex(ctx *gin.Context) {
+	indexed, err := ctrl.mpiService.Reindex()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "indexed": indexed})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"indexed": indexed})
+}
@@ -3,7 +3,14 @@ package api
 import (
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/services"
+	"agnos-middleware/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,10 +26,11 @@ func NewPatientController(patientService *services.PatientService) *PatientContr
 }
 
 // @Summary      Search for patients
-// @Description  Search for patients by optional criteria. Requires JWT authentication. Staff can only access patients from their own hospital.
+// @Description  Search for patients by optional criteria. Requires JWT authentication. Staff can only access patients from their own hospital. With Accept: application/x-ndjson the matches are streamed one JSON object per line as they are read from the database, followed by a summary line; no matches then returns 200 with a summary count of 0. Each patient has a source: local when read from the database, his when just fetched from HIS. When the id is not found locally and HIS fails, the answer is 502, 503 or 504, or with HIS_FALLBACK_MODE=cache-only a 404 with source stale-cache.
 // @Tags         Patient
 // @Accept       json
 // @Produce      json
+// @Produce      application/x-ndjson
 // @Param        id query string false "Patient ID (must be national_id or passport_id). Examples: Hospital A - 1234567890123, 9876543210987, AB1234567; Hospital B - 1111222233334, 4455667788990" default(1234567890123)
 // @Param        patient_hn query string false "Hospital Number"
 // @Param        national_id query string false "National ID"
@@ -34,12 +42,17 @@ func NewPatientController(patientService *services.PatientService) *PatientContr
 // @Param        phone_number query string false "Phone number"
 // @Param        email query string false "Email"
 // @Param        gender query string false "Gender (M/F)"
+// @Param        fanout query bool false "Look the id up in every configured HIS at once instead (needs id); returns a models.HISFanOutResponse with per-HIS status and timing, 200 even when nothing is found"
+// @Param        q query string false "Advanced filter, e.g. last_name~jai AND (gender=F OR dob>=1990-01-01). Fields: hn, national_id, passport_id, first_name, middle_name, last_name, dob, gender, phone, email. Operators: = != ~ !~ (contains) and < <= > >= for dob; combine with AND, OR, NOT and parentheses. Also applied to a patient found in HIS for id"
 // @Security     BearerAuth
 // @Success      200  {object}  map[string]interface{}  "Patients found"
-// @Failure      400  {object}  utils.PatientSearchErrorResponse  "Bad request - at least one search criteria must be provided"
+// @Failure      400  {object}  utils.PatientSearchErrorResponse  "Bad request - at least one search criteria must be provided or invalid q"
 // @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
 // @Failure      403  {object}  utils.AccessDeniedErrorResponse  "Access denied - patient does not belong to your hospital"
 // @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Failure      502  {object}  utils.ErrorResponse  "HIS returned an invalid response"
+// @Failure      503  {object}  utils.ErrorResponse  "HIS is unavailable"
+// @Failure      504  {object}  utils.ErrorResponse  "HIS did not respond in time"
 // @Router       /patient/search [get]
 func (ctrl *PatientController) SearchPatient(ctx *gin.Context) {
 	var req models.PatientSearchRequest
@@ -50,23 +63,40 @@ func (ctrl *PatientController) SearchPatient(ctx *gin.Context) {
 
 	if req.ID == nil && req.PatientHN == nil && req.NationalID == nil && req.PassportID == nil &&
 		req.FirstName == nil && req.MiddleName == nil && req.LastName == nil &&
-		req.DateOfBirth == nil && req.PhoneNumber == nil && req.Email == nil && req.Gender == nil {
+		req.DateOfBirth == nil && req.PhoneNumber == nil && req.Email == nil && req.Gender == nil && req.Q == nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "at least one search criteria must be provided"})
 		return
 	}
 
+	if fanOut, _ := strconv.ParseBool(ctx.Query("fanout")); fanOut {
+		ctrl.fanOutSearch(ctx, &req)
+		return
+	}
+
 	staffHospital, exists := ctx.Get("staff_hospital")
 	if !exists {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
 		return
 	}
 
+	if acceptsNDJSON(ctx.GetHeader("Accept")) {
+		ctrl.streamSearchPatient(ctx, &req, staffHospital.(string))
+		return
+	}
+
 	patients, err := ctrl.patientService.SearchPatient(&req, staffHospital.(string))
 	if err != nil {
-		if err.Error() == "access denied: patient does not belong to your hospital" {
+		if errors.Is(err, services.ErrAccessDenied) {
 			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, services.ErrInvalidQuery) {
+			writeQueryError(ctx, err)
+			return
+		}
+		if writeHISError(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -81,3 +111,343 @@ func (ctrl *PatientController) SearchPatient(ctx *gin.Context) {
 		"count":    len(patients),
 	})
 }
+
+// fanOutSearch answers a search with fanout=true. Failures of single HIS are
+// reported in the sources, not as an error status.
+func (ctrl *PatientController) fanOutSearch(ctx *gin.Context, req *models.PatientSearchRequest) {
+	if req.ID == nil || *req.ID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "a fanout search needs id"})
+		return
+	}
+
+	value, _ := ctx.Get("staff")
+	staff, ok := value.(*models.Staff)
+	if !ok {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	response, err := ctrl.patientService.FanOutSearch(ctx.Request.Context(), *req.ID, staff)
+	if err != nil {
+		if ctx.Request.Context().Err() != nil {
+			// The client is gone; there is nobody to answer
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// streamSearchPatient writes the search as NDJSON. Headers are sent with the
+// first row, so errors found before any row is read still get a normal JSON
+// error response; later errors end the stream with an incomplete summary.
+func (ctrl *PatientController) streamSearchPatient(ctx *gin.Context, req *models.PatientSearchRequest, staffHospital string) {
+	encoder := json.NewEncoder(ctx.Writer)
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			ctx.Header("Content-Type", "application/x-ndjson")
+			ctx.Header("X-Content-Type-Options", "nosniff")
+			// Stops nginx from buffering the stream, so flushed rows reach the client
+			ctx.Header("X-Accel-Buffering", "no")
+			ctx.Status(http.StatusOK)
+		}
+	}
+
+	count, err := ctrl.patientService.StreamSearchPatient(ctx.Request.Context(), req, staffHospital, func(patient *models.Patient) error {
+		start()
+		return encoder.Encode(patient)
+	}, ctx.Writer.Flush)
+	if err != nil && !started {
+		switch {
+		case errors.Is(err, services.ErrAccessDenied):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidQuery):
+			writeQueryError(ctx, err)
+		case ctx.Request.Context().Err() != nil:
+			// The client is gone; there is nobody to answer
+		case writeHISError(ctx, err):
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if ctx.Request.Context().Err() != nil {
+		return
+	}
+
+	start()
+	summary := models.PatientStreamSummary{Count: count, Complete: err == nil}
+	if err != nil {
+		summary.Error = err.Error()
+		fmt.Printf("[Search] NDJSON stream stopped after %d rows: %v\n", count, err)
+	}
+	if err := encoder.Encode(models.PatientStreamSummaryLine{Summary: summary}); err == nil {
+		ctx.Writer.Flush()
+	}
+}
+
+func acceptsNDJSON(accept string) bool {
+	return strings.Contains(accept, "application/x-ndjson") || strings.Contains(accept, "application/ndjson")
+}
+
+// @Summary      Look up many patients at once
+// @Description  Resolve a list of hospital numbers, national IDs or passport IDs in one call. Local hits are served from the database and misses are fetched from HIS concurrently. Each item reports its own status: found, not_found, forbidden or error. Items that could not be checked in HIS because it is unavailable report error, or not_found with source stale-cache in cache-only fallback mode.
+// @Tags         Patient
+// @Accept       json
+// @Produce      json
+// @Param        request body models.BatchLookupRequest true "IDs to look up (max 500)"
+// @Security     BearerAuth
+// @Success      200  {object}  models.BatchLookupResponse  "Per-item lookup results"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Router       /patient/batch-lookup [post]
+func (ctrl *PatientController) BatchLookup(ctx *gin.Context) {
+	var req models.BatchLookupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := ctrl.patientService.BatchLookup(req.IDs, ctx.GetString("staff_hospital"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.BatchLookupResponse{
+		Results: results,
+		Count:   len(results),
+	})
+}
+
+// @Summary      Get patient by hospital number
+// @Description  Get a single patient by hospital number (HN). Requires JWT authentication. Only patients from the staff member's own hospital are visible. An HN retired by a merge returns the surviving patient with a Content-Location header.
+// @Tags         Patient
+// @Produce      json
+// @Param        hn path string true "Hospital Number" default(HN001)
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient  "Patient found"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Failure      500  {object}  utils.ErrorResponse  "Internal server error"
+// @Router       /patient/{hn} [get]
+func (ctrl *PatientController) GetPatient(ctx *gin.Context) {
+	staffHospital, exists := ctx.Get("staff_hospital")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	patient, err := ctrl.patientService.GetPatientByHN(ctx.Param("hn"), staffHospital.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrPatientNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if patient.PatientHN != ctx.Param("hn") {
+		// The requested HN was retired by a merge
+		ctx.Header("Content-Location", "/patient/"+patient.PatientHN)
+	}
+	ctrl.patientService.RecordPatientView(patient, ctx.GetInt("staff_id"))
+
+	ctx.Header("ETag", patientETag(patient))
+	ctx.JSON(http.StatusOK, patient)
+}
+
+// @Summary      Refresh a patient from HIS
+// @Description  Fetch the patient from HIS now and replace the cached copy, however fresh it is. HIS failures are returned as errors; no fallback applies.
+// @Tags         Patient
+// @Produce      json
+// @Param        hn path string true "Hospital Number"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient  "Patient refreshed"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found, or not known to HIS"
+// @Failure      500  {object}  utils.ErrorResponse  "Internal server error"
+// @Failure      502  {object}  utils.ErrorResponse  "HIS returned an invalid response"
+// @Failure      503  {object}  utils.ErrorResponse  "HIS is unavailable"
+// @Failure      504  {object}  utils.ErrorResponse  "HIS timed out"
+// @Router       /patient/{hn}/refresh [post]
+func (ctrl *PatientController) RefreshPatient(ctx *gin.Context) {
+	staffHospital, exists := ctx.Get("staff_hospital")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	patient, err := ctrl.patientService.RefreshPatient(ctx.Param("hn"), staffHospital.(string))
+	if err != nil {
+		if writeHISError(ctx, err) {
+			return
+		}
+		if errors.Is(err, services.ErrPatientNotFound) || errors.Is(err, services.ErrNoHISAdapter) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("ETag", patientETag(patient))
+	ctx.JSON(http.StatusOK, patient)
+}
+
+// @Summary      Register a patient
+// @Description  Register a patient locally in the staff member's hospital. A hospital number is allocated automatically. Either national_id or passport_id and a first and last name (Thai or English) are required.
+// @Tags         Patient
+// @Accept       json
+// @Produce      json
+// @Param        request body models.CreatePatientRequest true "Patient registration request"
+// @Security     BearerAuth
+// @Success      201  {object}  models.Patient  "Patient registered"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      409  {object}  utils.ErrorResponse  "Conflict - patient already registered"
+// @Failure      500  {object}  utils.ErrorResponse  "Internal server error"
+// @Router       /patient [post]
+func (ctrl *PatientController) CreatePatient(ctx *gin.Context) {
+	var req models.CreatePatientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staffHospital, exists := ctx.Get("staff_hospital")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	patient, err := ctrl.patientService.CreatePatient(&req, staffHospital.(string), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writePatientError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", "/patient/"+patient.PatientHN)
+	ctx.Header("ETag", patientETag(patient))
+	ctx.JSON(http.StatusCreated, patient)
+}
+
+// @Summary      Update a patient
+// @Description  Partially update a patient in the staff member's hospital. Send the ETag from GET /patient/{hn} in the If-Match header (or the patient's updated_at in the body). A stale version is rejected with 412. Patients cached from HIS can only be updated when write back to that HIS is on; otherwise correct them in HIS.
+// @Tags         Patient
+// @Accept       json
+// @Produce      json
+// @Param        hn path string true "Hospital Number"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        request body models.UpdatePatientRequest true "Fields to update"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient  "Patient updated"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Conflict - national_id or passport_id already registered, or patient managed by HIS without write back"
+// @Failure      412  {object}  utils.ErrorResponse  "Precondition failed - patient has been modified"
+// @Failure      428  {object}  utils.ErrorResponse  "Precondition required - If-Match header or updated_at missing"
+// @Router       /patient/{hn} [patch]
+func (ctrl *PatientController) UpdatePatient(ctx *gin.Context) {
+	var req models.UpdatePatientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var expectedUpdatedAt *time.Time
+	if ifMatch := ctx.GetHeader("If-Match"); ifMatch != "" {
+		updatedAt, err := parsePatientETag(ifMatch)
+		if err != nil {
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
+		expectedUpdatedAt = &updatedAt
+	}
+
+	staffHospital, exists := ctx.Get("staff_hospital")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	patient, err := ctrl.patientService.UpdatePatient(ctx.Param("hn"), &req, expectedUpdatedAt, staffHospital.(string), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writePatientError(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", patientETag(patient))
+	ctx.JSON(http.StatusOK, patient)
+}
+
+func (ctrl *PatientController) writePatientError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPatient):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientExists), errors.Is(err, services.ErrPatientHISManaged):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientModified):
+		ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPreconditionRequired):
+		ctx.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// patientETag is derived from updated_at, which changes on every write.
+func patientETag(patient *models.Patient) string {
+	return fmt.Sprintf("\"%d\"", patient.UpdatedAt.UnixMicro())
+}
+
+func parsePatientETag(value string) (time.Time, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	micros, err := strconv.ParseInt(strings.Trim(value, "\""), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid If-Match header")
+	}
+	return time.UnixMicro(micros).UTC(), nil
+}
+
+// writeQueryError answers an invalid q filter with 400 and the position of the
+// problem (1-based) so clients can point at it.
+func writeQueryError(ctx *gin.Context, err error) {
+	response := gin.H{"error": err.Error()}
+
+	var filterErr *utils.FilterError
+	if errors.As(err, &filterErr) {
+		response["position"] = filterErr.Pos + 1
+	}
+
+	ctx.JSON(http.StatusBadRequest, response)
+}
+
+// writeHISError answers HIS failures so clients can tell them from a patient
+// that does not exist: 503 when HIS is down, 504 when it timed out and 502 when
+// it answered with something unusable. In cache-only fallback mode a patient
+// missing from the cache is a 404 with source stale-cache. It returns false,
+// writing nothing, for other errors.
+func writeHISError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrNotFoundInCache):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "source": models.PatientSourceStaleCache})
+	case errors.Is(err, services.ErrHISTimeout):
+		ctx.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHISUnavailable):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHISBadResponse):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
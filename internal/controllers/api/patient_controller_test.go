@@ -0,0 +1,398 @@
+package api
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"agnos-middleware/internal/services"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupPatientTestRouter(t *testing.T, staffHospital string) (*gin.Engine, *repositories.PatientRepository) {
+	config := &configs.ApplicationConfig{}
+	config.Patient.HNPrefix = "HN"
+	return setupPatientTestRouterWithConfig(t, staffHospital, config)
+}
+
+func setupPatientTestRouterWithConfig(t *testing.T, staffHospital string, config *configs.ApplicationConfig) (*gin.Engine, *repositories.PatientRepository) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientHistory{})
+
+	repo := repositories.NewPatientRepository(db)
+	patientService := services.NewPatientService(repo, config)
+	patientController := NewPatientController(patientService)
+
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("staff", &models.Staff{Hospital: staffHospital, Role: "Doctor"})
+		ctx.Set("staff_hospital", staffHospital)
+		ctx.Next()
+	})
+	router.GET("/patient/search", patientController.SearchPatient)
+	router.POST("/patient", patientController.CreatePatient)
+	router.GET("/patient/:hn", patientController.GetPatient)
+	router.PATCH("/patient/:hn", patientController.UpdatePatient)
+	router.POST("/patient/:hn/refresh", patientController.RefreshPatient)
+
+	return router, repo
+}
+
+func TestGetPatient_Positive(t *testing.T) {
+	router, repo := setupPatientTestRouter(t, "Hospital A")
+
+	firstName := "Somchai"
+	repo.UpsertPatient(&models.Patient{
+		PatientHN:   "HN001",
+		Hospital:    "Hospital A",
+		FirstNameEN: &firstName,
+		Gender:      "M",
+		DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC),
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	req, _ := http.NewRequest("GET", "/patient/HN001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Patient
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "HN001", response.PatientHN)
+	assert.Equal(t, "Hospital A", response.Hospital)
+	assert.Equal(t, "Somchai", *response.FirstNameEN)
+}
+
+func TestGetPatient_Negative_NotFound(t *testing.T) {
+	router, repo := setupPatientTestRouter(t, "Hospital A")
+
+	repo.UpsertPatient(&models.Patient{
+		PatientHN:   "HN004",
+		Hospital:    "Hospital B",
+		Gender:      "M",
+		DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC),
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	req, _ := http.NewRequest("GET", "/patient/HN004", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "patient not found", response["error"])
+}
+
+func TestCreateAndUpdatePatient_Positive(t *testing.T) {
+	router, _ := setupPatientTestRouter(t, "Hospital A")
+
+	nationalID := "1234567890123"
+	firstName := "Somchai"
+	lastName := "Jaidee"
+	payload := models.CreatePatientRequest{
+		NationalID:  &nationalID,
+		FirstNameEN: &firstName,
+		LastNameEN:  &lastName,
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}
+	jsonValue, _ := json.Marshal(payload)
+
+	createReq, _ := http.NewRequest("POST", "/patient", bytes.NewBuffer(jsonValue))
+	createReq.Header.Set("Content-Type", "application/json")
+	createW := httptest.NewRecorder()
+	router.ServeHTTP(createW, createReq)
+
+	assert.Equal(t, http.StatusCreated, createW.Code)
+	assert.Equal(t, "/patient/HN000001", createW.Header().Get("Location"))
+	etag := createW.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	updateReq, _ := http.NewRequest("PATCH", "/patient/HN000001", bytes.NewBufferString(`{"phone_number":"0891234567"}`))
+	updateReq.Header.Set("Content-Type", "application/json")
+	updateReq.Header.Set("If-Match", etag)
+	updateW := httptest.NewRecorder()
+	router.ServeHTTP(updateW, updateReq)
+
+	assert.Equal(t, http.StatusOK, updateW.Code)
+
+	var response models.Patient
+	json.Unmarshal(updateW.Body.Bytes(), &response)
+	assert.Equal(t, "0891234567", *response.PhoneNumber)
+	assert.NotEqual(t, etag, updateW.Header().Get("ETag"))
+
+	staleReq, _ := http.NewRequest("PATCH", "/patient/HN000001", bytes.NewBufferString(`{"phone_number":"0800000000"}`))
+	staleReq.Header.Set("Content-Type", "application/json")
+	staleReq.Header.Set("If-Match", etag)
+	staleW := httptest.NewRecorder()
+	router.ServeHTTP(staleW, staleReq)
+
+	assert.Equal(t, http.StatusPreconditionFailed, staleW.Code)
+}
+
+func TestCreatePatient_Negative_Validation(t *testing.T) {
+	router, _ := setupPatientTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("POST", "/patient", bytes.NewBufferString(`{"first_name_en":"Somchai","date_of_birth":"15/03/1985","gender":"M"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchPatient_Negative_InvalidQuery(t *testing.T) {
+	router, _ := setupPatientTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/patient/search?q="+url.QueryEscape("gender=F OR"), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(12), response["position"])
+	assert.Contains(t, response["error"], "expected a field name")
+}
+
+func TestSearchPatient_Positive_NDJSONStream(t *testing.T) {
+	router, repo := setupPatientTestRouter(t, "Hospital A")
+
+	for _, patient := range []*models.Patient{
+		{PatientHN: "HN001", Hospital: "Hospital A", Gender: "M", DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN002", Hospital: "Hospital A", Gender: "M", DateOfBirth: time.Date(1990, 7, 20, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN003", Hospital: "Hospital B", Gender: "M", DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS})
+	}
+
+	req, _ := http.NewRequest("GET", "/patient/search?gender=M", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 3)
+
+	var first models.Patient
+	json.Unmarshal(lines[0], &first)
+	assert.Equal(t, "HN001", first.PatientHN)
+
+	var summary models.PatientStreamSummaryLine
+	json.Unmarshal(lines[2], &summary)
+	assert.Equal(t, 2, summary.Summary.Count)
+	assert.True(t, summary.Summary.Complete)
+}
+
+func TestSearchPatient_Negative_NDJSONInvalidQuery(t *testing.T) {
+	router, _ := setupPatientTestRouter(t, "Hospital A")
+
+	req, _ := http.NewRequest("GET", "/patient/search?q="+url.QueryEscape("gender=="), nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestSearchPatient_Negative_HISFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		fallbackMode string
+		wantStatus   int
+		wantSource   string
+	}{
+		{
+			name:       "unavailable",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "bad response",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) },
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:         "cache-only",
+			handler:      func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			fallbackMode: configs.HISFallbackCacheOnly,
+			wantStatus:   http.StatusNotFound,
+			wantSource:   models.PatientSourceStaleCache,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			his := httptest.NewServer(tt.handler)
+			defer his.Close()
+
+			config := &configs.ApplicationConfig{}
+			config.Patient.HNPrefix = "HN"
+			config.HISAPI.BaseURL = his.URL
+			config.HISAPI.Timeout = 50 * time.Millisecond
+			config.HISAPI.FallbackMode = tt.fallbackMode
+			router, _ := setupPatientTestRouterWithConfig(t, "Hospital A", config)
+
+			req, _ := http.NewRequest("GET", "/patient/search?id=1234567890123", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			assert.NotEmpty(t, body["error"])
+			if tt.wantSource != "" {
+				assert.Equal(t, tt.wantSource, body["source"])
+			}
+		})
+	}
+}
+
+func TestSearchPatient_Positive_Source(t *testing.T) {
+	his := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A","national_id":"1234567890123","gender":"M"}`))
+	}))
+	defer his.Close()
+
+	config := &configs.ApplicationConfig{}
+	config.Patient.HNPrefix = "HN"
+	config.HISAPI.BaseURL = his.URL
+	router, _ := setupPatientTestRouterWithConfig(t, "Hospital A", config)
+
+	for _, wantSource := range []string{models.PatientSourceHIS, models.PatientSourceLocal} {
+		req, _ := http.NewRequest("GET", "/patient/search?id=1234567890123", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Patients []models.Patient `json:"patients"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if assert.Len(t, response.Patients, 1) {
+			assert.Equal(t, wantSource, response.Patients[0].Source)
+		}
+	}
+}
+
+func TestRefreshPatient_Positive(t *testing.T) {
+	his := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A","national_id":"1234567890123","first_name_en":"Somchai","gender":"M"}`))
+	}))
+	defer his.Close()
+
+	config := &configs.ApplicationConfig{}
+	config.Patient.HNPrefix = "HN"
+	config.HISAPI.BaseURL = his.URL
+	router, repo := setupPatientTestRouterWithConfig(t, "Hospital A", config)
+
+	firstName := "Old"
+	repo.UpsertPatient(&models.Patient{
+		PatientHN:   "HN001",
+		Hospital:    "Hospital A",
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: &firstName,
+		Gender:      "M",
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	req, _ := http.NewRequest("POST", "/patient/HN001/refresh", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	var response models.Patient
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Somchai", *response.FirstNameEN)
+	assert.Equal(t, models.PatientSourceHIS, response.Source)
+}
+
+func TestRefreshPatient_Negative(t *testing.T) {
+	his := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer his.Close()
+
+	config := &configs.ApplicationConfig{}
+	config.Patient.HNPrefix = "HN"
+	config.HISAPI.BaseURL = his.URL
+	router, repo := setupPatientTestRouterWithConfig(t, "Hospital A", config)
+
+	repo.UpsertPatient(&models.Patient{
+		PatientHN: "HN001",
+		Hospital:  "Hospital A",
+		Gender:    "M",
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	for hn, wantStatus := range map[string]int{"HN001": http.StatusServiceUnavailable, "HN999": http.StatusNotFound} {
+		req, _ := http.NewRequest("POST", "/patient/"+hn+"/refresh", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, wantStatus, w.Code, hn)
+	}
+}
+
+func TestSearchPatient_Positive_FanOut(t *testing.T) {
+	his := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A","national_id":"1234567890123","gender":"M"}`))
+	}))
+	defer his.Close()
+
+	config := &configs.ApplicationConfig{}
+	config.Patient.HNPrefix = "HN"
+	config.HISAPI.BaseURL = his.URL
+	router, _ := setupPatientTestRouterWithConfig(t, "Hospital A", config)
+
+	req, _ := http.NewRequest("GET", "/patient/search?id=1234567890123&fanout=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.HISFanOutResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 1, response.Count)
+	if assert.Len(t, response.Sources, 1) {
+		assert.Equal(t, "Hospital A", response.Sources[0].Hospital)
+		assert.Equal(t, models.HISSourceFound, response.Sources[0].Status)
+	}
+
+	req, _ = http.NewRequest("GET", "/patient/search?first_name=Somchai&fanout=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientExportController struct {
+	exportService *services.PatientExportService
+}
+
+func NewPatientExportController(exportService *services.PatientExportService) *PatientExportController {
+	return &PatientExportController{
+		exportService: exportService,
+	}
+}
+
+// @Summary      Export patients
+// @Description  Download every patient in the staff member's hospital that matches the search criteria as CSV (UTF-8 with BOM), NDJSON or a FHIR searchset Bundle. The format comes from the format parameter or, when it is missing, the Accept header. Results are streamed and each export is recorded in the audit log with its row count.
+// @Tags         Patient
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/fhir+json
+// @Param        format query string false "csv, ndjson or fhir" default(csv)
+// @Param        patient_hn query string false "Hospital Number"
+// @Param        national_id query string false "National ID"
+// @Param        passport_id query string false "Passport ID"
+// @Param        first_name query string false "First name (partial match)"
+// @Param        middle_name query string false "Middle name (partial match)"
+// @Param        last_name query string false "Last name (partial match)"
+// @Param        date_of_birth query string false "Date of birth (YYYY-MM-DD)"
+// @Param        phone_number query string false "Phone number"
+// @Param        email query string false "Email"
+// @Param        gender query string false "Gender (M/F)"
+// @Param        q query string false "Advanced filter, same grammar as /patient/search"
+// @Security     BearerAuth
+// @Success      200  {file}    file  "Exported patients"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - unsupported format or invalid q"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Router       /patient/export [get]
+func (ctrl *PatientExportController) ExportPatients(ctx *gin.Context) {
+	var req models.PatientSearchRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidateSearchRequest(&req); err != nil {
+		writeQueryError(ctx, err)
+		return
+	}
+
+	format := negotiateExportFormat(ctx.Query("format"), ctx.GetHeader("Accept"))
+	contentType := services.ExportContentType(format)
+	if contentType == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": services.ErrUnsupportedExportFormat.Error()})
+		return
+	}
+
+	extension := format
+	if format == services.ExportFormatFHIR {
+		extension = "json"
+	}
+	filename := fmt.Sprintf("patients-%s.%s", time.Now().Format("20060102-150405"), extension)
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure part way can only be logged; the
+	// export service records it in the audit log
+	if _, err := ctrl.exportService.ExportPatients(ctx.Request.Context(), &req, format, ctx.Writer, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id")); err != nil {
+		fmt.Printf("[Export] Export by staff %d failed: %v\n", ctx.GetInt("staff_id"), err)
+	}
+}
+
+func negotiateExportFormat(format string, accept string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch {
+	case acceptsNDJSON(accept):
+		return services.ExportFormatNDJSON
+	case strings.Contains(accept, models.FHIRContentType):
+		return services.ExportFormatFHIR
+	default:
+		return services.ExportFormatCSV
+	}
+}
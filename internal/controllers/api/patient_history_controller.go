@@ -0,0 +1,52 @@
+package api
+
+import (
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientHistoryController struct {
+	historyService *services.PatientHistoryService
+}
+
+func NewPatientHistoryController(historyService *services.PatientHistoryService) *PatientHistoryController {
+	return &PatientHistoryController{
+		historyService: historyService,
+	}
+}
+
+// @Summary      Get patient version history
+// @Description  List every recorded change to a patient, newest first, with its source (his, local, merge, unmerge), the staff member who made it and a field-level diff. Only patients from the staff member's own hospital are visible.
+// @Tags         Patient
+// @Produce      json
+// @Param        hn path string true "Hospital Number" default(HN001)
+// @Param        limit query int false "Page size (max 200)" default(50)
+// @Param        offset query int false "Offset" default(0)
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Patient history"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Router       /patient/{hn}/history [get]
+func (ctrl *PatientHistoryController) GetPatientHistory(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	history, err := ctrl.historyService.GetPatientHistory(ctx.Param("hn"), ctx.GetString("staff_hospital"), limit, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrPatientNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"history": history,
+		"count":   len(history),
+	})
+}
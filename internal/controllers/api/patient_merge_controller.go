@@ -0,0 +1,112 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientMergeController struct {
+	mergeService *services.PatientMergeService
+}
+
+func NewPatientMergeController(mergeService *services.PatientMergeService) *PatientMergeController {
+	return &PatientMergeController{
+		mergeService: mergeService,
+	}
+}
+
+// @Summary      Merge duplicate patients
+// @Description  Merge two HNs of the same person in the staff member's hospital. Lookups by the retired HN resolve to the survivor afterwards. Requires the DataSteward or Admin role.
+// @Tags         Patient Merge
+// @Accept       json
+// @Produce      json
+// @Param        request body models.MergePatientRequest true "Merge request"
+// @Security     BearerAuth
+// @Success      201  {object}  models.PatientMerge  "Patients merged"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Conflict - patient has already been merged"
+// @Router       /patient/merge [post]
+func (ctrl *PatientMergeController) MergePatients(ctx *gin.Context) {
+	var req models.MergePatientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merge, err := ctrl.mergeService.MergePatients(&req, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeMergeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, merge)
+}
+
+// @Summary      List patient merges
+// @Description  List merges in the staff member's hospital, newest first, including reversed ones. Requires the DataSteward or Admin role.
+// @Tags         Patient Merge
+// @Produce      json
+// @Param        hn query string false "Only merges involving this HN"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Merge history"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /patient/merges [get]
+func (ctrl *PatientMergeController) ListMerges(ctx *gin.Context) {
+	merges, err := ctrl.mergeService.ListMerges(ctx.GetString("staff_hospital"), ctx.Query("hn"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"merges": merges,
+		"count":  len(merges),
+	})
+}
+
+// @Summary      Reverse a patient merge
+// @Description  Restore the retired HN as a patient of its own. Requires the DataSteward or Admin role.
+// @Tags         Patient Merge
+// @Produce      json
+// @Param        id path int true "Merge ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.PatientMerge  "Merge reversed"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.ErrorResponse  "Merge not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Merge has already been reversed"
+// @Router       /patient/merges/{id}/unmerge [post]
+func (ctrl *PatientMergeController) UnmergePatients(ctx *gin.Context) {
+	mergeID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid merge id"})
+		return
+	}
+
+	merge, err := ctrl.mergeService.UnmergePatients(mergeID, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeMergeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, merge)
+}
+
+func (ctrl *PatientMergeController) writeMergeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMergeSamePatient):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientNotFound), errors.Is(err, services.ErrMergeNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientAlreadyMerged), errors.Is(err, services.ErrMergeAlreadyReversed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientRetentionController struct {
+	retentionService *services.PatientRetentionService
+}
+
+func NewPatientRetentionController(retentionService *services.PatientRetentionService) *PatientRetentionController {
+	return &PatientRetentionController{
+		retentionService: retentionService,
+	}
+}
+
+// @Summary      Delete a patient
+// @Description  Soft delete a patient in the staff member's hospital. The patient disappears from all lookups and is purged permanently after the soft delete retention period. Requires the DataSteward or Admin role.
+// @Tags         Patient Retention
+// @Produce      json
+// @Param        hn path string true "Hospital Number"
+// @Security     BearerAuth
+// @Success      204  "Patient deleted"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Router       /patient/{hn} [delete]
+func (ctrl *PatientRetentionController) DeletePatient(ctx *gin.Context) {
+	err := ctrl.retentionService.SoftDeletePatient(ctx.Param("hn"), ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeRetentionError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary      Request PDPA erasure
+// @Description  File a request to erase a patient's personal data. Another staff member with the DataSteward or Admin role must approve it; the erasure job then scrubs the data and keeps a tombstone.
+// @Tags         Patient Retention
+// @Accept       json
+// @Produce      json
+// @Param        hn path string true "Hospital Number"
+// @Param        request body models.CreateErasureRequest true "Erasure request"
+// @Security     BearerAuth
+// @Success      201  {object}  models.PatientErasureRequest  "Erasure requested"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      404  {object}  utils.NotFoundErrorResponse  "Patient not found"
+// @Failure      409  {object}  utils.ErrorResponse  "An erasure request for this patient is already open"
+// @Router       /patient/{hn}/erasure [post]
+func (ctrl *PatientRetentionController) RequestErasure(ctx *gin.Context) {
+	var req models.CreateErasureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := ctrl.retentionService.RequestErasure(ctx.Param("hn"), &req, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeRetentionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, request)
+}
+
+// @Summary      List erasure requests
+// @Description  List PDPA erasure requests in the staff member's hospital. Requires the DataSteward or Admin role.
+// @Tags         Patient Retention
+// @Produce      json
+// @Param        status query string false "Filter by status (pending, approved, rejected, erased, failed)"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Erasure requests"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Router       /erasure-requests [get]
+func (ctrl *PatientRetentionController) ListErasureRequests(ctx *gin.Context) {
+	requests, err := ctrl.retentionService.ListErasureRequests(ctx.Query("status"), ctx.GetString("staff_hospital"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"requests": requests,
+		"count":    len(requests),
+	})
+}
+
+// @Summary      Approve an erasure request
+// @Description  Approve a pending erasure request filed by another staff member. Requires the DataSteward or Admin role.
+// @Tags         Patient Retention
+// @Produce      json
+// @Param        id path int true "Erasure request ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.PatientErasureRequest  "Erasure approved"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role or own request"
+// @Failure      404  {object}  utils.ErrorResponse  "Erasure request not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Erasure request is not pending"
+// @Router       /erasure-requests/{id}/approve [post]
+func (ctrl *PatientRetentionController) ApproveErasure(ctx *gin.Context) {
+	ctrl.reviewErasure(ctx, ctrl.retentionService.ApproveErasure)
+}
+
+// @Summary      Reject an erasure request
+// @Description  Reject a pending erasure request. Requires the DataSteward or Admin role.
+// @Tags         Patient Retention
+// @Produce      json
+// @Param        id path int true "Erasure request ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.PatientErasureRequest  "Erasure rejected"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.ErrorResponse  "Erasure request not found"
+// @Failure      409  {object}  utils.ErrorResponse  "Erasure request is not pending"
+// @Router       /erasure-requests/{id}/reject [post]
+func (ctrl *PatientRetentionController) RejectErasure(ctx *gin.Context) {
+	ctrl.reviewErasure(ctx, ctrl.retentionService.RejectErasure)
+}
+
+func (ctrl *PatientRetentionController) reviewErasure(ctx *gin.Context, review func(int, string, int) (*models.PatientErasureRequest, error)) {
+	requestID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid erasure request id"})
+		return
+	}
+
+	request, err := review(requestID, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeRetentionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
+
+func (ctrl *PatientRetentionController) writeRetentionError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound), errors.Is(err, services.ErrErasureRequestNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrErasureRequestOpen), errors.Is(err, services.ErrErasureNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrErasureSelfApproval):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
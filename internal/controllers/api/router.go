@@ -1,7 +1,9 @@
 package api
 
 import (
+	"agnos-middleware/internal/controllers/gql"
 	"agnos-middleware/internal/middlewares"
+	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,17 @@ import (
 func SetupRouter(
 	staffController *StaffController,
 	patientController *PatientController,
+	mpiController *MPIController,
+	patientMergeController *PatientMergeController,
+	patientHistoryController *PatientHistoryController,
+	patientRetentionController *PatientRetentionController,
+	fhirController *FHIRController,
+	hl7Controller *HL7Controller,
+	patientExportController *PatientExportController,
+	staffWorkspaceController *StaffWorkspaceController,
+	hisController *HISController,
+	hisEventController *HISEventController,
+	graphqlHandler *gql.Handler,
 	authService *services.AuthService,
 ) *gin.Engine {
 	router := gin.Default()
@@ -34,6 +47,82 @@ func SetupRouter(
 	protected.Use(middlewares.AuthMiddleware(authService))
 	{
 		protected.GET("/patient/search", patientController.SearchPatient)
+		protected.GET("/patient/export", patientExportController.ExportPatients)
+		protected.POST("/patient", patientController.CreatePatient)
+		protected.POST("/patient/batch-lookup", patientController.BatchLookup)
+		protected.GET("/patient/:hn", patientController.GetPatient)
+		protected.PATCH("/patient/:hn", patientController.UpdatePatient)
+		protected.POST("/patient/:hn/refresh", patientController.RefreshPatient)
+		protected.GET("/patient/:hn/history", patientHistoryController.GetPatientHistory)
+		protected.POST("/patient/:hn/erasure", patientRetentionController.RequestErasure)
+
+		protected.GET("/staff/me/saved-searches", staffWorkspaceController.ListSavedSearches)
+		protected.POST("/staff/me/saved-searches", staffWorkspaceController.SaveSearch)
+		protected.DELETE("/staff/me/saved-searches/:id", staffWorkspaceController.DeleteSavedSearch)
+		protected.GET("/staff/me/saved-searches/:id/run", staffWorkspaceController.RunSavedSearch)
+		protected.GET("/staff/me/recent-patients", staffWorkspaceController.ListRecentPatients)
+
+		protected.GET("/graphql", graphqlHandler.Serve)
+		protected.POST("/graphql", graphqlHandler.Serve)
+	}
+
+	admins := protected.Group("/")
+	admins.Use(middlewares.RequireRole(models.RoleAdmin))
+	{
+		admins.PUT("/staff/:id/role", staffController.UpdateStaffRole)
+	}
+
+	stewards := protected.Group("/")
+	stewards.Use(middlewares.RequireRole(models.RoleDataSteward, models.RoleAdmin))
+	{
+		stewards.POST("/patient/merge", patientMergeController.MergePatients)
+		stewards.GET("/patient/merges", patientMergeController.ListMerges)
+		stewards.POST("/patient/merges/:id/unmerge", patientMergeController.UnmergePatients)
+		stewards.DELETE("/patient/:hn", patientRetentionController.DeletePatient)
+		stewards.GET("/erasure-requests", patientRetentionController.ListErasureRequests)
+		stewards.POST("/erasure-requests/:id/approve", patientRetentionController.ApproveErasure)
+		stewards.POST("/erasure-requests/:id/reject", patientRetentionController.RejectErasure)
+	}
+
+	router.GET("/fhir/metadata", fhirController.Metadata)
+
+	fhir := router.Group("/fhir")
+	fhir.Use(middlewares.AuthMiddleware(authService))
+	{
+		fhir.GET("/Patient", fhirController.SearchPatients)
+		fhir.GET("/Patient/:id", fhirController.ReadPatient)
+	}
+
+	hl7 := router.Group("/hl7")
+	hl7.Use(middlewares.AuthMiddleware(authService), middlewares.RequireRole(models.RoleAdmin))
+	{
+		hl7.GET("/dead-letters", hl7Controller.ListDeadLetters)
+	}
+
+	his := router.Group("/his")
+	his.Use(middlewares.AuthMiddleware(authService), middlewares.RequireRole(models.RoleAdmin))
+	{
+		his.GET("/status", hisController.GetStatus)
+		his.GET("/sync", hisController.GetSyncStatus)
+		his.POST("/sync/trigger", hisController.TriggerSync)
+		his.POST("/sync/pause", hisController.PauseSync)
+		his.POST("/sync/resume", hisController.ResumeSync)
+		his.GET("/events", hisEventController.ListDeliveries)
+	}
+
+	// Authenticated by the HMAC signature of each event rather than a JWT
+	router.POST("/integrations/his/:hospital/events", hisEventController.ReceiveEvent)
+
+	mpi := router.Group("/mpi")
+	mpi.Use(middlewares.AuthMiddleware(authService), middlewares.RequireRole(models.RoleDataSteward, models.RoleAdmin))
+	{
+		mpi.GET("/candidates", mpiController.ListCandidates)
+		mpi.POST("/candidates/:id/link", mpiController.LinkCandidate)
+		mpi.POST("/candidates/:id/reject", mpiController.RejectCandidate)
+		mpi.POST("/patients/:id/unlink", mpiController.UnlinkPatient)
+		mpi.GET("/enterprise/:eid", mpiController.GetEnterprisePatient)
+		mpi.GET("/stats", mpiController.GetStats)
+		mpi.POST("/reindex", mpiController.Reindex)
 	}
 
 	return router
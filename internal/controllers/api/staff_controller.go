@@ -3,7 +3,9 @@ package api
 import (
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/services"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +21,7 @@ func NewStaffController(authService *services.AuthService) *StaffController {
 }
 
 // @Summary      Create a new staff member
-// @Description  Create a new hospital staff account with employee details. All fields will be pre-filled with example values in Swagger UI.
+// @Description  Create a new hospital staff account with employee details. The role is one of Doctor, Nurse or Staff; the Admin and DataSteward roles are granted by an Admin. All fields will be pre-filled with example values in Swagger UI.
 // @Tags         Staff
 // @Accept       json
 // @Produce      json
@@ -59,6 +61,50 @@ func (ctrl *StaffController) CreateStaff(ctx *gin.Context) {
 	})
 }
 
+// @Summary      Change a staff member's role
+// @Description  Give a staff member of the caller's hospital a new role, including the privileged Admin and DataSteward roles. Requires the Admin role.
+// @Tags         Staff
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Staff ID"
+// @Param        request body models.UpdateStaffRoleRequest true "New role"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Role changed"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      403  {object}  utils.ErrorResponse  "Access denied - insufficient role"
+// @Failure      404  {object}  utils.ErrorResponse  "Staff not found"
+// @Router       /staff/{id}/role [put]
+func (ctrl *StaffController) UpdateStaffRole(ctx *gin.Context) {
+	staffID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff id"})
+		return
+	}
+
+	var req models.UpdateStaffRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staff, err := ctrl.authService.UpdateStaffRole(staffID, req.Role, ctx.GetString("staff_hospital"))
+	if err != nil {
+		if errors.Is(err, services.ErrStaffNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update staff role"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"id":       staff.ID,
+		"username": staff.Username,
+		"role":     staff.Role,
+		"hospital": staff.Hospital,
+	})
+}
+
 // @Summary      Staff login
 // @Description  Authenticate staff member and receive JWT token
 // @Tags         Staff
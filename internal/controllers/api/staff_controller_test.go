@@ -174,3 +174,40 @@ func TestLogin_Negative_InvalidCredentials(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, loginW.Code)
 }
+
+func TestCreateStaff_Negative_PrivilegedRole(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	for _, role := range []string{models.RoleAdmin, "admin", models.RoleDataSteward} {
+		payload := models.CreateStaffRequest{
+			EmployeeID: "EMP001",
+			Username:   "testuser",
+			Password:   "password123",
+			FirstName:  "John",
+			LastName:   "Doe",
+			Email:      "john.doe@hospital.com",
+			Role:       role,
+			Hospital:   "Hospital A",
+		}
+		jsonValue, _ := json.Marshal(payload)
+
+		req, _ := http.NewRequest("POST", "/staff/create", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, role)
+	}
+
+	loginPayload := models.LoginRequest{
+		Username: "testuser",
+		Password: "password123",
+	}
+	loginJson, _ := json.Marshal(loginPayload)
+	loginReq, _ := http.NewRequest("POST", "/staff/login", bytes.NewBuffer(loginJson))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginW := httptest.NewRecorder()
+	router.ServeHTTP(loginW, loginReq)
+
+	assert.Equal(t, http.StatusUnauthorized, loginW.Code)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StaffWorkspaceController struct {
+	workspaceService *services.StaffWorkspaceService
+}
+
+func NewStaffWorkspaceController(workspaceService *services.StaffWorkspaceService) *StaffWorkspaceController {
+	return &StaffWorkspaceController{
+		workspaceService: workspaceService,
+	}
+}
+
+// @Summary      Save a patient search
+// @Description  Save named patient search criteria for the current staff member. Criteria use the same fields as GET /patient/search, including q.
+// @Tags         Staff Workspace
+// @Accept       json
+// @Produce      json
+// @Param        request body models.SaveSearchRequest true "Saved search"
+// @Security     BearerAuth
+// @Success      201  {object}  models.SavedSearch  "Search saved"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - validation error"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      409  {object}  utils.ErrorResponse  "Conflict - name already used or saved search limit reached"
+// @Router       /staff/me/saved-searches [post]
+func (ctrl *StaffWorkspaceController) SaveSearch(ctx *gin.Context) {
+	var req models.SaveSearchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, err := ctrl.workspaceService.SaveSearch(&req, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeWorkspaceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, search)
+}
+
+// @Summary      List saved searches
+// @Description  List the current staff member's saved searches in their hospital, by name.
+// @Tags         Staff Workspace
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Saved searches"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Router       /staff/me/saved-searches [get]
+func (ctrl *StaffWorkspaceController) ListSavedSearches(ctx *gin.Context) {
+	searches, err := ctrl.workspaceService.ListSavedSearches(ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"saved_searches": searches,
+		"count":          len(searches),
+	})
+}
+
+// @Summary      Run a saved search
+// @Description  Run a saved search exactly like GET /patient/search. An empty result returns an empty list.
+// @Tags         Staff Workspace
+// @Produce      json
+// @Param        id path int true "Saved search ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Matching patients"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - invalid id"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.ErrorResponse  "Saved search not found"
+// @Failure      502  {object}  utils.ErrorResponse  "HIS returned an invalid response"
+// @Failure      503  {object}  utils.ErrorResponse  "HIS is unavailable"
+// @Failure      504  {object}  utils.ErrorResponse  "HIS did not respond in time"
+// @Router       /staff/me/saved-searches/{id}/run [get]
+func (ctrl *StaffWorkspaceController) RunSavedSearch(ctx *gin.Context) {
+	searchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid saved search id"})
+		return
+	}
+
+	patients, err := ctrl.workspaceService.RunSavedSearch(searchID, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctrl.writeWorkspaceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"patients": patients,
+		"count":    len(patients),
+	})
+}
+
+// @Summary      Delete a saved search
+// @Description  Delete one of the current staff member's saved searches.
+// @Tags         Staff Workspace
+// @Param        id path int true "Saved search ID"
+// @Security     BearerAuth
+// @Success      204  "Saved search deleted"
+// @Failure      400  {object}  utils.ErrorResponse  "Bad request - invalid id"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      404  {object}  utils.ErrorResponse  "Saved search not found"
+// @Router       /staff/me/saved-searches/{id} [delete]
+func (ctrl *StaffWorkspaceController) DeleteSavedSearch(ctx *gin.Context) {
+	searchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid saved search id"})
+		return
+	}
+
+	if err := ctrl.workspaceService.DeleteSavedSearch(searchID, ctx.GetString("staff_hospital"), ctx.GetInt("staff_id")); err != nil {
+		ctrl.writeWorkspaceError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary      List recently viewed patients
+// @Description  List the patients the current staff member opened most recently through GET /patient/{hn}, newest first. Only patients of the staff member's hospital are listed; the length is set by RECENT_PATIENTS_LIMIT.
+// @Tags         Staff Workspace
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Recently viewed patients"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Router       /staff/me/recent-patients [get]
+func (ctrl *StaffWorkspaceController) ListRecentPatients(ctx *gin.Context) {
+	entries, err := ctrl.workspaceService.ListRecentPatients(ctx.GetString("staff_hospital"), ctx.GetInt("staff_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"recent_patients": entries,
+		"count":           len(entries),
+	})
+}
+
+func (ctrl *StaffWorkspaceController) writeWorkspaceError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidQuery):
+		writeQueryError(ctx, err)
+	case errors.Is(err, services.ErrSavedSearchName), errors.Is(err, services.ErrSavedSearchCriteria):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSavedSearchNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSavedSearchExists), errors.Is(err, services.ErrSavedSearchLimit):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAccessDenied):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case writeHISError(ctx, err):
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,137 @@
+package gql
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves GraphQL queries over HTTP. It must run after AuthMiddleware,
+// which puts the staff member the queries are resolved for in the context.
+type Handler struct {
+	schema        graphql.Schema
+	maxDepth      int
+	maxComplexity int
+	// maxBodyBytes bounds the request body, or the query and variables of a GET
+	maxBodyBytes int64
+}
+
+func NewHandler(patientService *services.PatientService, maxDepth int, maxComplexity int, maxBodyBytes int64) (*Handler, error) {
+	schema, err := newSchema(patientService)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		schema:        schema,
+		maxDepth:      maxDepth,
+		maxComplexity: maxComplexity,
+		maxBodyBytes:  maxBodyBytes,
+	}, nil
+}
+
+// @Summary      GraphQL query
+// @Description  Run a GraphQL query. Queries: me, patient(hn) and patients(filter, first, after). Results go through the same hospital scoping as the REST endpoints. Queries deeper than GRAPHQL_MAX_DEPTH or more complex than GRAPHQL_MAX_COMPLEXITY, introspection fields included, or selecting __schema or __type more than once are rejected with 400; requests over GRAPHQL_MAX_BODY_BYTES get 413. Only queries are supported.
+// @Tags         GraphQL
+// @Accept       json
+// @Produce      json
+// @Param        request body gql.Request true "GraphQL request"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "GraphQL result with data and errors"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query or limits exceeded"
+// @Failure      401  {object}  utils.AuthErrorResponse  "Unauthorized - authorization header required or invalid token"
+// @Failure      413  {object}  map[string]interface{}  "Request body too large"
+// @Router       /graphql [post]
+func (h *Handler) Serve(ctx *gin.Context) {
+	var req Request
+	if ctx.Request.Method == http.MethodGet {
+		if h.maxBodyBytes > 0 && int64(len(ctx.Request.URL.RawQuery)) > h.maxBodyBytes {
+			writeErrors(ctx, http.StatusRequestEntityTooLarge, gqlerrors.NewFormattedError("query is too large"))
+			return
+		}
+		req.Query = ctx.Query("query")
+		req.OperationName = ctx.Query("operationName")
+		if variables := ctx.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeErrors(ctx, http.StatusBadRequest, gqlerrors.NewFormattedError("variables must be a JSON object"))
+				return
+			}
+		}
+	} else {
+		if h.maxBodyBytes > 0 {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxBodyBytes)
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeErrors(ctx, http.StatusRequestEntityTooLarge, gqlerrors.NewFormattedError("request body is too large"))
+				return
+			}
+			writeErrors(ctx, http.StatusBadRequest, gqlerrors.NewFormattedError(err.Error()))
+			return
+		}
+	}
+	if req.Query == "" {
+		writeErrors(ctx, http.StatusBadRequest, gqlerrors.NewFormattedError("query is required"))
+		return
+	}
+
+	value, _ := ctx.Get("staff")
+	staff, ok := value.(*models.Staff)
+	if !ok {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "staff information not found"})
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		writeErrors(ctx, http.StatusBadRequest, gqlerrors.FormatErrors(err)...)
+		return
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		writeErrors(ctx, http.StatusBadRequest, validation.Errors...)
+		return
+	}
+
+	if err := checkLimits(doc, req.OperationName, req.Variables, h.maxDepth, h.maxComplexity); err != nil {
+		writeErrors(ctx, http.StatusBadRequest, gqlerrors.FormattedError{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "QUERY_TOO_COMPLEX"},
+		})
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx.Request.Context(), staffContextKey{}, staff),
+	})
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+func writeErrors(ctx *gin.Context, status int, errs ...gqlerrors.FormattedError) {
+	ctx.JSON(status, &graphql.Result{Errors: errs})
+}
@@ -0,0 +1,204 @@
+package gql
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"agnos-middleware/internal/services"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type graphqlResponse struct {
+	Data   map[string]interface{}   `json:"data"`
+	Errors []map[string]interface{} `json:"errors"`
+}
+
+func setupGraphQLTestRouter(t *testing.T, maxDepth int, maxComplexity int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientHistory{})
+
+	repo := repositories.NewPatientRepository(db)
+	for i, hn := range []string{"HN001", "HN002", "HN003"} {
+		repo.UpsertPatient(&models.Patient{
+			PatientHN:   hn,
+			Hospital:    "Hospital A",
+			LastNameEN:  stringPtr("Jaidee"),
+			Gender:      "M",
+			DateOfBirth: time.Date(1985, 3, 15+i, 0, 0, 0, 0, time.UTC),
+		}, models.PatientChange{Source: models.ChangeSourceHIS})
+	}
+	repo.UpsertPatient(&models.Patient{
+		PatientHN:  "HN900",
+		Hospital:   "Hospital B",
+		LastNameEN: stringPtr("Jaidee"),
+		Gender:     "M",
+	}, models.PatientChange{Source: models.ChangeSourceHIS})
+
+	config := &configs.ApplicationConfig{}
+	handler, err := NewHandler(services.NewPatientService(repo, config), maxDepth, maxComplexity, 4<<10)
+	if err != nil {
+		t.Fatalf("Failed to build schema: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("staff", &models.Staff{ID: 1, Username: "doctor1", Role: "Doctor", Hospital: "Hospital A"})
+		ctx.Next()
+	})
+	router.POST("/graphql", handler.Serve)
+
+	return router
+}
+
+func postGraphQL(router *gin.Engine, query string, variables map[string]interface{}) (int, graphqlResponse) {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response graphqlResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestGraphQL_Positive_Me(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+
+	code, response := postGraphQL(router, `{ me { username hospital role } }`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	me := response.Data["me"].(map[string]interface{})
+	assert.Equal(t, "doctor1", me["username"])
+	assert.Equal(t, "Hospital A", me["hospital"])
+}
+
+func TestGraphQL_Positive_PatientsPagination(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+	query := `query($after: String) {
+		patients(filter: {q: "last_name~jaidee"}, first: 2, after: $after) {
+			edges { cursor node { patientHn hospital } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+
+	code, response := postGraphQL(router, query, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+
+	patients := response.Data["patients"].(map[string]interface{})
+	edges := patients["edges"].([]interface{})
+	pageInfo := patients["pageInfo"].(map[string]interface{})
+	assert.Len(t, edges, 2)
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+
+	_, response = postGraphQL(router, query, map[string]interface{}{"after": pageInfo["endCursor"]})
+	patients = response.Data["patients"].(map[string]interface{})
+	edges = patients["edges"].([]interface{})
+	assert.Len(t, edges, 1)
+	node := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+	assert.Equal(t, "HN003", node["patientHn"])
+	assert.Equal(t, "Hospital A", node["hospital"])
+	assert.Equal(t, false, patients["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestGraphQL_Negative_OtherHospital(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+
+	code, response := postGraphQL(router, `{ patient(hn: "HN900") { patientHn } }`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.Nil(t, response.Data["patient"])
+}
+
+func TestGraphQL_Negative_Limits(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+
+	// 3 fields per patient plus edges and node, for 100 patients
+	code, response := postGraphQL(router, `{ patients(filter: {gender: "M"}, first: 100) { edges { node { patientHn hospital gender } } } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, response.Errors[0]["message"], "complexity")
+	assert.Equal(t, "QUERY_TOO_COMPLEX", response.Errors[0]["extensions"].(map[string]interface{})["code"])
+
+	code, response = postGraphQL(router, `{ patients(filter: {gender: "M"}, first: 1) { edges { node { patientHn } } pageInfo { hasNextPage } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+
+	_, response = postGraphQL(router, `mutation { me { username } }`, nil)
+	assert.NotEmpty(t, response.Errors)
+}
+
+func TestGraphQL_Negative_Depth(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 3, 0)
+	query := `
+		fragment Page on PatientConnection { edges { node { patientHn } } }
+		{ patients(filter: {gender: "M"}, first: 1) { ...Page } }`
+
+	code, response := postGraphQL(router, query, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, response.Errors[0]["message"], "depth 4")
+
+	code, response = postGraphQL(router, `{ patients(filter: {gender: "M"}, first: 1) { pageInfo { hasNextPage } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+}
+
+func TestGraphQL_Negative_Introspection(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 200)
+
+	code, response := postGraphQL(router, `{ __schema { queryType { name } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+
+	// Aliased copies of the schema only multiply the response
+	code, response = postGraphQL(router, `{ a: __schema { queryType { name } } b: __schema { queryType { name } } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, response.Errors[0]["message"], "introspection")
+
+	// Introspection fields count towards the depth like any other field
+	code, response = postGraphQL(router, `{ __schema { types { fields { args { type { ofType { name } } } } } } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, response.Errors[0]["message"], "depth")
+}
+
+func TestGraphQL_Negative_BodyTooLarge(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+
+	query := "{ me { username } }" + strings.Repeat(" ", 8<<10)
+	code, response := postGraphQL(router, query, nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.NotEmpty(t, response.Errors)
+}
+
+func TestGraphQL_Negative_InvalidFilter(t *testing.T) {
+	router := setupGraphQLTestRouter(t, 5, 100)
+
+	code, response := postGraphQL(router, `{ patients(filter: {q: "gender=="}) { edges { cursor } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "BAD_USER_INPUT", response.Errors[0]["extensions"].(map[string]interface{})["code"])
+
+	_, response = postGraphQL(router, `{ patients(filter: {}) { edges { cursor } } }`, nil)
+	assert.Equal(t, "BAD_USER_INPUT", response.Errors[0]["extensions"].(map[string]interface{})["code"])
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,131 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// queryCost walks the selected operation to measure how deep and how expensive
+// it is before anything is resolved. Every field costs 1, introspection fields
+// included; fields below a list that takes first are counted once per
+// requested item.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// schemaFields counts the __schema and __type fields selected; a schema
+	// explorer needs one, aliased copies only multiply the response
+	schemaFields int
+}
+
+// maxSchemaFields is how many __schema or __type fields an operation may select.
+const maxSchemaFields = 1
+
+// checkLimits rejects the operation when its depth or complexity is above the
+// limits. A limit of 0 or less turns that check off.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}, maxDepth int, maxComplexity int) error {
+	cost := &queryCost{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			cost.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				if operation == nil {
+					operation = definition
+				}
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	depth, complexity := cost.selectionSet(operation.SelectionSet, map[string]bool{})
+	if cost.schemaFields > maxSchemaFields {
+		return fmt.Errorf("query selects %d introspection fields, only %d is allowed", cost.schemaFields, maxSchemaFields)
+	}
+	if maxDepth > 0 && depth > maxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, maxDepth)
+	}
+	if maxComplexity > 0 && complexity > maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, maxComplexity)
+	}
+	return nil
+}
+
+// selectionSet returns the depth and complexity of a selection set. visiting
+// guards against fragment cycles.
+func (c *queryCost) selectionSet(set *ast.SelectionSet, visiting map[string]bool) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	for _, selection := range set.Selections {
+		var d, cx int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if name := selection.Name.Value; name == "__schema" || name == "__type" {
+				c.schemaFields++
+			}
+			childDepth, childComplexity := c.selectionSet(selection.SelectionSet, visiting)
+			d = childDepth + 1
+			cx = 1 + c.multiplier(selection)*childComplexity
+		case *ast.InlineFragment:
+			d, cx = c.selectionSet(selection.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := c.fragments[name]
+			if fragment == nil || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			d, cx = c.selectionSet(fragment.SelectionSet, visiting)
+			delete(visiting, name)
+		}
+
+		if d > depth {
+			depth = d
+		}
+		complexity += cx
+	}
+
+	return depth, complexity
+}
+
+// multiplier is how many items a field returns: the value of its first
+// argument, the default page size for patients, or 1.
+func (c *queryCost) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			switch n := c.variables[value.Name.Value].(type) {
+			case float64:
+				if n > 0 {
+					return int(n)
+				}
+			case int:
+				if n > 0 {
+					return n
+				}
+			}
+		}
+		return 1
+	}
+
+	if field.Name.Value == "patients" {
+		return defaultPageSize
+	}
+	return 1
+}
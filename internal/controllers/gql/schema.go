@@ -0,0 +1,345 @@
+package gql
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/services"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Page sizes for patients(first:)
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+const cursorPrefix = "patient:"
+
+type staffContextKey struct{}
+
+// resolverError adds a machine-readable code to errors returned by resolvers.
+type resolverError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	return e.extensions
+}
+
+func newResolverError(code string, message string) *resolverError {
+	return &resolverError{message: message, extensions: map[string]interface{}{"code": code}}
+}
+
+// resolvers hold the services the schema resolves against. Every resolver reads
+// the authenticated staff member from the context and is limited to their
+// hospital, exactly like the REST handlers.
+type resolvers struct {
+	patientService *services.PatientService
+}
+
+func newSchema(patientService *services.PatientService) (graphql.Schema, error) {
+	r := &resolvers{patientService: patientService}
+
+	patientType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Patient",
+		Fields: graphql.Fields{
+			"id":           patientField(graphql.NewNonNull(graphql.Int), func(p *models.Patient) interface{} { return p.ID }),
+			"patientHn":    patientField(graphql.NewNonNull(graphql.String), func(p *models.Patient) interface{} { return p.PatientHN }),
+			"hospital":     patientField(graphql.NewNonNull(graphql.String), func(p *models.Patient) interface{} { return p.Hospital }),
+			"nationalId":   patientField(graphql.String, func(p *models.Patient) interface{} { return p.NationalID }),
+			"passportId":   patientField(graphql.String, func(p *models.Patient) interface{} { return p.PassportID }),
+			"firstNameTh":  patientField(graphql.String, func(p *models.Patient) interface{} { return p.FirstNameTH }),
+			"middleNameTh": patientField(graphql.String, func(p *models.Patient) interface{} { return p.MiddleNameTH }),
+			"lastNameTh":   patientField(graphql.String, func(p *models.Patient) interface{} { return p.LastNameTH }),
+			"firstNameEn":  patientField(graphql.String, func(p *models.Patient) interface{} { return p.FirstNameEN }),
+			"middleNameEn": patientField(graphql.String, func(p *models.Patient) interface{} { return p.MiddleNameEN }),
+			"lastNameEn":   patientField(graphql.String, func(p *models.Patient) interface{} { return p.LastNameEN }),
+			"dateOfBirth": patientField(graphql.String, func(p *models.Patient) interface{} {
+				if p.DateOfBirth.IsZero() {
+					return nil
+				}
+				return p.DateOfBirth.Format("2006-01-02")
+			}),
+			"gender":       patientField(graphql.String, func(p *models.Patient) interface{} { return p.Gender }),
+			"phoneNumber":  patientField(graphql.String, func(p *models.Patient) interface{} { return p.PhoneNumber }),
+			"email":        patientField(graphql.String, func(p *models.Patient) interface{} { return p.Email }),
+			"enterpriseId": patientField(graphql.String, func(p *models.Patient) interface{} { return p.EnterpriseID }),
+			"updatedAt": patientField(graphql.String, func(p *models.Patient) interface{} {
+				return p.UpdatedAt.UTC().Format(time.RFC3339)
+			}),
+			"source": patientField(graphql.String, func(p *models.Patient) interface{} { return p.Source }),
+		},
+	})
+
+	staffType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Staff",
+		Fields: graphql.Fields{
+			"id":          staffField(graphql.NewNonNull(graphql.Int), func(s *models.Staff) interface{} { return s.ID }),
+			"employeeId":  staffField(graphql.NewNonNull(graphql.String), func(s *models.Staff) interface{} { return s.EmployeeID }),
+			"username":    staffField(graphql.NewNonNull(graphql.String), func(s *models.Staff) interface{} { return s.Username }),
+			"firstName":   staffField(graphql.String, func(s *models.Staff) interface{} { return s.FirstName }),
+			"lastName":    staffField(graphql.String, func(s *models.Staff) interface{} { return s.LastName }),
+			"email":       staffField(graphql.String, func(s *models.Staff) interface{} { return s.Email }),
+			"phoneNumber": staffField(graphql.String, func(s *models.Staff) interface{} { return s.PhoneNumber }),
+			"role":        staffField(graphql.NewNonNull(graphql.String), func(s *models.Staff) interface{} { return s.Role }),
+			"department":  staffField(graphql.String, func(s *models.Staff) interface{} { return s.Department }),
+			"hospital":    staffField(graphql.NewNonNull(graphql.String), func(s *models.Staff) interface{} { return s.Hospital }),
+		},
+	})
+
+	patientEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PatientEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(patientType)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	patientConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PatientConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(patientEdgeType)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	patientFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "PatientFilter",
+		Description: "Same criteria as GET /patient/search.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":          &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "National ID or passport ID"},
+			"patientHn":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"nationalId":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"passportId":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"firstName":   &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Partial match"},
+			"middleName":  &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Partial match"},
+			"lastName":    &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Partial match"},
+			"dateOfBirth": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+			"phoneNumber": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"gender":      &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "M or F"},
+			"q":           &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Advanced filter, same grammar as /patient/search"},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:        graphql.NewNonNull(staffType),
+				Description: "The authenticated staff member.",
+				Resolve:     r.me,
+			},
+			"patient": &graphql.Field{
+				Type:        patientType,
+				Description: "A patient of your hospital by hospital number; a retired HN resolves to the surviving patient.",
+				Args: graphql.FieldConfigArgument{
+					"hn": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: r.patient,
+			},
+			"patients": &graphql.Field{
+				Type:        graphql.NewNonNull(patientConnectionType),
+				Description: "Patients of your hospital matching the filter, in id order.",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: graphql.NewNonNull(patientFilterType)},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: r.patients,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func (r *resolvers) me(p graphql.ResolveParams) (interface{}, error) {
+	return staffFromContext(p.Context)
+}
+
+func (r *resolvers) patient(p graphql.ResolveParams) (interface{}, error) {
+	staff, err := staffFromContext(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	hn, _ := p.Args["hn"].(string)
+	patient, err := r.patientService.GetPatientByHN(hn, staff.Hospital)
+	if err != nil {
+		if errors.Is(err, services.ErrPatientNotFound) {
+			return nil, nil
+		}
+		return nil, serviceError(err)
+	}
+
+	r.patientService.RecordPatientView(patient, staff.ID)
+	return patient, nil
+}
+
+func (r *resolvers) patients(p graphql.ResolveParams) (interface{}, error) {
+	staff, err := staffFromContext(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	req := searchRequestFromFilter(p.Args["filter"])
+	if req == nil {
+		return nil, newResolverError("BAD_USER_INPUT", "at least one search criteria must be provided")
+	}
+
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, newResolverError("BAD_USER_INPUT", "first must be between 1 and "+strconv.Itoa(maxPageSize))
+	}
+
+	afterID := 0
+	if after, ok := p.Args["after"].(string); ok && after != "" {
+		afterID, err = decodeCursor(after)
+		if err != nil {
+			return nil, newResolverError("BAD_USER_INPUT", "invalid cursor")
+		}
+	}
+
+	patients, hasNext, err := r.patientService.SearchPatientPage(req, staff.Hospital, afterID, first)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	edges := make([]map[string]interface{}, 0, len(patients))
+	var endCursor interface{}
+	for _, patient := range patients {
+		cursor := encodeCursor(patient.ID)
+		edges = append(edges, map[string]interface{}{"cursor": cursor, "node": patient})
+		endCursor = cursor
+	}
+
+	return map[string]interface{}{
+		"edges": edges,
+		"pageInfo": map[string]interface{}{
+			"hasNextPage": hasNext,
+			"endCursor":   endCursor,
+		},
+	}, nil
+}
+
+func patientField(fieldType graphql.Output, value func(*models.Patient) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			patient, ok := p.Source.(*models.Patient)
+			if !ok {
+				return nil, nil
+			}
+			return value(patient), nil
+		},
+	}
+}
+
+func staffField(fieldType graphql.Output, value func(*models.Staff) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			staff, ok := p.Source.(*models.Staff)
+			if !ok {
+				return nil, nil
+			}
+			return value(staff), nil
+		},
+	}
+}
+
+func staffFromContext(ctx context.Context) (*models.Staff, error) {
+	staff, ok := ctx.Value(staffContextKey{}).(*models.Staff)
+	if !ok || staff == nil {
+		return nil, newResolverError("UNAUTHENTICATED", "staff information not found")
+	}
+	return staff, nil
+}
+
+// searchRequestFromFilter maps the PatientFilter input to a search request. It
+// returns nil when no criteria are set.
+func searchRequestFromFilter(value interface{}) *models.PatientSearchRequest {
+	filter, _ := value.(map[string]interface{})
+	req := &models.PatientSearchRequest{}
+	criteria := 0
+
+	for name, target := range map[string]**string{
+		"id":          &req.ID,
+		"patientHn":   &req.PatientHN,
+		"nationalId":  &req.NationalID,
+		"passportId":  &req.PassportID,
+		"firstName":   &req.FirstName,
+		"middleName":  &req.MiddleName,
+		"lastName":    &req.LastName,
+		"dateOfBirth": &req.DateOfBirth,
+		"phoneNumber": &req.PhoneNumber,
+		"email":       &req.Email,
+		"gender":      &req.Gender,
+		"q":           &req.Q,
+	} {
+		if s, ok := filter[name].(string); ok && s != "" {
+			*target = &s
+			criteria++
+		}
+	}
+
+	if criteria == 0 {
+		return nil
+	}
+	return req
+}
+
+func serviceError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrAccessDenied):
+		return newResolverError("FORBIDDEN", err.Error())
+	case errors.Is(err, services.ErrInvalidQuery):
+		return newResolverError("BAD_USER_INPUT", err.Error())
+	case errors.Is(err, services.ErrNotFoundInCache):
+		return newResolverError("NOT_FOUND_IN_CACHE", err.Error())
+	case errors.Is(err, services.ErrHISTimeout):
+		return newResolverError("HIS_TIMEOUT", err.Error())
+	case errors.Is(err, services.ErrHISUnavailable):
+		return newResolverError("HIS_UNAVAILABLE", err.Error())
+	case errors.Is(err, services.ErrHISBadResponse):
+		return newResolverError("HIS_BAD_RESPONSE", err.Error())
+	default:
+		return err
+	}
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, errors.New("invalid cursor")
+	}
+	return strconv.Atoi(value)
+}
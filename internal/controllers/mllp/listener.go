@@ -0,0 +1,136 @@
+package mllp
+
+import (
+	"agnos-middleware/internal/utils"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MessageHandler applies one HL7 message and returns the ACK to send back.
+type MessageHandler interface {
+	ProcessMessage(raw string, remoteAddr string) string
+}
+
+// Listener accepts HL7 v2 messages over MLLP. Each connection is served by its
+// own goroutine; messages on a connection are handled in order.
+type Listener struct {
+	addr        string
+	idleTimeout time.Duration
+	handler     MessageHandler
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewListener(addr string, idleTimeout time.Duration, handler MessageHandler) *Listener {
+	return &Listener{
+		addr:        addr,
+		idleTimeout: idleTimeout,
+		handler:     handler,
+		conns:       map[net.Conn]struct{}{},
+	}
+}
+
+func (l *Listener) Start() error {
+	listener, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return fmt.Errorf("failed to start MLLP listener: %w", err)
+	}
+
+	l.mu.Lock()
+	l.listener = listener
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go l.acceptLoop(listener)
+
+	return nil
+}
+
+// Addr returns the address the listener is bound to, or nil before Start.
+func (l *Listener) Addr() net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.listener == nil {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+// Stop closes the listener and all open connections and waits for in-flight
+// messages to finish.
+func (l *Listener) Stop() {
+	l.mu.Lock()
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+func (l *Listener) acceptLoop(listener net.Listener) {
+	defer l.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("[MLLP] Accept failed: %v\n", err)
+			continue
+		}
+
+		l.mu.Lock()
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.wg.Add(1)
+		go l.serve(conn)
+	}
+}
+
+func (l *Listener) serve(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
+	}()
+
+	remoteAddr := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+
+	for {
+		if l.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(l.idleTimeout))
+		}
+
+		message, err := utils.ReadMLLPFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("[MLLP] Connection from %s closed: %v\n", remoteAddr, err)
+			}
+			return
+		}
+
+		ack := l.handler.ProcessMessage(message, remoteAddr)
+		if err := utils.WriteMLLPFrame(conn, ack); err != nil {
+			fmt.Printf("[MLLP] Failed to send ACK to %s: %v\n", remoteAddr, err)
+			return
+		}
+	}
+}
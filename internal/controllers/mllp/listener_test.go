@@ -0,0 +1,73 @@
+package mllp
+
+import (
+	"agnos-middleware/internal/utils"
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) ProcessMessage(raw string, remoteAddr string) string {
+	msg, err := utils.ParseHL7(raw)
+	if err != nil {
+		return utils.BuildHL7ACK(nil, "", utils.HL7AckReject, err.Error())
+	}
+	return utils.BuildHL7ACK(msg, "", utils.HL7AckAccept, "")
+}
+
+func TestListener_Positive_AcknowledgesEachMessage(t *testing.T) {
+	listener := NewListener("127.0.0.1:0", time.Second, echoHandler{})
+	if err := listener.Start(); err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for _, controlID := range []string{"MSG1", "MSG2"} {
+		message := "MSH|^~\\&|HIS|HOSPA|AGNOS|AGNOS|20240101120000||ADT^A04|" + controlID + "|P|2.5\rPID|1||HN001"
+		assert.NoError(t, utils.WriteMLLPFrame(conn, message))
+
+		ack, err := utils.ReadMLLPFrame(reader)
+		assert.NoError(t, err)
+		assert.Contains(t, ack, "MSA|AA|"+controlID)
+	}
+}
+
+func TestListener_Negative_ClosesConnectionWithoutStartBlock(t *testing.T) {
+	listener := NewListener("127.0.0.1:0", time.Second, echoHandler{})
+	if err := listener.Start(); err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// The listener stops reading long before the noise ends, so the write may fail
+	go conn.Write(bytes.Repeat([]byte("x"), 1<<20))
+
+	// Closed with unread noise, the connection ends in EOF or a reset rather
+	// than the read timing out
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if assert.Error(t, err) && errors.As(err, &netErr) {
+		assert.False(t, netErr.Timeout(), "expected the listener to close the connection")
+	}
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"agnos-middleware/internal/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through staff with one of the given roles. It must run
+// after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, exists := ctx.Get("staff")
+		staff, ok := value.(*models.Staff)
+		if !exists || !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "staff information not found"})
+			ctx.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if strings.EqualFold(staff.Role, role) {
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "access denied: insufficient role"})
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,49 @@
+package mockhis
+
+import (
+	"agnos-middleware/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Adapter answers patient lookups from the fixtures in process, without a
+// mock HIS server. It implements the services.HISAdapter methods and backs the
+// mock HIS fallback in development.
+type Adapter struct {
+	hospitals []*Hospital
+	// notFound is wrapped in the error for an id no fixture has, so callers
+	// can match it with errors.Is
+	notFound error
+}
+
+// NewAdapter returns an adapter over the hospitals' fixtures. Lookups of an
+// unknown id fail with notFound.
+func NewAdapter(hospitals []*Hospital, notFound error) *Adapter {
+	return &Adapter{hospitals: hospitals, notFound: notFound}
+}
+
+// FetchPatient returns the first fixture patient matching id, marked as a mock
+// patient.
+func (a *Adapter) FetchPatient(ctx context.Context, id string) (*models.Patient, error) {
+	fixture := FindPatient(a.hospitals, id)
+	if fixture == nil {
+		return nil, fmt.Errorf("%w in mock HIS", a.notFound)
+	}
+
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		return nil, err
+	}
+	var patient models.Patient
+	if err := json.Unmarshal(data, &patient); err != nil {
+		return nil, fmt.Errorf("invalid mock HIS patient %s: %w", id, err)
+	}
+	patient.Source = models.PatientSourceMock
+	return &patient, nil
+}
+
+// PushPatient discards the patient; the fixtures are read only.
+func (a *Adapter) PushPatient(ctx context.Context, patient *models.Patient, create bool) error {
+	return nil
+}
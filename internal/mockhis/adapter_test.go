@@ -0,0 +1,31 @@
+package mockhis
+
+import (
+	"agnos-middleware/internal/models"
+	"errors"
+	"testing"
+)
+
+func TestAdapter_Positive_FetchPatient(t *testing.T) {
+	hospitals := []*Hospital{{
+		Name:     "Hospital A",
+		IDFields: []string{"patient_hn", "national_id"},
+		Patients: []map[string]interface{}{
+			{"patient_hn": "HN001", "hospital": "Hospital A", "national_id": "1234567890123"},
+		},
+	}}
+	notFound := errors.New("patient not found")
+	adapter := NewAdapter(hospitals, notFound)
+
+	patient, err := adapter.FetchPatient(t.Context(), "1234567890123")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.PatientHN != "HN001" || patient.Source != models.PatientSourceMock {
+		t.Errorf("Expected mock HN001, got %s from %s", patient.PatientHN, patient.Source)
+	}
+
+	if _, err := adapter.FetchPatient(t.Context(), "0000000000000"); !errors.Is(err, notFound) {
+		t.Errorf("Expected the not found error, got: %v", err)
+	}
+}
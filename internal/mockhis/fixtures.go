@@ -0,0 +1,159 @@
+package mockhis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hospital is one mock HIS loaded from a fixture file.
+type Hospital struct {
+	Name string
+	// Prefix is the URL path segment the hospital is served under, e.g.
+	// /hospital-a/patient/search/{id}.
+	Prefix string
+	// Latency, ErrorRate and ErrorStatus override the server defaults when set.
+	Latency     time.Duration
+	ErrorRate   float64
+	ErrorStatus int
+	// IDFields are the patient fields a lookup id is matched against.
+	IDFields []string
+	Patients []map[string]interface{}
+}
+
+type fixtureFile struct {
+	Hospital    string                   `json:"hospital" yaml:"hospital"`
+	Prefix      string                   `json:"prefix" yaml:"prefix"`
+	Latency     string                   `json:"latency" yaml:"latency"`
+	ErrorRate   float64                  `json:"error_rate" yaml:"error_rate"`
+	ErrorStatus int                      `json:"error_status" yaml:"error_status"`
+	IDFields    []string                 `json:"id_fields" yaml:"id_fields"`
+	Patients    []map[string]interface{} `json:"patients" yaml:"patients"`
+}
+
+var defaultIDFields = []string{"national_id", "passport_id", "patient_hn"}
+
+// LoadFixtures reads one hospital per .json, .yaml or .yml file. path may be a
+// single file or a directory of fixture files.
+func LoadFixtures(path string) ([]*Hospital, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".yaml", ".yml":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	hospitals := make([]*Hospital, 0, len(files))
+	prefixes := map[string]string{}
+	for _, file := range files {
+		hospital, err := loadFixtureFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := prefixes[hospital.Prefix]; ok {
+			return nil, fmt.Errorf("fixture %s: prefix %q is already used by %s", file, hospital.Prefix, other)
+		}
+		prefixes[hospital.Prefix] = file
+		hospitals = append(hospitals, hospital)
+	}
+	if len(hospitals) == 0 {
+		return nil, fmt.Errorf("no fixture files in %s", path)
+	}
+
+	return hospitals, nil
+}
+
+func loadFixtureFile(path string) (*Hospital, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+
+	var fixture fixtureFile
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &fixture)
+	} else {
+		err = yaml.Unmarshal(data, &fixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+
+	hospital := &Hospital{
+		Name:        fixture.Hospital,
+		Prefix:      fixture.Prefix,
+		ErrorRate:   fixture.ErrorRate,
+		ErrorStatus: fixture.ErrorStatus,
+		IDFields:    fixture.IDFields,
+		Patients:    fixture.Patients,
+	}
+	if hospital.Name == "" {
+		return nil, fmt.Errorf("fixture %s: hospital is required", path)
+	}
+	if hospital.Prefix == "" {
+		hospital.Prefix = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.Contains(hospital.Prefix, "/") {
+		return nil, fmt.Errorf("fixture %s: prefix %q must be a single path segment", path, hospital.Prefix)
+	}
+	if len(hospital.IDFields) == 0 {
+		hospital.IDFields = defaultIDFields
+	}
+	if fixture.Latency != "" {
+		latency, err := time.ParseDuration(fixture.Latency)
+		if err != nil || latency < 0 {
+			return nil, fmt.Errorf("fixture %s: invalid latency %q", path, fixture.Latency)
+		}
+		hospital.Latency = latency
+	}
+	if hospital.ErrorRate < 0 || hospital.ErrorRate > 1 {
+		return nil, fmt.Errorf("fixture %s: error_rate must be between 0 and 1", path)
+	}
+
+	for i, patient := range hospital.Patients {
+		hospital.Patients[i] = normalizeFixture(patient).(map[string]interface{})
+	}
+
+	return hospital, nil
+}
+
+// normalizeFixture turns YAML timestamps into RFC 3339 strings so patients
+// encode the same way whichever format the fixture was written in.
+func normalizeFixture(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalizeFixture(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeFixture(item)
+		}
+		return value
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	default:
+		return value
+	}
+}
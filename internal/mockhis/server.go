@@ -0,0 +1,319 @@
+package mockhis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options are the server wide defaults. Hospitals override them in their
+// fixture, and they apply as is to requests without a hospital prefix.
+type Options struct {
+	Latency     time.Duration
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+// Server serves the HIS REST contract the middleware's REST adapter speaks:
+//
+//	GET  /patient/search/{id}   look a patient up by national ID, passport ID or HN
+//	POST /patient               write back a new patient
+//	PUT  /patient/{hn}          write back a corrected patient
+//	GET  /patient/changes       page through patients changed since a time
+//
+// Each hospital is served under its own prefix, e.g. /hospital-a/patient/search/{id}.
+// Requests without a prefix search every hospital, the way a single shared
+// HIS_API_BASE_URL does. Written back patients are kept in memory only.
+type Server struct {
+	mu        sync.RWMutex
+	hospitals map[string]*Hospital
+	order     []*Hospital
+	options   Options
+	mux       *http.ServeMux
+	// random returns a number in [0, 1) to decide error injection
+	random func() float64
+	// changes logs every patient loaded or written back, in order, for the
+	// change feed
+	changes []patientChange
+}
+
+type patientChange struct {
+	seq      int
+	at       time.Time
+	hospital *Hospital
+	hn       string
+}
+
+func NewServer(hospitals []*Hospital, options Options) *Server {
+	if options.ErrorStatus == 0 {
+		options.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	s := &Server{
+		hospitals: make(map[string]*Hospital, len(hospitals)),
+		order:     hospitals,
+		options:   options,
+		mux:       http.NewServeMux(),
+		random:    rand.Float64,
+	}
+	for _, hospital := range hospitals {
+		s.hospitals[hospital.Prefix] = hospital
+		for _, patient := range hospital.Patients {
+			hn, _ := patient["patient_hn"].(string)
+			s.recordChange(hospital, hn)
+		}
+	}
+
+	s.mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	s.mux.HandleFunc("GET /patient/search/{id}", s.searchPatient)
+	s.mux.HandleFunc("GET /patient/changes", s.listChanges)
+	s.mux.HandleFunc("POST /patient", s.writePatient)
+	s.mux.HandleFunc("PUT /patient/{hn}", s.writePatient)
+	s.mux.HandleFunc("GET /{hospital}/patient/search/{id}", s.searchPatient)
+	s.mux.HandleFunc("GET /{hospital}/patient/changes", s.listChanges)
+	s.mux.HandleFunc("POST /{hospital}/patient", s.writePatient)
+	s.mux.HandleFunc("PUT /{hospital}/patient/{hn}", s.writePatient)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// hospitalsFor returns the hospitals a request targets and the options that
+// apply to it. ok is false when the prefix is unknown.
+func (s *Server) hospitalsFor(r *http.Request) ([]*Hospital, Options, bool) {
+	prefix := r.PathValue("hospital")
+	if prefix == "" {
+		return s.order, s.options, true
+	}
+
+	hospital, ok := s.hospitals[prefix]
+	if !ok {
+		return nil, s.options, false
+	}
+
+	options := s.options
+	if hospital.Latency > 0 {
+		options.Latency = hospital.Latency
+	}
+	if hospital.ErrorRate > 0 {
+		options.ErrorRate = hospital.ErrorRate
+	}
+	if hospital.ErrorStatus != 0 {
+		options.ErrorStatus = hospital.ErrorStatus
+	}
+	return []*Hospital{hospital}, options, true
+}
+
+// simulate waits out the latency and reports whether the request should fail
+// with an injected error. It stops waiting when the client goes away.
+func (s *Server) simulate(w http.ResponseWriter, r *http.Request, options Options) bool {
+	if options.Latency > 0 {
+		timer := time.NewTimer(options.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		case <-timer.C:
+		}
+	}
+
+	if options.ErrorRate > 0 && s.random() < options.ErrorRate {
+		writeJSON(w, options.ErrorStatus, map[string]string{"error": "injected error"})
+		return true
+	}
+	return false
+}
+
+func (s *Server) searchPatient(w http.ResponseWriter, r *http.Request) {
+	hospitals, options, ok := s.hospitalsFor(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown hospital"})
+		return
+	}
+	if s.simulate(w, r, options) {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if patient := FindPatient(hospitals, r.PathValue("id")); patient != nil {
+		writeJSON(w, http.StatusOK, patient)
+		return
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "patient not found"})
+}
+
+// FindPatient returns the first fixture patient whose id fields match id, or
+// nil.
+func FindPatient(hospitals []*Hospital, id string) map[string]interface{} {
+	for _, hospital := range hospitals {
+		for _, patient := range hospital.Patients {
+			for _, field := range hospital.IDFields {
+				if value, ok := patient[field].(string); ok && value == id {
+					return patient
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writePatient stores a written back patient. Without a prefix the patient's
+// hospital field picks the hospital.
+func (s *Server) writePatient(w http.ResponseWriter, r *http.Request) {
+	hospitals, options, ok := s.hospitalsFor(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown hospital"})
+		return
+	}
+	if s.simulate(w, r, options) {
+		return
+	}
+
+	var patient map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patient); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid patient: %v", err)})
+		return
+	}
+
+	hn := r.PathValue("hn")
+	if hn == "" {
+		hn, _ = patient["patient_hn"].(string)
+	}
+	if hn == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "patient_hn is required"})
+		return
+	}
+	patient["patient_hn"] = hn
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hospital := hospitals[0]
+	if r.PathValue("hospital") == "" {
+		name, _ := patient["hospital"].(string)
+		hospital = nil
+		for _, candidate := range hospitals {
+			if candidate.Name == name {
+				hospital = candidate
+			}
+		}
+		if hospital == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown hospital"})
+			return
+		}
+	}
+
+	for i, existing := range hospital.Patients {
+		if existing["patient_hn"] == hn {
+			if r.Method == http.MethodPost {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "patient already exists"})
+				return
+			}
+			hospital.Patients[i] = patient
+			s.recordChange(hospital, hn)
+			writeJSON(w, http.StatusOK, patient)
+			return
+		}
+	}
+
+	if r.Method == http.MethodPut {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "patient not found"})
+		return
+	}
+	hospital.Patients = append(hospital.Patients, patient)
+	s.recordChange(hospital, hn)
+	writeJSON(w, http.StatusCreated, patient)
+}
+
+// recordChange logs a patient change. The caller holds s.mu, or is NewServer.
+func (s *Server) recordChange(hospital *Hospital, hn string) {
+	s.changes = append(s.changes, patientChange{
+		seq:      len(s.changes) + 1,
+		at:       time.Now().UTC(),
+		hospital: hospital,
+		hn:       hn,
+	})
+}
+
+// listChanges serves the change feed: patients changed at or after since (all
+// when unset), limit at a time, oldest change first. next_cursor continues
+// after the last change returned and is empty on the last page. A patient
+// changed twice is returned twice, as it is now.
+func (s *Server) listChanges(w http.ResponseWriter, r *http.Request) {
+	hospitals, options, ok := s.hospitalsFor(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown hospital"})
+		return
+	}
+	if s.simulate(w, r, options) {
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 time"})
+			return
+		}
+		since = parsed
+	}
+	cursor, _ := strconv.Atoi(query.Get("cursor"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	targeted := make(map[*Hospital]bool, len(hospitals))
+	for _, hospital := range hospitals {
+		targeted[hospital] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	asOf := time.Now().UTC()
+	patients := []map[string]interface{}{}
+	nextCursor := ""
+	lastSeq := 0
+	for _, change := range s.changes {
+		if change.seq <= cursor || change.at.Before(since) || !targeted[change.hospital] {
+			continue
+		}
+		if len(patients) == limit {
+			nextCursor = strconv.Itoa(lastSeq)
+			break
+		}
+		lastSeq = change.seq
+		for _, patient := range change.hospital.Patients {
+			if patient["patient_hn"] == change.hn {
+				patients = append(patients, patient)
+				break
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"patients":    patients,
+		"next_cursor": nextCursor,
+		"as_of":       asOf.Format(time.RFC3339Nano),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
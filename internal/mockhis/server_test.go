@@ -0,0 +1,211 @@
+package mockhis
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFixture(t *testing.T, dir, name, content string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+}
+
+func setupMockHIS(t *testing.T, options Options) *httptest.Server {
+	dir := t.TempDir()
+	writeFixture(t, dir, "hospital-a.yaml", `
+hospital: Hospital A
+patients:
+  - patient_hn: HN001
+    hospital: Hospital A
+    national_id: "1234567890123"
+    date_of_birth: 1985-03-15
+`)
+	writeFixture(t, dir, "b.json", `{
+  "hospital": "Hospital B",
+  "prefix": "hospital-b",
+  "id_fields": ["mrn"],
+  "patients": [{"mrn": "B-77", "hospital": "Hospital B"}]
+}`)
+	writeFixture(t, dir, "README.txt", "not a fixture")
+
+	hospitals, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	server := httptest.NewServer(NewServer(hospitals, options))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getPatient(t *testing.T, url string) (int, map[string]interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestServer_Positive_SearchByPrefix(t *testing.T) {
+	server := setupMockHIS(t, Options{})
+
+	status, patient := getPatient(t, server.URL+"/hospital-a/patient/search/1234567890123")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if patient["patient_hn"] != "HN001" {
+		t.Errorf("Expected HN001, got %v", patient["patient_hn"])
+	}
+	if patient["date_of_birth"] != "1985-03-15T00:00:00Z" {
+		t.Errorf("Expected YAML date as RFC 3339, got %v", patient["date_of_birth"])
+	}
+
+	status, patient = getPatient(t, server.URL+"/hospital-b/patient/search/B-77")
+	if status != http.StatusOK || patient["hospital"] != "Hospital B" {
+		t.Errorf("Expected B-77 of Hospital B by its id field, got %d %v", status, patient)
+	}
+
+	if status, _ := getPatient(t, server.URL+"/hospital-b/patient/search/1234567890123"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for another hospital's patient, got %d", status)
+	}
+	if status, _ := getPatient(t, server.URL+"/hospital-c/patient/search/1234567890123"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown hospital, got %d", status)
+	}
+}
+
+func TestServer_Positive_SearchWithoutPrefixCoversAllHospitals(t *testing.T) {
+	server := setupMockHIS(t, Options{})
+
+	for _, id := range []string{"HN001", "B-77"} {
+		if status, _ := getPatient(t, server.URL+"/patient/search/"+id); status != http.StatusOK {
+			t.Errorf("Expected %s to be found, got %d", id, status)
+		}
+	}
+}
+
+func TestServer_Negative_ErrorInjection(t *testing.T) {
+	server := setupMockHIS(t, Options{ErrorRate: 1, ErrorStatus: http.StatusBadGateway})
+
+	if status, _ := getPatient(t, server.URL+"/hospital-a/patient/search/HN001"); status != http.StatusBadGateway {
+		t.Errorf("Expected injected 502, got %d", status)
+	}
+}
+
+func TestServer_Positive_Latency(t *testing.T) {
+	server := setupMockHIS(t, Options{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	getPatient(t, server.URL+"/patient/search/HN001")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms latency, got %v", elapsed)
+	}
+}
+
+func TestServer_Positive_WriteBack(t *testing.T) {
+	server := setupMockHIS(t, Options{})
+
+	resp, err := http.Post(server.URL+"/patient", "application/json", strings.NewReader(`{"patient_hn":"HN002","hospital":"Hospital A"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/hospital-a/patient/HN002", strings.NewReader(`{"hospital":"Hospital A","gender":"F"}`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	status, patient := getPatient(t, server.URL+"/hospital-a/patient/search/HN002")
+	if status != http.StatusOK || patient["gender"] != "F" {
+		t.Errorf("Expected updated HN002, got %d %v", status, patient)
+	}
+}
+
+func TestServer_Positive_ChangeFeed(t *testing.T) {
+	server := setupMockHIS(t, Options{})
+	started := time.Now().UTC()
+
+	for _, hn := range []string{"HN002", "HN003"} {
+		resp, err := http.Post(server.URL+"/patient", "application/json", strings.NewReader(`{"patient_hn":"`+hn+`","hospital":"Hospital A"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	type page struct {
+		Patients   []map[string]interface{} `json:"patients"`
+		NextCursor string                   `json:"next_cursor"`
+		AsOf       time.Time                `json:"as_of"`
+	}
+	get := func(query string) page {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/hospital-a/patient/changes?" + query)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body page
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body
+	}
+
+	first := get("limit=2")
+	if len(first.Patients) != 2 || first.Patients[0]["patient_hn"] != "HN001" || first.NextCursor == "" {
+		t.Fatalf("Expected HN001 and HN002 with a next cursor, got %v", first)
+	}
+	second := get("limit=2&cursor=" + first.NextCursor)
+	if len(second.Patients) != 1 || second.Patients[0]["patient_hn"] != "HN003" || second.NextCursor != "" {
+		t.Errorf("Expected only HN003 on the last page, got %v", second)
+	}
+	if second.AsOf.Before(started) {
+		t.Errorf("Expected as_of to be the time of the request, got %v", second.AsOf)
+	}
+
+	later := get("since=" + second.AsOf.Add(time.Second).Format(time.RFC3339Nano))
+	if len(later.Patients) != 0 {
+		t.Errorf("Expected no changes after as_of, got %d", len(later.Patients))
+	}
+}
+
+func TestLoadFixtures_Negative_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing.yaml": "patients: []",
+		"latency.yaml": "hospital: Hospital A\nlatency: soon",
+		"rate.json":    `{"hospital": "Hospital A", "error_rate": 2}`,
+	}
+
+	for name, content := range tests {
+		dir := t.TempDir()
+		writeFixture(t, dir, name, content)
+		if _, err := LoadFixtures(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	dir := t.TempDir()
+	writeFixture(t, dir, "a.yaml", "hospital: Hospital A\nprefix: shared")
+	writeFixture(t, dir, "b.yaml", "hospital: Hospital B\nprefix: shared")
+	if _, err := LoadFixtures(dir); err == nil {
+		t.Error("Expected an error for a duplicate prefix")
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionPatientDelete = "patient.delete"
+	AuditActionPatientErase  = "patient.erase"
+	AuditActionPatientPurge  = "patient.purge"
+	AuditActionPatientExport = "patient.export"
+)
+
+// AuditLog records sensitive operations. It never holds patient PII, only
+// identifiers and counts, so it can outlive the data it describes.
+type AuditLog struct {
+	ID        int       `json:"id" gorm:"primaryKey;column:id"`
+	Action    string    `json:"action" gorm:"index;column:action"`
+	ActorID   *int      `json:"actor_id,omitempty" gorm:"column:actor_id"`
+	Hospital  string    `json:"hospital,omitempty" gorm:"index;column:hospital"`
+	PatientID *int      `json:"patient_id,omitempty" gorm:"column:patient_id"`
+	PatientHN string    `json:"patient_hn,omitempty" gorm:"column:patient_hn"`
+	RowCount  int       `json:"row_count" gorm:"column:row_count"`
+	Details   string    `json:"details,omitempty" gorm:"type:text;column:details"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index;column:created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
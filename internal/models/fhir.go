@@ -0,0 +1,153 @@
+package models
+
+// FHIR R4 resources served by the /fhir endpoints. Only the elements this
+// middleware can fill are modelled.
+
+const FHIRVersion = "4.0.1"
+
+const FHIRContentType = "application/fhir+json"
+
+// Identifier systems
+const (
+	FHIRSystemNationalID = "https://terms.sil-th.org/id/th-cid"
+	FHIRSystemPassport   = "https://terms.sil-th.org/id/passport-number"
+	FHIRSystemHN         = "urn:agnos-middleware:patient-hn"
+)
+
+// FHIRExtensionLanguage marks the language of a HumanName
+const FHIRExtensionLanguage = "http://hl7.org/fhir/StructureDefinition/language"
+
+type FHIRMeta struct {
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+type FHIRCoding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type FHIRCodeableConcept struct {
+	Coding []FHIRCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type FHIRReference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type FHIRExtension struct {
+	URL       string `json:"url"`
+	ValueCode string `json:"valueCode,omitempty"`
+}
+
+type FHIRIdentifier struct {
+	Use      string               `json:"use,omitempty"`
+	Type     *FHIRCodeableConcept `json:"type,omitempty"`
+	System   string               `json:"system"`
+	Value    string               `json:"value"`
+	Assigner *FHIRReference       `json:"assigner,omitempty"`
+}
+
+type FHIRHumanName struct {
+	Extension []FHIRExtension `json:"extension,omitempty"`
+	Use       string          `json:"use,omitempty"`
+	Text      string          `json:"text,omitempty"`
+	Family    string          `json:"family,omitempty"`
+	Given     []string        `json:"given,omitempty"`
+}
+
+type FHIRContactPoint struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+	Use    string `json:"use,omitempty"`
+}
+
+type FHIRPatient struct {
+	ResourceType string             `json:"resourceType" example:"Patient"`
+	ID           string             `json:"id" example:"HN001"`
+	Meta         *FHIRMeta          `json:"meta,omitempty"`
+	Identifier   []FHIRIdentifier   `json:"identifier,omitempty"`
+	Active       bool               `json:"active"`
+	Name         []FHIRHumanName    `json:"name,omitempty"`
+	Telecom      []FHIRContactPoint `json:"telecom,omitempty"`
+	Gender       string             `json:"gender,omitempty" example:"male"`
+	BirthDate    string             `json:"birthDate,omitempty" example:"1985-03-15"`
+}
+
+type FHIRBundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+type FHIRBundleSearch struct {
+	Mode string `json:"mode"`
+}
+
+type FHIRBundleEntry struct {
+	FullURL  string            `json:"fullUrl"`
+	Resource *FHIRPatient      `json:"resource"`
+	Search   *FHIRBundleSearch `json:"search,omitempty"`
+}
+
+type FHIRBundle struct {
+	ResourceType string            `json:"resourceType" example:"Bundle"`
+	Type         string            `json:"type" example:"searchset"`
+	Total        int               `json:"total"`
+	Link         []FHIRBundleLink  `json:"link,omitempty"`
+	Entry        []FHIRBundleEntry `json:"entry,omitempty"`
+}
+
+type FHIROperationOutcomeIssue struct {
+	Severity    string `json:"severity" example:"error"`
+	Code        string `json:"code" example:"not-found"`
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+type FHIROperationOutcome struct {
+	ResourceType string                      `json:"resourceType" example:"OperationOutcome"`
+	Issue        []FHIROperationOutcomeIssue `json:"issue"`
+}
+
+type FHIRSearchParam struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
+type FHIRInteraction struct {
+	Code string `json:"code"`
+}
+
+type FHIRCapabilityResource struct {
+	Type        string            `json:"type"`
+	Interaction []FHIRInteraction `json:"interaction"`
+	SearchParam []FHIRSearchParam `json:"searchParam,omitempty"`
+}
+
+type FHIRCapabilitySecurity struct {
+	Service     []FHIRCodeableConcept `json:"service,omitempty"`
+	Description string                `json:"description,omitempty"`
+}
+
+type FHIRCapabilityRest struct {
+	Mode     string                   `json:"mode"`
+	Security *FHIRCapabilitySecurity  `json:"security,omitempty"`
+	Resource []FHIRCapabilityResource `json:"resource"`
+}
+
+type FHIRCapabilitySoftware struct {
+	Name string `json:"name"`
+}
+
+type FHIRCapabilityStatement struct {
+	ResourceType string                  `json:"resourceType" example:"CapabilityStatement"`
+	Status       string                  `json:"status"`
+	Date         string                  `json:"date"`
+	Kind         string                  `json:"kind"`
+	Software     *FHIRCapabilitySoftware `json:"software,omitempty"`
+	FHIRVersion  string                  `json:"fhirVersion"`
+	Format       []string                `json:"format"`
+	Rest         []FHIRCapabilityRest    `json:"rest"`
+}
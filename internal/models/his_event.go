@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// HIS event types pushed to POST /integrations/his/{hospital}/events
+const (
+	HISEventPatientCreated = "patient.created"
+	HISEventPatientUpdated = "patient.updated"
+)
+
+// HIS event delivery outcomes
+const (
+	// HISDeliveryApplied: the patient was saved or refreshed.
+	HISDeliveryApplied = "applied"
+	// HISDeliveryIgnored: the event was valid but had nothing to change, e.g. a
+	// change notification for a patient that is not cached.
+	HISDeliveryIgnored = "ignored"
+	// HISDeliveryDuplicate: an event with the same ID was already handled.
+	HISDeliveryDuplicate = "duplicate"
+	// HISDeliveryRejected: bad signature, stale timestamp or invalid event. The
+	// sender should not retry.
+	HISDeliveryRejected = "rejected"
+	// HISDeliveryFailed: the event could not be applied, e.g. HIS was down for
+	// a refresh. The sender may retry it.
+	HISDeliveryFailed = "failed"
+)
+
+// HISEvent is a patient change notification pushed by a HIS. Patient holds the
+// patient in the vendor's format, mapped with the hospital's adapter fields;
+// without it the patient with PatientHN is refreshed from HIS.
+type HISEvent struct {
+	EventID    string                 `json:"event_id" example:"evt-20240315-0001"`
+	Type       string                 `json:"type" example:"patient.updated"`
+	OccurredAt *time.Time             `json:"occurred_at,omitempty"`
+	PatientHN  string                 `json:"patient_hn,omitempty" example:"HN001"`
+	Patient    map[string]interface{} `json:"patient,omitempty"`
+}
+
+// HISEventRecord marks an event ID of a hospital as handled, so a redelivered
+// event is not applied twice. Events that fail are not kept, so they can be
+// retried. Records are purged with deliveries after RETENTION_HIS_EVENT_DAYS.
+type HISEventRecord struct {
+	Hospital   string    `json:"hospital" gorm:"primaryKey;column:hospital"`
+	EventID    string    `json:"event_id" gorm:"primaryKey;column:event_id"`
+	Type       string    `json:"type" gorm:"column:type"`
+	ReceivedAt time.Time `json:"received_at" gorm:"index;column:received_at"`
+}
+
+func (HISEventRecord) TableName() string {
+	return "his_event"
+}
+
+// HISEventDelivery records every call to the HIS event endpoint and its
+// outcome for troubleshooting. Only deliveries with a valid signature and event
+// keep a Payload, redacted to the event fields and the names of the patient
+// fields sent, and the SHA-256 of the body to compare with the sender's logs.
+type HISEventDelivery struct {
+	ID            int       `json:"id" gorm:"primaryKey;column:id"`
+	Hospital      string    `json:"hospital" gorm:"index;column:hospital"`
+	EventID       string    `json:"event_id,omitempty" gorm:"index;column:event_id"`
+	Type          string    `json:"type,omitempty" gorm:"column:type"`
+	PatientHN     string    `json:"patient_hn,omitempty" gorm:"column:patient_hn"`
+	Status        string    `json:"status" gorm:"index;column:status"`
+	Error         string    `json:"error,omitempty" gorm:"type:text;column:error"`
+	RemoteAddr    string    `json:"remote_addr,omitempty" gorm:"column:remote_addr"`
+	Payload       string    `json:"payload,omitempty" gorm:"type:text;column:payload"`
+	PayloadSHA256 string    `json:"payload_sha256,omitempty" gorm:"column:payload_sha256"`
+	DurationMS    int64     `json:"duration_ms" gorm:"column:duration_ms"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime;index;column:created_at"`
+}
+
+func (HISEventDelivery) TableName() string {
+	return "his_event_delivery"
+}
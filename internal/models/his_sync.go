@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// HISSyncCheckpoint records how far the background sync has read the change
+// feed of one HIS. It is saved in the same transaction as each batch of
+// patients, so a sync stopped at any point resumes without losing or
+// re-reading more than one page.
+type HISSyncCheckpoint struct {
+	// Source is the hospital of the HIS, or "default" for HIS_API_BASE_URL
+	Source string `json:"source" gorm:"primaryKey;column:source"`
+	// Since is the point every change before which has been synced
+	Since *time.Time `json:"since,omitempty" gorm:"column:since"`
+	// Cursor is the next page of an unfinished run; RunSince becomes Since
+	// once that run reaches the end of the feed.
+	Cursor         string     `json:"cursor,omitempty" gorm:"column:cursor"`
+	RunSince       *time.Time `json:"run_since,omitempty" gorm:"column:run_since"`
+	PatientsSynced int64      `json:"patients_synced" gorm:"column:patients_synced"`
+	// PatientsSkipped counts patients of the feed that could not be saved; the
+	// sync moves past them and LastError says why the last one failed.
+	PatientsSkipped int64      `json:"patients_skipped" gorm:"column:patients_skipped"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty" gorm:"column:last_run_at"`
+	LastSuccessAt   *time.Time `json:"last_success_at,omitempty" gorm:"column:last_success_at"`
+	LastError       string     `json:"last_error,omitempty" gorm:"type:text;column:last_error"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (HISSyncCheckpoint) TableName() string {
+	return "his_sync_checkpoint"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// HL7DeadLetter keeps an inbound HL7 message that could not be parsed or applied,
+// with the reason, so it can be inspected and replayed.
+type HL7DeadLetter struct {
+	ID          int       `json:"id" gorm:"primaryKey;column:id"`
+	ControlID   string    `json:"control_id,omitempty" gorm:"index;column:control_id"`
+	MessageType string    `json:"message_type,omitempty" gorm:"column:message_type"`
+	Facility    string    `json:"facility,omitempty" gorm:"column:facility"`
+	RemoteAddr  string    `json:"remote_addr,omitempty" gorm:"column:remote_addr"`
+	RawMessage  string    `json:"raw_message" gorm:"type:text;column:raw_message"`
+	Error       string    `json:"error" gorm:"type:text;column:error"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime;index;column:created_at"`
+}
+
+func (HL7DeadLetter) TableName() string {
+	return "hl7_dead_letter"
+}
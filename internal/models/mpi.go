@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MatchStatusPending  = "pending"
+	MatchStatusLinked   = "linked"
+	MatchStatusRejected = "rejected"
+)
+
+// PatientMatchCandidate is a pair of patient registrations from different hospitals
+// that may be the same person. PatientID is always the lower of the two IDs.
+type PatientMatchCandidate struct {
+	ID          int        `json:"id" gorm:"primaryKey;column:id"`
+	PatientID   int        `json:"patient_id" gorm:"uniqueIndex:idx_match_pair;column:patient_id"`
+	CandidateID int        `json:"candidate_id" gorm:"uniqueIndex:idx_match_pair;column:candidate_id"`
+	Score       int        `json:"score" gorm:"column:score"`
+	Reasons     string     `json:"reasons" gorm:"column:reasons"`
+	Status      string     `json:"status" gorm:"index;column:status"`
+	ReviewedBy  *int       `json:"reviewed_by,omitempty" gorm:"column:reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty" gorm:"column:reviewed_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+
+	Patient   *Patient `json:"patient,omitempty" gorm:"foreignKey:PatientID;constraint:OnDelete:CASCADE"`
+	Candidate *Patient `json:"candidate,omitempty" gorm:"foreignKey:CandidateID;constraint:OnDelete:CASCADE"`
+}
+
+func (PatientMatchCandidate) TableName() string {
+	return "patient_match_candidate"
+}
+
+type MPIStatsResponse struct {
+	Registrations int64 `json:"registrations"`
+	People        int64 `json:"people"`
+	Pending       int64 `json:"pending_candidates"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Patient struct {
@@ -20,25 +22,56 @@ type Patient struct {
 	Gender       string    `json:"gender" gorm:"column:gender"`
 	PatientHN    string    `json:"patient_hn" gorm:"uniqueIndex:idx_patient_hn_hospital;column:patient_hn"`
 	Hospital     string    `json:"hospital" gorm:"uniqueIndex:idx_patient_hn_hospital;column:hospital"`
-	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+	EnterpriseID *string   `json:"enterprise_id,omitempty" gorm:"index;column:enterprise_id"`
+	MergedIntoID *int      `json:"merged_into_id,omitempty" gorm:"index;column:merged_into_id"`
+	// LastFetchedAt is when the row was last refreshed from HIS; nil for rows only
+	// ever registered locally. Retention purges HIS-cached rows based on it.
+	LastFetchedAt *time.Time     `json:"last_fetched_at,omitempty" gorm:"index;column:last_fetched_at"`
+	ErasedAt      *time.Time     `json:"erased_at,omitempty" gorm:"column:erased_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index;column:deleted_at"`
+	UpdatedAt     time.Time      `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+	// Source tells where this copy of the patient came from; see PatientSource*.
+	Source string `json:"source,omitempty" gorm:"-" example:"local"`
 }
 
+// Patient sources
+const (
+	// PatientSourceLocal is a patient read from the local database.
+	PatientSourceLocal = "local"
+	// PatientSourceHIS is a patient fetched from HIS for this request.
+	PatientSourceHIS = "his"
+	// PatientSourceStaleCache marks a cached HIS copy older than HIS_CACHE_TTL,
+	// which is being refreshed, or answers from the local cache given because
+	// HIS could not be reached. Either may be out of date or incomplete.
+	PatientSourceStaleCache = "stale-cache"
+	// PatientSourceMock is a patient from the mock HIS fixtures (development only).
+	PatientSourceMock = "mock"
+)
+
 func (Patient) TableName() string {
 	return "patient"
 }
 
+// AfterFind marks patients read from the database as local.
+func (p *Patient) AfterFind(tx *gorm.DB) error {
+	p.Source = PatientSourceLocal
+	return nil
+}
+
 type PatientSearchRequest struct {
-	ID          *string `form:"id" example:"1234567890123"` // Can be either national_id or passport_id (per HIS API spec)
-	PatientHN   *string `form:"patient_hn"`
-	NationalID  *string `form:"national_id"`
-	PassportID  *string `form:"passport_id"`
-	FirstName   *string `form:"first_name"`
-	MiddleName  *string `form:"middle_name"`
-	LastName    *string `form:"last_name"`
-	DateOfBirth *string `form:"date_of_birth"`
-	PhoneNumber *string `form:"phone_number"`
-	Email       *string `form:"email"`
-	Gender      *string `form:"gender"`
+	ID          *string `form:"id" json:"id,omitempty" example:"1234567890123"` // Can be either national_id or passport_id (per HIS API spec)
+	PatientHN   *string `form:"patient_hn" json:"patient_hn,omitempty"`
+	NationalID  *string `form:"national_id" json:"national_id,omitempty"`
+	PassportID  *string `form:"passport_id" json:"passport_id,omitempty"`
+	FirstName   *string `form:"first_name" json:"first_name,omitempty"`
+	MiddleName  *string `form:"middle_name" json:"middle_name,omitempty"`
+	LastName    *string `form:"last_name" json:"last_name,omitempty"`
+	DateOfBirth *string `form:"date_of_birth" json:"date_of_birth,omitempty"`
+	PhoneNumber *string `form:"phone_number" json:"phone_number,omitempty"`
+	Email       *string `form:"email" json:"email,omitempty"`
+	Gender      *string `form:"gender" json:"gender,omitempty"`
+	// Q is an advanced filter, e.g. last_name~"jai" AND (gender=F OR dob>=1990-01-01)
+	Q *string `form:"q" json:"q,omitempty"`
 }
 
 type PatientSearchResponse struct {
@@ -46,3 +79,112 @@ type PatientSearchResponse struct {
 	Count    int        `json:"count"`
 	Error    string     `json:"error,omitempty"`
 }
+
+// PatientStreamSummary is the last line of an NDJSON search response. Complete is
+// false when the stream stopped early; Error then says why.
+type PatientStreamSummary struct {
+	Count    int    `json:"count"`
+	Complete bool   `json:"complete"`
+	Error    string `json:"error,omitempty"`
+}
+
+type PatientStreamSummaryLine struct {
+	Summary PatientStreamSummary `json:"summary"`
+}
+
+// HNSequence holds the last hospital number allocated locally for a hospital.
+type HNSequence struct {
+	Hospital  string    `json:"hospital" gorm:"primaryKey;column:hospital"`
+	LastValue int       `json:"last_value" gorm:"column:last_value"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (HNSequence) TableName() string {
+	return "hn_sequence"
+}
+
+type CreatePatientRequest struct {
+	NationalID   *string `json:"national_id,omitempty" binding:"omitempty,len=13,numeric" example:"1234567890123"`
+	PassportID   *string `json:"passport_id,omitempty" binding:"omitempty,alphanum,max=20" example:"AB1234567"`
+	FirstNameTH  *string `json:"first_name_th,omitempty" binding:"omitempty,max=255" example:"สมชาย"`
+	MiddleNameTH *string `json:"middle_name_th,omitempty" binding:"omitempty,max=255"`
+	LastNameTH   *string `json:"last_name_th,omitempty" binding:"omitempty,max=255" example:"ใจดี"`
+	FirstNameEN  *string `json:"first_name_en,omitempty" binding:"omitempty,max=255" example:"Somchai"`
+	MiddleNameEN *string `json:"middle_name_en,omitempty" binding:"omitempty,max=255"`
+	LastNameEN   *string `json:"last_name_en,omitempty" binding:"omitempty,max=255" example:"Jaidee"`
+	DateOfBirth  string  `json:"date_of_birth" binding:"required,datetime=2006-01-02" example:"1985-03-15"`
+	PhoneNumber  *string `json:"phone_number,omitempty" binding:"omitempty,max=20" example:"0891234567"`
+	Email        *string `json:"email,omitempty" binding:"omitempty,email" example:"somchai@email.com"`
+	Gender       string  `json:"gender" binding:"required,oneof=M F" example:"M"`
+}
+
+// UpdatePatientRequest only changes the fields that are present. Sending an empty
+// string clears an optional field. UpdatedAt can be used instead of an If-Match header.
+type UpdatePatientRequest struct {
+	NationalID   *string    `json:"national_id,omitempty" binding:"omitempty,len=13,numeric"`
+	PassportID   *string    `json:"passport_id,omitempty" binding:"omitempty,alphanum,max=20"`
+	FirstNameTH  *string    `json:"first_name_th,omitempty" binding:"omitempty,max=255"`
+	MiddleNameTH *string    `json:"middle_name_th,omitempty" binding:"omitempty,max=255"`
+	LastNameTH   *string    `json:"last_name_th,omitempty" binding:"omitempty,max=255"`
+	FirstNameEN  *string    `json:"first_name_en,omitempty" binding:"omitempty,max=255"`
+	MiddleNameEN *string    `json:"middle_name_en,omitempty" binding:"omitempty,max=255"`
+	LastNameEN   *string    `json:"last_name_en,omitempty" binding:"omitempty,max=255"`
+	DateOfBirth  *string    `json:"date_of_birth,omitempty" binding:"omitempty,datetime=2006-01-02" example:"1985-03-15"`
+	PhoneNumber  *string    `json:"phone_number,omitempty" binding:"omitempty,max=20" example:"0891234567"`
+	Email        *string    `json:"email,omitempty" binding:"omitempty,email"`
+	Gender       *string    `json:"gender,omitempty" binding:"omitempty,oneof=M F"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// Batch lookup result statuses
+const (
+	BatchLookupFound     = "found"
+	BatchLookupNotFound  = "not_found"
+	BatchLookupForbidden = "forbidden"
+	BatchLookupError     = "error"
+)
+
+type BatchLookupRequest struct {
+	// IDs can be hospital numbers, national IDs or passport IDs
+	IDs []string `json:"ids" binding:"required,min=1,max=500,dive,required,max=20" example:"HN001,1234567890123"`
+}
+
+type BatchLookupResult struct {
+	ID      string   `json:"id"`
+	Status  string   `json:"status" example:"found"`
+	Patient *Patient `json:"patient,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	// Source is stale-cache when the item was not found locally and HIS could
+	// not be reached to check.
+	Source string `json:"source,omitempty"`
+}
+
+type BatchLookupResponse struct {
+	Results []*BatchLookupResult `json:"results"`
+	Count   int                  `json:"count"`
+}
+
+// Fan-out search source statuses
+const (
+	HISSourceFound     = "found"
+	HISSourceNotFound  = "not_found"
+	HISSourceForbidden = "forbidden"
+	HISSourceError     = "error"
+	HISSourceTimeout   = "timeout"
+)
+
+// HISSourceResult reports how one HIS answered a fan-out search.
+type HISSourceResult struct {
+	Hospital   string `json:"hospital" example:"Hospital B"`
+	Status     string `json:"status" example:"found"`
+	DurationMS int64  `json:"duration_ms" example:"84"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HISFanOutResponse holds the patients every HIS returned that the caller may
+// see, and the outcome of each HIS.
+type HISFanOutResponse struct {
+	Patients []*Patient         `json:"patients"`
+	Count    int                `json:"count"`
+	Sources  []*HISSourceResult `json:"sources"`
+}
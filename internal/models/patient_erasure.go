@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ErasureStatusPending  = "pending"
+	ErasureStatusApproved = "approved"
+	ErasureStatusRejected = "rejected"
+	ErasureStatusErased   = "erased"
+	ErasureStatusFailed   = "failed"
+)
+
+// PatientErasureRequest is a PDPA erasure request. A second staff member approves
+// it and the erasure job then scrubs the patient's personal data, leaving a
+// tombstone row so the HN is never reused.
+type PatientErasureRequest struct {
+	ID          int        `json:"id" gorm:"primaryKey;column:id"`
+	PatientID   int        `json:"patient_id" gorm:"index;column:patient_id"`
+	PatientHN   string     `json:"patient_hn" gorm:"column:patient_hn"`
+	Hospital    string     `json:"hospital" gorm:"index;column:hospital"`
+	Reason      string     `json:"reason" gorm:"column:reason"`
+	Status      string     `json:"status" gorm:"index;column:status"`
+	RequestedBy int        `json:"requested_by" gorm:"column:requested_by"`
+	ReviewedBy  *int       `json:"reviewed_by,omitempty" gorm:"column:reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty" gorm:"column:reviewed_at"`
+	ErasedAt    *time.Time `json:"erased_at,omitempty" gorm:"column:erased_at"`
+	Error       string     `json:"error,omitempty" gorm:"column:error"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (PatientErasureRequest) TableName() string {
+	return "patient_erasure_request"
+}
+
+type CreateErasureRequest struct {
+	Reason string `json:"reason" binding:"required,max=500" example:"Patient request under PDPA section 33"`
+}
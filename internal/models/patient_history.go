@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Where a patient change came from.
+const (
+	ChangeSourceHIS     = "his"
+	ChangeSourceHL7     = "hl7"
+	ChangeSourceLocal   = "local"
+	ChangeSourceMerge   = "merge"
+	ChangeSourceUnmerge = "unmerge"
+	ChangeSourceErasure = "erasure"
+)
+
+const (
+	HistoryActionCreate  = "create"
+	HistoryActionUpdate  = "update"
+	HistoryActionDelete  = "delete"
+	HistoryActionRestore = "restore"
+)
+
+// PatientChange describes who made a change to a patient and through which path.
+type PatientChange struct {
+	Source  string
+	ActorID *int
+}
+
+type PatientFieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// PatientChanges maps a patient field (by its JSON name) to its old and new value.
+type PatientChanges map[string]PatientFieldChange
+
+func (c PatientChanges) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *PatientChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case []byte:
+		return json.Unmarshal(v, c)
+	default:
+		return errors.New("unsupported type for patient changes")
+	}
+}
+
+// PatientHistory is an append-only record of one change to a patient row.
+type PatientHistory struct {
+	ID        int            `json:"id" gorm:"primaryKey;column:id"`
+	PatientID int            `json:"patient_id" gorm:"index;column:patient_id"`
+	PatientHN string         `json:"patient_hn" gorm:"column:patient_hn"`
+	Hospital  string         `json:"hospital" gorm:"column:hospital"`
+	Action    string         `json:"action" gorm:"column:action"`
+	Source    string         `json:"source" gorm:"column:source"`
+	ActorID   *int           `json:"actor_id,omitempty" gorm:"column:actor_id"`
+	Changes   PatientChanges `json:"changes" gorm:"type:text;column:changes"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime;index;column:created_at"`
+}
+
+func (PatientHistory) TableName() string {
+	return "patient_history"
+}
+
+// Fields that are bookkeeping rather than patient data and are left out of diffs.
+var untrackedPatientFields = map[string]bool{
+	"id":              true,
+	"enterprise_id":   true,
+	"last_fetched_at": true,
+	"erased_at":       true,
+	"updated_at":      true,
+	"source":          true,
+}
+
+// DiffPatients compares two versions of a patient field by field. A nil before
+// treats every field of after as new.
+func DiffPatients(before *Patient, after *Patient) PatientChanges {
+	changes := PatientChanges{}
+
+	afterValue := reflect.ValueOf(after).Elem()
+	var beforeValue reflect.Value
+	if before != nil {
+		beforeValue = reflect.ValueOf(before).Elem()
+	}
+
+	patientType := afterValue.Type()
+	for i := 0; i < patientType.NumField(); i++ {
+		name := strings.Split(patientType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || untrackedPatientFields[name] {
+			continue
+		}
+
+		var oldValue interface{}
+		if before != nil {
+			oldValue = comparableValue(beforeValue.Field(i))
+		}
+		newValue := comparableValue(afterValue.Field(i))
+
+		if oldValue != newValue {
+			changes[name] = PatientFieldChange{Old: oldValue, New: newValue}
+		}
+	}
+
+	return changes
+}
+
+func comparableValue(value reflect.Value) interface{} {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if t, ok := value.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if value.String() == "" {
+			return nil
+		}
+		return value.String()
+	case reflect.Int, reflect.Int64:
+		return value.Int()
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
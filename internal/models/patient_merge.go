@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MergeStatusMerged   = "merged"
+	MergeStatusUnmerged = "unmerged"
+)
+
+// PatientMerge records one merge of a retired HN into a surviving HN in the same
+// hospital. FilledFields holds the survivor columns that were empty and copied from
+// the retired record, so an unmerge can clear them again. ChainedIDs are the
+// patients that had been merged into the retired record and were redirected to
+// the survivor, and RegroupedIDs those moved from the retired record's
+// enterprise group to SurvivorEnterprise; an unmerge moves them back.
+type PatientMerge struct {
+	ID                 int        `json:"id" gorm:"primaryKey;column:id"`
+	Hospital           string     `json:"hospital" gorm:"index;column:hospital"`
+	SurvivorID         int        `json:"survivor_id" gorm:"index;column:survivor_id"`
+	SurvivorHN         string     `json:"survivor_hn" gorm:"column:survivor_hn"`
+	RetiredID          int        `json:"retired_id" gorm:"index;column:retired_id"`
+	RetiredHN          string     `json:"retired_hn" gorm:"column:retired_hn"`
+	RetiredEnterprise  *string    `json:"retired_enterprise_id,omitempty" gorm:"column:retired_enterprise_id"`
+	SurvivorEnterprise *string    `json:"survivor_enterprise_id,omitempty" gorm:"column:survivor_enterprise_id"`
+	FilledFields       string     `json:"filled_fields,omitempty" gorm:"type:text;column:filled_fields"`
+	ChainedIDs         []int      `json:"chained_ids,omitempty" gorm:"type:text;serializer:json;column:chained_ids"`
+	RegroupedIDs       []int      `json:"regrouped_ids,omitempty" gorm:"type:text;serializer:json;column:regrouped_ids"`
+	Reason             string     `json:"reason" gorm:"column:reason"`
+	Status             string     `json:"status" gorm:"column:status"`
+	MergedBy           int        `json:"merged_by" gorm:"column:merged_by"`
+	MergedAt           time.Time  `json:"merged_at" gorm:"column:merged_at"`
+	UnmergedBy         *int       `json:"unmerged_by,omitempty" gorm:"column:unmerged_by"`
+	UnmergedAt         *time.Time `json:"unmerged_at,omitempty" gorm:"column:unmerged_at"`
+}
+
+func (PatientMerge) TableName() string {
+	return "patient_merge"
+}
+
+type MergePatientRequest struct {
+	SurvivorHN string `json:"survivor_hn" binding:"required" example:"HN001"`
+	RetiredHN  string `json:"retired_hn" binding:"required" example:"HN000001"`
+	Reason     string `json:"reason" binding:"required,max=500" example:"Duplicate walk-in registration"`
+}
@@ -4,6 +4,21 @@ import (
 	"time"
 )
 
+// Roles with access beyond the staff member's own hospital. They cannot be
+// chosen when registering; an Admin grants them with PUT /staff/{id}/role, and
+// STAFF_BOOTSTRAP_ADMINS promotes the first Admins at startup.
+const (
+	RoleAdmin       = "Admin"
+	RoleDataSteward = "DataSteward"
+)
+
+// Roles staff may register with.
+const (
+	RoleDoctor = "Doctor"
+	RoleNurse  = "Nurse"
+	RoleStaff  = "Staff"
+)
+
 type Staff struct {
 	ID           int       `json:"id" gorm:"primaryKey;column:id"`
 	EmployeeID   string    `json:"employee_id" gorm:"uniqueIndex;column:employee_id"`
@@ -33,11 +48,15 @@ type CreateStaffRequest struct {
 	LastName    string  `json:"last_name" binding:"required" example:"Doe"`
 	Email       string  `json:"email" binding:"required,email" example:"john.doe@hospital.com"`
 	PhoneNumber *string `json:"phone_number,omitempty" example:"0891234567"`
-	Role        string  `json:"role" binding:"required" example:"Doctor"`
+	Role        string  `json:"role" binding:"required,oneof=Doctor Nurse Staff" example:"Doctor"`
 	Department  *string `json:"department,omitempty" example:"Cardiology"`
 	Hospital    string  `json:"hospital" binding:"required" example:"Hospital A"`
 }
 
+type UpdateStaffRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=Doctor Nurse Staff DataSteward Admin" example:"DataSteward"`
+}
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required" example:"doctor1"`
 	Password string `json:"password" binding:"required" example:"password123"`
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// SavedSearch is a named patient search kept by a staff member so it can be re-run.
+type SavedSearch struct {
+	ID        int                  `json:"id" gorm:"primaryKey;column:id"`
+	StaffID   int                  `json:"staff_id" gorm:"uniqueIndex:idx_saved_search_staff_name;column:staff_id"`
+	Hospital  string               `json:"hospital" gorm:"column:hospital"`
+	Name      string               `json:"name" gorm:"uniqueIndex:idx_saved_search_staff_name;column:name"`
+	Criteria  PatientSearchRequest `json:"criteria" gorm:"type:text;serializer:json;column:criteria"`
+	CreatedAt time.Time            `json:"created_at" gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time            `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (SavedSearch) TableName() string {
+	return "saved_search"
+}
+
+type SaveSearchRequest struct {
+	Name     string               `json:"name" binding:"required,max=100" example:"Cardiology follow-ups"`
+	Criteria PatientSearchRequest `json:"criteria"`
+}
+
+// RecentPatient records when a staff member last opened a patient.
+type RecentPatient struct {
+	ID        int       `json:"id" gorm:"primaryKey;column:id"`
+	StaffID   int       `json:"staff_id" gorm:"uniqueIndex:idx_recent_patient_staff_patient;index:idx_recent_patient_staff_hospital;column:staff_id"`
+	Hospital  string    `json:"hospital" gorm:"index:idx_recent_patient_staff_hospital;column:hospital"`
+	PatientID int       `json:"patient_id" gorm:"uniqueIndex:idx_recent_patient_staff_patient;index;column:patient_id"`
+	ViewedAt  time.Time `json:"viewed_at" gorm:"column:viewed_at"`
+}
+
+func (RecentPatient) TableName() string {
+	return "recent_patient"
+}
+
+type RecentPatientEntry struct {
+	ViewedAt time.Time `json:"viewed_at"`
+	Patient  *Patient  `json:"patient"`
+}
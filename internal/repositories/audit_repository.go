@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) CreateAuditLog(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *AuditRepository) ListAuditLogs(action string, hospital string, limit int, offset int) ([]*models.AuditLog, error) {
+	var entries []*models.AuditLog
+	query := r.db.Order("created_at DESC, id DESC")
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if hospital != "" {
+		query = query.Where("hospital = ?", hospital)
+	}
+
+	result := query.Limit(limit).Offset(offset).Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrHISEventExists = errors.New("HIS event already handled")
+
+type HISEventRepository struct {
+	db *gorm.DB
+}
+
+func NewHISEventRepository(db *gorm.DB) *HISEventRepository {
+	return &HISEventRepository{db: db}
+}
+
+// ClaimEvent records an event as handled. It returns ErrHISEventExists when
+// the hospital already sent an event with the same ID, including one being
+// handled right now.
+func (r *HISEventRepository) ClaimEvent(record *models.HISEventRecord) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHISEventExists
+	}
+	return nil
+}
+
+// ReleaseEvent forgets a claimed event that could not be applied, so a
+// redelivery is handled again.
+func (r *HISEventRepository) ReleaseEvent(record *models.HISEventRecord) error {
+	return r.db.Delete(record).Error
+}
+
+func (r *HISEventRepository) CreateDelivery(delivery *models.HISEventDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// ListDeliveries returns deliveries newest first, optionally of one hospital
+// and with one status.
+func (r *HISEventRepository) ListDeliveries(hospital string, status string, limit int, offset int) ([]*models.HISEventDelivery, error) {
+	query := r.db.Order("created_at DESC, id DESC").Limit(limit).Offset(offset)
+	if hospital != "" {
+		query = query.Where("hospital = ?", hospital)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []*models.HISEventDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// PurgeBefore removes deliveries and handled event IDs older than cutoff. It
+// returns the number of deliveries removed.
+func (r *HISEventRepository) PurgeBefore(cutoff time.Time) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("created_at < ?", cutoff).Delete(&models.HISEventDelivery{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+
+		return tx.Where("received_at < ?", cutoff).Delete(&models.HISEventRecord{}).Error
+	})
+	return purged, err
+}
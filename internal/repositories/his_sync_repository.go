@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type HISSyncRepository struct {
+	db *gorm.DB
+}
+
+func NewHISSyncRepository(db *gorm.DB) *HISSyncRepository {
+	return &HISSyncRepository{db: db}
+}
+
+func (r *HISSyncRepository) GetCheckpoint(source string) (*models.HISSyncCheckpoint, error) {
+	var checkpoint models.HISSyncCheckpoint
+	if err := r.db.Where("source = ?", source).First(&checkpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+func (r *HISSyncRepository) ListCheckpoints() ([]*models.HISSyncCheckpoint, error) {
+	var checkpoints []*models.HISSyncCheckpoint
+	if err := r.db.Order("source").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+func (r *HISSyncRepository) SaveCheckpoint(checkpoint *models.HISSyncCheckpoint) error {
+	return r.db.Save(checkpoint).Error
+}
+
+// SaveBatch upserts a page of patients from the change feed and saves the
+// checkpoint in one transaction. Erased and deleted patients are left alone.
+// Each patient is saved in its own savepoint: one that fails is skipped,
+// counted in PatientsSkipped and reported in LastError, so a bad record
+// cannot hold the feed back. It returns the patients saved.
+func (r *HISSyncRepository) SaveBatch(patients []*models.Patient, checkpoint *models.HISSyncCheckpoint) ([]*models.Patient, error) {
+	saved := make([]*models.Patient, 0, len(patients))
+	synced, skipped, lastError := checkpoint.PatientsSynced, checkpoint.PatientsSkipped, checkpoint.LastError
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, patient := range patients {
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return upsertPatient(tx, patient, models.PatientChange{Source: models.ChangeSourceHIS})
+			})
+			if errors.Is(err, ErrPatientErased) || errors.Is(err, ErrPatientDeleted) {
+				continue
+			}
+			if err != nil {
+				checkpoint.PatientsSkipped++
+				checkpoint.LastError = fmt.Sprintf("skipped patient %s of %s: %v", patient.PatientHN, patient.Hospital, err)
+				continue
+			}
+			saved = append(saved, patient)
+		}
+
+		checkpoint.PatientsSynced = synced + int64(len(saved))
+		return tx.Save(checkpoint).Error
+	})
+	if err != nil {
+		checkpoint.PatientsSynced, checkpoint.PatientsSkipped, checkpoint.LastError = synced, skipped, lastError
+		return nil, err
+	}
+
+	return saved, nil
+}
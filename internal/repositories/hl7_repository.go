@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type HL7Repository struct {
+	db *gorm.DB
+}
+
+func NewHL7Repository(db *gorm.DB) *HL7Repository {
+	return &HL7Repository{db: db}
+}
+
+func (r *HL7Repository) CreateDeadLetter(deadLetter *models.HL7DeadLetter) error {
+	return r.db.Create(deadLetter).Error
+}
+
+func (r *HL7Repository) ListDeadLetters(limit int, offset int) ([]*models.HL7DeadLetter, error) {
+	var deadLetters []*models.HL7DeadLetter
+	result := r.db.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&deadLetters)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return deadLetters, nil
+}
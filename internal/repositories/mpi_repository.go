@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"database/sql"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MPIRepository struct {
+	db *gorm.DB
+}
+
+func NewMPIRepository(db *gorm.DB) *MPIRepository {
+	return &MPIRepository{db: db}
+}
+
+// maxMatchCandidates bounds how many registrations one patient is scored
+// against, so a common name and date of birth cannot load a whole hospital.
+const maxMatchCandidates = 50
+
+// FindMatchCandidates returns active registrations in other hospitals that
+// share an identifier with the patient, or the date of birth together with a
+// first or last name. Merged and erased registrations are left out.
+func (r *MPIRepository) FindMatchCandidates(patient *models.Patient) ([]*models.Patient, error) {
+	var candidates []*models.Patient
+	query := r.db.Model(&models.Patient{}).
+		Where("hospital <> ? AND merged_into_id IS NULL AND erased_at IS NULL", patient.Hospital)
+
+	conditions := r.db.Where("1 = 0")
+	if patient.NationalID != nil {
+		conditions = conditions.Or("national_id = ?", *patient.NationalID)
+	}
+	if patient.PassportID != nil {
+		conditions = conditions.Or("passport_id = ?", *patient.PassportID)
+	}
+	// Names are only a block here; spelling differences are scored later
+	names := r.db.Where("1 = 0")
+	columns := []string{"first_name_en", "last_name_en", "first_name_th", "last_name_th"}
+	for i, name := range []*string{patient.FirstNameEN, patient.LastNameEN, patient.FirstNameTH, patient.LastNameTH} {
+		if name != nil {
+			names = names.Or("LOWER("+columns[i]+") = LOWER(?)", *name)
+		}
+	}
+	conditions = conditions.Or(r.db.Where("date_of_birth = ?", patient.DateOfBirth).Where(names))
+
+	result := query.Where(conditions).Order("id").Limit(maxMatchCandidates).Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return candidates, nil
+}
+
+func (r *MPIRepository) GetPatientByID(id int) (*models.Patient, error) {
+	patient := &models.Patient{}
+	result := r.db.First(patient, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return patient, nil
+}
+
+func (r *MPIRepository) SetEnterpriseID(patientID int, enterpriseID string) error {
+	return r.db.Model(&models.Patient{}).
+		Where("id = ?", patientID).
+		UpdateColumn("enterprise_id", enterpriseID).Error
+}
+
+// LinkCandidate joins every registration of the candidate's enterprise patient
+// to target, gives the patient target if it has no enterprise ID yet, and saves
+// the reviewed candidate, all in one transaction.
+func (r *MPIRepository) LinkCandidate(candidate *models.PatientMatchCandidate, target string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if candidate.Patient.EnterpriseID == nil {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", candidate.Patient.ID).
+				UpdateColumn("enterprise_id", target).Error; err != nil {
+				return err
+			}
+		}
+
+		if candidate.Candidate.EnterpriseID == nil {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", candidate.Candidate.ID).
+				UpdateColumn("enterprise_id", target).Error; err != nil {
+				return err
+			}
+		} else if *candidate.Candidate.EnterpriseID != target {
+			if err := tx.Model(&models.Patient{}).Where("enterprise_id = ?", *candidate.Candidate.EnterpriseID).
+				UpdateColumn("enterprise_id", target).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Omit(clause.Associations).Save(candidate).Error
+	})
+}
+
+func (r *MPIRepository) GetPatientsByEnterpriseID(enterpriseID string) ([]*models.Patient, error) {
+	var patients []*models.Patient
+	result := r.db.Where("enterprise_id = ?", enterpriseID).Order("hospital, patient_hn").Find(&patients)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return patients, nil
+}
+
+func (r *MPIRepository) GetPatientsWithoutEnterpriseID(limit int) ([]*models.Patient, error) {
+	var patients []*models.Patient
+	result := r.db.Where("enterprise_id IS NULL").Order("id").Limit(limit).Find(&patients)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return patients, nil
+}
+
+// UpsertCandidate records a candidate pair. Pairs that were already reviewed keep
+// their status; only the score and reasons are refreshed.
+func (r *MPIRepository) UpsertCandidate(candidate *models.PatientMatchCandidate) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "patient_id"}, {Name: "candidate_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "reasons", "updated_at"}),
+	}).Create(candidate).Error
+}
+
+func (r *MPIRepository) GetCandidatePair(patientID int, candidateID int) (*models.PatientMatchCandidate, error) {
+	candidate := &models.PatientMatchCandidate{}
+	result := r.db.Where("patient_id = ? AND candidate_id = ?", patientID, candidateID).First(candidate)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return candidate, nil
+}
+
+func (r *MPIRepository) GetCandidate(id int) (*models.PatientMatchCandidate, error) {
+	candidate := &models.PatientMatchCandidate{}
+	result := r.db.Preload("Patient").Preload("Candidate").First(candidate, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return candidate, nil
+}
+
+func (r *MPIRepository) ListCandidates(status string, limit int, offset int) ([]*models.PatientMatchCandidate, error) {
+	var candidates []*models.PatientMatchCandidate
+	query := r.db.Preload("Patient").Preload("Candidate").Order("score DESC, id")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	result := query.Limit(limit).Offset(offset).Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return candidates, nil
+}
+
+func (r *MPIRepository) SaveCandidate(candidate *models.PatientMatchCandidate) error {
+	return r.db.Omit(clause.Associations).Save(candidate).Error
+}
+
+func (r *MPIRepository) CountStats() (*models.MPIStatsResponse, error) {
+	stats := &models.MPIStatsResponse{}
+
+	// Merged and erased registrations are no longer separate people
+	active := func() *gorm.DB {
+		return r.db.Model(&models.Patient{}).Where("merged_into_id IS NULL AND erased_at IS NULL")
+	}
+
+	if err := active().Count(&stats.Registrations).Error; err != nil {
+		return nil, err
+	}
+
+	// Registrations that were never indexed still count as one person each
+	var unindexed int64
+	if err := active().Where("enterprise_id IS NULL").Count(&unindexed).Error; err != nil {
+		return nil, err
+	}
+	if err := active().Where("enterprise_id IS NOT NULL").
+		Distinct("enterprise_id").Count(&stats.People).Error; err != nil {
+		return nil, err
+	}
+	stats.People += unindexed
+
+	if err := r.db.Model(&models.PatientMatchCandidate{}).
+		Where("status = ?", models.MatchStatusPending).Count(&stats.Pending).Error; err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PatientHistoryRepository only reads history. Entries are appended by the
+// repositories that change patients, inside the same transaction as the change.
+type PatientHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientHistoryRepository(db *gorm.DB) *PatientHistoryRepository {
+	return &PatientHistoryRepository{db: db}
+}
+
+func (r *PatientHistoryRepository) ListHistory(patientID int, limit int, offset int) ([]*models.PatientHistory, error) {
+	var history []*models.PatientHistory
+	result := r.db.Where("patient_id = ?", patientID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&history)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return history, nil
+}
+
+// recordPatientHistory appends the diff between before and after. before is nil
+// for a newly created patient. Updates that change nothing are not recorded.
+func recordPatientHistory(tx *gorm.DB, before *models.Patient, after *models.Patient, change models.PatientChange) error {
+	changes := models.DiffPatients(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	action := models.HistoryActionUpdate
+	if before == nil {
+		action = models.HistoryActionCreate
+	}
+
+	return tx.Create(&models.PatientHistory{
+		PatientID: after.ID,
+		PatientHN: after.PatientHN,
+		Hospital:  after.Hospital,
+		Action:    action,
+		Source:    change.Source,
+		ActorID:   change.ActorID,
+		Changes:   changes,
+	}).Error
+}
+
+func loadPatient(tx *gorm.DB, id int) (*models.Patient, error) {
+	patient := &models.Patient{}
+	if err := tx.First(patient, id).Error; err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPatientMerged is returned when a patient taking part in a merge or unmerge
+// has been merged into another patient in the meantime.
+var ErrPatientMerged = errors.New("patient has already been merged")
+
+type PatientMergeRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientMergeRepository(db *gorm.DB) *PatientMergeRepository {
+	return &PatientMergeRepository{db: db}
+}
+
+// MergePatients retires merge.RetiredID into merge.SurvivorID in one transaction:
+// the survivor gets survivorUpdates, the retired HN (and anything already merged
+// into it) is redirected to the survivor, dependent rows are moved over and the
+// merge is recorded with what was moved, so it can be reversed.
+func (r *PatientMergeRepository) MergePatients(merge *models.PatientMerge, survivorUpdates map[string]interface{}) error {
+	change := models.PatientChange{Source: models.ChangeSourceMerge, ActorID: &merge.MergedBy}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnmergedPatient(tx, merge.SurvivorID); err != nil {
+			return err
+		}
+		survivorBefore, err := loadPatient(tx, merge.SurvivorID)
+		if err != nil {
+			return err
+		}
+		retiredBefore, err := loadPatient(tx, merge.RetiredID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND merged_into_id IS NULL", merge.RetiredID).
+			UpdateColumn("merged_into_id", merge.SurvivorID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPatientMerged
+		}
+
+		if len(survivorUpdates) > 0 {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", merge.SurvivorID).Updates(survivorUpdates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := recordPatientChanges(tx, change, survivorBefore, retiredBefore); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Patient{}).Where("merged_into_id = ?", merge.RetiredID).
+			Pluck("id", &merge.ChainedIDs).Error; err != nil {
+			return err
+		}
+		if len(merge.ChainedIDs) > 0 {
+			if err := tx.Model(&models.Patient{}).
+				Where("id IN ?", merge.ChainedIDs).
+				UpdateColumn("merged_into_id", merge.SurvivorID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := moveDependentRows(tx, merge.RetiredID, merge.SurvivorID); err != nil {
+			return err
+		}
+
+		merge.SurvivorEnterprise = survivorBefore.EnterpriseID
+		if merge.RetiredEnterprise != nil && merge.SurvivorEnterprise != nil && *merge.RetiredEnterprise != *merge.SurvivorEnterprise {
+			if err := tx.Model(&models.Patient{}).
+				Where("enterprise_id = ? AND id <> ?", *merge.RetiredEnterprise, merge.RetiredID).
+				Pluck("id", &merge.RegroupedIDs).Error; err != nil {
+				return err
+			}
+			if len(merge.RegroupedIDs) > 0 {
+				if err := tx.Model(&models.Patient{}).
+					Where("id IN ?", merge.RegroupedIDs).
+					UpdateColumn("enterprise_id", *merge.SurvivorEnterprise).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Create(merge).Error
+	})
+}
+
+// lockUnmergedPatient checks that the patient has not been merged into another
+// one. The no-op update locks its row until the transaction ends, so a
+// concurrent merge cannot retire it in between.
+func lockUnmergedPatient(tx *gorm.DB, id int) error {
+	result := tx.Model(&models.Patient{}).
+		Where("id = ? AND merged_into_id IS NULL", id).
+		UpdateColumn("merged_into_id", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPatientMerged
+	}
+	return nil
+}
+
+// recordPatientChanges reloads each patient and appends its history entry.
+func recordPatientChanges(tx *gorm.DB, change models.PatientChange, befores ...*models.Patient) error {
+	for _, before := range befores {
+		after, err := loadPatient(tx, before.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordPatientHistory(tx, before, after, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDependentRows points rows in tables that reference a patient at the survivor.
+func moveDependentRows(tx *gorm.DB, retiredID int, survivorID int) error {
+	// Match candidates of the retired record are moot once it is merged
+	return tx.Where("patient_id = ? OR candidate_id = ?", retiredID, retiredID).
+		Delete(&models.PatientMatchCandidate{}).Error
+}
+
+// UnmergePatients reverses a merge: the retired HN becomes a patient of its own
+// again, survivor columns in survivorClears are reset, and patients the merge
+// redirected or regrouped go back to the retired record unless they were
+// changed since.
+func (r *PatientMergeRepository) UnmergePatients(merge *models.PatientMerge, survivorClears map[string]interface{}, staffID int) error {
+	change := models.PatientChange{Source: models.ChangeSourceUnmerge, ActorID: &staffID}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnmergedPatient(tx, merge.SurvivorID); err != nil {
+			return err
+		}
+		survivorBefore, err := loadPatient(tx, merge.SurvivorID)
+		if err != nil {
+			return err
+		}
+		retiredBefore, err := loadPatient(tx, merge.RetiredID)
+		if err != nil {
+			return err
+		}
+
+		retiredUpdates := map[string]interface{}{"merged_into_id": nil}
+		if merge.RetiredEnterprise != nil {
+			retiredUpdates["enterprise_id"] = *merge.RetiredEnterprise
+		}
+
+		if len(survivorClears) > 0 {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", merge.SurvivorID).Updates(survivorClears).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND merged_into_id = ?", merge.RetiredID, merge.SurvivorID).
+			UpdateColumns(retiredUpdates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("retired patient is no longer merged into the survivor")
+		}
+
+		if len(merge.ChainedIDs) > 0 {
+			if err := tx.Model(&models.Patient{}).
+				Where("id IN ? AND merged_into_id = ?", merge.ChainedIDs, merge.SurvivorID).
+				UpdateColumn("merged_into_id", merge.RetiredID).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(merge.RegroupedIDs) > 0 && merge.RetiredEnterprise != nil && merge.SurvivorEnterprise != nil {
+			if err := tx.Model(&models.Patient{}).
+				Where("id IN ? AND enterprise_id = ?", merge.RegroupedIDs, *merge.SurvivorEnterprise).
+				UpdateColumn("enterprise_id", *merge.RetiredEnterprise).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := recordPatientChanges(tx, change, survivorBefore, retiredBefore); err != nil {
+			return err
+		}
+
+		unmergedAt := time.Now()
+		merge.Status = models.MergeStatusUnmerged
+		merge.UnmergedBy = &staffID
+		merge.UnmergedAt = &unmergedAt
+
+		return tx.Save(merge).Error
+	})
+}
+
+func (r *PatientMergeRepository) GetMerge(id int, hospital string) (*models.PatientMerge, error) {
+	merge := &models.PatientMerge{}
+	result := r.db.Where("id = ? AND hospital = ?", id, hospital).First(merge)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return merge, nil
+}
+
+func (r *PatientMergeRepository) ListMerges(hospital string, hn string) ([]*models.PatientMerge, error) {
+	var merges []*models.PatientMerge
+	query := r.db.Where("hospital = ?", hospital)
+	if hn != "" {
+		query = query.Where("(survivor_hn = ? OR retired_hn = ?)", hn, hn)
+	}
+
+	result := query.Order("merged_at DESC").Find(&merges)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return merges, nil
+}
@@ -2,12 +2,31 @@ package repositories
 
 import (
 	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/utils"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrPatientErased is returned when HIS data would re-create a patient whose
+// personal data was erased.
+var ErrPatientErased = errors.New("patient has been erased")
+
+// ErrDuplicateIdentifier is returned when a local registration or edit would
+// give the patient a national ID or passport ID another patient of the
+// hospital already has. Patients from HIS are saved as HIS has them.
+var ErrDuplicateIdentifier = errors.New("national_id or passport_id already registered")
+
+// ErrPatientDeleted is returned when HIS data would bring back a soft deleted
+// patient. It stays deleted until it is purged.
+var ErrPatientDeleted = errors.New("patient has been deleted")
+
 type PatientRepository struct {
 	db *gorm.DB
 }
@@ -16,8 +35,33 @@ func NewPatientRepository(db *gorm.DB) *PatientRepository {
 	return &PatientRepository{db: db}
 }
 
-func (r *PatientRepository) UpsertPatient(patient *models.Patient) error {
-	result := r.db.Where("patient_hn = ? AND hospital = ?", patient.PatientHN, patient.Hospital).
+func (r *PatientRepository) UpsertPatient(patient *models.Patient, change models.PatientChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return upsertPatient(tx, patient, change)
+	})
+}
+
+func upsertPatient(tx *gorm.DB, patient *models.Patient, change models.PatientChange) error {
+	var before *models.Patient
+	existing := &models.Patient{}
+	err := tx.Unscoped().Where("patient_hn = ? AND hospital = ?", patient.PatientHN, patient.Hospital).First(existing).Error
+	if err == nil {
+		before = existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if before != nil && before.ErasedAt != nil {
+		return ErrPatientErased
+	}
+
+	// A deleted patient stays deleted even while HIS still serves it, so a
+	// delete or a pending erasure cannot be undone by the next HIS lookup
+	if before != nil && before.DeletedAt.Valid {
+		return ErrPatientDeleted
+	}
+
+	result := tx.Where("patient_hn = ? AND hospital = ?", patient.PatientHN, patient.Hospital).
 		Assign(*patient).
 		FirstOrCreate(patient)
 
@@ -25,7 +69,161 @@ func (r *PatientRepository) UpsertPatient(patient *models.Patient) error {
 		return result.Error
 	}
 
-	return nil
+	return recordPatientHistory(tx, before, patient, change)
+}
+
+// CreatePatient allocates the next hospital number for the patient's hospital and
+// inserts the patient in the same transaction.
+func (r *PatientRepository) CreatePatient(patient *models.Patient, hnPrefix string, change models.PatientChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for {
+			hn, err := nextHN(tx, patient.Hospital, hnPrefix)
+			if err != nil {
+				return err
+			}
+
+			// HNs that came from HIS share the namespace, so skip any that are taken
+			var count int64
+			if err := tx.Unscoped().Model(&models.Patient{}).
+				Where("patient_hn = ? AND hospital = ?", hn, patient.Hospital).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				patient.PatientHN = hn
+				break
+			}
+		}
+
+		// nextHN locked the hospital's sequence row, so registrations racing
+		// past the service's check are serialized here
+		taken, err := identifierTaken(tx, patient.NationalID, patient.PassportID, patient.Hospital, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateIdentifier
+		}
+
+		if err := tx.Create(patient).Error; err != nil {
+			return err
+		}
+
+		return recordPatientHistory(tx, nil, patient, change)
+	})
+}
+
+func nextHN(tx *gorm.DB, hospital string, prefix string) (string, error) {
+	sequence, err := lockHNSequence(tx, hospital)
+	if err != nil {
+		return "", err
+	}
+
+	sequence.LastValue++
+	if err := tx.Model(sequence).Update("last_value", sequence.LastValue).Error; err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%06d", prefix, sequence.LastValue), nil
+}
+
+// lockHNSequence locks the HN sequence row of the hospital until the
+// transaction ends, creating it first if needed. Holding it serializes local
+// registrations and identifier changes of the hospital.
+func lockHNSequence(tx *gorm.DB, hospital string) (*models.HNSequence, error) {
+	// Concurrent first registrations of a hospital both insert; one is a no-op
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.HNSequence{Hospital: hospital}).Error; err != nil {
+		return nil, err
+	}
+
+	sequence := &models.HNSequence{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("hospital = ?", hospital).First(sequence).Error; err != nil {
+		return nil, err
+	}
+	return sequence, nil
+}
+
+// UpdatePatient applies updates only if the row still carries expectedUpdatedAt.
+// It returns false when the row was changed (or removed) in the meantime.
+func (r *PatientRepository) UpdatePatient(patient *models.Patient, updates map[string]interface{}, expectedUpdatedAt time.Time, change models.PatientChange) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := loadPatient(tx, patient.ID)
+		if err != nil {
+			return err
+		}
+
+		_, nationalID := updates["national_id"]
+		_, passportID := updates["passport_id"]
+		if nationalID || passportID {
+			if _, err := lockHNSequence(tx, patient.Hospital); err != nil {
+				return err
+			}
+			taken, err := identifierTaken(tx, patient.NationalID, patient.PassportID, patient.Hospital, patient.ID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrDuplicateIdentifier
+			}
+		}
+
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND updated_at = ?", patient.ID, expectedUpdatedAt).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.First(patient, patient.ID).Error; err != nil {
+			return err
+		}
+		updated = true
+
+		return recordPatientHistory(tx, before, patient, change)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return updated, nil
+}
+
+// ExistsByIdentifier reports whether a patient with the national ID or passport ID
+// is already registered in the hospital. excludeID skips the patient being updated;
+// records merged into another are not counted.
+func (r *PatientRepository) ExistsByIdentifier(nationalID *string, passportID *string, hospital string, excludeID int) (bool, error) {
+	return identifierTaken(r.db, nationalID, passportID, hospital, excludeID)
+}
+
+func identifierTaken(tx *gorm.DB, nationalID *string, passportID *string, hospital string, excludeID int) (bool, error) {
+	if (nationalID == nil || *nationalID == "") && (passportID == nil || *passportID == "") {
+		return false, nil
+	}
+
+	// Records retired by a merge keep their identifiers but are the survivor now
+	query := tx.Model(&models.Patient{}).Where("hospital = ? AND id <> ? AND merged_into_id IS NULL", hospital, excludeID)
+	switch {
+	case nationalID != nil && *nationalID != "" && passportID != nil && *passportID != "":
+		query = query.Where("(national_id = ? OR passport_id = ?)", *nationalID, *passportID)
+	case nationalID != nil && *nationalID != "":
+		query = query.Where("national_id = ?", *nationalID)
+	default:
+		query = query.Where("passport_id = ?", *passportID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
 }
 
 func (r *PatientRepository) GetPatientByHN(hn string, hospital string) (*models.Patient, error) {
@@ -42,9 +240,177 @@ func (r *PatientRepository) GetPatientByHN(hn string, hospital string) (*models.
 	return patient, nil
 }
 
+// ResolvePatientByHN works like GetPatientByHN but follows merges, so an HN that
+// was retired by a merge resolves to the surviving patient.
+func (r *PatientRepository) ResolvePatientByHN(hn string, hospital string) (*models.Patient, error) {
+	patient, err := r.GetPatientByHN(hn, hospital)
+	if err != nil {
+		return nil, err
+	}
+
+	if patient.MergedIntoID == nil {
+		return patient, nil
+	}
+
+	return r.GetPatientByID(*patient.MergedIntoID)
+}
+
+func (r *PatientRepository) GetPatientByID(id int) (*models.Patient, error) {
+	patient := &models.Patient{}
+	result := r.db.First(patient, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return patient, nil
+}
+
+// FindPatientsByIdentifiers loads every patient in the hospital whose HN, national
+// ID or passport ID is one of ids, in a single query. Merged patients are returned
+// as is; callers resolve them to the survivor.
+func (r *PatientRepository) FindPatientsByIdentifiers(ids []string, hospital string) ([]*models.Patient, error) {
+	var patients []*models.Patient
+	err := r.db.Where("hospital = ? AND (patient_hn IN ? OR national_id IN ? OR passport_id IN ?)", hospital, ids, ids, ids).
+		Find(&patients).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return patients, nil
+}
+
+func (r *PatientRepository) GetPatientsByIDs(ids []int) ([]*models.Patient, error) {
+	var patients []*models.Patient
+	if err := r.db.Where("id IN ?", ids).Find(&patients).Error; err != nil {
+		return nil, err
+	}
+
+	return patients, nil
+}
+
 func (r *PatientRepository) SearchPatients(req *models.PatientSearchRequest, hospital string) ([]*models.Patient, error) {
 	var patients []*models.Patient
-	query := r.db.Model(&models.Patient{}).Where("hospital = ?", hospital)
+
+	query, err := r.searchQuery(req, hospital)
+	if err != nil {
+		return nil, err
+	}
+
+	result := query.Find(&patients)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return patients, nil
+}
+
+// SearchPatientsAfter runs the same query as SearchPatients but returns at most
+// limit patients with an id greater than afterID, in id order.
+func (r *PatientRepository) SearchPatientsAfter(req *models.PatientSearchRequest, hospital string, afterID int, limit int) ([]*models.Patient, error) {
+	var patients []*models.Patient
+
+	query, err := r.searchQuery(req, hospital)
+	if err != nil {
+		return nil, err
+	}
+
+	result := query.Where("id > ?", afterID).Order("id").Limit(limit).Find(&patients)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return patients, nil
+}
+
+// SearchPatientsOffset runs the same query as SearchPatients but returns at
+// most limit patients after skipping offset, in id order, with the number of
+// patients the query matches in total.
+func (r *PatientRepository) SearchPatientsOffset(req *models.PatientSearchRequest, hospital string, offset int, limit int) ([]*models.Patient, int64, error) {
+	query, err := r.searchQuery(req, hospital)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	patients := []*models.Patient{}
+	if limit == 0 || int64(offset) >= total {
+		return patients, total, nil
+	}
+
+	query, err = r.searchQuery(req, hospital)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id").Offset(offset).Limit(limit).Find(&patients).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return patients, total, nil
+}
+
+// StreamPatients runs the same query as SearchPatients but hands each row to fn
+// as it is read from the database cursor, so large result sets are never held in
+// memory. It stops at the first error returned by fn; cancelling ctx cancels the
+// query.
+func (r *PatientRepository) StreamPatients(ctx context.Context, req *models.PatientSearchRequest, hospital string, fn func(*models.Patient) error) error {
+	query, err := r.searchQuery(req, hospital)
+	if err != nil {
+		return err
+	}
+
+	rows, err := query.WithContext(ctx).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var patient models.Patient
+		if err := r.db.ScanRows(rows, &patient); err != nil {
+			return err
+		}
+		if err := fn(&patient); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// patientFilterFields are the fields that may be used in the q filter.
+var patientFilterFields = map[string]utils.FilterField{
+	"hn":            {Columns: []string{"patient_hn"}},
+	"national_id":   {Columns: []string{"national_id"}},
+	"passport_id":   {Columns: []string{"passport_id"}},
+	"first_name":    {Columns: []string{"first_name_en", "first_name_th"}},
+	"middle_name":   {Columns: []string{"middle_name_en", "middle_name_th"}},
+	"last_name":     {Columns: []string{"last_name_en", "last_name_th"}},
+	"dob":           {Columns: []string{"date_of_birth"}, Type: utils.FilterDate},
+	"date_of_birth": {Columns: []string{"date_of_birth"}, Type: utils.FilterDate},
+	"gender":        {Columns: []string{"gender"}},
+	"phone":         {Columns: []string{"phone_number"}},
+	"phone_number":  {Columns: []string{"phone_number"}},
+	"email":         {Columns: []string{"email"}},
+}
+
+// ValidatePatientFilter checks a q filter without running it.
+func ValidatePatientFilter(q string) error {
+	_, _, err := utils.CompileFilter(q, patientFilterFields)
+	return err
+}
+
+// searchQuery builds the search query. An invalid q filter is returned as a
+// *utils.FilterError.
+func (r *PatientRepository) searchQuery(req *models.PatientSearchRequest, hospital string) (*gorm.DB, error) {
+	query := r.db.Model(&models.Patient{}).Where("hospital = ? AND merged_into_id IS NULL", hospital)
 
 	if req.ID != nil && *req.ID != "" {
 		// ID can be either national_id or passport_id (per HIS API spec)
@@ -84,10 +450,13 @@ func (r *PatientRepository) SearchPatients(req *models.PatientSearchRequest, hos
 		query = query.Where("gender = ?", *req.Gender)
 	}
 
-	result := query.Find(&patients)
-	if result.Error != nil {
-		return nil, result.Error
+	if req.Q != nil && strings.TrimSpace(*req.Q) != "" {
+		condition, args, err := utils.CompileFilter(*req.Q, patientFilterFields)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
 	}
 
-	return patients, nil
+	return query, nil
 }
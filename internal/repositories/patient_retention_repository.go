@@ -0,0 +1,407 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// erasedText replaces personal data kept in free text, such as raw messages.
+const erasedText = "[erased]"
+
+type PatientRetentionRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientRetentionRepository(db *gorm.DB) *PatientRetentionRepository {
+	return &PatientRetentionRepository{db: db}
+}
+
+// SoftDeletePatient hides the patient from every lookup and records the deletion.
+func (r *PatientRetentionRepository) SoftDeletePatient(patient *models.Patient, actorID int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Patient{}, patient.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.PatientHistory{
+			PatientID: patient.ID,
+			PatientHN: patient.PatientHN,
+			Hospital:  patient.Hospital,
+			Action:    models.HistoryActionDelete,
+			Source:    models.ChangeSourceLocal,
+			ActorID:   &actorID,
+			Changes:   models.PatientChanges{},
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AuditLog{
+			Action:    models.AuditActionPatientDelete,
+			ActorID:   &actorID,
+			Hospital:  patient.Hospital,
+			PatientID: &patient.ID,
+			PatientHN: patient.PatientHN,
+			RowCount:  1,
+		}).Error
+	})
+}
+
+// PurgePatients permanently removes up to limit patients matching the scope,
+// together with their history and the personal data other tables hold about
+// them, and writes one audit entry per patient. It returns how many were
+// removed.
+func (r *PatientRetentionRepository) PurgePatients(scope func(*gorm.DB) *gorm.DB, reason string, limit int) (int, error) {
+	var patients []*models.Patient
+	if err := scope(r.db.Unscoped()).Where("erased_at IS NULL").Order("id").Limit(limit).Find(&patients).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, patient := range patients {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := scrubDependentRows(tx, patient); err != nil {
+				return err
+			}
+			if err := tx.Where("patient_id = ?", patient.ID).Delete(&models.PatientHistory{}).Error; err != nil {
+				return err
+			}
+			// Records merged into the patient would otherwise point at a missing
+			// row. Merges keep the group one level deep, so they are re-pointed
+			// to where the patient itself was merged, if anywhere.
+			if err := tx.Unscoped().Model(&models.Patient{}).Where("merged_into_id = ?", patient.ID).
+				UpdateColumn("merged_into_id", patient.MergedIntoID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.Patient{}, patient.ID).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&models.AuditLog{
+				Action:    models.AuditActionPatientPurge,
+				Hospital:  patient.Hospital,
+				PatientID: &patient.ID,
+				PatientHN: patient.PatientHN,
+				RowCount:  1,
+				Details:   reason,
+			}).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StaleCacheScope selects HIS-cached patients that were not re-fetched since cutoff.
+func StaleCacheScope(cutoff time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("last_fetched_at IS NOT NULL AND last_fetched_at < ? AND deleted_at IS NULL", cutoff)
+	}
+}
+
+// SoftDeletedScope selects patients that were soft deleted before cutoff.
+func SoftDeletedScope(cutoff time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+	}
+}
+
+// ErasePatient scrubs every piece of personal data about the patient. Merged
+// records hold the same person's data, so the whole merge group is erased: the
+// survivor and every record merged into it. The rows stay behind as tombstones
+// holding only the HN and hospital.
+func (r *PatientRetentionRepository) ErasePatient(request *models.PatientErasureRequest) error {
+	erasedAt := time.Now()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		patient := &models.Patient{}
+		if err := tx.Unscoped().Where("id = ? AND erased_at IS NULL", request.PatientID).First(patient).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("patient not found or already erased")
+			}
+			return err
+		}
+
+		// Merges re-point chained records to the final survivor, so the group
+		// is one level deep
+		survivorID := patient.ID
+		if patient.MergedIntoID != nil {
+			survivorID = *patient.MergedIntoID
+		}
+
+		// Identifiers are read before they are scrubbed, to find them elsewhere
+		var group []*models.Patient
+		if err := tx.Unscoped().Where("(id = ? OR merged_into_id = ?) AND erased_at IS NULL", survivorID, survivorID).
+			Order("id").Find(&group).Error; err != nil {
+			return err
+		}
+
+		for _, member := range group {
+			if err := erasePatientRow(tx, member, request.ReviewedBy, erasedAt); err != nil {
+				return err
+			}
+		}
+
+		request.Status = models.ErasureStatusErased
+		request.ErasedAt = &erasedAt
+		request.Error = ""
+		if err := tx.Save(request).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AuditLog{
+			Action:    models.AuditActionPatientErase,
+			ActorID:   request.ReviewedBy,
+			Hospital:  request.Hospital,
+			PatientID: &request.PatientID,
+			PatientHN: request.PatientHN,
+			RowCount:  len(group),
+		}).Error
+	})
+}
+
+// erasePatientRow nulls the patient's personal data, scrubs the rows that
+// refer to it and records the erasure in its history.
+func erasePatientRow(tx *gorm.DB, patient *models.Patient, actorID *int, erasedAt time.Time) error {
+	result := tx.Unscoped().Model(&models.Patient{}).
+		Where("id = ? AND erased_at IS NULL", patient.ID).
+		UpdateColumns(map[string]interface{}{
+			"national_id":    nil,
+			"passport_id":    nil,
+			"first_name_th":  nil,
+			"middle_name_th": nil,
+			"last_name_th":   nil,
+			"first_name_en":  nil,
+			"middle_name_en": nil,
+			"last_name_en":   nil,
+			"date_of_birth":  time.Time{},
+			"phone_number":   nil,
+			"email":          nil,
+			"gender":         "",
+			"enterprise_id":  nil,
+			"erased_at":      erasedAt,
+			"deleted_at":     erasedAt,
+			"updated_at":     erasedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("patient not found or already erased")
+	}
+
+	if err := scrubDependentRows(tx, patient); err != nil {
+		return err
+	}
+
+	return tx.Create(&models.PatientHistory{
+		PatientID: patient.ID,
+		PatientHN: patient.PatientHN,
+		Hospital:  patient.Hospital,
+		Action:    models.HistoryActionDelete,
+		Source:    models.ChangeSourceErasure,
+		ActorID:   actorID,
+		Changes:   models.PatientChanges{},
+	}).Error
+}
+
+// scrubDependentRows removes personal data that other tables hold about the
+// patient, found by patient ID or, in free text, by HN, national ID or passport
+// ID.
+func scrubDependentRows(tx *gorm.DB, patient *models.Patient) error {
+	// History diffs contain old values, so only the fact that a change happened is kept
+	if err := tx.Model(&models.PatientHistory{}).Where("patient_id = ?", patient.ID).
+		UpdateColumn("changes", models.PatientChanges{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("patient_id = ? OR candidate_id = ?", patient.ID, patient.ID).
+		Delete(&models.PatientMatchCandidate{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("patient_id = ?", patient.ID).Delete(&models.RecentPatient{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.PatientMerge{}).
+		Where("survivor_id = ? OR retired_id = ?", patient.ID, patient.ID).
+		UpdateColumn("filled_fields", "").Error; err != nil {
+		return err
+	}
+
+	identifiers := []string{patient.PatientHN}
+	for _, identifier := range []*string{patient.NationalID, patient.PassportID} {
+		if identifier != nil && *identifier != "" {
+			identifiers = append(identifiers, *identifier)
+		}
+	}
+
+	// Dead letters may not have been parsed, so they are matched by their text
+	// in every hospital
+	var deadLetters []*models.HL7DeadLetter
+	if err := tx.Where(containsAny(tx, identifiers, "raw_message", "error")).Find(&deadLetters).Error; err != nil {
+		return err
+	}
+	for _, deadLetter := range deadLetters {
+		if !mentionsIdentifier(deadLetter.RawMessage+"\n"+deadLetter.Error, identifiers) {
+			continue
+		}
+		if err := tx.Model(deadLetter).UpdateColumns(map[string]interface{}{
+			"raw_message": erasedText,
+			"error":       erasedText,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	var deliveries []*models.HISEventDelivery
+	if err := tx.Where("hospital = ?", patient.Hospital).
+		Where(tx.Where("patient_hn = ?", patient.PatientHN).Or(containsAny(tx, identifiers, "payload"))).
+		Find(&deliveries).Error; err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if delivery.PatientHN != patient.PatientHN && !mentionsIdentifier(delivery.Payload, identifiers) {
+			continue
+		}
+		if err := tx.Model(delivery).UpdateColumn("payload", erasedText).Error; err != nil {
+			return err
+		}
+	}
+
+	// Saved searches for the patient are removed rather than left with
+	// criteria that would match every patient
+	var searches []*models.SavedSearch
+	if err := tx.Where("hospital = ?", patient.Hospital).Where(containsAny(tx, identifiers, "criteria")).
+		Find(&searches).Error; err != nil {
+		return err
+	}
+	for _, search := range searches {
+		criteria, err := json.Marshal(search.Criteria)
+		if err != nil {
+			return err
+		}
+		if !mentionsIdentifier(string(criteria), identifiers) {
+			continue
+		}
+		if err := tx.Delete(search).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containsAny selects rows where any of the columns contains any of the values.
+// It may match values inside longer ones, so callers check matches with
+// mentionsIdentifier.
+func containsAny(tx *gorm.DB, values []string, columns ...string) *gorm.DB {
+	condition := tx.Where("1 = 0")
+	for _, value := range values {
+		for _, column := range columns {
+			condition = condition.Or(column+" LIKE ?", "%"+value+"%")
+		}
+	}
+	return condition
+}
+
+// mentionsIdentifier reports whether text holds one of the identifiers as a
+// whole token, so erasing HN001 leaves HN0010 alone.
+func mentionsIdentifier(text string, identifiers []string) bool {
+	for _, identifier := range identifiers {
+		for start := 0; ; {
+			index := strings.Index(text[start:], identifier)
+			if index < 0 {
+				break
+			}
+			index += start
+			end := index + len(identifier)
+			if (index == 0 || !isIdentifierChar(text[index-1])) && (end == len(text) || !isIdentifierChar(text[end])) {
+				return true
+			}
+			start = index + 1
+		}
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// GetErasablePatient finds a patient by HN that has not been erased yet,
+// including soft deleted patients.
+func (r *PatientRetentionRepository) GetErasablePatient(hn string, hospital string) (*models.Patient, error) {
+	patient := &models.Patient{}
+	result := r.db.Unscoped().Where("patient_hn = ? AND hospital = ? AND erased_at IS NULL", hn, hospital).First(patient)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return patient, nil
+}
+
+func (r *PatientRetentionRepository) CreateErasureRequest(request *models.PatientErasureRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *PatientRetentionRepository) SaveErasureRequest(request *models.PatientErasureRequest) error {
+	return r.db.Save(request).Error
+}
+
+func (r *PatientRetentionRepository) GetErasureRequest(id int) (*models.PatientErasureRequest, error) {
+	request := &models.PatientErasureRequest{}
+	result := r.db.First(request, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return request, nil
+}
+
+func (r *PatientRetentionRepository) ListErasureRequests(status string, hospital string, limit int) ([]*models.PatientErasureRequest, error) {
+	var requests []*models.PatientErasureRequest
+	query := r.db.Order("created_at, id")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if hospital != "" {
+		query = query.Where("hospital = ?", hospital)
+	}
+
+	result := query.Limit(limit).Find(&requests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return requests, nil
+}
+
+func (r *PatientRetentionRepository) HasOpenErasureRequest(patientID int) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.PatientErasureRequest{}).
+		Where("patient_id = ? AND status IN ?", patientID, []string{models.ErasureStatusPending, models.ErasureStatusApproved}).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
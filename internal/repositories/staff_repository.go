@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"agnos-middleware/internal/models"
+	"database/sql"
 	"errors"
 
 	"gorm.io/gorm"
@@ -85,3 +86,23 @@ func (r *StaffRepository) GetStaffByEmployeeID(employeeID string) (*models.Staff
 
 	return staff, nil
 }
+
+// GetStaffInHospital finds a staff member of the hospital by ID.
+func (r *StaffRepository) GetStaffInHospital(id int, hospital string) (*models.Staff, error) {
+	staff := &models.Staff{}
+
+	result := r.db.Where("id = ? AND hospital = ?", id, hospital).First(staff)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return staff, nil
+}
+
+func (r *StaffRepository) UpdateStaffRole(id int, role string) error {
+	return r.db.Model(&models.Staff{}).Where("id = ?", id).Update("role", role).Error
+}
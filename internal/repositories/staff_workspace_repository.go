@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"agnos-middleware/internal/models"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StaffWorkspaceRepository struct {
+	db *gorm.DB
+}
+
+func NewStaffWorkspaceRepository(db *gorm.DB) *StaffWorkspaceRepository {
+	return &StaffWorkspaceRepository{db: db}
+}
+
+func (r *StaffWorkspaceRepository) CreateSavedSearch(search *models.SavedSearch) error {
+	return r.db.Create(search).Error
+}
+
+func (r *StaffWorkspaceRepository) SavedSearchNameExists(staffID int, name string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.SavedSearch{}).Where("staff_id = ? AND name = ?", staffID, name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *StaffWorkspaceRepository) CountSavedSearches(staffID int) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.SavedSearch{}).Where("staff_id = ?", staffID).Count(&count).Error
+	return count, err
+}
+
+func (r *StaffWorkspaceRepository) ListSavedSearches(staffID int, hospital string) ([]*models.SavedSearch, error) {
+	var searches []*models.SavedSearch
+	result := r.db.Where("staff_id = ? AND hospital = ?", staffID, hospital).Order("name").Find(&searches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return searches, nil
+}
+
+func (r *StaffWorkspaceRepository) GetSavedSearch(id int, staffID int, hospital string) (*models.SavedSearch, error) {
+	search := &models.SavedSearch{}
+	result := r.db.Where("id = ? AND staff_id = ? AND hospital = ?", id, staffID, hospital).First(search)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, result.Error
+	}
+
+	return search, nil
+}
+
+func (r *StaffWorkspaceRepository) DeleteSavedSearch(id int, staffID int, hospital string) (bool, error) {
+	result := r.db.Where("id = ? AND staff_id = ? AND hospital = ?", id, staffID, hospital).Delete(&models.SavedSearch{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecordPatientView moves the patient to the top of the staff member's recent
+// list and trims the list for the hospital to limit entries.
+func (r *StaffWorkspaceRepository) RecordPatientView(staffID int, hospital string, patientID int, limit int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "staff_id"}, {Name: "patient_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"hospital", "viewed_at"}),
+		}).Create(&models.RecentPatient{
+			StaffID:   staffID,
+			Hospital:  hospital,
+			PatientID: patientID,
+			ViewedAt:  time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		keep := tx.Model(&models.RecentPatient{}).Select("id").
+			Where("staff_id = ? AND hospital = ?", staffID, hospital).
+			Order("viewed_at DESC, id DESC").Limit(limit)
+
+		return tx.Where("staff_id = ? AND hospital = ? AND id NOT IN (?)", staffID, hospital, keep).
+			Delete(&models.RecentPatient{}).Error
+	})
+}
+
+func (r *StaffWorkspaceRepository) ListRecentPatients(staffID int, hospital string, limit int) ([]*models.RecentPatient, error) {
+	var recent []*models.RecentPatient
+	result := r.db.Where("staff_id = ? AND hospital = ?", staffID, hospital).
+		Order("viewed_at DESC, id DESC").Limit(limit).Find(&recent)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return recent, nil
+}
@@ -4,13 +4,18 @@ import (
 	"agnos-middleware/internal/configs"
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/repositories"
+	"database/sql"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrStaffNotFound = errors.New("staff not found")
+
 type AuthService struct {
 	staffRepo *repositories.StaffRepository
 	config    *configs.ApplicationConfig
@@ -65,6 +70,53 @@ func (s *AuthService) CreateStaff(req *models.CreateStaffRequest) (*models.Staff
 	return staff, nil
 }
 
+// UpdateStaffRole gives a staff member of the caller's hospital a new role,
+// including the privileged Admin and DataSteward roles. Only Admins may call it.
+func (s *AuthService) UpdateStaffRole(id int, role string, staffHospital string) (*models.Staff, error) {
+	staff, err := s.staffRepo.GetStaffInHospital(id, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.staffRepo.UpdateStaffRole(staff.ID, role); err != nil {
+		return nil, err
+	}
+	staff.Role = role
+
+	return staff, nil
+}
+
+// PromoteBootstrapAdmins makes the staff in STAFF_BOOTSTRAP_ADMINS Admins, so
+// a new deployment has an Admin to grant other privileged roles. Anyone can
+// register while the deployment is open, so a staff member is only promoted
+// when both the username and the employee ID match. Usernames that are not
+// registered yet are skipped.
+func (s *AuthService) PromoteBootstrapAdmins() error {
+	for username, employeeID := range s.config.Staff.BootstrapAdmins {
+		staff, err := s.staffRepo.GetStaffByUsername(username)
+		if err != nil {
+			log.Printf("Bootstrap admin %q is not registered yet", username)
+			continue
+		}
+		if staff.EmployeeID != employeeID {
+			log.Printf("Bootstrap admin %q is registered with a different employee ID, not promoting", username)
+			continue
+		}
+		if strings.EqualFold(staff.Role, models.RoleAdmin) {
+			continue
+		}
+		if err := s.staffRepo.UpdateStaffRole(staff.ID, models.RoleAdmin); err != nil {
+			return err
+		}
+		log.Printf("Promoted bootstrap admin %q", username)
+	}
+
+	return nil
+}
+
 func (s *AuthService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
 	staff, err := s.staffRepo.GetStaffByUsername(req.Username)
 	if err != nil {
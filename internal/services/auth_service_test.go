@@ -4,6 +4,7 @@ import (
 	"agnos-middleware/internal/configs"
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/repositories"
+	"errors"
 	"testing"
 
 	"gorm.io/driver/sqlite"
@@ -253,3 +254,103 @@ func TestValidateToken_Negative_InvalidToken(t *testing.T) {
 		t.Error("Expected error for invalid token, got nil")
 	}
 }
+
+func TestPromoteBootstrapAdmins_Positive(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewStaffRepository(db)
+	config := &configs.ApplicationConfig{}
+	config.Staff.BootstrapAdmins = map[string]string{"testuser": "EMP001", "unregistered": "EMP999"}
+	service := NewAuthService(repo, config)
+
+	req := &models.CreateStaffRequest{
+		EmployeeID: "EMP001",
+		Username:   "testuser",
+		Password:   "password123",
+		FirstName:  "John",
+		LastName:   "Doe",
+		Email:      "john.doe@hospital.com",
+		Role:       "Doctor",
+		Hospital:   "Hospital A",
+	}
+	if _, err := service.CreateStaff(req); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if err := service.PromoteBootstrapAdmins(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	staff, err := repo.GetStaffByUsername("testuser")
+	if err != nil {
+		t.Fatalf("Expected staff, got: %v", err)
+	}
+	if staff.Role != models.RoleAdmin {
+		t.Errorf("Expected role %s, got %s", models.RoleAdmin, staff.Role)
+	}
+}
+
+func TestPromoteBootstrapAdmins_Negative_EmployeeIDMismatch(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewStaffRepository(db)
+	config := &configs.ApplicationConfig{}
+	config.Staff.BootstrapAdmins = map[string]string{"testuser": "EMP001"}
+	service := NewAuthService(repo, config)
+
+	req := &models.CreateStaffRequest{
+		EmployeeID: "EMP666",
+		Username:   "testuser",
+		Password:   "password123",
+		FirstName:  "John",
+		LastName:   "Doe",
+		Email:      "john.doe@hospital.com",
+		Role:       "Doctor",
+		Hospital:   "Hospital A",
+	}
+	if _, err := service.CreateStaff(req); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if err := service.PromoteBootstrapAdmins(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	staff, err := repo.GetStaffByUsername("testuser")
+	if err != nil {
+		t.Fatalf("Expected staff, got: %v", err)
+	}
+	if staff.Role != "Doctor" {
+		t.Errorf("Expected role Doctor, got %s", staff.Role)
+	}
+}
+
+func TestUpdateStaffRole_Negative_OtherHospital(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewStaffRepository(db)
+	service := NewAuthService(repo, &configs.ApplicationConfig{})
+
+	staff, err := service.CreateStaff(&models.CreateStaffRequest{
+		EmployeeID: "EMP001",
+		Username:   "testuser",
+		Password:   "password123",
+		FirstName:  "John",
+		LastName:   "Doe",
+		Email:      "john.doe@hospital.com",
+		Role:       "Doctor",
+		Hospital:   "Hospital A",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := service.UpdateStaffRole(staff.ID, models.RoleAdmin, "Hospital B"); !errors.Is(err, ErrStaffNotFound) {
+		t.Errorf("Expected ErrStaffNotFound, got: %v", err)
+	}
+
+	updated, err := service.UpdateStaffRole(staff.ID, models.RoleAdmin, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if updated.Role != models.RoleAdmin {
+		t.Errorf("Expected role %s, got %s", models.RoleAdmin, updated.Role)
+	}
+}
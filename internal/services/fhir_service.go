@@ -0,0 +1,305 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSearchParameter = errors.New("invalid search parameter")
+
+const fhirSearchMaxCount = 100
+
+// fhirSearchParams are the Patient search parameters supported by SearchPatients.
+// Parameters starting with an underscore other than _count and _offset are
+// ignored.
+var fhirSearchParams = []models.FHIRSearchParam{
+	{Name: "identifier", Type: "token", Documentation: "National ID, passport ID or hospital number, as system|value or value"},
+	{Name: "family", Type: "string", Documentation: "Family name in Thai or English"},
+	{Name: "given", Type: "string", Documentation: "Given name in Thai or English"},
+	{Name: "birthdate", Type: "date", Documentation: "Date of birth (YYYY-MM-DD, eq prefix only)"},
+	{Name: "gender", Type: "token", Documentation: "male or female"},
+}
+
+type FHIRService struct {
+	patientService *PatientService
+	config         *configs.ApplicationConfig
+}
+
+func NewFHIRService(patientService *PatientService, config *configs.ApplicationConfig) *FHIRService {
+	return &FHIRService{
+		patientService: patientService,
+		config:         config,
+	}
+}
+
+// ReadPatient returns the FHIR Patient whose logical id is the hospital number.
+func (s *FHIRService) ReadPatient(id string, staffHospital string) (*models.FHIRPatient, error) {
+	patient, err := s.patientService.GetPatientByHN(id, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToFHIRPatient(patient), nil
+}
+
+// SearchPatients runs a FHIR Patient search and returns a searchset Bundle.
+func (s *FHIRService) SearchPatients(params url.Values, staffHospital string) (*models.FHIRBundle, error) {
+	req := &models.PatientSearchRequest{}
+	count := fhirSearchMaxCount
+	offset := 0
+	criteria := 0
+
+	for name, values := range params {
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+		value := values[0]
+
+		switch name {
+		case "identifier":
+			system, code, hasSystem := strings.Cut(value, "|")
+			if !hasSystem {
+				system, code = "", value
+			}
+			switch system {
+			case "":
+				req.ID = &code
+			case models.FHIRSystemNationalID:
+				req.NationalID = &code
+			case models.FHIRSystemPassport:
+				req.PassportID = &code
+			case models.FHIRSystemHN:
+				req.PatientHN = &code
+			default:
+				return nil, fmt.Errorf("%w: unknown identifier system %s", ErrInvalidSearchParameter, system)
+			}
+		case "family":
+			req.LastName = &value
+		case "given":
+			req.FirstName = &value
+		case "birthdate":
+			date := strings.TrimPrefix(value, "eq")
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return nil, fmt.Errorf("%w: birthdate must be YYYY-MM-DD", ErrInvalidSearchParameter)
+			}
+			req.DateOfBirth = &date
+		case "gender":
+			gender, ok := fromFHIRGender(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: gender must be male or female", ErrInvalidSearchParameter)
+			}
+			req.Gender = &gender
+		case "_count":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: _count must be a non-negative integer", ErrInvalidSearchParameter)
+			}
+			if n < count {
+				count = n
+			}
+			continue
+		case "_offset":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: _offset must be a non-negative integer", ErrInvalidSearchParameter)
+			}
+			offset = n
+			continue
+		default:
+			if strings.HasPrefix(name, "_") {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalidSearchParameter, name)
+		}
+		criteria++
+	}
+
+	if criteria == 0 {
+		return nil, fmt.Errorf("%w: at least one search parameter is required", ErrInvalidSearchParameter)
+	}
+
+	patients, total, err := s.patientService.SearchPatientOffset(req, staffHospital, offset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// total counts every match; the entries are the page from offset
+	bundle := &models.FHIRBundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Total:        total,
+		Link: []models.FHIRBundleLink{
+			{Relation: "self", URL: s.searchURL(params, offset, count)},
+		},
+	}
+	if count > 0 && offset+len(patients) < total {
+		bundle.Link = append(bundle.Link, models.FHIRBundleLink{
+			Relation: "next",
+			URL:      s.searchURL(params, offset+count, count),
+		})
+	}
+
+	for _, patient := range patients {
+		bundle.Entry = append(bundle.Entry, models.FHIRBundleEntry{
+			FullURL:  s.config.FHIR.BaseURL + "/Patient/" + patient.PatientHN,
+			Resource: ToFHIRPatient(patient),
+			Search:   &models.FHIRBundleSearch{Mode: "match"},
+		})
+	}
+
+	return bundle, nil
+}
+
+// searchURL is the Patient search URL of params with the page set to offset
+// and count.
+func (s *FHIRService) searchURL(params url.Values, offset int, count int) string {
+	page := url.Values{}
+	for name, values := range params {
+		page[name] = values
+	}
+	page.Set("_count", strconv.Itoa(count))
+	page.Set("_offset", strconv.Itoa(offset))
+	return s.config.FHIR.BaseURL + "/Patient?" + page.Encode()
+}
+
+func (s *FHIRService) CapabilityStatement() *models.FHIRCapabilityStatement {
+	return &models.FHIRCapabilityStatement{
+		ResourceType: "CapabilityStatement",
+		Status:       "active",
+		Date:         time.Now().UTC().Format("2006-01-02"),
+		Kind:         "instance",
+		Software:     &models.FHIRCapabilitySoftware{Name: "agnos-middleware"},
+		FHIRVersion:  models.FHIRVersion,
+		Format:       []string{"json"},
+		Rest: []models.FHIRCapabilityRest{
+			{
+				Mode: "server",
+				Security: &models.FHIRCapabilitySecurity{
+					Description: "Bearer JWT from POST /staff/login. Results are limited to the staff member's hospital.",
+				},
+				Resource: []models.FHIRCapabilityResource{
+					{
+						Type: "Patient",
+						Interaction: []models.FHIRInteraction{
+							{Code: "read"},
+							{Code: "search-type"},
+						},
+						SearchParam: fhirSearchParams,
+					},
+				},
+			},
+		},
+	}
+}
+
+// ToFHIRPatient maps a patient to a FHIR R4 Patient. The logical id is the
+// hospital number.
+func ToFHIRPatient(patient *models.Patient) *models.FHIRPatient {
+	resource := &models.FHIRPatient{
+		ResourceType: "Patient",
+		ID:           patient.PatientHN,
+		Active:       patient.ErasedAt == nil,
+		Gender:       toFHIRGender(patient.Gender),
+	}
+
+	if !patient.UpdatedAt.IsZero() {
+		resource.Meta = &models.FHIRMeta{LastUpdated: patient.UpdatedAt.UTC().Format(time.RFC3339)}
+	}
+
+	resource.Identifier = append(resource.Identifier, models.FHIRIdentifier{
+		Use:      "usual",
+		System:   models.FHIRSystemHN,
+		Value:    patient.PatientHN,
+		Assigner: &models.FHIRReference{Display: patient.Hospital},
+	})
+	if patient.NationalID != nil {
+		resource.Identifier = append(resource.Identifier, models.FHIRIdentifier{
+			Use:    "official",
+			System: models.FHIRSystemNationalID,
+			Value:  *patient.NationalID,
+		})
+	}
+	if patient.PassportID != nil {
+		resource.Identifier = append(resource.Identifier, models.FHIRIdentifier{
+			Use:    "official",
+			System: models.FHIRSystemPassport,
+			Value:  *patient.PassportID,
+		})
+	}
+
+	if name := toFHIRHumanName("th", patient.FirstNameTH, patient.MiddleNameTH, patient.LastNameTH); name != nil {
+		resource.Name = append(resource.Name, *name)
+	}
+	if name := toFHIRHumanName("en", patient.FirstNameEN, patient.MiddleNameEN, patient.LastNameEN); name != nil {
+		resource.Name = append(resource.Name, *name)
+	}
+
+	if patient.PhoneNumber != nil {
+		resource.Telecom = append(resource.Telecom, models.FHIRContactPoint{System: "phone", Value: *patient.PhoneNumber, Use: "mobile"})
+	}
+	if patient.Email != nil {
+		resource.Telecom = append(resource.Telecom, models.FHIRContactPoint{System: "email", Value: *patient.Email})
+	}
+
+	if !patient.DateOfBirth.IsZero() {
+		resource.BirthDate = patient.DateOfBirth.Format("2006-01-02")
+	}
+
+	return resource
+}
+
+func toFHIRHumanName(language string, first *string, middle *string, last *string) *models.FHIRHumanName {
+	if first == nil && middle == nil && last == nil {
+		return nil
+	}
+
+	name := &models.FHIRHumanName{
+		Extension: []models.FHIRExtension{{URL: models.FHIRExtensionLanguage, ValueCode: language}},
+		Use:       "official",
+	}
+
+	var parts []string
+	for _, given := range []*string{first, middle} {
+		if given != nil {
+			name.Given = append(name.Given, *given)
+			parts = append(parts, *given)
+		}
+	}
+	if last != nil {
+		name.Family = *last
+		parts = append(parts, *last)
+	}
+	name.Text = strings.Join(parts, " ")
+
+	return name
+}
+
+func toFHIRGender(gender string) string {
+	switch strings.ToUpper(gender) {
+	case "M":
+		return "male"
+	case "F":
+		return "female"
+	case "":
+		return "unknown"
+	default:
+		return "other"
+	}
+}
+
+func fromFHIRGender(gender string) (string, bool) {
+	switch gender {
+	case "male":
+		return "M", true
+	case "female":
+		return "F", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupFHIRTest(t *testing.T) *FHIRService {
+	repo := repositories.NewPatientRepository(setupPatientTestDB(t))
+	config := getTestConfig()
+	config.FHIR.BaseURL = "http://localhost:8080/fhir"
+
+	for _, patient := range []*models.Patient{
+		{PatientHN: "HN001", Hospital: "Hospital A", NationalID: stringPtr("1234567890123"), Gender: "M", DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN002", Hospital: "Hospital A", NationalID: stringPtr("9876543210987"), Gender: "M", DateOfBirth: time.Date(1990, 7, 20, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN003", Hospital: "Hospital A", NationalID: stringPtr("1111222233334"), Gender: "M", DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN004", Hospital: "Hospital A", NationalID: stringPtr("5555666677778"), Gender: "F", DateOfBirth: time.Date(1975, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+			t.Fatalf("Failed to create patient: %v", err)
+		}
+	}
+
+	return NewFHIRService(NewPatientService(repo, config), config)
+}
+
+func bundleLink(bundle *models.FHIRBundle, relation string) string {
+	for _, link := range bundle.Link {
+		if link.Relation == relation {
+			return link.URL
+		}
+	}
+	return ""
+}
+
+func TestFHIRSearchPatients_Positive_Paging(t *testing.T) {
+	service := setupFHIRTest(t)
+
+	bundle, err := service.SearchPatients(url.Values{"gender": {"male"}, "_count": {"2"}}, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if bundle.Total != 3 || len(bundle.Entry) != 2 {
+		t.Fatalf("Expected total 3 with 2 entries, got total %d with %d entries", bundle.Total, len(bundle.Entry))
+	}
+	if bundle.Entry[0].Resource.ID != "HN001" || bundle.Entry[1].Resource.ID != "HN002" {
+		t.Errorf("Expected HN001 and HN002 first, got %s and %s", bundle.Entry[0].Resource.ID, bundle.Entry[1].Resource.ID)
+	}
+
+	next := bundleLink(bundle, "next")
+	if next == "" {
+		t.Fatal("Expected a next link")
+	}
+	nextURL, err := url.Parse(next)
+	if err != nil || !strings.HasPrefix(next, "http://localhost:8080/fhir/Patient?") {
+		t.Fatalf("Expected a Patient search URL, got %s", next)
+	}
+
+	bundle, err = service.SearchPatients(nextURL.Query(), "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if bundle.Total != 3 || len(bundle.Entry) != 1 || bundle.Entry[0].Resource.ID != "HN003" {
+		t.Fatalf("Expected HN003 alone on the last page of 3, got total %d with %d entries", bundle.Total, len(bundle.Entry))
+	}
+	if link := bundleLink(bundle, "next"); link != "" {
+		t.Errorf("Expected no next link on the last page, got %s", link)
+	}
+}
+
+func TestFHIRSearchPatients_Negative_InvalidOffset(t *testing.T) {
+	service := setupFHIRTest(t)
+
+	_, err := service.SearchPatients(url.Values{"gender": {"male"}, "_offset": {"-1"}}, "Hospital A")
+	if !errors.Is(err, ErrInvalidSearchParameter) {
+		t.Errorf("Expected ErrInvalidSearchParameter, got: %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoHISAdapter = errors.New("no HIS configured for hospital")
+	// HIS failures, so callers can tell them apart from a patient HIS does not
+	// know. They map to 503, 504 and 502.
+	ErrHISUnavailable = errors.New("HIS is unavailable")
+	ErrHISTimeout     = errors.New("HIS did not respond in time")
+	ErrHISBadResponse = errors.New("HIS returned an invalid response")
+	// ErrNotFoundInCache is returned in cache-only fallback mode when a patient
+	// is not cached locally and HIS could not be asked.
+	ErrNotFoundInCache = errors.New("patient not found in the local cache and HIS is unavailable")
+)
+
+// HISStatusError is returned when HIS answers with an unexpected HTTP status.
+type HISStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HISStatusError) Error() string {
+	return fmt.Sprintf("HIS API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// HISAdapter talks to the HIS of one hospital.
+type HISAdapter interface {
+	// FetchPatient looks a patient up by national ID, passport ID or HN. It
+	// returns ErrPatientNotFound when HIS does not know the patient.
+	FetchPatient(ctx context.Context, id string) (*models.Patient, error)
+	// PushPatient writes a locally registered (create) or corrected patient back
+	// to HIS. Adapters with write back turned off return nil without a call.
+	PushPatient(ctx context.Context, patient *models.Patient, create bool) error
+}
+
+// HISWriter is implemented by adapters that can say whether PushPatient
+// reaches HIS.
+type HISWriter interface {
+	// WritesBack reports whether write back to this HIS is turned on.
+	WritesBack() bool
+}
+
+// HISChangeFeed is implemented by adapters whose HIS can list the patients
+// changed since a point in time, for the background sync.
+type HISChangeFeed interface {
+	// HasChangeFeed reports whether this HIS has a change feed configured.
+	HasChangeFeed() bool
+	// FetchChanges returns one page of patients changed since since (nil means
+	// all patients). cursor is empty for the first page and NextCursor of the
+	// previous page after that.
+	FetchChanges(ctx context.Context, since *time.Time, cursor string, limit int) (*HISChangePage, error)
+}
+
+// HISChangePage is one page of a HIS change feed.
+type HISChangePage struct {
+	Patients []*models.Patient
+	// NextCursor is empty on the last page.
+	NextCursor string
+	// AsOf is the HIS time the feed was read at, the next run's since. Zero
+	// when HIS does not say.
+	AsOf time.Time
+}
+
+// hisAdapterFactories build an adapter for each vendor in the adapter file.
+var hisAdapterFactories = map[string]func(hospital string, config configs.HISAdapterConfig) (HISAdapter, error){
+	configs.HISVendorREST: func(hospital string, config configs.HISAdapterConfig) (HISAdapter, error) {
+		return newRESTHISAdapter(hospital, config)
+	},
+}
+
+// HISAdapterRegistry picks the HIS adapter for a hospital. Every adapter is
+// wrapped with retries and its own circuit breaker.
+type HISAdapterRegistry struct {
+	mu       sync.RWMutex
+	config   *configs.ApplicationConfig
+	adapters map[string]*resilientHISAdapter
+	// fallback serves every hospital when no adapter file is configured
+	fallback *resilientHISAdapter
+}
+
+// NewHISAdapterRegistry builds an adapter for every hospital in
+// config.HISAPI.Adapters. Without adapters, every hospital shares one REST
+// adapter for HIS_API_BASE_URL.
+func NewHISAdapterRegistry(config *configs.ApplicationConfig) *HISAdapterRegistry {
+	registry := &HISAdapterRegistry{
+		config:   config,
+		adapters: map[string]*resilientHISAdapter{},
+	}
+
+	if len(config.HISAPI.Adapters) == 0 {
+		timeout := config.HISAPI.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		adapter, err := newRESTHISAdapter("", configs.HISAdapterConfig{
+			Vendor:      configs.HISVendorREST,
+			BaseURL:     config.HISAPI.BaseURL,
+			Timeout:     timeout,
+			WriteBack:   config.HISAPI.WriteBack,
+			ChangesPath: config.HISAPI.ChangesPath,
+		})
+		if err != nil {
+			fmt.Printf("[HIS API] Failed to set up HIS_API_BASE_URL, hospitals have no HIS: %v\n", err)
+			return registry
+		}
+		registry.fallback = registry.wrap(defaultHISAdapterName, adapter)
+		return registry
+	}
+
+	for hospital, adapterConfig := range config.HISAPI.Adapters {
+		factory, ok := hisAdapterFactories[adapterConfig.Vendor]
+		if !ok {
+			fmt.Printf("[HIS API] Unknown vendor %q for %s, hospital has no HIS\n", adapterConfig.Vendor, hospital)
+			continue
+		}
+		adapter, err := factory(hospital, adapterConfig)
+		if err != nil {
+			fmt.Printf("[HIS API] Failed to set up the HIS of %s, hospital has no HIS: %v\n", hospital, err)
+			continue
+		}
+		registry.adapters[hospital] = registry.wrap(hospital, adapter)
+	}
+
+	return registry
+}
+
+// Register adds or replaces the adapter of a hospital. The hospital gets a new,
+// closed circuit breaker.
+func (r *HISAdapterRegistry) Register(hospital string, adapter HISAdapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.adapters[hospital] = r.wrap(hospital, adapter)
+}
+
+func (r *HISAdapterRegistry) Adapter(hospital string) (HISAdapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if adapter, ok := r.adapters[hospital]; ok {
+		return adapter, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("%w %s", ErrNoHISAdapter, hospital)
+}
+
+// Hospitals lists the hospitals with their own adapter.
+func (r *HISAdapterRegistry) Hospitals() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hospitals := make([]string, 0, len(r.adapters))
+	for hospital := range r.adapters {
+		hospitals = append(hospitals, hospital)
+	}
+	sort.Strings(hospitals)
+	return hospitals
+}
+
+// Status returns the circuit breaker of every adapter, sorted by hospital. The
+// shared HIS_API_BASE_URL adapter is reported as "default".
+func (r *HISAdapterRegistry) Status() []utils.BreakerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]utils.BreakerStatus, 0, len(r.adapters)+1)
+	if r.fallback != nil {
+		statuses = append(statuses, r.fallback.breaker.Status())
+	}
+	for _, adapter := range r.adapters {
+		statuses = append(statuses, adapter.breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// ChangeFeeds returns the adapters whose HIS has a change feed, by hospital.
+// The shared HIS_API_BASE_URL adapter is keyed "default".
+func (r *HISAdapterRegistry) ChangeFeeds() map[string]HISChangeFeed {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	feeds := map[string]HISChangeFeed{}
+	if r.fallback != nil && r.fallback.HasChangeFeed() {
+		feeds[defaultHISAdapterName] = r.fallback
+	}
+	for hospital, adapter := range r.adapters {
+		if adapter.HasChangeFeed() {
+			feeds[hospital] = adapter
+		}
+	}
+	return feeds
+}
+
+func (r *HISAdapterRegistry) wrap(name string, adapter HISAdapter) *resilientHISAdapter {
+	return &resilientHISAdapter{
+		adapter: adapter,
+		breaker: utils.NewCircuitBreaker(name, r.config.HISAPI.BreakerFailureThreshold, r.config.HISAPI.BreakerOpenTimeout),
+		retry: utils.RetryPolicy{
+			MaxAttempts: r.config.HISAPI.RetryMaxAttempts,
+			BaseDelay:   r.config.HISAPI.RetryBaseDelay,
+			MaxDelay:    r.config.HISAPI.RetryMaxDelay,
+		},
+	}
+}
+
+// restHISAdapter speaks a JSON REST HIS. URL shape, credentials and where the
+// patient fields sit in the response come from the adapter config.
+type restHISAdapter struct {
+	hospital    string
+	config      configs.HISAdapterConfig
+	httpClient  *http.Client
+	credentials HISCredentialProvider
+}
+
+func newRESTHISAdapter(hospital string, config configs.HISAdapterConfig) (*restHISAdapter, error) {
+	if config.SearchPath == "" {
+		config.SearchPath = "/patient/search/{id}"
+	}
+	if config.WritePath == "" {
+		config.WritePath = "/patient"
+	}
+
+	httpClient, credentials, err := newHISHTTPClient(config.Auth, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restHISAdapter{
+		hospital:    hospital,
+		config:      config,
+		httpClient:  httpClient,
+		credentials: credentials,
+	}, nil
+}
+
+func (a *restHISAdapter) FetchPatient(ctx context.Context, id string) (*models.Patient, error) {
+	endpoint := strings.TrimRight(a.config.BaseURL, "/") + strings.ReplaceAll(a.config.SearchPath, "{id}", url.PathEscape(id))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w in HIS API", ErrPatientNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &HISStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	patient, err := a.decodePatient(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	a.stampHospital(patient)
+
+	return patient, nil
+}
+
+// stampHospital sets the hospital of a patient from this HIS. An adapter of
+// one hospital overrides whatever HIS answered, so a HIS cannot place patients
+// in another hospital; the shared HIS_API_BASE_URL adapter keeps HIS's value.
+func (a *restHISAdapter) stampHospital(patient *models.Patient) {
+	if a.hospital != "" {
+		patient.Hospital = a.hospital
+	}
+}
+
+func (a *restHISAdapter) WritesBack() bool {
+	return a.config.WriteBack
+}
+
+func (a *restHISAdapter) HasChangeFeed() bool {
+	return a.config.ChangesPath != ""
+}
+
+// FetchChanges reads GET ChangesPath?since=&cursor=&limit=, which answers
+// {"patients": [...], "next_cursor": "...", "as_of": "RFC 3339 time"}. Each
+// patient is mapped with Fields.
+func (a *restHISAdapter) FetchChanges(ctx context.Context, since *time.Time, cursor string, limit int) (*HISChangePage, error) {
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if since != nil {
+		query.Set("since", since.UTC().Format(time.RFC3339Nano))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	endpoint := strings.TrimRight(a.config.BaseURL, "/") + a.config.ChangesPath + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &HISStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var body struct {
+		Patients   []map[string]interface{} `json:"patients"`
+		NextCursor string                   `json:"next_cursor"`
+		AsOf       time.Time                `json:"as_of"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode change feed: %w", err)
+	}
+
+	page := &HISChangePage{
+		Patients:   make([]*models.Patient, 0, len(body.Patients)),
+		NextCursor: body.NextCursor,
+		AsOf:       body.AsOf,
+	}
+	for _, result := range body.Patients {
+		patient, err := a.mapPatient(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode change feed: %w", err)
+		}
+		a.stampHospital(patient)
+		page.Patients = append(page.Patients, patient)
+	}
+
+	return page, nil
+}
+
+func (a *restHISAdapter) PushPatient(ctx context.Context, patient *models.Patient, create bool) error {
+	if !a.config.WriteBack {
+		return nil
+	}
+
+	method := http.MethodPost
+	endpoint := strings.TrimRight(a.config.BaseURL, "/") + a.config.WritePath
+	if !create {
+		method = http.MethodPut
+		endpoint += "/" + url.PathEscape(patient.PatientHN)
+	}
+
+	body, err := json.Marshal(patient)
+	if err != nil {
+		return fmt.Errorf("failed to encode patient: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create write back request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &HISStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return nil
+}
+
+// do authenticates and sends a request. A 401 drops credentials HIS may have
+// revoked, so the next call gets new ones.
+func (a *restHISAdapter) do(req *http.Request) (*http.Response, error) {
+	if err := a.credentials.Authenticate(req.Context(), req); err != nil {
+		return nil, fmt.Errorf("failed to authenticate HIS request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to API: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		if revocable, ok := a.credentials.(hisRevocableCredentials); ok {
+			revocable.Invalidate()
+		}
+	}
+	return resp, nil
+}
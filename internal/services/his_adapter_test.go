@@ -0,0 +1,141 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/repositories"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHISAdapterRegistry_Positive_PerHospitalVendor(t *testing.T) {
+	hospitalA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/patient/search/1234567890123" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"patient_hn":  "HN001",
+			"hospital":    "Hospital A",
+			"national_id": "1234567890123",
+		})
+	}))
+	defer hospitalA.Close()
+
+	hospitalB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret-b" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v2/patients" || r.URL.Query().Get("cid") != "1111222233334" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":[{"mrn":"B-77","cid":"1111222233334","name":{"given":"Wichai","family":"Sukjai"},"sex":"M"}]}`))
+	}))
+	defer hospitalB.Close()
+
+	t.Setenv("HIS_B_KEY", "secret-b")
+
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.Adapters = map[string]configs.HISAdapterConfig{
+		"Hospital A": {Vendor: configs.HISVendorREST, BaseURL: hospitalA.URL, Timeout: time.Second},
+		"Hospital B": {
+			Vendor:     configs.HISVendorREST,
+			BaseURL:    hospitalB.URL,
+			Timeout:    time.Second,
+			SearchPath: "/api/v2/patients?cid={id}",
+			ResultPath: "data.0",
+			Fields: map[string]configs.HISFieldMapping{
+				"patient_hn":    {Path: "mrn"},
+				"national_id":   {Path: "cid"},
+				"first_name_en": {Path: "name.given"},
+				"last_name_en":  {Path: "name.family"},
+				"gender":        {Path: "sex"},
+			},
+			Auth: configs.HISAuthConfig{Type: configs.HISAuthAPIKey, Header: "X-Api-Key", KeyEnv: "HIS_B_KEY"},
+		},
+	}
+	service := NewPatientService(repo, config)
+
+	patient, err := service.searchPatientFromHIS(t.Context(), "Hospital A", "1234567890123")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.PatientHN != "HN001" {
+		t.Errorf("Expected HN001 from Hospital A's HIS, got %s", patient.PatientHN)
+	}
+
+	patient, err = service.searchPatientFromHIS(t.Context(), "Hospital B", "1111222233334")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.PatientHN != "B-77" || patient.Hospital != "Hospital B" {
+		t.Errorf("Expected B-77 of Hospital B, got %s of %s", patient.PatientHN, patient.Hospital)
+	}
+	if patient.FirstNameEN == nil || *patient.FirstNameEN != "Wichai" || patient.Gender != "M" {
+		t.Error("Expected vendor fields to be mapped")
+	}
+
+	if _, err := service.searchPatientFromHIS(t.Context(), "Hospital C", "1111222233334"); !errors.Is(err, ErrNoHISAdapter) {
+		t.Errorf("Expected ErrNoHISAdapter for a hospital without HIS, got: %v", err)
+	}
+}
+
+func TestRESTHISAdapter_Negative_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	adapter, err := newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{BaseURL: server.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	if _, err := adapter.FetchPatient(t.Context(), "0000000000000"); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got: %v", err)
+	}
+}
+
+func TestRESTHISAdapter_Negative_HISCannotChooseHospital(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		patient := map[string]interface{}{"patient_hn": "HN001", "hospital": "Hospital B"}
+		if r.URL.Path == "/changes" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"patients": []interface{}{patient}})
+			return
+		}
+		json.NewEncoder(w).Encode(patient)
+	}))
+	defer server.Close()
+
+	adapter, err := newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{
+		BaseURL:     server.URL,
+		Timeout:     time.Second,
+		ChangesPath: "/changes",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	patient, err := adapter.FetchPatient(t.Context(), "HN001")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.Hospital != "Hospital A" {
+		t.Errorf("Expected the adapter's hospital Hospital A, got %s", patient.Hospital)
+	}
+
+	page, err := adapter.FetchChanges(t.Context(), nil, "", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(page.Patients) != 1 || page.Patients[0].Hospital != "Hospital A" {
+		t.Errorf("Expected one change feed patient of Hospital A, got %+v", page.Patients)
+	}
+}
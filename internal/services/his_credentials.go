@@ -0,0 +1,280 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HISCredentialProvider adds the outbound credentials of one HIS adapter to its
+// requests.
+type HISCredentialProvider interface {
+	// Authenticate sets the credentials on a request before it is sent.
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// hisTLSCredentials is implemented by providers that authenticate on the TLS
+// layer instead of, or as well as, in request headers.
+type hisTLSCredentials interface {
+	configureTLS(config *tls.Config)
+}
+
+// hisRevocableCredentials is implemented by providers holding credentials that
+// HIS may reject before they expire; Invalidate drops them after a 401.
+type hisRevocableCredentials interface {
+	Invalidate()
+}
+
+// hisCredentialProviders build the credential provider of each auth type.
+var hisCredentialProviders = map[string]func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider{
+	configs.HISAuthNone: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return noHISCredentials{}
+	},
+	configs.HISAuthBearer: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return &headerHISCredentials{header: "Authorization", prefix: "Bearer ", file: auth.TokenFile, env: auth.TokenEnv}
+	},
+	configs.HISAuthBasic: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return &basicHISCredentials{username: auth.Username, file: auth.PasswordFile, env: auth.PasswordEnv}
+	},
+	configs.HISAuthAPIKey: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return &headerHISCredentials{header: auth.Header, file: auth.KeyFile, env: auth.KeyEnv}
+	},
+	configs.HISAuthOAuth2: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return newOAuth2HISCredentials(auth, &http.Client{Timeout: timeout})
+	},
+	configs.HISAuthMTLS: func(auth configs.HISAuthConfig, timeout time.Duration) HISCredentialProvider {
+		return &mtlsHISCredentials{certFile: auth.ClientCertFile, keyFile: auth.ClientKeyFile}
+	},
+}
+
+// newHISHTTPClient returns the HTTP client and credential provider of an
+// adapter's auth config.
+func newHISHTTPClient(auth configs.HISAuthConfig, timeout time.Duration) (*http.Client, HISCredentialProvider, error) {
+	factory, ok := hisCredentialProviders[auth.Type]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+	credentials := factory(auth, timeout)
+
+	client := &http.Client{Timeout: timeout}
+	tlsCredentials, usesTLS := credentials.(hisTLSCredentials)
+	if auth.CAFile == "" && !usesTLS {
+		return client, credentials, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if auth.CAFile != "" {
+		pool, err := configs.LoadCertPool(auth.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if usesTLS {
+		tlsCredentials.configureTLS(tlsConfig)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client, credentials, nil
+}
+
+type noHISCredentials struct{}
+
+func (noHISCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	return nil
+}
+
+// headerHISCredentials sends a static secret, such as a bearer token or API key,
+// in a header.
+type headerHISCredentials struct {
+	header string
+	prefix string
+	file   string
+	env    string
+}
+
+func (c *headerHISCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	secret, err := configs.ReadSecret(c.file, c.env)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(c.header, c.prefix+secret)
+	return nil
+}
+
+type basicHISCredentials struct {
+	username string
+	file     string
+	env      string
+}
+
+func (c *basicHISCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	password, err := configs.ReadSecret(c.file, c.env)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, password)
+	return nil
+}
+
+// mtlsHISCredentials presents a client certificate. The key pair is loaded from
+// its files on every handshake so a renewed certificate is used without a
+// restart.
+type mtlsHISCredentials struct {
+	certFile string
+	keyFile  string
+}
+
+func (c *mtlsHISCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	return nil
+}
+
+func (c *mtlsHISCredentials) configureTLS(config *tls.Config) {
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load HIS client certificate: %w", err)
+		}
+		return &certificate, nil
+	}
+}
+
+const (
+	// oauth2DefaultTokenLifetime is assumed when the token response has no
+	// expires_in.
+	oauth2DefaultTokenLifetime = 5 * time.Minute
+	// oauth2MaxRefreshMargin caps how long before expiry a token is renewed;
+	// shorter lived tokens are renewed when 90% of their lifetime has passed.
+	oauth2MaxRefreshMargin = time.Minute
+)
+
+// oauth2HISCredentials gets access tokens with the OAuth2 client credentials
+// grant and caches them until shortly before they expire. One token request
+// runs at a time; concurrent calls wait for it.
+type oauth2HISCredentials struct {
+	auth       configs.HISAuthConfig
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+func newOAuth2HISCredentials(auth configs.HISAuthConfig, httpClient *http.Client) *oauth2HISCredentials {
+	return &oauth2HISCredentials{
+		auth:       auth,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+func (c *oauth2HISCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token so the next call requests a new one.
+func (c *oauth2HISCredentials) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = ""
+}
+
+// accessToken returns the cached token, renewing it once it is due. When the
+// renewal fails the cached token is used until it actually expires.
+func (c *oauth2HISCredentials) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.token != "" && now.Before(c.refreshAt) {
+		return c.token, nil
+	}
+
+	token, lifetime, err := c.requestToken(ctx)
+	if err != nil {
+		if c.token != "" && now.Before(c.expiresAt) {
+			fmt.Printf("[HIS API] Failed to renew OAuth2 token from %s, using the current one: %v\n", c.auth.TokenURL, err)
+			return c.token, nil
+		}
+		return "", fmt.Errorf("failed to get OAuth2 token: %w", err)
+	}
+
+	margin := lifetime / 10
+	if margin > oauth2MaxRefreshMargin {
+		margin = oauth2MaxRefreshMargin
+	}
+	c.token = token
+	c.expiresAt = now.Add(lifetime)
+	c.refreshAt = c.expiresAt.Add(-margin)
+	return token, nil
+}
+
+func (c *oauth2HISCredentials) requestToken(ctx context.Context) (string, time.Duration, error) {
+	secret, err := configs.ReadSecret(c.auth.ClientSecretFile, c.auth.ClientSecretEnv)
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.auth.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.auth.ClientID), url.QueryEscape(secret))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", 0, &HISStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("unsupported token type %q", body.TokenType)
+	}
+
+	lifetime := oauth2DefaultTokenLifetime
+	if body.ExpiresIn > 0 {
+		lifetime = time.Duration(body.ExpiresIn) * time.Second
+	}
+	return body.AccessToken, lifetime, nil
+}
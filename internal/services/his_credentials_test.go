@@ -0,0 +1,220 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOAuth2HISCredentials_Positive_CachesAndRenewsToken(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != "middleware" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "patient.read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		n := requests.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	}))
+	defer tokenServer.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "client_secret")
+	os.WriteFile(secretFile, []byte("s3cret\n"), 0o600)
+
+	credentials := newOAuth2HISCredentials(configs.HISAuthConfig{
+		Type:             configs.HISAuthOAuth2,
+		TokenURL:         tokenServer.URL,
+		ClientID:         "middleware",
+		ClientSecretFile: secretFile,
+		Scopes:           []string{"patient.read"},
+	}, tokenServer.Client())
+	now := time.Now()
+	credentials.now = func() time.Time { return now }
+
+	authorization := func() string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/patient/search/1", nil)
+		if err := credentials.Authenticate(t.Context(), req); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	if got := authorization(); got != "Bearer token-1" {
+		t.Errorf("Expected Bearer token-1, got %q", got)
+	}
+	now = now.Add(3 * time.Minute)
+	if got := authorization(); got != "Bearer token-1" || requests.Load() != 1 {
+		t.Errorf("Expected the cached token, got %q after %d token requests", got, requests.Load())
+	}
+
+	// Renewal is due once 90% of the lifetime has passed; a failed renewal keeps
+	// the token while it is still valid
+	now = now.Add(90 * time.Second)
+	failing.Store(true)
+	if got := authorization(); got != "Bearer token-1" {
+		t.Errorf("Expected the current token while renewal fails, got %q", got)
+	}
+
+	failing.Store(false)
+	if got := authorization(); got != "Bearer token-2" {
+		t.Errorf("Expected a renewed token before expiry, got %q", got)
+	}
+
+	credentials.Invalidate()
+	if got := authorization(); got != "Bearer token-3" {
+		t.Errorf("Expected a new token after invalidation, got %q", got)
+	}
+}
+
+func TestOAuth2HISCredentials_Negative_TokenEndpointDown(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer tokenServer.Close()
+
+	t.Setenv("HIS_CLIENT_SECRET", "s3cret")
+	adapter, err := newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{
+		BaseURL: "http://127.0.0.1:1",
+		Timeout: time.Second,
+		Auth: configs.HISAuthConfig{
+			Type:            configs.HISAuthOAuth2,
+			TokenURL:        tokenServer.URL,
+			ClientID:        "middleware",
+			ClientSecretEnv: "HIS_CLIENT_SECRET",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	_, err = adapter.FetchPatient(t.Context(), "1234567890123")
+	if !isTransientHISError(err) {
+		t.Errorf("Expected a token endpoint outage to be transient, got: %v", err)
+	}
+}
+
+func TestHeaderHISCredentials_Positive_RereadsRotatedSecret(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("first\n"), 0o600)
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A"}`))
+	}))
+	defer server.Close()
+
+	adapter, err := newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{
+		BaseURL: server.URL,
+		Timeout: time.Second,
+		Auth:    configs.HISAuthConfig{Type: configs.HISAuthBearer, TokenFile: tokenFile},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	adapter.FetchPatient(t.Context(), "HN001")
+	os.WriteFile(tokenFile, []byte("second\n"), 0o600)
+	adapter.FetchPatient(t.Context(), "HN001")
+
+	if len(got) != 2 || got[0] != "Bearer first" || got[1] != "Bearer second" {
+		t.Errorf("Expected the rotated token to be sent, got %v", got)
+	}
+}
+
+func TestMTLSHISCredentials_Positive_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "middleware")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "middleware" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600)
+
+	auth := configs.HISAuthConfig{Type: configs.HISAuthMTLS, ClientCertFile: certFile, ClientKeyFile: keyFile, CAFile: caFile}
+	adapter, err := newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{BaseURL: server.URL, Timeout: time.Second, Auth: auth})
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	patient, err := adapter.FetchPatient(t.Context(), "HN001")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.PatientHN != "HN001" {
+		t.Errorf("Expected HN001, got %s", patient.PatientHN)
+	}
+
+	auth.Type = configs.HISAuthNone
+	adapter, _ = newRESTHISAdapter("Hospital A", configs.HISAdapterConfig{BaseURL: server.URL, Timeout: time.Second, Auth: auth})
+	if _, err := adapter.FetchPatient(t.Context(), "HN001"); err == nil {
+		t.Error("Expected the server to refuse a client without a certificate")
+	}
+}
+
+// writeTestCertificate writes a self-signed client certificate and its key as
+// PEM files.
+func writeTestCertificate(t *testing.T, dir string, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrHISEventUnknownHospital = errors.New("hospital does not push HIS events")
+	ErrHISEventSignature       = errors.New("invalid HIS event signature")
+	ErrHISEventExpired         = errors.New("HIS event timestamp is outside the allowed window")
+	ErrInvalidHISEvent         = errors.New("invalid HIS event")
+)
+
+const hisEventDeliveryPageMaxSize = 200
+
+// redactedValue replaces patient values in stored event payloads.
+const redactedValue = "[redacted]"
+
+// HISEventService applies patient changes a HIS pushes, so cached patients are
+// updated as soon as HIS changes them rather than when they go stale.
+type HISEventService struct {
+	patientService *PatientService
+	eventRepo      *repositories.HISEventRepository
+	config         *configs.ApplicationConfig
+	// unauthenticated counts deliveries that were not recorded because they
+	// named an unknown hospital or were not signed with its secret
+	unauthenticated atomic.Int64
+}
+
+func NewHISEventService(patientService *PatientService, eventRepo *repositories.HISEventRepository, config *configs.ApplicationConfig) *HISEventService {
+	return &HISEventService{
+		patientService: patientService,
+		eventRepo:      eventRepo,
+		config:         config,
+	}
+}
+
+// HandleEvent verifies and applies one event delivery and records its outcome.
+// The body must be signed with the hospital's webhook secret: signature is
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>", where timestamp
+// is in Unix seconds and within HIS_WEBHOOK_TOLERANCE of now. An event ID the
+// hospital already sent is reported as a duplicate without being applied
+// again; events that fail are not remembered, so the HIS can retry them.
+// Anyone can call the endpoint, so deliveries that fail authentication are
+// only logged and counted, never stored.
+func (s *HISEventService) HandleEvent(hospital string, timestamp string, signature string, body []byte, remoteAddr string) (*models.HISEventDelivery, error) {
+	started := time.Now()
+	delivery := &models.HISEventDelivery{Hospital: hospital, RemoteAddr: remoteAddr}
+
+	status, err := s.handleEvent(delivery, timestamp, signature, body)
+	switch {
+	case err == nil:
+		delivery.Status = status
+	case errors.Is(err, ErrHISEventUnknownHospital), errors.Is(err, ErrHISEventSignature),
+		errors.Is(err, ErrHISEventExpired), errors.Is(err, ErrInvalidHISEvent), errors.Is(err, ErrInvalidHISPayload):
+		delivery.Status = models.HISDeliveryRejected
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = models.HISDeliveryFailed
+		delivery.Error = err.Error()
+	}
+	if delivery.Status == models.HISDeliveryRejected {
+		delivery.Payload = ""
+		delivery.PayloadSHA256 = ""
+	}
+	delivery.DurationMS = time.Since(started).Milliseconds()
+
+	if errors.Is(err, ErrHISEventUnknownHospital) || errors.Is(err, ErrHISEventSignature) {
+		s.unauthenticated.Add(1)
+		fmt.Printf("[HIS Events] Rejected unauthenticated delivery for %q from %s: %v\n", hospital, remoteAddr, err)
+		return delivery, err
+	}
+
+	if recordErr := s.eventRepo.CreateDelivery(delivery); recordErr != nil {
+		fmt.Printf("[HIS Events] Failed to record delivery of %s from %s: %v\n", delivery.EventID, hospital, recordErr)
+	}
+	return delivery, err
+}
+
+func (s *HISEventService) handleEvent(delivery *models.HISEventDelivery, timestamp string, signature string, body []byte) (string, error) {
+	secret, err := s.secret(delivery.Hospital)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyHISEventSignature(secret, timestamp, signature, body, s.config.HISWebhook.Tolerance, time.Now()); err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(body)
+	delivery.PayloadSHA256 = hex.EncodeToString(hash[:])
+
+	var event models.HISEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidHISEvent, err)
+	}
+	delivery.Payload = redactHISEvent(event)
+	delivery.EventID = event.EventID
+	delivery.Type = event.Type
+	delivery.PatientHN = event.PatientHN
+	if event.EventID == "" {
+		return "", fmt.Errorf("%w: event_id is required", ErrInvalidHISEvent)
+	}
+	if event.Type != models.HISEventPatientCreated && event.Type != models.HISEventPatientUpdated {
+		return "", fmt.Errorf("%w: unsupported type %q", ErrInvalidHISEvent, event.Type)
+	}
+
+	record := &models.HISEventRecord{
+		Hospital:   delivery.Hospital,
+		EventID:    event.EventID,
+		Type:       event.Type,
+		ReceivedAt: time.Now(),
+	}
+	if err := s.eventRepo.ClaimEvent(record); err != nil {
+		if errors.Is(err, repositories.ErrHISEventExists) {
+			return models.HISDeliveryDuplicate, nil
+		}
+		return "", err
+	}
+
+	status, err := s.applyEvent(delivery, &event)
+	if err != nil {
+		if releaseErr := s.eventRepo.ReleaseEvent(record); releaseErr != nil {
+			return "", errors.Join(err, releaseErr)
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// applyEvent saves the patient sent with the event, or refreshes the cached
+// patient from HIS when the event only names it.
+func (s *HISEventService) applyEvent(delivery *models.HISEventDelivery, event *models.HISEvent) (string, error) {
+	hospital := delivery.Hospital
+
+	if event.Patient == nil {
+		if event.PatientHN == "" {
+			return "", fmt.Errorf("%w: patient or patient_hn is required", ErrInvalidHISEvent)
+		}
+		_, err := s.patientService.RefreshPatient(event.PatientHN, hospital)
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrNoHISAdapter) {
+			// Not cached, so there is nothing to refresh
+			return models.HISDeliveryIgnored, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return models.HISDeliveryApplied, nil
+	}
+
+	patient, err := s.patientService.hisAdapters.MapPatient(hospital, event.Patient)
+	if err != nil {
+		return "", err
+	}
+	if patient.PatientHN == "" {
+		return "", fmt.Errorf("%w: the patient has no patient_hn", ErrInvalidHISEvent)
+	}
+	if event.PatientHN != "" && event.PatientHN != patient.PatientHN {
+		return "", fmt.Errorf("%w: event is for %s but the patient is %s", ErrInvalidHISEvent, event.PatientHN, patient.PatientHN)
+	}
+	if patient.Hospital != hospital {
+		return "", fmt.Errorf("%w: a HIS may only send patients of its own hospital, got %s", ErrInvalidHISEvent, patient.Hospital)
+	}
+	delivery.PatientHN = patient.PatientHN
+
+	patient.Source = models.PatientSourceHIS
+	if _, err := s.patientService.cacheHISPatient(patient); err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			// Erased and deleted patients stay that way
+			return models.HISDeliveryIgnored, nil
+		}
+		return "", err
+	}
+	return models.HISDeliveryApplied, nil
+}
+
+// redactHISEvent returns the event as JSON with every patient value replaced,
+// keeping the field names to show what the HIS sent.
+func redactHISEvent(event models.HISEvent) string {
+	if event.Patient != nil {
+		event.Patient = redactJSONValue(event.Patient).(map[string]interface{})
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	return string(payload)
+}
+
+func redactJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			redacted[key] = redactJSONValue(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, item := range value {
+			redacted[i] = redactJSONValue(item)
+		}
+		return redacted
+	case nil:
+		return nil
+	default:
+		return redactedValue
+	}
+}
+
+// PurgeExpired removes deliveries and handled event IDs older than
+// RETENTION_HIS_EVENT_DAYS. A HIS redelivering an event after that is applied
+// again.
+func (s *HISEventService) PurgeExpired(ctx context.Context) error {
+	days := s.config.Retention.HISEventDays
+	if days <= 0 {
+		return nil
+	}
+
+	purged, err := s.eventRepo.PurgeBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		fmt.Printf("[HIS Events] Purged %d deliveries older than %d days\n", purged, days)
+	}
+	return nil
+}
+
+// secret returns the webhook secret of the hospital's adapter, re-read on use,
+// or else the one in HIS_WEBHOOK_SECRETS.
+func (s *HISEventService) secret(hospital string) (string, error) {
+	if adapter, ok := s.config.HISAPI.Adapters[hospital]; ok && adapter.Webhook.Enabled() {
+		secret, err := configs.ReadSecret(adapter.Webhook.SecretFile, adapter.Webhook.SecretEnv)
+		if err != nil {
+			return "", fmt.Errorf("failed to read webhook secret of %s: %w", hospital, err)
+		}
+		if secret != "" {
+			return secret, nil
+		}
+	}
+	if secret := s.config.HISWebhook.Secrets[hospital]; secret != "" {
+		return secret, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrHISEventUnknownHospital, hospital)
+}
+
+// verifyHISEventSignature checks the signature before the timestamp, so only
+// senders holding the secret learn whether their clock is off.
+func verifyHISEventSignature(secret string, timestamp string, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or invalid timestamp", ErrHISEventSignature)
+	}
+
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("%w: expected sha256=<hex>", ErrHISEventSignature)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(sent, mac.Sum(nil)) {
+		return ErrHISEventSignature
+	}
+
+	if tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+	signedAt := time.Unix(seconds, 0)
+	if age := now.Sub(signedAt); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed at %s", ErrHISEventExpired, signedAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// UnauthenticatedDeliveries returns how many deliveries were turned away
+// without being recorded since the service started.
+func (s *HISEventService) UnauthenticatedDeliveries() int64 {
+	return s.unauthenticated.Load()
+}
+
+// ListDeliveries returns recorded deliveries, newest first, optionally of one
+// hospital and with one status.
+func (s *HISEventService) ListDeliveries(hospital string, status string, limit int, offset int) ([]*models.HISEventDelivery, error) {
+	if limit <= 0 || limit > hisEventDeliveryPageMaxSize {
+		limit = hisEventDeliveryPageMaxSize
+	}
+
+	return s.eventRepo.ListDeliveries(hospital, status, limit, offset)
+}
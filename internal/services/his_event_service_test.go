@@ -0,0 +1,264 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/mockhis"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setupHISEventService(t *testing.T, config *configs.ApplicationConfig) (*HISEventService, *repositories.PatientRepository, *repositories.HISEventRepository) {
+	t.Helper()
+
+	db := setupPatientTestDB(t)
+	if err := db.AutoMigrate(&models.HISEventRecord{}, &models.HISEventDelivery{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	repo := repositories.NewPatientRepository(db)
+	eventRepo := repositories.NewHISEventRepository(db)
+	config.HISWebhook.Tolerance = 5 * time.Minute
+	config.HISWebhook.Secrets = map[string]string{"Hospital A": "secret-a"}
+	return NewHISEventService(NewPatientService(repo, config), eventRepo, config), repo, eventRepo
+}
+
+// signHISEvent returns the timestamp and signature headers of body signed at
+// signedAt.
+func signHISEvent(secret string, signedAt time.Time, body string) (string, string) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return timestamp, "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendHISEvent(service *HISEventService, hospital string, secret string, body string) (*models.HISEventDelivery, error) {
+	timestamp, signature := signHISEvent(secret, time.Now(), body)
+	return service.HandleEvent(hospital, timestamp, signature, []byte(body), "10.0.0.5")
+}
+
+func TestHandleEvent_Positive_MappedPatientSavedOnce(t *testing.T) {
+	t.Setenv("HIS_B_WEBHOOK_SECRET", "secret-b")
+	config := getTestConfig()
+	config.HISAPI.Adapters = map[string]configs.HISAdapterConfig{
+		"Hospital B": {
+			Vendor:  configs.HISVendorREST,
+			BaseURL: "https://his.hospital-b.example.com",
+			Fields: map[string]configs.HISFieldMapping{
+				"patient_hn":    {Path: "mrn"},
+				"first_name_en": {Path: "name.given"},
+				"gender":        {Path: "sex", Transform: configs.HISTransformMap, Values: map[string]string{"1": "M", "2": "F"}},
+			},
+			Webhook: configs.HISWebhookConfig{SecretEnv: "HIS_B_WEBHOOK_SECRET"},
+		},
+	}
+	service, repo, eventRepo := setupHISEventService(t, config)
+
+	body := `{"event_id": "evt-1", "type": "patient.created", "patient": {"mrn": "B-77", "name": {"given": "Wichai"}, "sex": "1"}}`
+	delivery, err := sendHISEvent(service, "Hospital B", "secret-b", body)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryApplied || delivery.PatientHN != "B-77" {
+		t.Errorf("Expected B-77 to be applied, got %s for %s", delivery.Status, delivery.PatientHN)
+	}
+
+	patient, err := repo.GetPatientByHN("B-77", "Hospital B")
+	if err != nil {
+		t.Fatalf("Expected the pushed patient to be saved, got: %v", err)
+	}
+	if patient.FirstNameEN == nil || *patient.FirstNameEN != "Wichai" || patient.Gender != "M" {
+		t.Error("Expected the vendor fields to be mapped")
+	}
+	if patient.LastFetchedAt == nil {
+		t.Error("Expected the pushed patient to count as fresh from HIS")
+	}
+
+	delivery, err = sendHISEvent(service, "Hospital B", "secret-b", body)
+	if err != nil {
+		t.Fatalf("Expected no error on redelivery, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryDuplicate {
+		t.Errorf("Expected the redelivery to be a duplicate, got %s", delivery.Status)
+	}
+
+	deliveries, err := eventRepo.ListDeliveries("Hospital B", "", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list deliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].RemoteAddr != "10.0.0.5" {
+		t.Fatalf("Expected both deliveries to be recorded, got %d", len(deliveries))
+	}
+	hash := sha256.Sum256([]byte(body))
+	if deliveries[0].PayloadSHA256 != hex.EncodeToString(hash[:]) {
+		t.Errorf("Expected the SHA-256 of the body, got %q", deliveries[0].PayloadSHA256)
+	}
+	if strings.Contains(deliveries[0].Payload, "Wichai") || !strings.Contains(deliveries[0].Payload, `"given":"[redacted]"`) {
+		t.Errorf("Expected the patient values to be redacted, got %s", deliveries[0].Payload)
+	}
+}
+
+func TestHandleEvent_Positive_NotificationRefreshesCachedPatient(t *testing.T) {
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	service, repo, _ := setupHISEventService(t, config)
+	cacheStalePatient(t, repo)
+
+	delivery, err := sendHISEvent(service, "Hospital A", "secret-a", `{"event_id": "evt-1", "type": "patient.updated", "patient_hn": "HN001"}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryApplied {
+		t.Errorf("Expected the refresh to be applied, got %s", delivery.Status)
+	}
+	patient, _ := repo.GetPatientByHN("HN001", "Hospital A")
+	if *patient.FirstNameEN != "Somchai" {
+		t.Errorf("Expected HIS's copy after the refresh, got first name %s", *patient.FirstNameEN)
+	}
+
+	delivery, err = sendHISEvent(service, "Hospital A", "secret-a", `{"event_id": "evt-2", "type": "patient.updated", "patient_hn": "HN002"}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryIgnored {
+		t.Errorf("Expected a patient that is not cached to be ignored, got %s", delivery.Status)
+	}
+}
+
+func TestHandleEvent_Negative_Rejected(t *testing.T) {
+	service, _, eventRepo := setupHISEventService(t, getTestConfig())
+	body := `{"event_id": "evt-1", "type": "patient.updated", "patient_hn": "HN001"}`
+	timestamp, signature := signHISEvent("secret-a", time.Now(), body)
+	staleTimestamp, staleSignature := signHISEvent("secret-a", time.Now().Add(-10*time.Minute), body)
+	_, wrongSignature := signHISEvent("wrong-secret", time.Now(), body)
+
+	tests := []struct {
+		name      string
+		hospital  string
+		timestamp string
+		signature string
+		body      string
+		want      error
+	}{
+		{"unknown hospital", "Hospital Z", timestamp, signature, body, ErrHISEventUnknownHospital},
+		{"wrong secret", "Hospital A", timestamp, wrongSignature, body, ErrHISEventSignature},
+		{"tampered body", "Hospital A", timestamp, signature, `{"event_id": "evt-1", "type": "patient.updated", "patient_hn": "HN002"}`, ErrHISEventSignature},
+		{"missing timestamp", "Hospital A", "", signature, body, ErrHISEventSignature},
+		{"replayed after the tolerance", "Hospital A", staleTimestamp, staleSignature, body, ErrHISEventExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery, err := service.HandleEvent(tt.hospital, tt.timestamp, tt.signature, []byte(tt.body), "10.0.0.5")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got: %v", tt.want, err)
+			}
+			if delivery.Status != models.HISDeliveryRejected || delivery.Payload != "" {
+				t.Errorf("Expected a rejected delivery without payload, got %s", delivery.Status)
+			}
+		})
+	}
+
+	for _, body := range []string{
+		`{"type": "patient.updated", "patient_hn": "HN001"}`,
+		`{"event_id": "evt-2", "type": "patient.deleted", "patient_hn": "HN001"}`,
+		`{"event_id": "evt-3", "type": "patient.created", "patient": {"patient_hn": "HN009", "hospital": "Hospital B"}}`,
+	} {
+		delivery, err := sendHISEvent(service, "Hospital A", "secret-a", body)
+		if !errors.Is(err, ErrInvalidHISEvent) {
+			t.Errorf("Expected ErrInvalidHISEvent for %s, got: %v", body, err)
+		}
+		if delivery.Payload != "" || delivery.PayloadSHA256 != "" {
+			t.Errorf("Expected a rejected delivery without payload for %s", body)
+		}
+	}
+
+	deliveries, _ := eventRepo.ListDeliveries("", models.HISDeliveryRejected, 20, 0)
+	if len(deliveries) != 4 {
+		t.Errorf("Expected only authenticated rejected deliveries to be recorded, got %d", len(deliveries))
+	}
+	if count := service.UnauthenticatedDeliveries(); count != 4 {
+		t.Errorf("Expected 4 unauthenticated deliveries to be counted, got %d", count)
+	}
+}
+
+func TestHandleEvent_Negative_FailedEventRetried(t *testing.T) {
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load mock HIS fixtures: %v", err)
+	}
+	mock := mockhis.NewServer(hospitals, mockhis.Options{})
+	var down atomic.Bool
+	down.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	config := getTestConfig()
+	config.HISAPI.BaseURL = server.URL
+	service, repo, _ := setupHISEventService(t, config)
+	cacheStalePatient(t, repo)
+
+	body := `{"event_id": "evt-1", "type": "patient.updated", "patient_hn": "HN001"}`
+	delivery, err := sendHISEvent(service, "Hospital A", "secret-a", body)
+	if !errors.Is(err, ErrHISUnavailable) {
+		t.Fatalf("Expected ErrHISUnavailable, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryFailed {
+		t.Errorf("Expected a failed delivery, got %s", delivery.Status)
+	}
+
+	down.Store(false)
+	delivery, err = sendHISEvent(service, "Hospital A", "secret-a", body)
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got: %v", err)
+	}
+	if delivery.Status != models.HISDeliveryApplied {
+		t.Errorf("Expected the retried event to be applied, got %s", delivery.Status)
+	}
+}
+
+func TestPurgeExpired_Positive_RemovesOldHISEventDeliveries(t *testing.T) {
+	config := getTestConfig()
+	config.Retention.HISEventDays = 30
+	service, _, eventRepo := setupHISEventService(t, config)
+
+	if _, err := sendHISEvent(service, "Hospital A", "secret-a", `{"event_id": "evt-new", "type": "patient.updated", "patient_hn": "HN001"}`); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	old := &models.HISEventDelivery{Hospital: "Hospital A", EventID: "evt-old", Status: models.HISDeliveryApplied, CreatedAt: time.Now().AddDate(0, 0, -31)}
+	if err := eventRepo.CreateDelivery(old); err != nil {
+		t.Fatalf("Failed to create delivery: %v", err)
+	}
+	if err := eventRepo.ClaimEvent(&models.HISEventRecord{Hospital: "Hospital A", EventID: "evt-old", ReceivedAt: old.CreatedAt}); err != nil {
+		t.Fatalf("Failed to claim event: %v", err)
+	}
+
+	if err := service.PurgeExpired(t.Context()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	deliveries, err := eventRepo.ListDeliveries("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list deliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventID != "evt-new" {
+		t.Errorf("Expected only the recent delivery to remain, got %d", len(deliveries))
+	}
+	if err := eventRepo.ClaimEvent(&models.HISEventRecord{Hospital: "Hospital A", EventID: "evt-old", ReceivedAt: time.Now()}); err != nil {
+		t.Errorf("Expected the old event ID to be forgotten, got: %v", err)
+	}
+}
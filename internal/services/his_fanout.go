@@ -0,0 +1,144 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+type hisFanOutAnswer struct {
+	index    int
+	patient  *models.Patient
+	err      error
+	duration time.Duration
+}
+
+// FanOutSearch looks a patient up in every configured HIS at once, for patients
+// arriving on referral from an unknown hospital. All lookups share one
+// HIS_FANOUT_TIMEOUT deadline; a HIS that has not answered by then is reported
+// as timed out. Other hospitals' HIS are only asked when
+// HIS_FANOUT_CROSS_HOSPITAL is on and the staff member has one of
+// HIS_FANOUT_ROLES; otherwise they are reported as forbidden without a call.
+// Patients returned are cached like any HIS lookup. Cancelling ctx stops the
+// search.
+func (s *PatientService) FanOutSearch(ctx context.Context, patientID string, staff *models.Staff) (*models.HISFanOutResponse, error) {
+	hospitals := s.hisAdapters.Hospitals()
+	if len(hospitals) == 0 {
+		// Every hospital shares the HIS_API_BASE_URL adapter
+		hospitals = []string{staff.Hospital}
+	}
+
+	timeout := s.config.HISAPI.FanOutTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	searchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	answers := make(chan hisFanOutAnswer, len(hospitals))
+	asked := 0
+	for i, hospital := range hospitals {
+		if !s.canFanOutTo(staff, hospital) {
+			continue
+		}
+		asked++
+		go func() {
+			callStarted := time.Now()
+			patient, err := s.searchPatientFromHIS(searchCtx, hospital, patientID)
+			answers <- hisFanOutAnswer{index: i, patient: patient, err: err, duration: time.Since(callStarted)}
+		}()
+	}
+
+	// Stop waiting at the deadline even if an adapter ignores it
+	received := make([]*hisFanOutAnswer, len(hospitals))
+wait:
+	for range asked {
+		select {
+		case answer := <-answers:
+			received[answer.index] = &answer
+		case <-searchCtx.Done():
+			break wait
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := &models.HISFanOutResponse{
+		Patients: []*models.Patient{},
+		Sources:  make([]*models.HISSourceResult, len(hospitals)),
+	}
+	seen := map[string]bool{}
+	for i, hospital := range hospitals {
+		source := &models.HISSourceResult{Hospital: hospital}
+		response.Sources[i] = source
+
+		if !s.canFanOutTo(staff, hospital) {
+			source.Status = models.HISSourceForbidden
+			source.Error = ErrAccessDenied.Error()
+			continue
+		}
+
+		answer := received[i]
+		if answer == nil {
+			source.Status = models.HISSourceTimeout
+			source.DurationMS = time.Since(started).Milliseconds()
+			source.Error = ErrHISTimeout.Error()
+			continue
+		}
+		source.DurationMS = answer.duration.Milliseconds()
+
+		patient, err := answer.patient, answer.err
+		if err == nil && !s.canFanOutTo(staff, patient.Hospital) {
+			source.Status = models.HISSourceForbidden
+			source.Error = ErrAccessDenied.Error()
+			continue
+		}
+		if err == nil {
+			patient, err = s.cacheHISPatient(patient)
+		}
+
+		switch {
+		case errors.Is(err, ErrPatientNotFound):
+			source.Status = models.HISSourceNotFound
+		case errors.Is(err, ErrHISTimeout):
+			source.Status = models.HISSourceTimeout
+			source.Error = err.Error()
+		case err != nil:
+			source.Status = models.HISSourceError
+			source.Error = err.Error()
+		default:
+			source.Status = models.HISSourceFound
+			// Hospitals sharing one HIS return the same patient
+			key := patient.Hospital + "\x00" + patient.PatientHN
+			if !seen[key] {
+				seen[key] = true
+				response.Patients = append(response.Patients, patient)
+			}
+		}
+	}
+	response.Count = len(response.Patients)
+
+	return response, nil
+}
+
+// canFanOutTo is the access policy of fan-out searches: staff see the patients
+// of their own hospital, and those of other hospitals only when
+// HIS_FANOUT_CROSS_HOSPITAL is on and they have one of HIS_FANOUT_ROLES.
+func (s *PatientService) canFanOutTo(staff *models.Staff, hospital string) bool {
+	if hospital == staff.Hospital {
+		return true
+	}
+	if !s.config.HISAPI.FanOutCrossHospital {
+		return false
+	}
+	for _, role := range s.config.HISAPI.FanOutRoles {
+		if strings.EqualFold(role, staff.Role) {
+			return true
+		}
+	}
+	return false
+}
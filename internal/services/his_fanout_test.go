@@ -0,0 +1,139 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setupFanOutService(t *testing.T) *PatientService {
+	t.Helper()
+
+	hospitalA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A","national_id":"1234567890123","gender":"M"}`))
+	}))
+	hospitalB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patient_hn":"B-77","hospital":"Hospital B","national_id":"1234567890123","gender":"M"}`))
+	}))
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(func() {
+		hospitalA.Close()
+		hospitalB.Close()
+		slow.Close()
+		down.Close()
+	})
+
+	repo := repositories.NewPatientRepository(setupPatientTestDB(t))
+	config := getTestConfig()
+	config.HISAPI.FanOutTimeout = 200 * time.Millisecond
+	config.HISAPI.FanOutCrossHospital = true
+	config.HISAPI.FanOutRoles = []string{models.RoleAdmin}
+	config.HISAPI.Adapters = map[string]configs.HISAdapterConfig{
+		"Hospital A": {Vendor: configs.HISVendorREST, BaseURL: hospitalA.URL, Timeout: time.Second},
+		"Hospital B": {Vendor: configs.HISVendorREST, BaseURL: hospitalB.URL, Timeout: time.Second},
+		"Hospital C": {Vendor: configs.HISVendorREST, BaseURL: slow.URL, Timeout: 5 * time.Second},
+		"Hospital D": {Vendor: configs.HISVendorREST, BaseURL: down.URL, Timeout: time.Second},
+	}
+	return NewPatientService(repo, config)
+}
+
+func TestFanOutSearch_Positive_MergesAndReportsSources(t *testing.T) {
+	service := setupFanOutService(t)
+
+	started := time.Now()
+	response, err := service.FanOutSearch(t.Context(), "1234567890123", &models.Staff{Hospital: "Hospital A", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected the search to stop at the deadline, took %v", elapsed)
+	}
+
+	if response.Count != 2 || len(response.Patients) != 2 {
+		t.Fatalf("Expected 2 patients, got %d", response.Count)
+	}
+	if response.Patients[0].PatientHN != "HN001" || response.Patients[1].PatientHN != "B-77" {
+		t.Errorf("Expected HN001 and B-77 in hospital order, got %s and %s", response.Patients[0].PatientHN, response.Patients[1].PatientHN)
+	}
+	if response.Patients[1].Source != models.PatientSourceHIS {
+		t.Errorf("Expected source %s, got %s", models.PatientSourceHIS, response.Patients[1].Source)
+	}
+
+	want := map[string]string{
+		"Hospital A": models.HISSourceFound,
+		"Hospital B": models.HISSourceFound,
+		"Hospital C": models.HISSourceTimeout,
+		"Hospital D": models.HISSourceError,
+	}
+	if len(response.Sources) != len(want) {
+		t.Fatalf("Expected %d sources, got %d", len(want), len(response.Sources))
+	}
+	for _, source := range response.Sources {
+		if source.Status != want[source.Hospital] {
+			t.Errorf("Expected %s to be %s, got %s (%s)", source.Hospital, want[source.Hospital], source.Status, source.Error)
+		}
+	}
+}
+
+func TestFanOutSearch_Negative_OtherHospitalsFiltered(t *testing.T) {
+	service := setupFanOutService(t)
+
+	response, err := service.FanOutSearch(t.Context(), "1234567890123", &models.Staff{Hospital: "Hospital A", Role: "Doctor"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if response.Count != 1 || response.Patients[0].Hospital != "Hospital A" {
+		t.Fatalf("Expected only the Hospital A patient, got %d patients", response.Count)
+	}
+	if response.Sources[1].Hospital != "Hospital B" || response.Sources[1].Status != models.HISSourceForbidden {
+		t.Errorf("Expected Hospital B to be forbidden, got %s", response.Sources[1].Status)
+	}
+	if _, err := service.patientRepo.GetPatientByHN("B-77", "Hospital B"); err == nil {
+		t.Error("Expected a filtered patient not to be cached")
+	}
+}
+
+func TestFanOutSearch_Negative_CrossHospitalOff(t *testing.T) {
+	service := setupFanOutService(t)
+	service.config.HISAPI.FanOutCrossHospital = false
+
+	response, err := service.FanOutSearch(t.Context(), "1234567890123", &models.Staff{Hospital: "Hospital A", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if response.Count != 1 || response.Patients[0].Hospital != "Hospital A" {
+		t.Fatalf("Expected only the Hospital A patient, got %d patients", response.Count)
+	}
+	for _, source := range response.Sources[1:] {
+		if source.Status != models.HISSourceForbidden || source.DurationMS != 0 {
+			t.Errorf("Expected %s to be forbidden without a call, got %s after %dms", source.Hospital, source.Status, source.DurationMS)
+		}
+	}
+}
+
+func TestFanOutSearch_Negative_Cancelled(t *testing.T) {
+	service := setupFanOutService(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, err := service.FanOutSearch(ctx, "1234567890123", &models.Staff{Hospital: "Hospital A"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"time"
+)
+
+// findHISPatient looks up a patient missing locally in the HIS of the staff
+// member's hospital and caches it when it belongs to that hospital. Concurrent
+// lookups of the same identifier for the same hospital share one HIS call and
+// one upsert. A not found answer from HIS is remembered for HIS_NOT_FOUND_TTL,
+// so a burst of searches for an unknown patient reaches HIS once.
+func (s *PatientService) findHISPatient(staffHospital string, patientID string) (*models.Patient, error) {
+	key := hisLookupKey(staffHospital, patientID)
+	if s.knownNotFound(key) {
+		return nil, ErrPatientNotFound
+	}
+
+	result, err, _ := s.hisLookups.Do(key, func() (interface{}, error) {
+		patient, err := s.lookupHISPatient(staffHospital, patientID)
+		if err != nil {
+			return nil, err
+		}
+		if patient.Hospital != staffHospital {
+			// Not saved; the caller denies access
+			return patient, nil
+		}
+		return s.cacheHISPatient(patient)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers sharing a lookup each get their own copy
+	patient := *result.(*models.Patient)
+	return &patient, nil
+}
+
+// hisNotFoundSweepEvery is how many not found answers are remembered between
+// sweeps of the expired ones.
+const hisNotFoundSweepEvery = 256
+
+func hisLookupKey(hospital string, patientID string) string {
+	return hospital + "\x00" + patientID
+}
+
+func (s *PatientService) knownNotFound(key string) bool {
+	expiry, ok := s.hisNotFound.Load(key)
+	if !ok {
+		return false
+	}
+	if time.Now().Before(expiry.(time.Time)) {
+		return true
+	}
+	s.hisNotFound.CompareAndDelete(key, expiry)
+	return false
+}
+
+func (s *PatientService) rememberNotFound(key string) {
+	ttl := s.config.HISAPI.NotFoundTTL
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	s.hisNotFound.Store(key, now.Add(ttl))
+
+	// Expired answers are also dropped every hisNotFoundSweepEvery answers, or
+	// identifiers nobody searches again would pile up
+	if s.hisNotFoundStored.Add(1)%hisNotFoundSweepEvery == 0 {
+		s.hisNotFound.Range(func(key, expiry interface{}) bool {
+			if !now.Before(expiry.(time.Time)) {
+				s.hisNotFound.CompareAndDelete(key, expiry)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"agnos-middleware/internal/mockhis"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startCountingMockHIS serves the mock HIS fixtures, answering each call after
+// delay, and counts the calls.
+func startCountingMockHIS(t *testing.T, delay time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load mock HIS fixtures: %v", err)
+	}
+	mock := mockhis.NewServer(hospitals, mockhis.Options{})
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(delay)
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, calls
+}
+
+func TestSearchPatient_Positive_ConcurrentLookupsCoalesced(t *testing.T) {
+	db := setupPatientTestDB(t)
+	sqlDB, _ := db.DB()
+	// Every connection to :memory: would open an empty database
+	sqlDB.SetMaxOpenConns(1)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	baseURL, calls := startCountingMockHIS(t, 200*time.Millisecond)
+	config.HISAPI.BaseURL = baseURL
+	service := NewPatientService(repo, config)
+
+	id := "1234567890123"
+	var wg sync.WaitGroup
+	results := make([][]*models.Patient, 10)
+	errs := make([]error, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = service.SearchPatient(&models.PatientSearchRequest{ID: &id}, "Hospital A")
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("Expected no error, got: %v", errs[i])
+		}
+		if len(results[i]) != 1 || results[i][0].PatientHN != "HN001" {
+			t.Fatalf("Expected HN001 for every caller, got %v", results[i])
+		}
+	}
+	if results[0][0] == results[1][0] {
+		t.Error("Expected every caller to get its own copy of the patient")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 HIS call, got %d", got)
+	}
+
+	var count int64
+	db.Model(&models.PatientHistory{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected the patient to be saved once, got %d history entries", count)
+	}
+}
+
+func TestSearchPatient_Positive_NotFoundRemembered(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	baseURL, calls := startCountingMockHIS(t, 0)
+	config.HISAPI.BaseURL = baseURL
+	config.HISAPI.NotFoundTTL = 100 * time.Millisecond
+	service := NewPatientService(repo, config)
+
+	id := "0000000000000"
+	for i := 0; i < 3; i++ {
+		patients, err := service.SearchPatient(&models.PatientSearchRequest{ID: &id}, "Hospital A")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(patients) != 0 {
+			t.Fatalf("Expected no patients, got %d", len(patients))
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 HIS call within HIS_NOT_FOUND_TTL, got %d", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := service.SearchPatient(&models.PatientSearchRequest{ID: &id}, "Hospital A"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected HIS to be asked again after HIS_NOT_FOUND_TTL, got %d calls", got)
+	}
+}
+
+func TestRememberNotFound_Positive_ExpiredAnswersSweptPeriodically(t *testing.T) {
+	config := getTestConfig()
+	config.HISAPI.NotFoundTTL = 10 * time.Millisecond
+	service := NewPatientService(repositories.NewPatientRepository(setupPatientTestDB(t)), config)
+
+	for i := 0; i < hisNotFoundSweepEvery-1; i++ {
+		service.rememberNotFound(hisLookupKey("Hospital A", fmt.Sprintf("ID%d", i)))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// The next answer sweeps every expired one and keeps itself
+	service.rememberNotFound(hisLookupKey("Hospital A", "fresh"))
+
+	remembered := 0
+	service.hisNotFound.Range(func(key, expiry interface{}) bool {
+		remembered++
+		return true
+	})
+	if remembered != 1 {
+		t.Errorf("Expected only the fresh answer to be remembered, got %d", remembered)
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidHISPayload = errors.New("invalid HIS patient")
+
+// MapHISPayload maps a HIS lookup response the way the adapter configured by
+// config does, for trying out a mapping against a sample payload.
+func MapHISPayload(config configs.HISAdapterConfig, payload []byte) (*models.Patient, error) {
+	adapter := &restHISAdapter{config: config}
+	return adapter.decodePatient(bytes.NewReader(payload))
+}
+
+// MapPatient maps a patient object in the format of the hospital's HIS, using
+// the fields of its adapter. Hospitals without an adapter of their own send
+// patients under the patient JSON names.
+func (r *HISAdapterRegistry) MapPatient(hospital string, result map[string]interface{}) (*models.Patient, error) {
+	adapter := &restHISAdapter{hospital: hospital, config: r.config.HISAPI.Adapters[hospital]}
+	patient, err := adapter.mapPatient(result)
+	if err != nil {
+		return nil, err
+	}
+	if patient.Hospital == "" {
+		patient.Hospital = hospital
+	}
+	return patient, nil
+}
+
+// decodePatient reads the patient object at ResultPath and maps it with Fields.
+func (a *restHISAdapter) decodePatient(body io.Reader) (*models.Patient, error) {
+	if a.config.ResultPath == "" && len(a.config.Fields) == 0 {
+		var patient models.Patient
+		if err := json.NewDecoder(body).Decode(&patient); err != nil {
+			return nil, err
+		}
+		return &patient, nil
+	}
+
+	var document interface{}
+	if err := json.NewDecoder(body).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	result, ok := lookupJSONPath(document, a.config.ResultPath).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: no patient object at %q", ErrInvalidHISPayload, a.config.ResultPath)
+	}
+
+	return a.mapPatient(result)
+}
+
+// mapPatient reads each field listed in Fields of a decoded patient object from
+// its path, converts it and decodes the result as a patient. A value the
+// mapping cannot convert fails the whole patient rather than leaving the field
+// empty.
+func (a *restHISAdapter) mapPatient(result map[string]interface{}) (*models.Patient, error) {
+	mapped := make(map[string]interface{}, len(result)+len(a.config.Fields))
+	for field, value := range result {
+		mapped[field] = value
+	}
+
+	for field, mapping := range a.config.Fields {
+		value := lookupJSONPath(result, mapping.Path)
+		if value == nil {
+			delete(mapped, field)
+			continue
+		}
+		value, err := transformHISValue(value, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidHISPayload, field, err)
+		}
+		mapped[field] = value
+	}
+
+	data, err := json.Marshal(mapped)
+	if err != nil {
+		return nil, err
+	}
+	var patient models.Patient
+	if err := json.Unmarshal(data, &patient); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHISPayload, err)
+	}
+	return &patient, nil
+}
+
+func transformHISValue(value interface{}, mapping configs.HISFieldMapping) (interface{}, error) {
+	switch mapping.Transform {
+	case configs.HISTransformString:
+		return hisValueString(value)
+	case configs.HISTransformDate:
+		text, err := hisValueString(value)
+		if err != nil {
+			return nil, err
+		}
+		date, err := parseHISDate(text, mapping)
+		if err != nil {
+			return nil, err
+		}
+		return date.Format(time.RFC3339), nil
+	case configs.HISTransformMap:
+		code, err := hisValueString(value)
+		if err != nil {
+			return nil, err
+		}
+		if mapped, ok := mapping.Values[code]; ok {
+			return mapped, nil
+		}
+		if mapping.Default != nil {
+			return *mapping.Default, nil
+		}
+		return nil, fmt.Errorf("no mapping for code %q", code)
+	default:
+		return value, nil
+	}
+}
+
+// hisValueString returns a scalar JSON value as trimmed text.
+func hisValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("expected text or a number, got %T", value)
+	}
+}
+
+func parseHISDate(text string, mapping configs.HISFieldMapping) (time.Time, error) {
+	format := mapping.Format
+	if format == "" {
+		format = configs.DefaultHISDateFormat
+	}
+
+	value := text
+	if mapping.Calendar == configs.HISCalendarBuddhist {
+		// Convert the year first: 29/02/2567 is a valid date, but 2567 is not a
+		// Gregorian leap year
+		index, err := configs.BuddhistYearIndex(format)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(text) < index+4 {
+			return time.Time{}, fmt.Errorf("cannot parse %q as %s", text, format)
+		}
+		year, err := strconv.Atoi(text[index : index+4])
+		if err != nil || year <= configs.BuddhistEraOffset {
+			return time.Time{}, fmt.Errorf("cannot parse %q as %s: no Buddhist Era year", text, format)
+		}
+		value = text[:index] + fmt.Sprintf("%04d", year-configs.BuddhistEraOffset) + text[index+4:]
+	}
+
+	date, err := time.Parse(format, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q as %s", text, format)
+	}
+	return date, nil
+}
+
+// lookupJSONPath follows a dot separated path through decoded JSON; numeric
+// segments index arrays. It returns nil when the path does not exist.
+func lookupJSONPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil
+			}
+			value = current[index]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
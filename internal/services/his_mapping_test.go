@@ -0,0 +1,125 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadHISMapping writes an adapter file for Hospital B with the given result
+// path and fields and loads it.
+func loadHISMapping(t *testing.T, resultPath string, fields string) (configs.HISAdapterConfig, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "his_adapters.json")
+	file := `{"hospitals": {"Hospital B": {"base_url": "https://his.hospital-b.example.com", "result_path": "` + resultPath + `", "fields": ` + fields + `}}}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("Failed to write adapter file: %v", err)
+	}
+
+	adapters, err := configs.LoadHISAdapters(path)
+	if err != nil {
+		return configs.HISAdapterConfig{}, err
+	}
+	return adapters["Hospital B"], nil
+}
+
+func TestMapHISPayload_Positive_Transforms(t *testing.T) {
+	config, err := loadHISMapping(t, "$.data[0]", `{
+		"patient_hn": "mrn",
+		"national_id": {"path": "$['ids'][0]", "transform": "string"},
+		"first_name_th": "name.th.given",
+		"date_of_birth": {"path": "birth", "transform": "date", "format": "02/01/2006", "calendar": "buddhist"},
+		"gender": {"path": "sex", "transform": "map", "values": {"1": "M", "2": "F"}}
+	}`)
+	if err != nil {
+		t.Fatalf("Expected the mapping to load, got: %v", err)
+	}
+
+	patient, err := MapHISPayload(config, []byte(`{"data": [{
+		"mrn": "B-77",
+		"ids": [1111222233334],
+		"name": {"th": {"given": "วิชัย"}},
+		"birth": "29/02/2567",
+		"sex": 2
+	}]}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if patient.PatientHN != "B-77" {
+		t.Errorf("Expected HN B-77, got %s", patient.PatientHN)
+	}
+	if patient.NationalID == nil || *patient.NationalID != "1111222233334" {
+		t.Errorf("Expected the numeric national ID as text, got %v", patient.NationalID)
+	}
+	if patient.FirstNameTH == nil || *patient.FirstNameTH != "วิชัย" {
+		t.Errorf("Expected the Thai first name, got %v", patient.FirstNameTH)
+	}
+	if want := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC); !patient.DateOfBirth.Equal(want) {
+		t.Errorf("Expected 29/02/2567 BE to be %v, got %v", want, patient.DateOfBirth)
+	}
+	if patient.Gender != "F" {
+		t.Errorf("Expected gender code 2 to map to F, got %s", patient.Gender)
+	}
+}
+
+func TestMapHISPayload_Negative_UnconvertibleValue(t *testing.T) {
+	config, err := loadHISMapping(t, "", `{
+		"date_of_birth": {"path": "birth", "transform": "date", "format": "02/01/2006"},
+		"gender": {"path": "sex", "transform": "map", "values": {"1": "M", "2": "F"}}
+	}`)
+	if err != nil {
+		t.Fatalf("Expected the mapping to load, got: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		field   string
+	}{
+		{"unknown gender code", `{"patient_hn": "B-1", "birth": "15/03/1985", "sex": "9"}`, "gender"},
+		{"date in another format", `{"patient_hn": "B-1", "birth": "1985-03-15", "sex": "1"}`, "date_of_birth"},
+		{"date as an object", `{"patient_hn": "B-1", "birth": {"day": 15}, "sex": "1"}`, "date_of_birth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MapHISPayload(config, []byte(tt.payload))
+			if !errors.Is(err, ErrInvalidHISPayload) {
+				t.Fatalf("Expected ErrInvalidHISPayload, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("Expected the error to name %s, got: %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestLoadHISAdapters_Negative_InvalidMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"unknown patient field", `{"blood_type": "abo"}`, "unknown patient field"},
+		{"unknown transform", `{"gender": {"path": "sex", "transform": "upper"}}`, "unknown transform"},
+		{"map without values", `{"gender": {"path": "sex", "transform": "map"}}`, "needs values"},
+		{"date layout without a day", `{"date_of_birth": {"path": "dob", "transform": "date", "format": "01/2006"}}`, "Go date layout"},
+		{"buddhist year at no fixed position", `{"date_of_birth": {"path": "dob", "transform": "date", "format": "2/1/2006", "calendar": "buddhist"}}`, "fixed width"},
+		{"format without the date transform", `{"gender": {"path": "sex", "format": "02/01/2006"}}`, "only apply to the date transform"},
+		{"JSONPath filter", `{"patient_hn": "$.ids[?(@.type=='HN')].value"}`, "neither an array index"},
+		{"missing path", `{"gender": {"transform": "string"}}`, "path is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadHISMapping(t, "", tt.fields)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
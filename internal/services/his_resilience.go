@@ -0,0 +1,135 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultHISAdapterName names the breaker of the adapter every hospital shares
+// when no adapter file is configured.
+const defaultHISAdapterName = "default"
+
+// resilientHISAdapter retries idempotent HIS calls that failed because HIS was
+// unreachable or overloaded, and fails fast while the hospital's circuit
+// breaker is open so a HIS outage does not hold every search for the full
+// timeout.
+type resilientHISAdapter struct {
+	adapter HISAdapter
+	breaker *utils.CircuitBreaker
+	retry   utils.RetryPolicy
+}
+
+func (a *resilientHISAdapter) FetchPatient(ctx context.Context, id string) (*models.Patient, error) {
+	var patient *models.Patient
+	err := a.call(ctx, true, func() error {
+		var err error
+		patient, err = a.adapter.FetchPatient(ctx, id)
+		return err
+	})
+	return patient, err
+}
+
+// PushPatient only retries updates: a retried create could register the
+// patient twice in HIS. With write back turned off it returns without touching
+// the breaker, since no call reaches HIS.
+func (a *resilientHISAdapter) PushPatient(ctx context.Context, patient *models.Patient, create bool) error {
+	if !a.WritesBack() {
+		return nil
+	}
+	return a.call(ctx, !create, func() error {
+		return a.adapter.PushPatient(ctx, patient, create)
+	})
+}
+
+func (a *resilientHISAdapter) WritesBack() bool {
+	writer, ok := a.adapter.(HISWriter)
+	return ok && writer.WritesBack()
+}
+
+func (a *resilientHISAdapter) HasChangeFeed() bool {
+	feed, ok := a.adapter.(HISChangeFeed)
+	return ok && feed.HasChangeFeed()
+}
+
+func (a *resilientHISAdapter) FetchChanges(ctx context.Context, since *time.Time, cursor string, limit int) (*HISChangePage, error) {
+	feed, ok := a.adapter.(HISChangeFeed)
+	if !ok || !feed.HasChangeFeed() {
+		return nil, ErrNoChangeFeed
+	}
+
+	var page *HISChangePage
+	err := a.call(ctx, true, func() error {
+		var err error
+		page, err = feed.FetchChanges(ctx, since, cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+func (a *resilientHISAdapter) call(ctx context.Context, idempotent bool, fn func() error) error {
+	if err := a.breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: %w", ErrHISUnavailable, err)
+	}
+
+	policy := a.retry
+	if !idempotent {
+		policy.MaxAttempts = 1
+	}
+	err := utils.Retry(ctx, policy, isTransientHISError, fn)
+
+	switch {
+	case err == nil || errors.Is(err, ErrPatientNotFound):
+		a.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		a.breaker.Skip()
+	case isTransientHISError(err):
+		a.breaker.Failure(err)
+	default:
+		// HIS answered, so it is up even though the call failed
+		a.breaker.Success()
+	}
+	return classifyHISError(err)
+}
+
+// classifyHISError wraps a failed HIS call in ErrHISTimeout, ErrHISUnavailable
+// or ErrHISBadResponse. Not found and cancelled calls are returned as they are.
+func classifyHISError(err error) error {
+	var statusErr *HISStatusError
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, ErrPatientNotFound), errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout(),
+		errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %w", ErrHISTimeout, err)
+	case isTransientHISError(err):
+		return fmt.Errorf("%w: %w", ErrHISUnavailable, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrHISBadResponse, err)
+	}
+}
+
+// isTransientHISError reports whether a HIS call failed because HIS could not
+// be reached, timed out or was overloaded, and may succeed if tried again.
+func isTransientHISError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *HISStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func getResilienceTestConfig(baseURL string) *configs.ApplicationConfig {
+	config := getTestConfig()
+	config.HISAPI.Adapters = map[string]configs.HISAdapterConfig{
+		"Hospital A": {Vendor: configs.HISVendorREST, BaseURL: baseURL, Timeout: time.Second, WriteBack: true},
+	}
+	config.HISAPI.RetryMaxAttempts = 3
+	config.HISAPI.RetryBaseDelay = time.Millisecond
+	config.HISAPI.RetryMaxDelay = 5 * time.Millisecond
+	config.HISAPI.BreakerFailureThreshold = 2
+	config.HISAPI.BreakerOpenTimeout = time.Hour
+	return config
+}
+
+func TestResilientHISAdapter_Positive_RetriesTransientFailure(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A"}`))
+	}))
+	defer server.Close()
+
+	registry := NewHISAdapterRegistry(getResilienceTestConfig(server.URL))
+	adapter, _ := registry.Adapter("Hospital A")
+
+	patient, err := adapter.FetchPatient(context.Background(), "HN001")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.PatientHN != "HN001" {
+		t.Errorf("Expected HN001, got %s", patient.PatientHN)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+	if state := registry.Status()[0].State; state != utils.BreakerClosed {
+		t.Errorf("Expected closed breaker, got %s", state)
+	}
+}
+
+func TestResilientHISAdapter_Negative_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/patient/search/HN404" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	registry := NewHISAdapterRegistry(getResilienceTestConfig(server.URL))
+	adapter, _ := registry.Adapter("Hospital A")
+
+	if _, err := adapter.FetchPatient(context.Background(), "HN404"); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got: %v", err)
+	}
+	var statusErr *HISStatusError
+	if _, err := adapter.FetchPatient(context.Background(), "bad"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HIS status 400, got: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected one attempt per lookup, got %d", calls.Load())
+	}
+
+	status := registry.Status()[0]
+	if status.State != utils.BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("Expected HIS answers to keep the breaker closed, got %+v", status)
+	}
+}
+
+func TestResilientHISAdapter_Negative_DoesNotRetryCreate(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	registry := NewHISAdapterRegistry(getResilienceTestConfig(server.URL))
+	adapter, _ := registry.Adapter("Hospital A")
+	patient := &models.Patient{PatientHN: "HN001", Hospital: "Hospital A", Gender: "M"}
+
+	if err := adapter.PushPatient(context.Background(), patient, true); err == nil {
+		t.Fatal("Expected create to fail")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected create not to be retried, got %d attempts", calls.Load())
+	}
+
+	if err := adapter.PushPatient(context.Background(), patient, false); err == nil {
+		t.Fatal("Expected update to fail")
+	}
+	if calls.Load() != 4 {
+		t.Errorf("Expected update to be retried 3 times, got %d attempts in total", calls.Load()-1)
+	}
+}
+
+func TestResilientHISAdapter_Negative_BreakerFailsFast(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := getResilienceTestConfig(server.URL)
+	config.HISAPI.RetryMaxAttempts = 1
+	registry := NewHISAdapterRegistry(config)
+	adapter, _ := registry.Adapter("Hospital A")
+
+	for i := 0; i < 2; i++ {
+		if _, err := adapter.FetchPatient(context.Background(), "HN001"); errors.Is(err, utils.ErrCircuitOpen) {
+			t.Fatalf("Expected HIS to be called while the breaker is closed, got: %v", err)
+		}
+	}
+
+	_, err := adapter.FetchPatient(context.Background(), "HN001")
+	if !errors.Is(err, ErrHISUnavailable) || !errors.Is(err, utils.ErrCircuitOpen) {
+		t.Errorf("Expected open breaker error, got: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected no HIS call while the breaker is open, got %d calls", calls.Load())
+	}
+
+	status := registry.Status()[0]
+	if status.Name != "Hospital A" || status.State != utils.BreakerOpen || status.RetryAt == nil {
+		t.Errorf("Expected open breaker for Hospital A, got %+v", status)
+	}
+}
+
+func TestResilientHISAdapter_Positive_BreakerClosesAfterTrial(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"patient_hn":"HN001","hospital":"Hospital A"}`))
+	}))
+	defer server.Close()
+
+	config := getResilienceTestConfig(server.URL)
+	config.HISAPI.RetryMaxAttempts = 1
+	config.HISAPI.BreakerOpenTimeout = 20 * time.Millisecond
+	registry := NewHISAdapterRegistry(config)
+	adapter, _ := registry.Adapter("Hospital A")
+
+	for i := 0; i < 2; i++ {
+		adapter.FetchPatient(context.Background(), "HN001")
+	}
+	if state := registry.Status()[0].State; state != utils.BreakerOpen {
+		t.Fatalf("Expected open breaker, got %s", state)
+	}
+
+	healthy.Store(true)
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := adapter.FetchPatient(context.Background(), "HN001"); err != nil {
+		t.Fatalf("Expected trial call to succeed, got: %v", err)
+	}
+	if state := registry.Status()[0].State; state != utils.BreakerClosed {
+		t.Errorf("Expected closed breaker after a successful trial, got %s", state)
+	}
+}
+
+func TestResilientHISAdapter_Negative_PushWithoutWriteBackKeepsBreakerOpen(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := getResilienceTestConfig(server.URL)
+	config.HISAPI.Adapters["Hospital A"] = configs.HISAdapterConfig{Vendor: configs.HISVendorREST, BaseURL: server.URL, Timeout: time.Second}
+	config.HISAPI.RetryMaxAttempts = 1
+	config.HISAPI.BreakerOpenTimeout = 20 * time.Millisecond
+	registry := NewHISAdapterRegistry(config)
+	adapter, _ := registry.Adapter("Hospital A")
+
+	for i := 0; i < 2; i++ {
+		adapter.FetchPatient(context.Background(), "HN001")
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	patient := &models.Patient{PatientHN: "HN001", Hospital: "Hospital A", Gender: "M"}
+	if err := adapter.PushPatient(context.Background(), patient, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected no HIS call with write back off, got %d calls", calls.Load())
+	}
+	if state := registry.Status()[0].State; state == utils.BreakerClosed {
+		t.Error("Expected a push that never reached HIS not to close the breaker")
+	}
+}
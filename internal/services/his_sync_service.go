@@ -0,0 +1,150 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HISSyncJobName is the scheduler job that runs HISSyncService.Sync.
+const HISSyncJobName = "his-sync"
+
+var ErrNoChangeFeed = errors.New("HIS has no change feed")
+
+// HISSyncService pulls patients changed in HIS into the local database so local
+// search covers every patient, not only those someone already looked up. Only
+// HIS with a change feed are synced.
+type HISSyncService struct {
+	patientService *PatientService
+	syncRepo       *repositories.HISSyncRepository
+	config         *configs.ApplicationConfig
+}
+
+func NewHISSyncService(patientService *PatientService, syncRepo *repositories.HISSyncRepository, config *configs.ApplicationConfig) *HISSyncService {
+	return &HISSyncService{
+		patientService: patientService,
+		syncRepo:       syncRepo,
+		config:         config,
+	}
+}
+
+// Sync reads the change feed of every HIS from its checkpoint to the end. A HIS
+// that fails does not stop the others; the errors are returned together.
+// Cancelling ctx stops after the batch being saved.
+func (s *HISSyncService) Sync(ctx context.Context) error {
+	feeds := s.patientService.hisAdapters.ChangeFeeds()
+	sources := make([]string, 0, len(feeds))
+	for source := range feeds {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var errs []error
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.syncSource(ctx, source, feeds[source]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *HISSyncService) syncSource(ctx context.Context, source string, feed HISChangeFeed) error {
+	checkpoint, err := s.syncRepo.GetCheckpoint(source)
+	if errors.Is(err, sql.ErrNoRows) {
+		checkpoint = &models.HISSyncCheckpoint{Source: source}
+	} else if err != nil {
+		return err
+	}
+
+	batchSize := s.config.HISSync.BatchSize
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+
+	startedAt := time.Now()
+	checkpoint.LastRunAt = &startedAt
+	skippedBefore := checkpoint.PatientsSkipped
+	synced := 0
+	for {
+		// What the checkpoint goes back to when the page cannot be saved
+		previous := *checkpoint
+
+		page, err := feed.FetchChanges(ctx, checkpoint.Since, checkpoint.Cursor, batchSize)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				checkpoint.LastError = err.Error()
+			}
+			if saveErr := s.syncRepo.SaveCheckpoint(checkpoint); saveErr != nil {
+				return errors.Join(err, saveErr)
+			}
+			return err
+		}
+
+		if checkpoint.Cursor == "" {
+			// First page of a run: the end of this run is the next run's start
+			runSince := startedAt
+			if !page.AsOf.IsZero() {
+				runSince = page.AsOf
+			}
+			checkpoint.RunSince = &runSince
+		}
+		checkpoint.Cursor = page.NextCursor
+		if page.NextCursor == "" {
+			finishedAt := time.Now()
+			checkpoint.Since = checkpoint.RunSince
+			checkpoint.RunSince = nil
+			checkpoint.LastSuccessAt = &finishedAt
+			if checkpoint.PatientsSkipped == skippedBefore {
+				// Keep the error of a patient skipped earlier in this run
+				checkpoint.LastError = ""
+			}
+		}
+
+		fetchedAt := time.Now()
+		for _, patient := range page.Patients {
+			patient.LastFetchedAt = &fetchedAt
+		}
+		saved, err := s.syncRepo.SaveBatch(page.Patients, checkpoint)
+		if err != nil {
+			err = fmt.Errorf("failed to save synced patients: %w", err)
+			*checkpoint = previous
+			checkpoint.LastError = err.Error()
+			if saveErr := s.syncRepo.SaveCheckpoint(checkpoint); saveErr != nil {
+				return errors.Join(err, saveErr)
+			}
+			return err
+		}
+		for _, patient := range saved {
+			s.patientService.indexPatient(patient)
+		}
+		synced += len(saved)
+
+		if page.NextCursor == "" {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("[HIS Sync] Synced %d patients from %s\n", synced, source)
+	return nil
+}
+
+// Checkpoints returns the sync progress of every HIS synced so far.
+func (s *HISSyncService) Checkpoints() ([]*models.HISSyncCheckpoint, error) {
+	return s.syncRepo.ListCheckpoints()
+}
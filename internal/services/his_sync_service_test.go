@@ -0,0 +1,180 @@
+package services
+
+import (
+	"agnos-middleware/internal/mockhis"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func setupHISSyncService(t *testing.T, baseURL string) (*HISSyncService, *repositories.PatientRepository, *repositories.HISSyncRepository) {
+	t.Helper()
+
+	service, repo, syncRepo, _ := setupHISSyncServiceWithDB(t, baseURL)
+	return service, repo, syncRepo
+}
+
+func setupHISSyncServiceWithDB(t *testing.T, baseURL string) (*HISSyncService, *repositories.PatientRepository, *repositories.HISSyncRepository, *gorm.DB) {
+	t.Helper()
+
+	db := setupPatientTestDB(t)
+	if err := db.AutoMigrate(&models.HISSyncCheckpoint{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	repo := repositories.NewPatientRepository(db)
+	syncRepo := repositories.NewHISSyncRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = baseURL
+	config.HISAPI.ChangesPath = "/patient/changes"
+	config.HISSync.BatchSize = 2
+	return NewHISSyncService(NewPatientService(repo, config), syncRepo, config), repo, syncRepo, db
+}
+
+func TestSync_Positive_FullThenIncremental(t *testing.T) {
+	baseURL := startMockHIS(t)
+	service, repo, syncRepo := setupHISSyncService(t, baseURL)
+
+	if err := service.Sync(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	patient, err := repo.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected HN001 to be synced, got: %v", err)
+	}
+	if patient.LastFetchedAt == nil {
+		t.Error("Expected synced patient to record when it was fetched")
+	}
+	checkpoint, err := syncRepo.GetCheckpoint("default")
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if checkpoint.Since == nil || checkpoint.Cursor != "" || checkpoint.LastSuccessAt == nil {
+		t.Errorf("Expected a finished run, got %+v", checkpoint)
+	}
+	synced := checkpoint.PatientsSynced
+	if synced == 0 {
+		t.Fatal("Expected patients to be synced")
+	}
+
+	resp, err := http.DefaultClient.Do(mustRequest(t, http.MethodPut, baseURL+"/hospital-a/patient/HN001", `{"hospital":"Hospital A","national_id":"1234567890123","first_name_en":"Synced","last_name_en":"Jaidee","date_of_birth":"1985-03-15T00:00:00Z","gender":"M"}`))
+	if err != nil {
+		t.Fatalf("Failed to update mock HIS: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := service.Sync(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	patient, _ = repo.GetPatientByHN("HN001", "Hospital A")
+	if patient.FirstNameEN == nil || *patient.FirstNameEN != "Synced" {
+		t.Errorf("Expected the HIS change to be synced, got %v", patient.FirstNameEN)
+	}
+	checkpoint, _ = syncRepo.GetCheckpoint("default")
+	if checkpoint.PatientsSynced != synced+1 {
+		t.Errorf("Expected only the changed patient to be synced, got %d after %d", checkpoint.PatientsSynced, synced)
+	}
+}
+
+func TestSync_Negative_ResumesFromCursor(t *testing.T) {
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load mock HIS fixtures: %v", err)
+	}
+	mock := mockhis.NewServer(hospitals, mockhis.Options{})
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() && r.URL.Query().Get("cursor") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	service, _, syncRepo := setupHISSyncService(t, server.URL)
+
+	if err := service.Sync(context.Background()); err == nil {
+		t.Fatal("Expected an error when the second page fails")
+	}
+
+	checkpoint, err := syncRepo.GetCheckpoint("default")
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if checkpoint.Cursor == "" || checkpoint.Since != nil || checkpoint.LastError == "" {
+		t.Errorf("Expected an unfinished run with an error, got %+v", checkpoint)
+	}
+	if checkpoint.PatientsSynced != 2 {
+		t.Errorf("Expected the first page to be saved, got %d patients", checkpoint.PatientsSynced)
+	}
+
+	failing.Store(false)
+	if err := service.Sync(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	checkpoint, _ = syncRepo.GetCheckpoint("default")
+	if checkpoint.Cursor != "" || checkpoint.Since == nil || checkpoint.LastError != "" {
+		t.Errorf("Expected the run to finish, got %+v", checkpoint)
+	}
+	if checkpoint.PatientsSynced != 11 {
+		t.Errorf("Expected every fixture patient synced once, got %d", checkpoint.PatientsSynced)
+	}
+}
+
+func mustRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestSync_Negative_SkipsPatientThatCannotBeSaved(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"patients": [
+			{"patient_hn": "HN001", "hospital": "Hospital A", "gender": "M"},
+			{"patient_hn": "BAD", "hospital": "Hospital A", "gender": "M"},
+			{"patient_hn": "HN002", "hospital": "Hospital A", "gender": "F"}
+		], "next_cursor": ""}`))
+	}))
+	defer feed.Close()
+
+	service, repo, syncRepo, db := setupHISSyncServiceWithDB(t, feed.URL)
+	if err := db.Exec(`CREATE TRIGGER reject_bad BEFORE INSERT ON patient WHEN NEW.patient_hn = 'BAD'
+		BEGIN SELECT RAISE(ABORT, 'rejected by test'); END`).Error; err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	if err := service.Sync(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, hn := range []string{"HN001", "HN002"} {
+		if _, err := repo.GetPatientByHN(hn, "Hospital A"); err != nil {
+			t.Errorf("Expected %s to be synced past the bad patient, got: %v", hn, err)
+		}
+	}
+	checkpoint, err := syncRepo.GetCheckpoint("default")
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if checkpoint.Cursor != "" || checkpoint.PatientsSynced != 2 || checkpoint.PatientsSkipped != 1 {
+		t.Errorf("Expected a finished run with 2 synced and 1 skipped, got %+v", checkpoint)
+	}
+	if !strings.Contains(checkpoint.LastError, "BAD") {
+		t.Errorf("Expected the last error to name the skipped patient, got %q", checkpoint.LastError)
+	}
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"agnos-middleware/internal/utils"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrHL7Rejected marks messages that are rejected outright (AR) rather than
+// failing while being applied (AE).
+var ErrHL7Rejected = errors.New("HL7 message rejected")
+
+const hl7DeadLetterPageMaxSize = 200
+
+type HL7Service struct {
+	patientService *PatientService
+	patientRepo    *repositories.PatientRepository
+	mergeService   *PatientMergeService
+	hl7Repo        *repositories.HL7Repository
+	config         *configs.ApplicationConfig
+}
+
+func NewHL7Service(patientService *PatientService, patientRepo *repositories.PatientRepository, mergeService *PatientMergeService, hl7Repo *repositories.HL7Repository, config *configs.ApplicationConfig) *HL7Service {
+	return &HL7Service{
+		patientService: patientService,
+		patientRepo:    patientRepo,
+		mergeService:   mergeService,
+		hl7Repo:        hl7Repo,
+		config:         config,
+	}
+}
+
+// ProcessMessage applies one inbound HL7 v2 message and returns the ACK to send
+// back. Messages that fail are stored as dead letters.
+func (s *HL7Service) ProcessMessage(raw string, remoteAddr string) string {
+	msg, err := utils.ParseHL7(raw)
+	if err != nil {
+		s.deadLetter(nil, raw, remoteAddr, err)
+		return utils.BuildHL7ACK(nil, "", utils.HL7AckReject, err.Error())
+	}
+
+	if err := s.applyMessage(msg, remoteAddr); err != nil {
+		s.deadLetter(msg, raw, remoteAddr, err)
+		if errors.Is(err, ErrHL7Rejected) {
+			return utils.BuildHL7ACK(msg, "", utils.HL7AckReject, err.Error())
+		}
+		return utils.BuildHL7ACK(msg, "", utils.HL7AckError, err.Error())
+	}
+
+	return utils.BuildHL7ACK(msg, "", utils.HL7AckAccept, "")
+}
+
+func (s *HL7Service) ListDeadLetters(limit int, offset int) ([]*models.HL7DeadLetter, error) {
+	if limit <= 0 || limit > hl7DeadLetterPageMaxSize {
+		limit = hl7DeadLetterPageMaxSize
+	}
+
+	return s.hl7Repo.ListDeadLetters(limit, offset)
+}
+
+func (s *HL7Service) applyMessage(msg *utils.HL7Message, remoteAddr string) error {
+	code, event := msg.MessageType()
+	if code != "ADT" {
+		return fmt.Errorf("%w: unsupported message type %s", ErrHL7Rejected, code)
+	}
+
+	facility := msg.Component(msg.Field("MSH", 4), 1)
+	hospital, ok := s.config.HL7.Facilities[facility]
+	if !ok {
+		return fmt.Errorf("%w: unknown sending facility %q", ErrHL7Rejected, facility)
+	}
+	if !s.facilityAllowedFrom(facility, remoteAddr) {
+		return fmt.Errorf("%w: sending facility %q is not allowed from %s", ErrHL7Rejected, facility, remoteAddr)
+	}
+
+	switch event {
+	case "A04", "A08", "A28":
+		patient, err := s.patientFromPID(msg, hospital)
+		if err != nil {
+			return err
+		}
+		return s.upsertPatient(patient)
+	case "A40":
+		return s.mergePatients(msg, hospital)
+	default:
+		return fmt.Errorf("%w: unsupported event %s", ErrHL7Rejected, event)
+	}
+}
+
+// facilityAllowedFrom reports whether the connection's address is one of the
+// facility's HL7_FACILITY_SOURCES, so MSH-4 alone cannot pick the hospital.
+func (s *HL7Service) facilityAllowedFrom(facility string, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range s.config.HL7.FacilitySources[facility] {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *HL7Service) upsertPatient(patient *models.Patient) error {
+	fetchedAt := time.Now()
+	patient.LastFetchedAt = &fetchedAt
+	if err := s.patientRepo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHL7}); err != nil {
+		// An erased or deleted patient must not come back; HIS gets an AA so it
+		// stops resending
+		if errors.Is(err, repositories.ErrPatientErased) || errors.Is(err, repositories.ErrPatientDeleted) {
+			return nil
+		}
+		return err
+	}
+
+	if patient.MergedIntoID == nil {
+		s.patientService.indexPatient(patient)
+	}
+	return nil
+}
+
+// mergePatients handles ADT^A40: PID is the surviving patient and MRG-1 the HN
+// being retired. A retired HN that was never cached, or was already merged, has
+// nothing left to do.
+func (s *HL7Service) mergePatients(msg *utils.HL7Message, hospital string) error {
+	survivor, err := s.patientFromPID(msg, hospital)
+	if err != nil {
+		return err
+	}
+
+	retiredHN := msg.Component(firstRepetition(msg, msg.Field("MRG", 1)), 1)
+	if retiredHN == "" {
+		return fmt.Errorf("%w: MRG-1 prior patient identifier is required", ErrHL7Rejected)
+	}
+
+	if err := s.upsertPatient(survivor); err != nil {
+		return err
+	}
+
+	_, err = s.mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retiredHN,
+		Reason:     fmt.Sprintf("HL7 ADT^A40 %s", msg.ControlID()),
+	}, hospital, 0)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientAlreadyMerged) {
+			return nil
+		}
+		if errors.Is(err, ErrMergeSamePatient) {
+			return fmt.Errorf("%w: %v", ErrHL7Rejected, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// patientFromPID maps the PID segment. PID-3 carries the HN (type MR, or the
+// first identifier without a type), national ID (NI/CZ) and passport (PPN);
+// PID-5 names are sorted into Thai and English by script.
+func (s *HL7Service) patientFromPID(msg *utils.HL7Message, hospital string) (*models.Patient, error) {
+	patient := &models.Patient{Hospital: hospital}
+
+	for _, identifier := range msg.Repetitions(msg.Field("PID", 3)) {
+		value := msg.Component(identifier, 1)
+		if value == "" {
+			continue
+		}
+		switch strings.ToUpper(msg.Component(identifier, 5)) {
+		case "MR":
+			patient.PatientHN = value
+		case "":
+			if patient.PatientHN == "" {
+				patient.PatientHN = value
+			}
+		case "NI", "CZ":
+			patient.NationalID = &value
+		case "PPN":
+			patient.PassportID = &value
+		}
+	}
+	if patient.PatientHN == "" {
+		return nil, fmt.Errorf("%w: PID-3 hospital number is required", ErrHL7Rejected)
+	}
+
+	for _, name := range msg.Repetitions(msg.Field("PID", 5)) {
+		family := emptyToNil(stringPtr(msg.Component(name, 1)))
+		given := emptyToNil(stringPtr(msg.Component(name, 2)))
+		middle := emptyToNil(stringPtr(msg.Component(name, 3)))
+		if family == nil && given == nil {
+			continue
+		}
+
+		if isThai(name) {
+			if patient.FirstNameTH == nil && patient.LastNameTH == nil {
+				patient.FirstNameTH, patient.MiddleNameTH, patient.LastNameTH = given, middle, family
+			}
+		} else if patient.FirstNameEN == nil && patient.LastNameEN == nil {
+			patient.FirstNameEN, patient.MiddleNameEN, patient.LastNameEN = given, middle, family
+		}
+	}
+
+	if dob := msg.Component(msg.Field("PID", 7), 1); len(dob) >= 8 {
+		dateOfBirth, err := time.Parse("20060102", dob[:8])
+		if err != nil {
+			return nil, fmt.Errorf("%w: PID-7 date of birth %q is invalid", ErrHL7Rejected, dob)
+		}
+		patient.DateOfBirth = dateOfBirth
+	}
+
+	patient.Gender = strings.ToUpper(msg.Component(msg.Field("PID", 8), 1))
+
+	for _, telecom := range msg.Repetitions(msg.Field("PID", 13)) {
+		email := msg.Component(telecom, 4)
+		if strings.EqualFold(msg.Component(telecom, 3), "Internet") || strings.Contains(email, "@") {
+			if patient.Email == nil {
+				patient.Email = emptyToNil(&email)
+			}
+			continue
+		}
+
+		phone := msg.Component(telecom, 1)
+		if phone == "" {
+			phone = msg.Component(telecom, 6) + msg.Component(telecom, 7)
+		}
+		if patient.PhoneNumber == nil {
+			patient.PhoneNumber = emptyToNil(&phone)
+		}
+	}
+
+	return patient, nil
+}
+
+func (s *HL7Service) deadLetter(msg *utils.HL7Message, raw string, remoteAddr string, cause error) {
+	deadLetter := &models.HL7DeadLetter{
+		RemoteAddr: remoteAddr,
+		RawMessage: raw,
+		Error:      cause.Error(),
+	}
+	if msg != nil {
+		code, event := msg.MessageType()
+		deadLetter.ControlID = msg.ControlID()
+		deadLetter.MessageType = code + "^" + event
+		deadLetter.Facility = msg.Component(msg.Field("MSH", 4), 1)
+	}
+
+	if err := s.hl7Repo.CreateDeadLetter(deadLetter); err != nil {
+		fmt.Printf("[HL7] Failed to store dead letter: %v\n", err)
+	}
+	fmt.Printf("[HL7] Message %s from %s failed: %v\n", deadLetter.ControlID, remoteAddr, cause)
+}
+
+func firstRepetition(msg *utils.HL7Message, value string) string {
+	repetitions := msg.Repetitions(value)
+	if len(repetitions) == 0 {
+		return ""
+	}
+	return repetitions[0]
+}
+
+func isThai(value string) bool {
+	for _, r := range value {
+		if unicode.Is(unicode.Thai, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func setupHL7Test(t *testing.T) (*gorm.DB, *repositories.PatientRepository, *HL7Service) {
+	db := setupPatientTestDB(t)
+	err := db.AutoMigrate(&models.PatientMatchCandidate{}, &models.PatientMerge{}, &models.HL7DeadLetter{})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	config := getTestConfig()
+	config.HL7.Facilities = map[string]string{"HOSPA": "Hospital A", "HOSPB": "Hospital B"}
+	config.HL7.FacilitySources = map[string][]netip.Prefix{
+		"HOSPA": {netip.MustParsePrefix("127.0.0.1/32")},
+		"HOSPB": {netip.MustParsePrefix("10.1.0.0/16")},
+	}
+
+	repo := repositories.NewPatientRepository(db)
+	patientService := NewPatientService(repo, config)
+	mergeService := NewPatientMergeService(repo, repositories.NewPatientMergeRepository(db))
+	service := NewHL7Service(patientService, repo, mergeService, repositories.NewHL7Repository(db), config)
+
+	return db, repo, service
+}
+
+func hl7Message(event string, controlID string, segments ...string) string {
+	msh := "MSH|^~\\&|HIS|HOSPA|AGNOS|AGNOS|20240101120000||ADT^" + event + "^ADT_A01|" + controlID + "|P|2.5"
+	return strings.Join(append([]string{msh}, segments...), "\r")
+}
+
+func TestHL7ProcessMessage_Positive_RegisterAndUpdate(t *testing.T) {
+	_, repo, service := setupHL7Test(t)
+
+	ack := service.ProcessMessage(hl7Message("A04", "MSG001",
+		"EVN|A04|20240101120000",
+		"PID|1||HN500^^^HOSPA^MR~1234567890123^^^TH^NI||ใจดี^สมชาย~Jaidee^Somchai||19850315|M|||||0891234567~^NET^Internet^somchai@email.com",
+	), "127.0.0.1")
+	if !strings.Contains(ack, "MSA|AA|MSG001") {
+		t.Fatalf("Expected AA ack, got: %q", ack)
+	}
+
+	patient, err := repo.GetPatientByHN("HN500", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected patient to be cached, got error: %v", err)
+	}
+
+	if patient.NationalID == nil || *patient.NationalID != "1234567890123" {
+		t.Errorf("Expected national ID 1234567890123, got %v", patient.NationalID)
+	}
+	if patient.FirstNameTH == nil || *patient.FirstNameTH != "สมชาย" || patient.LastNameEN == nil || *patient.LastNameEN != "Jaidee" {
+		t.Errorf("Expected Thai and English names to be mapped, got %v %v", patient.FirstNameTH, patient.LastNameEN)
+	}
+	if patient.Email == nil || *patient.Email != "somchai@email.com" {
+		t.Errorf("Expected email to be mapped, got %v", patient.Email)
+	}
+	if patient.DateOfBirth.Format("2006-01-02") != "1985-03-15" {
+		t.Errorf("Expected date of birth 1985-03-15, got %s", patient.DateOfBirth.Format("2006-01-02"))
+	}
+
+	ack = service.ProcessMessage(hl7Message("A08", "MSG002",
+		"PID|1||HN500^^^HOSPA^MR||||||||||0800000000",
+	), "127.0.0.1")
+	if !strings.Contains(ack, "MSA|AA|MSG002") {
+		t.Fatalf("Expected AA ack, got: %q", ack)
+	}
+
+	patient, _ = repo.GetPatientByHN("HN500", "Hospital A")
+	if patient.PhoneNumber == nil || *patient.PhoneNumber != "0800000000" {
+		t.Errorf("Expected phone to be updated, got %v", patient.PhoneNumber)
+	}
+	if patient.FirstNameEN == nil || *patient.FirstNameEN != "Somchai" {
+		t.Error("Expected fields missing from the update to be kept")
+	}
+}
+
+func TestHL7ProcessMessage_Positive_Merge(t *testing.T) {
+	_, repo, service := setupHL7Test(t)
+
+	for i, hn := range []string{"HN600", "HN601"} {
+		service.ProcessMessage(hl7Message("A28", "REG"+hn,
+			"PID|1||"+hn+"^^^HOSPA^MR||Jaidee^Somchai||19850315|M",
+		), "127.0.0.1")
+		if _, err := repo.GetPatientByHN(hn, "Hospital A"); err != nil {
+			t.Fatalf("Failed to register patient %d: %v", i, err)
+		}
+	}
+
+	ack := service.ProcessMessage(hl7Message("A40", "MSG003",
+		"PID|1||HN600^^^HOSPA^MR||Jaidee^Somchai||19850315|M",
+		"MRG|HN601^^^HOSPA^MR",
+	), "127.0.0.1")
+	if !strings.Contains(ack, "MSA|AA|MSG003") {
+		t.Fatalf("Expected AA ack, got: %q", ack)
+	}
+
+	retired, err := repo.GetPatientByHN("HN601", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected retired patient to remain, got error: %v", err)
+	}
+	if retired.MergedIntoID == nil {
+		t.Error("Expected retired patient to be merged into the survivor")
+	}
+}
+
+func TestHL7ProcessMessage_Negative_DeadLetter(t *testing.T) {
+	_, _, service := setupHL7Test(t)
+
+	ack := service.ProcessMessage("PID|1||HN700", "127.0.0.1")
+	if !strings.Contains(ack, "MSA|AR|") {
+		t.Errorf("Expected AR ack for a malformed message, got: %q", ack)
+	}
+
+	ack = service.ProcessMessage(strings.Replace(hl7Message("A04", "MSG004", "PID|1||HN700^^^X^MR"), "HOSPA", "UNKNOWN", 1), "127.0.0.1")
+	if !strings.Contains(ack, "MSA|AR|MSG004") {
+		t.Errorf("Expected AR ack for an unknown facility, got: %q", ack)
+	}
+
+	// A connection may only speak for the facilities allowed from its address
+	ack = service.ProcessMessage(strings.Replace(hl7Message("A04", "MSG005", "PID|1||HN700^^^X^MR"), "HOSPA", "HOSPB", 1), "127.0.0.1:40000")
+	if !strings.Contains(ack, "MSA|AR|MSG005") {
+		t.Errorf("Expected AR ack for a facility sent from another source, got: %q", ack)
+	}
+
+	deadLetters, err := service.ListDeadLetters(10, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(deadLetters) != 3 {
+		t.Fatalf("Expected 3 dead letters, got %d", len(deadLetters))
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Match weights. A national ID match plus date of birth or name is enough to link
+// automatically; weaker evidence is queued for a data steward to review.
+const (
+	matchWeightNationalID   = 60
+	matchWeightPassportID   = 50
+	matchWeightName         = 20
+	matchWeightDateOfBirth  = 20
+	matchPenaltyNationalID  = -60
+	matchAutoLinkThreshold  = 80
+	matchReviewThreshold    = 40
+	mpiReindexBatchSize     = 500
+	mpiCandidatePageMaxSize = 200
+)
+
+var (
+	ErrMatchCandidateNotFound = errors.New("match candidate not found")
+	ErrMatchAlreadyReviewed   = errors.New("match candidate has already been reviewed")
+)
+
+type MPIService struct {
+	mpiRepo *repositories.MPIRepository
+}
+
+func NewMPIService(mpiRepo *repositories.MPIRepository) *MPIService {
+	return &MPIService{
+		mpiRepo: mpiRepo,
+	}
+}
+
+// IndexPatient assigns the patient an enterprise ID, linking it to registrations in
+// other hospitals that score above the auto-link threshold and queueing weaker
+// matches for review.
+func (s *MPIService) IndexPatient(patient *models.Patient) error {
+	candidates, err := s.mpiRepo.FindMatchCandidates(patient)
+	if err != nil {
+		return err
+	}
+
+	var best *models.Patient
+	bestScore := 0
+	for _, candidate := range candidates {
+		score, reasons := scoreMatch(patient, candidate)
+		if score < matchReviewThreshold {
+			continue
+		}
+
+		pair := newMatchCandidate(patient.ID, candidate.ID, score, reasons)
+		existing, err := s.mpiRepo.GetCandidatePair(pair.PatientID, pair.CandidateID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if existing != nil && existing.Status == models.MatchStatusRejected {
+			continue
+		}
+
+		sameEnterprise := patient.EnterpriseID != nil && candidate.EnterpriseID != nil &&
+			*patient.EnterpriseID == *candidate.EnterpriseID
+		canAutoLink := score >= matchAutoLinkThreshold && candidate.EnterpriseID != nil &&
+			(patient.EnterpriseID == nil || sameEnterprise)
+
+		switch {
+		case sameEnterprise:
+			pair.Status = models.MatchStatusLinked
+		case canAutoLink:
+			pair.Status = models.MatchStatusLinked
+			if score > bestScore {
+				best, bestScore = candidate, score
+			}
+		default:
+			pair.Status = models.MatchStatusPending
+		}
+
+		if err := s.mpiRepo.UpsertCandidate(pair); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case best != nil && patient.EnterpriseID == nil:
+		patient.EnterpriseID = best.EnterpriseID
+	case patient.EnterpriseID == nil:
+		enterpriseID, err := newEnterpriseID()
+		if err != nil {
+			return err
+		}
+		patient.EnterpriseID = &enterpriseID
+	default:
+		return nil
+	}
+
+	return s.mpiRepo.SetEnterpriseID(patient.ID, *patient.EnterpriseID)
+}
+
+// Reindex indexes every registration that has no enterprise ID yet.
+func (s *MPIService) Reindex() (int, error) {
+	indexed := 0
+	for {
+		patients, err := s.mpiRepo.GetPatientsWithoutEnterpriseID(mpiReindexBatchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(patients) == 0 {
+			return indexed, nil
+		}
+
+		for _, patient := range patients {
+			if err := s.IndexPatient(patient); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+}
+
+func (s *MPIService) ListCandidates(status string, limit int, offset int) ([]*models.PatientMatchCandidate, error) {
+	if limit <= 0 || limit > mpiCandidatePageMaxSize {
+		limit = mpiCandidatePageMaxSize
+	}
+
+	return s.mpiRepo.ListCandidates(status, limit, offset)
+}
+
+// LinkCandidate confirms a candidate pair: every registration of the candidate's
+// enterprise patient joins the patient's enterprise patient.
+func (s *MPIService) LinkCandidate(candidateID int, staffID int) (*models.PatientMatchCandidate, error) {
+	candidate, err := s.getPendingCandidate(candidateID)
+	if err != nil {
+		return nil, err
+	}
+
+	target := ""
+	if candidate.Patient.EnterpriseID != nil {
+		target = *candidate.Patient.EnterpriseID
+	} else if target, err = newEnterpriseID(); err != nil {
+		return nil, err
+	}
+
+	markReviewed(candidate, models.MatchStatusLinked, staffID)
+	if err := s.mpiRepo.LinkCandidate(candidate, target); err != nil {
+		return nil, err
+	}
+	candidate.Patient.EnterpriseID = &target
+	candidate.Candidate.EnterpriseID = &target
+
+	return candidate, nil
+}
+
+func (s *MPIService) RejectCandidate(candidateID int, staffID int) (*models.PatientMatchCandidate, error) {
+	candidate, err := s.getPendingCandidate(candidateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidate, s.review(candidate, models.MatchStatusRejected, staffID)
+}
+
+// UnlinkPatient splits a registration off into its own enterprise patient. The
+// pairs it formed with the rest of its former group are marked rejected so that
+// re-indexing does not link them again.
+func (s *MPIService) UnlinkPatient(patientID int, staffID int) (*models.Patient, error) {
+	patient, err := s.mpiRepo.GetPatientByID(patientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	if patient.EnterpriseID != nil {
+		group, err := s.mpiRepo.GetPatientsByEnterpriseID(*patient.EnterpriseID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, other := range group {
+			if other.ID == patient.ID {
+				continue
+			}
+
+			score, reasons := scoreMatch(patient, other)
+			pair := newMatchCandidate(patient.ID, other.ID, score, reasons)
+			existing, err := s.mpiRepo.GetCandidatePair(pair.PatientID, pair.CandidateID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			if existing != nil {
+				pair = existing
+			}
+
+			if err := s.review(pair, models.MatchStatusRejected, staffID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	enterpriseID, err := newEnterpriseID()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mpiRepo.SetEnterpriseID(patient.ID, enterpriseID); err != nil {
+		return nil, err
+	}
+	patient.EnterpriseID = &enterpriseID
+
+	return patient, nil
+}
+
+func (s *MPIService) GetEnterprisePatient(enterpriseID string) ([]*models.Patient, error) {
+	patients, err := s.mpiRepo.GetPatientsByEnterpriseID(enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(patients) == 0 {
+		return nil, ErrPatientNotFound
+	}
+
+	return patients, nil
+}
+
+func (s *MPIService) GetStats() (*models.MPIStatsResponse, error) {
+	return s.mpiRepo.CountStats()
+}
+
+func (s *MPIService) getPendingCandidate(candidateID int) (*models.PatientMatchCandidate, error) {
+	candidate, err := s.mpiRepo.GetCandidate(candidateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMatchCandidateNotFound
+		}
+		return nil, err
+	}
+
+	if candidate.Status != models.MatchStatusPending {
+		return nil, ErrMatchAlreadyReviewed
+	}
+
+	return candidate, nil
+}
+
+func (s *MPIService) review(candidate *models.PatientMatchCandidate, status string, staffID int) error {
+	markReviewed(candidate, status, staffID)
+
+	return s.mpiRepo.SaveCandidate(candidate)
+}
+
+func markReviewed(candidate *models.PatientMatchCandidate, status string, staffID int) {
+	reviewedAt := time.Now()
+	candidate.Status = status
+	candidate.ReviewedBy = &staffID
+	candidate.ReviewedAt = &reviewedAt
+}
+
+func newMatchCandidate(patientID int, candidateID int, score int, reasons []string) *models.PatientMatchCandidate {
+	if candidateID < patientID {
+		patientID, candidateID = candidateID, patientID
+	}
+
+	return &models.PatientMatchCandidate{
+		PatientID:   patientID,
+		CandidateID: candidateID,
+		Score:       score,
+		Reasons:     strings.Join(reasons, ","),
+	}
+}
+
+// scoreMatch returns the weighted match score between two registrations and the
+// fields that contributed to it.
+func scoreMatch(a *models.Patient, b *models.Patient) (int, []string) {
+	score := 0
+	var reasons []string
+
+	if a.NationalID != nil && b.NationalID != nil {
+		if *a.NationalID == *b.NationalID {
+			score += matchWeightNationalID
+			reasons = append(reasons, "national_id")
+		} else {
+			score += matchPenaltyNationalID
+			reasons = append(reasons, "national_id_mismatch")
+		}
+	}
+
+	if a.PassportID != nil && b.PassportID != nil &&
+		strings.EqualFold(*a.PassportID, *b.PassportID) {
+		score += matchWeightPassportID
+		reasons = append(reasons, "passport_id")
+	}
+
+	if namesMatch(a.FirstNameEN, a.LastNameEN, b.FirstNameEN, b.LastNameEN) ||
+		namesMatch(a.FirstNameTH, a.LastNameTH, b.FirstNameTH, b.LastNameTH) {
+		score += matchWeightName
+		reasons = append(reasons, "name")
+	}
+
+	if sameDate(a.DateOfBirth, b.DateOfBirth) {
+		score += matchWeightDateOfBirth
+		reasons = append(reasons, "date_of_birth")
+	}
+
+	return score, reasons
+}
+
+func namesMatch(firstA, lastA, firstB, lastB *string) bool {
+	if firstA == nil || lastA == nil || firstB == nil || lastB == nil {
+		return false
+	}
+
+	a := normalizeName(*firstA + *lastA)
+	return a != "" && a == normalizeName(*firstB+*lastB)
+}
+
+// normalizeName lowercases the name and drops spaces and punctuation. Thai vowel
+// and tone marks are kept since they distinguish names.
+func normalizeName(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func sameDate(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func newEnterpriseID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate enterprise ID: %w", err)
+	}
+	return "EP" + strings.ToUpper(hex.EncodeToString(buf)), nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupMPITest(t *testing.T) (*PatientService, *MPIService, *repositories.PatientRepository) {
+	_, patientService, mpiService, patientRepo := setupMPITestWithDB(t)
+	return patientService, mpiService, patientRepo
+}
+
+func setupMPITestWithDB(t *testing.T) (*gorm.DB, *PatientService, *MPIService, *repositories.PatientRepository) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	err = db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientMatchCandidate{}, &models.PatientHistory{})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	patientRepo := repositories.NewPatientRepository(db)
+	mpiService := NewMPIService(repositories.NewMPIRepository(db))
+	patientService := NewPatientService(patientRepo, getTestConfig())
+	patientService.SetMPIService(mpiService)
+
+	return db, patientService, mpiService, patientRepo
+}
+
+func TestIndexPatient_Positive_AutoLinkByNationalID(t *testing.T) {
+	patientService, mpiService, _ := setupMPITest(t)
+
+	req := &models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}
+	first, err := patientService.CreatePatient(req, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	second, err := patientService.CreatePatient(req, "Hospital B", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if first.EnterpriseID == nil || second.EnterpriseID == nil {
+		t.Fatal("Expected both patients to have an enterprise ID")
+	}
+
+	if *first.EnterpriseID != *second.EnterpriseID {
+		t.Errorf("Expected same enterprise ID, got '%s' and '%s'", *first.EnterpriseID, *second.EnterpriseID)
+	}
+
+	stats, err := mpiService.GetStats()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if stats.Registrations != 2 || stats.People != 1 {
+		t.Errorf("Expected 2 registrations and 1 person, got %d and %d", stats.Registrations, stats.People)
+	}
+}
+
+func TestIndexPatient_Positive_WeakMatchQueuedForReview(t *testing.T) {
+	patientService, mpiService, _ := setupMPITest(t)
+
+	first, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	second, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("AB1234567"),
+		FirstNameEN: stringPtr("SOMCHAI"),
+		LastNameEN:  stringPtr("Jai-dee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital B", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *first.EnterpriseID == *second.EnterpriseID {
+		t.Fatal("Expected weak match not to be linked automatically")
+	}
+
+	candidates, err := mpiService.ListCandidates(models.MatchStatusPending, 10, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(candidates) != 1 {
+		t.Fatalf("Expected 1 pending candidate, got %d", len(candidates))
+	}
+
+	if candidates[0].Score != matchWeightName+matchWeightDateOfBirth {
+		t.Errorf("Expected score %d, got %d", matchWeightName+matchWeightDateOfBirth, candidates[0].Score)
+	}
+
+	linked, err := mpiService.LinkCandidate(candidates[0].ID, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *linked.Patient.EnterpriseID != *linked.Candidate.EnterpriseID {
+		t.Error("Expected linked patients to share an enterprise ID")
+	}
+
+	if _, err := mpiService.LinkCandidate(candidates[0].ID, 1); err != ErrMatchAlreadyReviewed {
+		t.Errorf("Expected ErrMatchAlreadyReviewed, got: %v", err)
+	}
+}
+
+func TestUnlinkPatient_Positive(t *testing.T) {
+	patientService, mpiService, patientRepo := setupMPITest(t)
+
+	req := &models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}
+	first, _ := patientService.CreatePatient(req, "Hospital A", 1)
+	second, _ := patientService.CreatePatient(req, "Hospital B", 1)
+
+	unlinked, err := mpiService.UnlinkPatient(second.ID, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *unlinked.EnterpriseID == *first.EnterpriseID {
+		t.Error("Expected unlinked patient to get a new enterprise ID")
+	}
+
+	// A later update re-indexes the patient, but the rejected pair must stay apart
+	reloaded, _ := patientRepo.GetPatientByHN(second.PatientHN, "Hospital B")
+	updatedAt := reloaded.UpdatedAt
+	updated, err := patientService.UpdatePatient(second.PatientHN, &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0891234567"),
+	}, &updatedAt, "Hospital B", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *updated.EnterpriseID == *first.EnterpriseID {
+		t.Error("Expected unlinked patient to stay unlinked after re-indexing")
+	}
+}
+
+func TestFindMatchCandidates_Negative_SkipsDateOfBirthOnlyAndMerged(t *testing.T) {
+	db, patientService, mpiService, _ := setupMPITestWithDB(t)
+
+	patient, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Same date of birth but nothing else in common
+	if _, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("CD7654321"),
+		FirstNameEN: stringPtr("Malee"),
+		LastNameEN:  stringPtr("Sukjai"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "F",
+	}, "Hospital B", 1); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	merged, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("AB1234567"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital C", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := db.Model(&models.Patient{}).Where("id = ?", merged.ID).UpdateColumn("merged_into_id", patient.ID).Error; err != nil {
+		t.Fatalf("Failed to merge patient: %v", err)
+	}
+
+	candidates, err := repositories.NewMPIRepository(db).FindMatchCandidates(patient)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Errorf("Expected no candidates, got %d", len(candidates))
+	}
+
+	stats, err := mpiService.GetStats()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if stats.Registrations != 2 {
+		t.Errorf("Expected merged registration not to be counted, got %d registrations", stats.Registrations)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatFHIR   = "fhir"
+)
+
+var ErrUnsupportedExportFormat = errors.New("unsupported export format: use csv, ndjson or fhir")
+
+// utf8BOM makes Excel open the CSV as UTF-8 so Thai names are not garbled.
+const utf8BOM = "\xef\xbb\xbf"
+
+var exportCSVHeader = []string{
+	"patient_hn", "hospital", "national_id", "passport_id",
+	"first_name_th", "middle_name_th", "last_name_th",
+	"first_name_en", "middle_name_en", "last_name_en",
+	"date_of_birth", "gender", "phone_number", "email", "updated_at",
+}
+
+type PatientExportService struct {
+	patientRepo *repositories.PatientRepository
+	auditRepo   *repositories.AuditRepository
+	config      *configs.ApplicationConfig
+}
+
+func NewPatientExportService(patientRepo *repositories.PatientRepository, auditRepo *repositories.AuditRepository, config *configs.ApplicationConfig) *PatientExportService {
+	return &PatientExportService{
+		patientRepo: patientRepo,
+		auditRepo:   auditRepo,
+		config:      config,
+	}
+}
+
+// ExportContentType returns the response content type for an export format.
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	case ExportFormatFHIR:
+		return models.FHIRContentType
+	default:
+		return ""
+	}
+}
+
+// ExportPatients streams every local patient matching req to w in the given
+// format and records the export, with its row count, in the audit log. Only
+// patients already cached locally are exported; HIS is not queried. Cancelling
+// ctx, e.g. when the client disconnects, stops the export.
+func (s *PatientExportService) ExportPatients(ctx context.Context, req *models.PatientSearchRequest, format string, w io.Writer, staffHospital string, staffID int) (int, error) {
+	writer := bufio.NewWriter(w)
+
+	var exporter patientExporter
+	switch format {
+	case ExportFormatCSV:
+		exporter = &csvPatientExporter{csv: csv.NewWriter(writer)}
+	case ExportFormatNDJSON:
+		exporter = &ndjsonPatientExporter{encoder: json.NewEncoder(writer)}
+	case ExportFormatFHIR:
+		exporter = &fhirPatientExporter{w: writer, baseURL: s.config.FHIR.BaseURL}
+	default:
+		return 0, ErrUnsupportedExportFormat
+	}
+
+	rows := 0
+	err := exporter.begin(writer)
+	if err == nil {
+		err = s.patientRepo.StreamPatients(ctx, req, staffHospital, func(patient *models.Patient) error {
+			if err := exporter.write(patient); err != nil {
+				return err
+			}
+			rows++
+			return nil
+		})
+	}
+	if err == nil {
+		err = exporter.end(rows)
+	}
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+
+	details := fmt.Sprintf("format=%s criteria=%s", format, strings.Join(searchCriteriaNames(req), ","))
+	if err != nil {
+		details += " error=" + err.Error()
+	}
+	if auditErr := s.auditRepo.CreateAuditLog(&models.AuditLog{
+		Action:   models.AuditActionPatientExport,
+		ActorID:  &staffID,
+		Hospital: staffHospital,
+		RowCount: rows,
+		Details:  details,
+	}); auditErr != nil {
+		fmt.Printf("[Export] Failed to audit export by staff %d: %v\n", staffID, auditErr)
+	}
+
+	return rows, err
+}
+
+// searchCriteriaNames lists the search fields that were set, by their query
+// name. Values are left out because the audit log never holds PII.
+func searchCriteriaNames(req *models.PatientSearchRequest) []string {
+	var names []string
+	value := reflect.ValueOf(req).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() == reflect.Ptr && !field.IsNil() && field.Elem().String() != "" {
+			names = append(names, value.Type().Field(i).Tag.Get("form"))
+		}
+	}
+	return names
+}
+
+type patientExporter interface {
+	begin(w *bufio.Writer) error
+	write(patient *models.Patient) error
+	end(rows int) error
+}
+
+type csvPatientExporter struct {
+	csv *csv.Writer
+}
+
+func (e *csvPatientExporter) begin(w *bufio.Writer) error {
+	if _, err := w.WriteString(utf8BOM); err != nil {
+		return err
+	}
+	return e.csv.Write(exportCSVHeader)
+}
+
+func (e *csvPatientExporter) write(patient *models.Patient) error {
+	dateOfBirth := ""
+	if !patient.DateOfBirth.IsZero() {
+		dateOfBirth = patient.DateOfBirth.Format("2006-01-02")
+	}
+
+	return e.csv.Write([]string{
+		csvSafe(patient.PatientHN),
+		csvSafe(patient.Hospital),
+		csvSafe(derefString(patient.NationalID)),
+		csvSafe(derefString(patient.PassportID)),
+		csvSafe(derefString(patient.FirstNameTH)),
+		csvSafe(derefString(patient.MiddleNameTH)),
+		csvSafe(derefString(patient.LastNameTH)),
+		csvSafe(derefString(patient.FirstNameEN)),
+		csvSafe(derefString(patient.MiddleNameEN)),
+		csvSafe(derefString(patient.LastNameEN)),
+		dateOfBirth,
+		csvSafe(patient.Gender),
+		csvSafe(derefString(patient.PhoneNumber)),
+		csvSafe(derefString(patient.Email)),
+		patient.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (e *csvPatientExporter) end(rows int) error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+type ndjsonPatientExporter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonPatientExporter) begin(w *bufio.Writer) error {
+	return nil
+}
+
+func (e *ndjsonPatientExporter) write(patient *models.Patient) error {
+	return e.encoder.Encode(patient)
+}
+
+func (e *ndjsonPatientExporter) end(rows int) error {
+	return nil
+}
+
+// fhirPatientExporter writes a searchset Bundle entry by entry. total comes
+// after the entries because it is only known at the end.
+type fhirPatientExporter struct {
+	w       *bufio.Writer
+	baseURL string
+	entries int
+}
+
+func (e *fhirPatientExporter) begin(w *bufio.Writer) error {
+	_, err := w.WriteString(`{"resourceType":"Bundle","type":"searchset","entry":[`)
+	return err
+}
+
+func (e *fhirPatientExporter) write(patient *models.Patient) error {
+	entry, err := json.Marshal(models.FHIRBundleEntry{
+		FullURL:  e.baseURL + "/Patient/" + patient.PatientHN,
+		Resource: ToFHIRPatient(patient),
+		Search:   &models.FHIRBundleSearch{Mode: "match"},
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.entries > 0 {
+		if err := e.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.entries++
+
+	_, err = e.w.Write(entry)
+	return err
+}
+
+func (e *fhirPatientExporter) end(rows int) error {
+	_, err := fmt.Fprintf(e.w, `],"total":%d}`, rows)
+	return err
+}
+
+// csvSafe stops spreadsheet apps from running a cell as a formula. Only phone
+// numbers such as +66812345678, a + followed by digits alone, are left alone;
+// anything else starting with = + - @ tab or CR is quoted, even =1-1.
+func csvSafe(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+	if value[0] == '+' && len(value) > 1 && strings.Trim(value[1:], "0123456789") == "" {
+		return value
+	}
+	return "'" + value
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupExportTest(t *testing.T) (*repositories.AuditRepository, *PatientExportService) {
+	db := setupPatientTestDB(t)
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	repo := repositories.NewPatientRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	config := getTestConfig()
+	config.FHIR.BaseURL = "http://localhost:8080/fhir"
+
+	for _, patient := range []*models.Patient{
+		{PatientHN: "HN001", Hospital: "Hospital A", NationalID: stringPtr("1234567890123"), FirstNameTH: stringPtr("สมชาย"), LastNameEN: stringPtr("=cmd"), PhoneNumber: stringPtr("+66891234567"), Gender: "M", DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN002", Hospital: "Hospital A", NationalID: stringPtr("9876543210987"), Gender: "F", DateOfBirth: time.Date(1990, 7, 20, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN003", Hospital: "Hospital B", NationalID: stringPtr("1111222233334"), Gender: "M", DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+			t.Fatalf("Failed to create patient: %v", err)
+		}
+	}
+
+	return auditRepo, NewPatientExportService(repo, auditRepo, config)
+}
+
+func TestExportPatients_Positive_CSV(t *testing.T) {
+	auditRepo, service := setupExportTest(t)
+
+	var buf bytes.Buffer
+	rows, err := service.ExportPatients(context.Background(), &models.PatientSearchRequest{}, ExportFormatCSV, &buf, "Hospital A", 7)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if rows != 2 {
+		t.Fatalf("Expected 2 rows, got %d", rows)
+	}
+
+	output := buf.String()
+	if !strings.HasPrefix(output, utf8BOM+"patient_hn,") {
+		t.Error("Expected CSV to start with a UTF-8 BOM and the header")
+	}
+	if !strings.Contains(output, "สมชาย") || !strings.Contains(output, "+66891234567") {
+		t.Error("Expected Thai name and phone number to be exported unchanged")
+	}
+	if !strings.Contains(output, "'=cmd") {
+		t.Error("Expected formula-like cell to be escaped")
+	}
+	if strings.Contains(output, "HN003") {
+		t.Error("Expected patients of other hospitals to be excluded")
+	}
+
+	entries, err := auditRepo.ListAuditLogs(models.AuditActionPatientExport, "Hospital A", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list audit logs: %v", err)
+	}
+	if len(entries) != 1 || entries[0].RowCount != 2 || entries[0].ActorID == nil || *entries[0].ActorID != 7 {
+		t.Errorf("Expected one audit entry with 2 rows by staff 7, got %+v", entries)
+	}
+}
+
+func TestExportPatients_Positive_NDJSONAndFHIR(t *testing.T) {
+	_, service := setupExportTest(t)
+
+	var buf bytes.Buffer
+	req := &models.PatientSearchRequest{Gender: stringPtr("M")}
+	if _, err := service.ExportPatients(context.Background(), req, ExportFormatNDJSON, &buf, "Hospital A", 7); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 NDJSON line, got %d", len(lines))
+	}
+	var patient models.Patient
+	if err := json.Unmarshal([]byte(lines[0]), &patient); err != nil || patient.PatientHN != "HN001" {
+		t.Errorf("Expected HN001 as NDJSON, got %q (%v)", lines[0], err)
+	}
+
+	buf.Reset()
+	if _, err := service.ExportPatients(context.Background(), &models.PatientSearchRequest{}, ExportFormatFHIR, &buf, "Hospital A", 7); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var bundle models.FHIRBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatalf("Expected a valid FHIR Bundle, got error: %v", err)
+	}
+	if bundle.Total != 2 || len(bundle.Entry) != 2 {
+		t.Errorf("Expected 2 entries, got total %d with %d entries", bundle.Total, len(bundle.Entry))
+	}
+}
+
+func TestExportPatients_Negative_UnsupportedFormat(t *testing.T) {
+	_, service := setupExportTest(t)
+
+	var buf bytes.Buffer
+	if _, err := service.ExportPatients(context.Background(), &models.PatientSearchRequest{}, "xlsx", &buf, "Hospital A", 7); err != ErrUnsupportedExportFormat {
+		t.Errorf("Expected ErrUnsupportedExportFormat, got: %v", err)
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Somchai", "Somchai"},
+		{"+66891234567", "+66891234567"},
+		{"=1-1", "'=1-1"},
+		{"-1-1", "'-1-1"},
+		{"+1-1", "'+1-1"},
+		{"+", "'+"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=cmd", "'\t=cmd"},
+		{"\r1", "'\r1"},
+	}
+
+	for _, tt := range tests {
+		if got := csvSafe(tt.value); got != tt.want {
+			t.Errorf("csvSafe(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
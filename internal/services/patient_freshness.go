@@ -0,0 +1,110 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// checkFreshness marks a patient cached from HIS more than HIS_CACHE_TTL ago as
+// stale-cache and starts a background refresh from HIS. The stale copy is
+// served as is so the caller never waits on HIS. Locally registered patients
+// are never stale.
+func (s *PatientService) checkFreshness(patient *models.Patient) {
+	if patient.Source == "" {
+		patient.Source = models.PatientSourceLocal
+	}
+	if !s.isStale(patient) {
+		return
+	}
+
+	patient.Source = models.PatientSourceStaleCache
+	s.refreshInBackground(patient)
+}
+
+func (s *PatientService) isStale(patient *models.Patient) bool {
+	ttl := s.config.HISAPI.CacheTTL
+	return ttl > 0 && patient.LastFetchedAt != nil && time.Since(*patient.LastFetchedAt) > ttl
+}
+
+// refreshInBackground refreshes the patient from HIS unless it is already being
+// refreshed or HIS_REFRESH_CONCURRENCY refreshes are running. A skipped refresh
+// is tried again on the next hit.
+func (s *PatientService) refreshInBackground(patient *models.Patient) {
+	if _, running := s.refreshing.LoadOrStore(patient.ID, struct{}{}); running {
+		return
+	}
+
+	select {
+	case s.refreshSlots <- struct{}{}:
+	default:
+		s.refreshing.Delete(patient.ID)
+		return
+	}
+
+	snapshot := *patient
+	go func() {
+		defer func() {
+			<-s.refreshSlots
+			s.refreshing.Delete(snapshot.ID)
+		}()
+
+		if _, err := s.refreshFromHIS(&snapshot); err != nil {
+			fmt.Printf("[HIS API] Background refresh of patient %s failed: %v\n", snapshot.PatientHN, err)
+		}
+	}()
+}
+
+// RefreshPatient fetches the patient from HIS now and saves the result,
+// whatever its age. HIS failures are returned as they are; no fallback applies.
+func (s *PatientService) RefreshPatient(hn string, staffHospital string) (*models.Patient, error) {
+	patient, err := s.resolvePatient(hn, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.refreshFromHIS(patient)
+}
+
+// refreshFromHIS looks the patient up in HIS by its most specific identifier and
+// saves HIS's copy over the cached one. The lookup, retries included, is
+// bounded by HIS_REFRESH_TIMEOUT.
+func (s *PatientService) refreshFromHIS(patient *models.Patient) (*models.Patient, error) {
+	id := patient.PatientHN
+	if patient.NationalID != nil && *patient.NationalID != "" {
+		id = *patient.NationalID
+	} else if patient.PassportID != nil && *patient.PassportID != "" {
+		id = *patient.PassportID
+	}
+
+	adapter, err := s.hisAdapters.Adapter(patient.Hospital)
+	if err != nil {
+		return nil, err
+	}
+	timeout := s.config.HISAPI.RefreshTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fetched, err := adapter.FetchPatient(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, fmt.Errorf("%w: HIS no longer knows %s", ErrPatientNotFound, patient.PatientHN)
+		}
+		return nil, err
+	}
+
+	// The adapter belongs to the patient's hospital, whatever name HIS uses for it
+	fetched.Hospital = patient.Hospital
+	if fetched.PatientHN != patient.PatientHN {
+		return nil, fmt.Errorf("%w: asked for %s of %s, got %s",
+			ErrHISBadResponse, patient.PatientHN, patient.Hospital, fetched.PatientHN)
+	}
+
+	fetched.Source = models.PatientSourceHIS
+	return s.cacheHISPatient(fetched)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// cacheStalePatient saves HN001 of Hospital A as fetched from HIS two days ago,
+// with a first name HIS no longer has.
+func cacheStalePatient(t *testing.T, repo *repositories.PatientRepository) {
+	t.Helper()
+
+	fetchedAt := time.Now().Add(-48 * time.Hour)
+	patient := &models.Patient{
+		PatientHN:     "HN001",
+		Hospital:      "Hospital A",
+		NationalID:    stringPtr("1234567890123"),
+		FirstNameEN:   stringPtr("Old"),
+		LastNameEN:    stringPtr("Jaidee"),
+		Gender:        "M",
+		DateOfBirth:   time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC),
+		LastFetchedAt: &fetchedAt,
+	}
+	if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to cache patient: %v", err)
+	}
+}
+
+func TestGetPatientByHN_Positive_StaleServedAndRefreshed(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	config.HISAPI.CacheTTL = 24 * time.Hour
+	config.HISAPI.RefreshConcurrency = 1
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	patient, err := service.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.Source != models.PatientSourceStaleCache {
+		t.Errorf("Expected source %s, got %s", models.PatientSourceStaleCache, patient.Source)
+	}
+	if *patient.FirstNameEN != "Old" {
+		t.Errorf("Expected the cached copy to be served, got first name %s", *patient.FirstNameEN)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		refreshed, err := repo.GetPatientByHN("HN001", "Hospital A")
+		if err != nil {
+			t.Fatalf("Failed to load patient: %v", err)
+		}
+		if *refreshed.FirstNameEN == "Somchai" {
+			if time.Since(*refreshed.LastFetchedAt) > time.Minute {
+				t.Errorf("Expected last_fetched_at to be updated, got %v", refreshed.LastFetchedAt)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the background refresh to update the patient, first name is still %s", *refreshed.FirstNameEN)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	patient, err = service.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.Source != models.PatientSourceLocal {
+		t.Errorf("Expected the refreshed copy to be fresh, got source %s", patient.Source)
+	}
+}
+
+func TestRefreshPatient_Positive(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	patient, err := service.RefreshPatient("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.Source != models.PatientSourceHIS {
+		t.Errorf("Expected source %s, got %s", models.PatientSourceHIS, patient.Source)
+	}
+	if *patient.FirstNameEN != "Somchai" {
+		t.Errorf("Expected first name Somchai, got %s", *patient.FirstNameEN)
+	}
+}
+
+func TestRefreshPatient_Positive_HISNamesHospitalDifferently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"patient_hn":    "HN001",
+			"hospital":      "HOSP-A",
+			"national_id":   "1234567890123",
+			"first_name_en": "Somchai",
+			"last_name_en":  "Jaidee",
+			"date_of_birth": "1985-03-15T00:00:00Z",
+			"gender":        "M",
+		})
+	}))
+	defer server.Close()
+
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = server.URL
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	patient, err := service.RefreshPatient("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if patient.Hospital != "Hospital A" || *patient.FirstNameEN != "Somchai" {
+		t.Errorf("Expected Somchai of Hospital A, got %s of %s", *patient.FirstNameEN, patient.Hospital)
+	}
+}
+
+func TestRefreshPatient_Negative_HISUnavailable(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = "http://127.0.0.1:1"
+	config.HISAPI.FallbackMode = configs.HISFallbackCacheOnly
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	if _, err := service.RefreshPatient("HN001", "Hospital A"); !errors.Is(err, ErrHISUnavailable) {
+		t.Errorf("Expected ErrHISUnavailable, got: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"database/sql"
+	"errors"
+)
+
+const patientHistoryPageMaxSize = 200
+
+type PatientHistoryService struct {
+	patientRepo *repositories.PatientRepository
+	historyRepo *repositories.PatientHistoryRepository
+}
+
+func NewPatientHistoryService(patientRepo *repositories.PatientRepository, historyRepo *repositories.PatientHistoryRepository) *PatientHistoryService {
+	return &PatientHistoryService{
+		patientRepo: patientRepo,
+		historyRepo: historyRepo,
+	}
+}
+
+// GetPatientHistory returns the changes recorded for the HN, newest first. The HN
+// is not resolved through merges, so a retired HN shows its own history.
+func (s *PatientHistoryService) GetPatientHistory(hn string, staffHospital string, limit int, offset int) ([]*models.PatientHistory, error) {
+	patient, err := s.patientRepo.GetPatientByHN(hn, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	if limit <= 0 || limit > patientHistoryPageMaxSize {
+		limit = patientHistoryPageMaxSize
+	}
+
+	return s.historyRepo.ListHistory(patient.ID, limit, offset)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"testing"
+)
+
+func TestGetPatientHistory_Positive_RecordsSourceActorAndDiff(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	service := NewPatientService(repo, getTestConfig())
+	historyService := NewPatientHistoryService(repo, repositories.NewPatientHistoryRepository(db))
+
+	patient, err := service.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		PhoneNumber: stringPtr("0891234567"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 7)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	updatedAt := patient.UpdatedAt
+	if _, err := service.UpdatePatient(patient.PatientHN, &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+	}, &updatedAt, "Hospital A", 8); err != nil {
+		t.Fatalf("Failed to update patient: %v", err)
+	}
+
+	// A HIS refresh with identical data is not a change
+	his := &models.Patient{
+		NationalID:  stringPtr("1234567890123"),
+		PhoneNumber: stringPtr("0800000000"),
+		PatientHN:   patient.PatientHN,
+		Hospital:    "Hospital A",
+	}
+	if err := repo.UpsertPatient(his, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to upsert patient: %v", err)
+	}
+
+	history, err := historyService.GetPatientHistory(patient.PatientHN, "Hospital A", 10, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+
+	update := history[0]
+	if update.Action != models.HistoryActionUpdate || update.Source != models.ChangeSourceLocal {
+		t.Errorf("Expected local update, got %s from %s", update.Action, update.Source)
+	}
+
+	if update.ActorID == nil || *update.ActorID != 8 {
+		t.Errorf("Expected actor 8, got %v", update.ActorID)
+	}
+
+	change, ok := update.Changes["phone_number"]
+	if !ok || change.Old != "0891234567" || change.New != "0800000000" {
+		t.Errorf("Expected phone_number diff, got %v", update.Changes)
+	}
+
+	if len(update.Changes) != 1 {
+		t.Errorf("Expected only phone_number to change, got %v", update.Changes)
+	}
+
+	if history[1].Action != models.HistoryActionCreate {
+		t.Errorf("Expected oldest entry to be the create, got %s", history[1].Action)
+	}
+}
+
+func TestGetPatientHistory_Positive_HISChange(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	historyService := NewPatientHistoryService(repo, repositories.NewPatientHistoryRepository(db))
+
+	patient := &models.Patient{
+		PatientHN:   "HN001",
+		Hospital:    "Hospital A",
+		PhoneNumber: stringPtr("0891234567"),
+		Gender:      "M",
+	}
+	if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to upsert patient: %v", err)
+	}
+
+	refreshed := &models.Patient{
+		PatientHN:   "HN001",
+		Hospital:    "Hospital A",
+		PhoneNumber: stringPtr("0812345678"),
+		Gender:      "M",
+	}
+	if err := repo.UpsertPatient(refreshed, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to upsert patient: %v", err)
+	}
+
+	history, err := historyService.GetPatientHistory("HN001", "Hospital A", 10, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+
+	if history[0].Source != models.ChangeSourceHIS || history[0].ActorID != nil {
+		t.Errorf("Expected HIS change without actor, got %s by %v", history[0].Source, history[0].ActorID)
+	}
+
+	if history[0].Changes["phone_number"].New != "0812345678" {
+		t.Errorf("Expected new phone number in diff, got %v", history[0].Changes)
+	}
+}
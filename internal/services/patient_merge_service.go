@@ -0,0 +1,180 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrMergeSamePatient     = errors.New("survivor and retired HN must be different patients")
+	ErrPatientAlreadyMerged = errors.New("patient has already been merged")
+	ErrMergeNotFound        = errors.New("merge not found")
+	ErrMergeAlreadyReversed = errors.New("merge has already been reversed")
+)
+
+type PatientMergeService struct {
+	patientRepo *repositories.PatientRepository
+	mergeRepo   *repositories.PatientMergeRepository
+}
+
+func NewPatientMergeService(patientRepo *repositories.PatientRepository, mergeRepo *repositories.PatientMergeRepository) *PatientMergeService {
+	return &PatientMergeService{
+		patientRepo: patientRepo,
+		mergeRepo:   mergeRepo,
+	}
+}
+
+// MergePatients merges two HNs of the same person within the staff member's
+// hospital. The survivor keeps its values; fields it is missing are copied from
+// the retired record.
+func (s *PatientMergeService) MergePatients(req *models.MergePatientRequest, staffHospital string, staffID int) (*models.PatientMerge, error) {
+	if req.SurvivorHN == req.RetiredHN {
+		return nil, ErrMergeSamePatient
+	}
+
+	survivor, err := s.getUnmergedPatient(req.SurvivorHN, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+	retired, err := s.getUnmergedPatient(req.RetiredHN, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	filled := map[string]interface{}{}
+	fill := func(column string, survivorValue *string, retiredValue *string) {
+		if survivorValue == nil && retiredValue != nil {
+			filled[column] = *retiredValue
+		}
+	}
+	fill("national_id", survivor.NationalID, retired.NationalID)
+	fill("passport_id", survivor.PassportID, retired.PassportID)
+	fill("first_name_th", survivor.FirstNameTH, retired.FirstNameTH)
+	fill("middle_name_th", survivor.MiddleNameTH, retired.MiddleNameTH)
+	fill("last_name_th", survivor.LastNameTH, retired.LastNameTH)
+	fill("first_name_en", survivor.FirstNameEN, retired.FirstNameEN)
+	fill("middle_name_en", survivor.MiddleNameEN, retired.MiddleNameEN)
+	fill("last_name_en", survivor.LastNameEN, retired.LastNameEN)
+	fill("phone_number", survivor.PhoneNumber, retired.PhoneNumber)
+	fill("email", survivor.Email, retired.Email)
+
+	filledFields, err := json.Marshal(filled)
+	if err != nil {
+		return nil, err
+	}
+
+	merge := &models.PatientMerge{
+		Hospital:          staffHospital,
+		SurvivorID:        survivor.ID,
+		SurvivorHN:        survivor.PatientHN,
+		RetiredID:         retired.ID,
+		RetiredHN:         retired.PatientHN,
+		RetiredEnterprise: retired.EnterpriseID,
+		FilledFields:      string(filledFields),
+		Reason:            req.Reason,
+		Status:            models.MergeStatusMerged,
+		MergedBy:          staffID,
+		MergedAt:          time.Now(),
+	}
+
+	survivorUpdates := map[string]interface{}{}
+	for column, value := range filled {
+		survivorUpdates[column] = value
+	}
+	if len(survivorUpdates) > 0 {
+		survivorUpdates["updated_at"] = now()
+	}
+
+	if err := s.mergeRepo.MergePatients(merge, survivorUpdates); err != nil {
+		if errors.Is(err, repositories.ErrPatientMerged) {
+			return nil, ErrPatientAlreadyMerged
+		}
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// UnmergePatients reverses a merge. Copied fields are only cleared on the survivor
+// if nobody has changed them since the merge.
+func (s *PatientMergeService) UnmergePatients(mergeID int, staffHospital string, staffID int) (*models.PatientMerge, error) {
+	merge, err := s.mergeRepo.GetMerge(mergeID, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMergeNotFound
+		}
+		return nil, err
+	}
+
+	if merge.Status != models.MergeStatusMerged {
+		return nil, ErrMergeAlreadyReversed
+	}
+
+	survivor, err := s.patientRepo.GetPatientByID(merge.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filled map[string]string
+	if merge.FilledFields != "" {
+		if err := json.Unmarshal([]byte(merge.FilledFields), &filled); err != nil {
+			return nil, err
+		}
+	}
+
+	current := map[string]*string{
+		"national_id":    survivor.NationalID,
+		"passport_id":    survivor.PassportID,
+		"first_name_th":  survivor.FirstNameTH,
+		"middle_name_th": survivor.MiddleNameTH,
+		"last_name_th":   survivor.LastNameTH,
+		"first_name_en":  survivor.FirstNameEN,
+		"middle_name_en": survivor.MiddleNameEN,
+		"last_name_en":   survivor.LastNameEN,
+		"phone_number":   survivor.PhoneNumber,
+		"email":          survivor.Email,
+	}
+
+	survivorClears := map[string]interface{}{}
+	for column, value := range filled {
+		if currentValue := current[column]; currentValue != nil && *currentValue == value {
+			survivorClears[column] = nil
+		}
+	}
+	if len(survivorClears) > 0 {
+		survivorClears["updated_at"] = now()
+	}
+
+	if err := s.mergeRepo.UnmergePatients(merge, survivorClears, staffID); err != nil {
+		if errors.Is(err, repositories.ErrPatientMerged) {
+			return nil, ErrPatientAlreadyMerged
+		}
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (s *PatientMergeService) ListMerges(staffHospital string, hn string) ([]*models.PatientMerge, error) {
+	return s.mergeRepo.ListMerges(staffHospital, hn)
+}
+
+func (s *PatientMergeService) getUnmergedPatient(hn string, staffHospital string) (*models.Patient, error) {
+	patient, err := s.patientRepo.GetPatientByHN(hn, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	if patient.MergedIntoID != nil {
+		return nil, ErrPatientAlreadyMerged
+	}
+
+	return patient, nil
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupMergeTest(t *testing.T) (*PatientService, *PatientMergeService) {
+	_, patientService, mergeService := setupMergeTestWithDB(t)
+	return patientService, mergeService
+}
+
+func setupMergeTestWithDB(t *testing.T) (*gorm.DB, *PatientService, *PatientMergeService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	err = db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientMatchCandidate{}, &models.PatientMerge{}, &models.PatientHistory{})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	patientRepo := repositories.NewPatientRepository(db)
+	patientService := NewPatientService(patientRepo, getTestConfig())
+	mergeService := NewPatientMergeService(patientRepo, repositories.NewPatientMergeRepository(db))
+
+	return db, patientService, mergeService
+}
+
+func createMergeTestPatients(t *testing.T, patientService *PatientService) (*models.Patient, *models.Patient) {
+	survivor, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	retired, err := patientService.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("AB1234567"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		PhoneNumber: stringPtr("0891234567"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	return survivor, retired
+}
+
+func TestMergePatients_Positive_RetiredHNResolvesToSurvivor(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	_, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	resolved, err := patientService.GetPatientByHN(retired.PatientHN, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if resolved.PatientHN != survivor.PatientHN {
+		t.Errorf("Expected retired HN to resolve to '%s', got '%s'", survivor.PatientHN, resolved.PatientHN)
+	}
+
+	if resolved.PassportID == nil || *resolved.PassportID != "AB1234567" {
+		t.Error("Expected missing passport ID to be copied to the survivor")
+	}
+
+	patients, err := patientService.SearchPatient(&models.PatientSearchRequest{PassportID: stringPtr("AB1234567")}, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(patients) != 1 {
+		t.Errorf("Expected merged patient to be hidden from search, got %d patients", len(patients))
+	}
+}
+
+func TestBatchLookup_Positive_RetiredHNResolvesToSurvivor(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	if _, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1); err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+
+	results, err := patientService.BatchLookup([]string{retired.PatientHN, survivor.PatientHN}, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, result := range results {
+		if result.Status != models.BatchLookupFound || result.Patient == nil {
+			t.Fatalf("Expected %s to be found, got %s (%s)", result.ID, result.Status, result.Error)
+		}
+		if result.Patient.PatientHN != survivor.PatientHN {
+			t.Errorf("Expected %s to resolve to %s, got %s", result.ID, survivor.PatientHN, result.Patient.PatientHN)
+		}
+	}
+}
+
+func TestMergePatients_Negative_AlreadyMerged(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	req := &models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}
+	if _, err := mergeService.MergePatients(req, "Hospital A", 1); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := mergeService.MergePatients(req, "Hospital A", 1); err != ErrPatientAlreadyMerged {
+		t.Errorf("Expected ErrPatientAlreadyMerged, got: %v", err)
+	}
+}
+
+func TestUnmergePatients_Positive(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	merge, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	unmerged, err := mergeService.UnmergePatients(merge.ID, "Hospital A", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if unmerged.Status != models.MergeStatusUnmerged {
+		t.Errorf("Expected status '%s', got '%s'", models.MergeStatusUnmerged, unmerged.Status)
+	}
+
+	restored, err := patientService.GetPatientByHN(retired.PatientHN, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if restored.PatientHN != retired.PatientHN {
+		t.Errorf("Expected retired HN to resolve to itself again, got '%s'", restored.PatientHN)
+	}
+
+	reloadedSurvivor, _ := patientService.GetPatientByHN(survivor.PatientHN, "Hospital A")
+	if reloadedSurvivor.PassportID != nil {
+		t.Error("Expected copied passport ID to be cleared from the survivor")
+	}
+
+	if _, err := mergeService.UnmergePatients(merge.ID, "Hospital A", 2); err != ErrMergeAlreadyReversed {
+		t.Errorf("Expected ErrMergeAlreadyReversed, got: %v", err)
+	}
+}
+
+func TestUnmergePatients_Positive_RestoresChainAndEnterpriseGroup(t *testing.T) {
+	db, patientService, mergeService := setupMergeTestWithDB(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	chained, err := patientService.CreatePatient(&models.CreatePatientRequest{NationalID: stringPtr("1111111111111"), FirstNameEN: stringPtr("Somchai"), LastNameEN: stringPtr("Jaidee"), DateOfBirth: "1985-03-15", Gender: "M"}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	grouped, err := patientService.CreatePatient(&models.CreatePatientRequest{NationalID: stringPtr("1234567890123"), FirstNameEN: stringPtr("Somchai"), LastNameEN: stringPtr("Jaidee"), DateOfBirth: "1985-03-15", Gender: "M"}, "Hospital B", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	for id, enterpriseID := range map[int]string{survivor.ID: "EID-S", retired.ID: "EID-R", grouped.ID: "EID-R"} {
+		if err := db.Model(&models.Patient{}).Where("id = ?", id).UpdateColumn("enterprise_id", enterpriseID).Error; err != nil {
+			t.Fatalf("Failed to set enterprise ID: %v", err)
+		}
+	}
+
+	if _, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: retired.PatientHN,
+		RetiredHN:  chained.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1); err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	merge, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if len(merge.ChainedIDs) != 1 || len(merge.RegroupedIDs) != 1 {
+		t.Fatalf("Expected the merge to record 1 chained and 1 regrouped patient, got %v and %v", merge.ChainedIDs, merge.RegroupedIDs)
+	}
+
+	if _, err := mergeService.UnmergePatients(merge.ID, "Hospital A", 2); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	resolved, err := patientService.GetPatientByHN(chained.PatientHN, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resolved.PatientHN != retired.PatientHN {
+		t.Errorf("Expected %s to resolve to %s again, got %s", chained.PatientHN, retired.PatientHN, resolved.PatientHN)
+	}
+
+	var reloaded models.Patient
+	if err := db.First(&reloaded, grouped.ID).Error; err != nil {
+		t.Fatalf("Failed to load patient: %v", err)
+	}
+	if reloaded.EnterpriseID == nil || *reloaded.EnterpriseID != "EID-R" {
+		t.Errorf("Expected the regrouped patient back in EID-R, got %v", reloaded.EnterpriseID)
+	}
+}
+
+func TestUnmergePatients_Negative_SurvivorMergedSince(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	other, err := patientService.CreatePatient(&models.CreatePatientRequest{NationalID: stringPtr("2222222222222"), FirstNameEN: stringPtr("Somchai"), LastNameEN: stringPtr("Jaidee"), DateOfBirth: "1985-03-15", Gender: "M"}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	merge, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if _, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: other.PatientHN,
+		RetiredHN:  survivor.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1); err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+
+	if _, err := mergeService.UnmergePatients(merge.ID, "Hospital A", 2); err != ErrPatientAlreadyMerged {
+		t.Errorf("Expected ErrPatientAlreadyMerged, got: %v", err)
+	}
+}
+
+func TestMergePatients_Positive_SurvivorTakesRetiredIdentifier(t *testing.T) {
+	patientService, mergeService := setupMergeTest(t)
+	survivor, retired := createMergeTestPatients(t, patientService)
+
+	if _, err := mergeService.MergePatients(&models.MergePatientRequest{
+		SurvivorHN: survivor.PatientHN,
+		RetiredHN:  retired.PatientHN,
+		Reason:     "Duplicate walk-in registration",
+	}, "Hospital A", 1); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	current, err := patientService.GetPatientByHN(survivor.PatientHN, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	updatedAt := current.UpdatedAt
+	updated, err := patientService.UpdatePatient(survivor.PatientHN, &models.UpdatePatientRequest{
+		PassportID: stringPtr("AB1234567"),
+	}, &updatedAt, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected the retired record's identifier not to block the survivor, got: %v", err)
+	}
+	if updated.PassportID == nil || *updated.PassportID != "AB1234567" {
+		t.Errorf("Expected passport 'AB1234567', got %v", updated.PassportID)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	retentionPurgeBatchSize = 200
+	erasureBatchSize        = 50
+)
+
+var (
+	ErrErasureRequestNotFound = errors.New("erasure request not found")
+	ErrErasureRequestOpen     = errors.New("an erasure request for this patient is already open")
+	ErrErasureNotPending      = errors.New("erasure request is not pending")
+	ErrErasureSelfApproval    = errors.New("erasure request must be approved by a different staff member")
+)
+
+type PatientRetentionService struct {
+	patientRepo   *repositories.PatientRepository
+	retentionRepo *repositories.PatientRetentionRepository
+	config        *configs.ApplicationConfig
+}
+
+func NewPatientRetentionService(patientRepo *repositories.PatientRepository, retentionRepo *repositories.PatientRetentionRepository, config *configs.ApplicationConfig) *PatientRetentionService {
+	return &PatientRetentionService{
+		patientRepo:   patientRepo,
+		retentionRepo: retentionRepo,
+		config:        config,
+	}
+}
+
+func (s *PatientRetentionService) SoftDeletePatient(hn string, staffHospital string, staffID int) error {
+	patient, err := s.getPatient(hn, staffHospital)
+	if err != nil {
+		return err
+	}
+
+	return s.retentionRepo.SoftDeletePatient(patient, staffID)
+}
+
+// RequestErasure opens an erasure request for the patient. Soft deleted
+// patients can be erased too.
+func (s *PatientRetentionService) RequestErasure(hn string, req *models.CreateErasureRequest, staffHospital string, staffID int) (*models.PatientErasureRequest, error) {
+	patient, err := s.retentionRepo.GetErasablePatient(hn, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	open, err := s.retentionRepo.HasOpenErasureRequest(patient.ID)
+	if err != nil {
+		return nil, err
+	}
+	if open {
+		return nil, ErrErasureRequestOpen
+	}
+
+	request := &models.PatientErasureRequest{
+		PatientID:   patient.ID,
+		PatientHN:   patient.PatientHN,
+		Hospital:    patient.Hospital,
+		Reason:      req.Reason,
+		Status:      models.ErasureStatusPending,
+		RequestedBy: staffID,
+	}
+	if err := s.retentionRepo.CreateErasureRequest(request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ApproveErasure approves a pending request. The erasure itself is carried out by
+// the erasure job.
+func (s *PatientRetentionService) ApproveErasure(requestID int, staffHospital string, staffID int) (*models.PatientErasureRequest, error) {
+	request, err := s.getPendingErasureRequest(requestID, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.RequestedBy == staffID {
+		return nil, ErrErasureSelfApproval
+	}
+
+	return request, s.reviewErasure(request, models.ErasureStatusApproved, staffID)
+}
+
+func (s *PatientRetentionService) RejectErasure(requestID int, staffHospital string, staffID int) (*models.PatientErasureRequest, error) {
+	request, err := s.getPendingErasureRequest(requestID, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, s.reviewErasure(request, models.ErasureStatusRejected, staffID)
+}
+
+func (s *PatientRetentionService) ListErasureRequests(status string, staffHospital string) ([]*models.PatientErasureRequest, error) {
+	return s.retentionRepo.ListErasureRequests(status, staffHospital, 200)
+}
+
+// ProcessErasures erases every approved request. Failed requests are marked and
+// kept for a data steward to look at.
+func (s *PatientRetentionService) ProcessErasures(ctx context.Context) error {
+	requests, err := s.retentionRepo.ListErasureRequests(models.ErasureStatusApproved, "", erasureBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.retentionRepo.ErasePatient(request); err != nil {
+			request.Status = models.ErasureStatusFailed
+			request.Error = err.Error()
+			if saveErr := s.retentionRepo.SaveErasureRequest(request); saveErr != nil {
+				return saveErr
+			}
+			continue
+		}
+
+		fmt.Printf("[Retention] Erased patient %s (%s) for request %d\n", request.PatientHN, request.Hospital, request.ID)
+	}
+
+	return nil
+}
+
+// PurgeExpired permanently removes HIS-cached patients that were not re-fetched
+// within the cache retention period and patients that were soft deleted longer
+// than the soft delete retention period.
+func (s *PatientRetentionService) PurgeExpired(ctx context.Context) error {
+	if days := s.config.Retention.HISCacheDays; days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -days)
+		purged, err := s.purge(ctx, repositories.StaleCacheScope(cutoff), fmt.Sprintf("HIS cache not re-fetched in %d days", days))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			fmt.Printf("[Retention] Purged %d stale HIS-cached patients\n", purged)
+		}
+	}
+
+	if days := s.config.Retention.SoftDeleteDays; days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -days)
+		purged, err := s.purge(ctx, repositories.SoftDeletedScope(cutoff), fmt.Sprintf("soft deleted more than %d days ago", days))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			fmt.Printf("[Retention] Purged %d soft deleted patients\n", purged)
+		}
+	}
+
+	return nil
+}
+
+func (s *PatientRetentionService) purge(ctx context.Context, scope func(*gorm.DB) *gorm.DB, reason string) (int, error) {
+	total := 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+
+		purged, err := s.retentionRepo.PurgePatients(scope, reason, retentionPurgeBatchSize)
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < retentionPurgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (s *PatientRetentionService) getPatient(hn string, staffHospital string) (*models.Patient, error) {
+	patient, err := s.patientRepo.GetPatientByHN(hn, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	return patient, nil
+}
+
+func (s *PatientRetentionService) getPendingErasureRequest(requestID int, staffHospital string) (*models.PatientErasureRequest, error) {
+	request, err := s.retentionRepo.GetErasureRequest(requestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrErasureRequestNotFound
+		}
+		return nil, err
+	}
+
+	if request.Hospital != staffHospital {
+		return nil, ErrErasureRequestNotFound
+	}
+
+	if request.Status != models.ErasureStatusPending {
+		return nil, ErrErasureNotPending
+	}
+
+	return request, nil
+}
+
+func (s *PatientRetentionService) reviewErasure(request *models.PatientErasureRequest, status string, staffID int) error {
+	reviewedAt := time.Now()
+	request.Status = status
+	request.ReviewedBy = &staffID
+	request.ReviewedAt = &reviewedAt
+
+	return s.retentionRepo.SaveErasureRequest(request)
+}
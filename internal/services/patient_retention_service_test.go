@@ -0,0 +1,358 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func setupRetentionTest(t *testing.T) (*gorm.DB, *PatientService, *PatientRetentionService) {
+	db := setupPatientTestDB(t)
+	err := db.AutoMigrate(&models.PatientMatchCandidate{}, &models.PatientMerge{}, &models.PatientErasureRequest{}, &models.AuditLog{}, &models.RecentPatient{}, &models.HL7DeadLetter{}, &models.HISEventDelivery{}, &models.SavedSearch{})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	config := getTestConfig()
+	config.Retention.HISCacheDays = 90
+	config.Retention.SoftDeleteDays = 30
+
+	repo := repositories.NewPatientRepository(db)
+	service := NewPatientService(repo, config)
+	retentionService := NewPatientRetentionService(repo, repositories.NewPatientRetentionRepository(db), config)
+
+	return db, service, retentionService
+}
+
+func createRetentionTestPatient(t *testing.T, service *PatientService) *models.Patient {
+	patient, err := service.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		PhoneNumber: stringPtr("0891234567"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	return patient
+}
+
+func TestSoftDeletePatient_Positive_HidesPatient(t *testing.T) {
+	_, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	if err := retentionService.SoftDeletePatient(patient.PatientHN, "Hospital A", 2); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := service.GetPatientByHN(patient.PatientHN, "Hospital A"); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound after delete, got: %v", err)
+	}
+}
+
+func TestSoftDeletePatient_Negative_HISDoesNotRestore(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	if _, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Patient request"}, "Hospital A", 1); err != nil {
+		t.Fatalf("Failed to request erasure: %v", err)
+	}
+	if err := retentionService.SoftDeletePatient(patient.PatientHN, "Hospital A", 2); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fromHIS := &models.Patient{PatientHN: patient.PatientHN, Hospital: "Hospital A", FirstNameEN: stringPtr("Somchai"), Gender: "M", Source: models.PatientSourceHIS}
+	if _, err := service.cacheHISPatient(fromHIS); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got: %v", err)
+	}
+
+	var stored models.Patient
+	if err := db.Unscoped().Where("patient_hn = ?", patient.PatientHN).First(&stored).Error; err != nil {
+		t.Fatalf("Failed to load patient: %v", err)
+	}
+	if !stored.DeletedAt.Valid {
+		t.Error("Expected the patient to stay deleted")
+	}
+}
+
+func TestErasure_Positive_ScrubsPatientAndKeepsTombstone(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	request, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Patient request"}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to request erasure: %v", err)
+	}
+
+	if _, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Again"}, "Hospital A", 1); !errors.Is(err, ErrErasureRequestOpen) {
+		t.Errorf("Expected ErrErasureRequestOpen, got: %v", err)
+	}
+
+	if _, err := retentionService.ApproveErasure(request.ID, "Hospital A", 2); err != nil {
+		t.Fatalf("Failed to approve erasure: %v", err)
+	}
+
+	if err := retentionService.ProcessErasures(context.Background()); err != nil {
+		t.Fatalf("Failed to process erasures: %v", err)
+	}
+
+	var tombstone models.Patient
+	if err := db.Unscoped().First(&tombstone, patient.ID).Error; err != nil {
+		t.Fatalf("Expected tombstone to remain, got: %v", err)
+	}
+
+	if tombstone.ErasedAt == nil {
+		t.Error("Expected erased_at to be set")
+	}
+
+	if tombstone.NationalID != nil || tombstone.FirstNameEN != nil || tombstone.PhoneNumber != nil {
+		t.Error("Expected personal data to be scrubbed")
+	}
+
+	requests, err := retentionService.ListErasureRequests(models.ErasureStatusErased, "Hospital A")
+	if err != nil {
+		t.Fatalf("Failed to list erasure requests: %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Errorf("Expected 1 erased request, got %d", len(requests))
+	}
+}
+
+func erasePatientForTest(t *testing.T, retentionService *PatientRetentionService, patient *models.Patient) {
+	t.Helper()
+
+	request, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Patient request"}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to request erasure: %v", err)
+	}
+	if _, err := retentionService.ApproveErasure(request.ID, "Hospital A", 2); err != nil {
+		t.Fatalf("Failed to approve erasure: %v", err)
+	}
+	if err := retentionService.ProcessErasures(context.Background()); err != nil {
+		t.Fatalf("Failed to process erasures: %v", err)
+	}
+
+	requests, err := retentionService.ListErasureRequests(models.ErasureStatusErased, "Hospital A")
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Expected the patient to be erased, got %d erased requests (%v)", len(requests), err)
+	}
+}
+
+func TestErasure_Positive_ErasesMergeGroup(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	survivor := createRetentionTestPatient(t, service)
+	retired, err := service.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("AA1234567"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	if err := db.Model(&models.Patient{}).Where("id = ?", retired.ID).UpdateColumn("merged_into_id", survivor.ID).Error; err != nil {
+		t.Fatalf("Failed to merge patients: %v", err)
+	}
+
+	erasePatientForTest(t, retentionService, retired)
+
+	for _, id := range []int{survivor.ID, retired.ID} {
+		var tombstone models.Patient
+		if err := db.Unscoped().First(&tombstone, id).Error; err != nil {
+			t.Fatalf("Expected tombstone to remain, got: %v", err)
+		}
+		if tombstone.ErasedAt == nil || tombstone.FirstNameEN != nil || tombstone.NationalID != nil || tombstone.PassportID != nil {
+			t.Errorf("Expected patient %d to be erased with its merge group", id)
+		}
+	}
+}
+
+func TestErasure_Positive_ErasesSoftDeletedPatient(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	if err := retentionService.SoftDeletePatient(patient.PatientHN, "Hospital A", 1); err != nil {
+		t.Fatalf("Failed to delete patient: %v", err)
+	}
+
+	erasePatientForTest(t, retentionService, patient)
+
+	var tombstone models.Patient
+	if err := db.Unscoped().First(&tombstone, patient.ID).Error; err != nil {
+		t.Fatalf("Expected tombstone to remain, got: %v", err)
+	}
+	if tombstone.ErasedAt == nil || tombstone.NationalID != nil {
+		t.Error("Expected the soft deleted patient to be erased")
+	}
+
+	if _, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Again"}, "Hospital A", 1); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound for an erased patient, got: %v", err)
+	}
+}
+
+func TestErasure_Positive_ScrubsHL7DeadLetters(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	deadLetters := []*models.HL7DeadLetter{
+		{RawMessage: "MSH|^~\\&|HIS|HOSP_A\rPID|1||" + patient.PatientHN + "^^^HOSP_A||Jaidee^Somchai", Error: "bad PID"},
+		{RawMessage: "MSH|^~\\&|HIS|HOSP_A\rPID|1||X1|1234567890123|Jaidee^Somchai", Error: "bad PID"},
+		{RawMessage: "MSH|^~\\&|HIS|HOSP_A\rPID|1||" + patient.PatientHN + "0^^^HOSP_A||Other^Patient", Error: "bad PID"},
+	}
+	if err := db.Create(&deadLetters).Error; err != nil {
+		t.Fatalf("Failed to create dead letters: %v", err)
+	}
+
+	erasePatientForTest(t, retentionService, patient)
+
+	for i, want := range []bool{true, true, false} {
+		var stored models.HL7DeadLetter
+		if err := db.First(&stored, deadLetters[i].ID).Error; err != nil {
+			t.Fatalf("Failed to load dead letter: %v", err)
+		}
+		if scrubbed := stored.RawMessage == "[erased]"; scrubbed != want {
+			t.Errorf("Expected dead letter %d scrubbed=%v, got raw message %q", i, want, stored.RawMessage)
+		}
+	}
+}
+
+func TestErasure_Positive_ScrubsHISEventPayloads(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	deliveries := []*models.HISEventDelivery{
+		{Hospital: "Hospital A", EventID: "evt-1", PatientHN: patient.PatientHN, Status: models.HISDeliveryApplied, Payload: `{"patient_hn":"` + patient.PatientHN + `"}`},
+		{Hospital: "Hospital A", EventID: "evt-2", Status: models.HISDeliveryFailed, Payload: `{"patient":{"cid":"1234567890123"}}`},
+		{Hospital: "Hospital B", EventID: "evt-3", PatientHN: patient.PatientHN, Status: models.HISDeliveryApplied, Payload: `{"patient_hn":"` + patient.PatientHN + `"}`},
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		t.Fatalf("Failed to create deliveries: %v", err)
+	}
+
+	erasePatientForTest(t, retentionService, patient)
+
+	for i, want := range []bool{true, true, false} {
+		var stored models.HISEventDelivery
+		if err := db.First(&stored, deliveries[i].ID).Error; err != nil {
+			t.Fatalf("Failed to load delivery: %v", err)
+		}
+		if scrubbed := stored.Payload == "[erased]"; scrubbed != want {
+			t.Errorf("Expected delivery %s scrubbed=%v, got payload %q", stored.EventID, want, stored.Payload)
+		}
+	}
+}
+
+func TestErasure_Positive_RemovesSavedSearches(t *testing.T) {
+	db, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	searches := []*models.SavedSearch{
+		{StaffID: 1, Hospital: "Hospital A", Name: "By national ID", Criteria: models.PatientSearchRequest{NationalID: stringPtr("1234567890123")}},
+		{StaffID: 1, Hospital: "Hospital A", Name: "By HN", Criteria: models.PatientSearchRequest{Q: stringPtr("patient_hn=" + patient.PatientHN)}},
+		{StaffID: 1, Hospital: "Hospital A", Name: "Cardiology", Criteria: models.PatientSearchRequest{LastName: stringPtr("Jaidee")}},
+	}
+	if err := db.Create(&searches).Error; err != nil {
+		t.Fatalf("Failed to create saved searches: %v", err)
+	}
+
+	erasePatientForTest(t, retentionService, patient)
+
+	var remaining []*models.SavedSearch
+	if err := db.Find(&remaining).Error; err != nil {
+		t.Fatalf("Failed to load saved searches: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].Name != "Cardiology" {
+		t.Errorf("Expected only the search without identifiers to remain, got %d searches", len(remaining))
+	}
+}
+
+func TestApproveErasure_Negative_SelfApproval(t *testing.T) {
+	_, service, retentionService := setupRetentionTest(t)
+	patient := createRetentionTestPatient(t, service)
+
+	request, err := retentionService.RequestErasure(patient.PatientHN, &models.CreateErasureRequest{Reason: "Patient request"}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Failed to request erasure: %v", err)
+	}
+
+	if _, err := retentionService.ApproveErasure(request.ID, "Hospital A", 1); !errors.Is(err, ErrErasureSelfApproval) {
+		t.Errorf("Expected ErrErasureSelfApproval, got: %v", err)
+	}
+}
+
+func TestPurgeExpired_Positive_RemovesStaleHISCache(t *testing.T) {
+	db, _, retentionService := setupRetentionTest(t)
+
+	stale := time.Now().AddDate(0, 0, -120)
+	patient := &models.Patient{
+		PatientHN:     "HN900",
+		NationalID:    stringPtr("9999999999999"),
+		Hospital:      "Hospital A",
+		LastFetchedAt: &stale,
+	}
+	if err := db.Create(patient).Error; err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	if err := retentionService.PurgeExpired(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var count int64
+	db.Unscoped().Model(&models.Patient{}).Where("id = ?", patient.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected stale patient to be purged, got %d rows", count)
+	}
+}
+
+func TestPurgeExpired_Positive_ScrubsDependentRowsAndMerges(t *testing.T) {
+	db, _, retentionService := setupRetentionTest(t)
+
+	stale := time.Now().AddDate(0, 0, -120)
+	patient := &models.Patient{
+		PatientHN:     "HN900",
+		NationalID:    stringPtr("9999999999999"),
+		Hospital:      "Hospital A",
+		LastFetchedAt: &stale,
+	}
+	if err := db.Create(patient).Error; err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	merged := &models.Patient{PatientHN: "HN901", Hospital: "Hospital A", MergedIntoID: &patient.ID}
+	if err := db.Create(merged).Error; err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+	delivery := &models.HISEventDelivery{Hospital: "Hospital A", EventID: "evt-1", PatientHN: "HN900", Status: models.HISDeliveryApplied, Payload: `{"patient_hn":"HN900"}`}
+	if err := db.Create(delivery).Error; err != nil {
+		t.Fatalf("Failed to create delivery: %v", err)
+	}
+
+	if err := retentionService.PurgeExpired(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var stored models.HISEventDelivery
+	if err := db.First(&stored, delivery.ID).Error; err != nil {
+		t.Fatalf("Failed to load delivery: %v", err)
+	}
+	if stored.Payload != "[erased]" {
+		t.Errorf("Expected the purged patient's event payload to be scrubbed, got %q", stored.Payload)
+	}
+
+	var remaining models.Patient
+	if err := db.First(&remaining, merged.ID).Error; err != nil {
+		t.Fatalf("Failed to load merged patient: %v", err)
+	}
+	if remaining.MergedIntoID != nil {
+		t.Errorf("Expected merged_into_id to be cleared, got %d", *remaining.MergedIntoID)
+	}
+}
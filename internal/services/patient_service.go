@@ -5,299 +5,756 @@ import (
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/repositories"
 	"agnos-middleware/internal/utils"
-	"encoding/json"
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrPatientNotFound      = errors.New("patient not found")
+	ErrAccessDenied         = errors.New("access denied: patient does not belong to your hospital")
+	ErrPatientExists        = errors.New("patient already registered")
+	ErrPatientModified      = errors.New("patient has been modified by another request")
+	ErrPreconditionRequired = errors.New("If-Match header or updated_at is required")
+	ErrInvalidPatient       = errors.New("invalid patient data")
+	ErrInvalidQuery         = errors.New("invalid search query")
+	ErrPatientHISManaged    = errors.New("patient is managed by HIS: correct it in HIS")
 )
 
 type PatientService struct {
 	patientRepo *repositories.PatientRepository
 	config      *configs.ApplicationConfig
-	httpClient  *http.Client
+	hisAdapters *HISAdapterRegistry
+	mpiService  *MPIService
+	// mockHIS answers lookups HIS failed in the mock fallback mode
+	mockHIS HISAdapter
+	// refreshing holds the IDs of patients being refreshed in the background;
+	// refreshSlots bounds how many refreshes run at once
+	refreshing   sync.Map
+	refreshSlots chan struct{}
+	// hisLookups coalesces concurrent HIS lookups of one identifier;
+	// hisNotFound holds when each recent not found answer expires and
+	// hisNotFoundStored counts the answers stored, to pace sweeps
+	hisLookups        singleflight.Group
+	hisNotFound       sync.Map
+	hisNotFoundStored atomic.Int64
+
+	workspaceService *StaffWorkspaceService
 }
 
 func NewPatientService(patientRepo *repositories.PatientRepository, config *configs.ApplicationConfig) *PatientService {
-	return &PatientService{
-		patientRepo: patientRepo,
-		config:      config,
-		httpClient:  utils.DefaultHTTPClient(),
+	refreshConcurrency := config.HISAPI.RefreshConcurrency
+	if refreshConcurrency <= 0 {
+		refreshConcurrency = 1
+	}
+
+	service := &PatientService{
+		patientRepo:  patientRepo,
+		config:       config,
+		hisAdapters:  NewHISAdapterRegistry(config),
+		refreshSlots: make(chan struct{}, refreshConcurrency),
+	}
+
+	return service
+}
+
+// SetMockHIS sets the adapter that answers lookups HIS failed when
+// HIS_FALLBACK_MODE is mock. Without it the mock mode behaves like strict.
+func (s *PatientService) SetMockHIS(adapter HISAdapter) {
+	s.mockHIS = adapter
+}
+
+// SetMPIService enables master patient index updates whenever a patient is saved.
+func (s *PatientService) SetMPIService(mpiService *MPIService) {
+	s.mpiService = mpiService
+}
+
+// SetWorkspaceService enables the recently viewed patients list.
+func (s *PatientService) SetWorkspaceService(workspaceService *StaffWorkspaceService) {
+	s.workspaceService = workspaceService
+}
+
+// HISStatus returns the circuit breaker state of every hospital's HIS.
+func (s *PatientService) HISStatus() []utils.BreakerStatus {
+	return s.hisAdapters.Status()
+}
+
+// RecordPatientView records that the staff member opened the patient.
+func (s *PatientService) RecordPatientView(patient *models.Patient, staffID int) {
+	if s.workspaceService != nil {
+		s.workspaceService.RecordPatientView(patient, staffID)
 	}
 }
 
 func (s *PatientService) SearchPatient(req *models.PatientSearchRequest, staffHospital string) ([]*models.Patient, error) {
 	patients, err := s.patientRepo.SearchPatients(req, staffHospital)
 	if err != nil {
-		return nil, err
+		return nil, wrapFilterError(err)
 	}
 
 	if len(patients) > 0 {
+		for _, patient := range patients {
+			s.checkFreshness(patient)
+		}
 		return patients, nil
 	}
 
-	if req.ID != nil && *req.ID != "" {
-		patient, err := s.searchPatientFromHIS(*req.ID)
+	return s.searchPatientByIDFromHIS(req, staffHospital)
+}
+
+// SearchPatientPage works like SearchPatient with cursor pagination: it returns
+// up to limit patients with an id greater than afterID, in id order, and whether
+// more follow. The HIS fallback only applies to the first page.
+func (s *PatientService) SearchPatientPage(req *models.PatientSearchRequest, staffHospital string, afterID int, limit int) ([]*models.Patient, bool, error) {
+	patients, err := s.patientRepo.SearchPatientsAfter(req, staffHospital, afterID, limit+1)
+	if err != nil {
+		return nil, false, wrapFilterError(err)
+	}
+
+	for _, patient := range patients {
+		s.checkFreshness(patient)
+	}
+	if len(patients) > limit {
+		return patients[:limit], true, nil
+	}
+	if len(patients) > 0 || afterID > 0 {
+		return patients, false, nil
+	}
+
+	patients, err = s.searchPatientByIDFromHIS(req, staffHospital)
+	if err != nil {
+		return nil, false, err
+	}
+	return patients, false, nil
+}
+
+// SearchPatientOffset works like SearchPatient with offset pagination: it
+// returns up to limit patients after skipping offset, in id order, and how many
+// patients match in total. The HIS fallback only applies when nothing matches
+// locally.
+func (s *PatientService) SearchPatientOffset(req *models.PatientSearchRequest, staffHospital string, offset int, limit int) ([]*models.Patient, int, error) {
+	patients, total, err := s.patientRepo.SearchPatientsOffset(req, staffHospital, offset, limit)
+	if err != nil {
+		return nil, 0, wrapFilterError(err)
+	}
+
+	for _, patient := range patients {
+		s.checkFreshness(patient)
+	}
+	if total > 0 {
+		return patients, int(total), nil
+	}
+
+	patients, err = s.searchPatientByIDFromHIS(req, staffHospital)
+	if err != nil {
+		return nil, 0, err
+	}
+	matched := len(patients)
+	patients = patients[min(offset, matched):]
+	patients = patients[:min(limit, len(patients))]
+	return patients, matched, nil
+}
+
+// StreamSearchPatient works like SearchPatient but hands each patient to fn as it
+// is read from the database cursor instead of collecting them, and calls flush
+// every PATIENT_STREAM_FLUSH_ROWS patients. It returns how many patients were
+// passed to fn. Cancelling ctx cancels the query.
+func (s *PatientService) StreamSearchPatient(ctx context.Context, req *models.PatientSearchRequest, staffHospital string, fn func(*models.Patient) error, flush func()) (int, error) {
+	flushRows := s.config.Patient.StreamFlushRows
+	if flushRows <= 0 {
+		flushRows = 1
+	}
+
+	count := 0
+	emit := func(patient *models.Patient) error {
+		s.checkFreshness(patient)
+		if err := fn(patient); err != nil {
+			return err
+		}
+		count++
+		if count%flushRows == 0 {
+			flush()
+		}
+		return nil
+	}
+
+	if err := s.patientRepo.StreamPatients(ctx, req, staffHospital, emit); err != nil {
+		return count, wrapFilterError(err)
+	}
+
+	if count == 0 {
+		patients, err := s.searchPatientByIDFromHIS(req, staffHospital)
 		if err != nil {
-			return []*models.Patient{}, nil
+			return 0, err
 		}
+		for _, patient := range patients {
+			if err := emit(patient); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// searchPatientByIDFromHIS is the HIS fallback for searches by id that found
+// nothing locally. With a q filter the patient is only returned when it
+// matches the filter.
+func (s *PatientService) searchPatientByIDFromHIS(req *models.PatientSearchRequest, staffHospital string) ([]*models.Patient, error) {
+	if req.ID == nil || *req.ID == "" {
+		return []*models.Patient{}, nil
+	}
+
+	patient, err := s.findHISPatient(staffHospital, *req.ID)
+	if errors.Is(err, ErrPatientNotFound) {
+		return []*models.Patient{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		if patient.Hospital != staffHospital {
-			return nil, errors.New("access denied: patient does not belong to your hospital")
+	if patient.Hospital != staffHospital {
+		return nil, ErrAccessDenied
+	}
+
+	if req.Q != nil && strings.TrimSpace(*req.Q) != "" {
+		return s.filterHISPatient(req, staffHospital, patient)
+	}
+	return []*models.Patient{patient}, nil
+}
+
+// filterHISPatient applies the search, q filter included, to a patient just
+// fetched from HIS. The filter only runs as SQL, so the search is repeated now
+// that the patient is cached; a patient that was not cached, such as a mock
+// one, cannot be checked and is left out.
+func (s *PatientService) filterHISPatient(req *models.PatientSearchRequest, staffHospital string, patient *models.Patient) ([]*models.Patient, error) {
+	if patient.ID == 0 {
+		return []*models.Patient{}, nil
+	}
+
+	patients, err := s.patientRepo.SearchPatients(req, staffHospital)
+	if err != nil {
+		return nil, wrapFilterError(err)
+	}
+	for _, matched := range patients {
+		matched.Source = patient.Source
+	}
+	return patients, nil
+}
+
+// BatchLookup resolves a list of HNs, national IDs or passport IDs. Local hits are
+// loaded in one query; misses are fetched from HIS concurrently, bounded by
+// HIS_API_BATCH_CONCURRENCY. Results keep the order of ids, with duplicates removed.
+func (s *PatientService) BatchLookup(ids []string, staffHospital string) ([]*models.BatchLookupResult, error) {
+	results := make([]*models.BatchLookupResult, 0, len(ids))
+	byID := make(map[string]*models.BatchLookupResult, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || byID[id] != nil {
+			continue
 		}
+		result := &models.BatchLookupResult{ID: id, Status: models.BatchLookupNotFound}
+		byID[id] = result
+		results = append(results, result)
+	}
+
+	lookupIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		lookupIDs = append(lookupIDs, result.ID)
+	}
 
-		if err := s.patientRepo.UpsertPatient(patient); err != nil {
+	patients, err := s.patientRepo.FindPatientsByIdentifiers(lookupIDs, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	survivors, err := s.loadSurvivors(patients)
+	if err != nil {
+		return nil, err
+	}
+
+	// Identifiers are matched on the row found, which for an HN retired by a
+	// merge is the retired row; the survivor is what is returned
+	for _, matched := range patients {
+		patient := matched
+		if matched.MergedIntoID != nil {
+			if survivors[*matched.MergedIntoID] == nil {
+				continue
+			}
+			patient = survivors[*matched.MergedIntoID]
 		}
+		for _, identifier := range []*string{&matched.PatientHN, matched.NationalID, matched.PassportID} {
+			if identifier == nil {
+				continue
+			}
+			if result := byID[*identifier]; result != nil && result.Patient == nil {
+				s.checkFreshness(patient)
+				result.Status = models.BatchLookupFound
+				result.Patient = patient
+			}
+		}
+	}
 
-		return []*models.Patient{patient}, nil
+	var misses []*models.BatchLookupResult
+	for _, result := range results {
+		if result.Patient == nil {
+			misses = append(misses, result)
+		}
+	}
+
+	fetched := s.fetchFromHIS(misses, staffHospital)
+
+	for i, result := range misses {
+		patient, err := fetched[i].patient, fetched[i].err
+		switch {
+		case errors.Is(err, ErrNotFoundInCache):
+			result.Status = models.BatchLookupNotFound
+			result.Error = err.Error()
+			result.Source = models.PatientSourceStaleCache
+		case errors.Is(err, ErrPatientNotFound):
+			result.Status = models.BatchLookupNotFound
+		case err != nil:
+			result.Status = models.BatchLookupError
+			result.Error = err.Error()
+		case patient.Hospital != staffHospital:
+			result.Status = models.BatchLookupForbidden
+			result.Error = ErrAccessDenied.Error()
+		default:
+			result.Status = models.BatchLookupFound
+			result.Patient = patient
+		}
 	}
 
-	return []*models.Patient{}, nil
+	return results, nil
 }
 
-func (s *PatientService) searchPatientFromHIS(patientID string) (*models.Patient, error) {
-	fmt.Printf("[HIS API] Searching for patient: %s\n", patientID)
+type hisFetchResult struct {
+	patient *models.Patient
+	err     error
+}
 
-	// Try to call actual API first
-	patient, err := s.callHISAPI(patientID)
-	if err == nil && patient != nil {
-		fmt.Printf("[HIS API] Successfully fetched from external API\n")
-		return patient, nil
+func (s *PatientService) fetchFromHIS(misses []*models.BatchLookupResult, staffHospital string) []hisFetchResult {
+	fetched := make([]hisFetchResult, len(misses))
+
+	concurrency := s.config.HISAPI.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, result := range misses {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			patient, err := s.findHISPatient(staffHospital, id)
+			fetched[i] = hisFetchResult{patient: patient, err: err}
+		}(i, result.ID)
+	}
+	wg.Wait()
+
+	return fetched
+}
+
+func (s *PatientService) loadSurvivors(patients []*models.Patient) (map[int]*models.Patient, error) {
+	var survivorIDs []int
+	for _, patient := range patients {
+		if patient.MergedIntoID != nil {
+			survivorIDs = append(survivorIDs, *patient.MergedIntoID)
+		}
+	}
+
+	survivors := make(map[int]*models.Patient, len(survivorIDs))
+	if len(survivorIDs) == 0 {
+		return survivors, nil
+	}
+
+	loaded, err := s.patientRepo.GetPatientsByIDs(survivorIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, survivor := range loaded {
+		survivors[survivor.ID] = survivor
 	}
 
-	// If API call failed, fall back to mock data since current cant connect to api url
-	fmt.Printf("[HIS API] API call failed, using mock data: %v\n", err)
-	patient = s.getMockPatient(patientID)
-	if patient != nil {
-		fmt.Printf("[HIS API] Found in mock data\n")
+	return survivors, nil
+}
+
+// cacheHISPatient saves a patient fetched from HIS and returns the patient to hand
+// out, which is the survivor when the HN was retired by a merge. Mock patients
+// are never saved. A patient erased or deleted here is reported as not found, so
+// HIS cannot bring the data back.
+func (s *PatientService) cacheHISPatient(patient *models.Patient) (*models.Patient, error) {
+	if patient.Source == models.PatientSourceMock {
 		return patient, nil
 	}
 
-	return nil, errors.New("patient not found in HIS API")
+	fetchedAt := time.Now()
+	patient.LastFetchedAt = &fetchedAt
+	if err := s.patientRepo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		if errors.Is(err, repositories.ErrPatientErased) || errors.Is(err, repositories.ErrPatientDeleted) {
+			return nil, fmt.Errorf("%w: %w", ErrPatientNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to cache HIS patient %s: %w", patient.PatientHN, err)
+	}
+
+	if patient.MergedIntoID != nil {
+		survivor, err := s.patientRepo.GetPatientByID(*patient.MergedIntoID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load surviving patient of %s: %w", patient.PatientHN, err)
+		}
+		return survivor, nil
+	}
+	s.indexPatient(patient)
+
+	patient.Source = models.PatientSourceHIS
+	return patient, nil
 }
 
-func (s *PatientService) callHISAPI(patientID string) (*models.Patient, error) {
-	url := fmt.Sprintf("%s/patient/search/%s", s.config.HISAPI.BaseURL, patientID)
+// GetPatientByHN returns the patient with the HN. An HN retired by a merge
+// resolves to the surviving patient. A stale HIS copy is returned as is while
+// it is refreshed in the background.
+func (s *PatientService) GetPatientByHN(hn string, staffHospital string) (*models.Patient, error) {
+	patient, err := s.resolvePatient(hn, staffHospital)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	s.checkFreshness(patient)
+	return patient, nil
+}
+
+func (s *PatientService) resolvePatient(hn string, staffHospital string) (*models.Patient, error) {
+	patient, err := s.patientRepo.ResolvePatientByHN(hn, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	return patient, nil
+}
+
+func (s *PatientService) CreatePatient(req *models.CreatePatientRequest, staffHospital string, staffID int) (*models.Patient, error) {
+	dateOfBirth, err := parseDateOfBirth(req.DateOfBirth)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+
+	patient := &models.Patient{
+		NationalID:   emptyToNil(req.NationalID),
+		PassportID:   emptyToNil(req.PassportID),
+		FirstNameTH:  emptyToNil(req.FirstNameTH),
+		MiddleNameTH: emptyToNil(req.MiddleNameTH),
+		LastNameTH:   emptyToNil(req.LastNameTH),
+		FirstNameEN:  emptyToNil(req.FirstNameEN),
+		MiddleNameEN: emptyToNil(req.MiddleNameEN),
+		LastNameEN:   emptyToNil(req.LastNameEN),
+		DateOfBirth:  dateOfBirth,
+		PhoneNumber:  emptyToNil(req.PhoneNumber),
+		Email:        emptyToNil(req.Email),
+		Gender:       req.Gender,
+		Hospital:     staffHospital,
+		UpdatedAt:    now(),
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if err := validatePatient(patient); err != nil {
+		return nil, err
+	}
 
-	resp, err := s.httpClient.Do(req)
+	exists, err := s.patientRepo.ExistsByIdentifier(patient.NationalID, patient.PassportID, staffHospital, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HIS API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var patient models.Patient
-	if err := json.NewDecoder(resp.Body).Decode(&patient); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &patient, nil
-}
-
-func (s *PatientService) getMockPatient(patientID string) *models.Patient {
-	mockPatients := map[string]*models.Patient{
-		"HN001": {
-			NationalID:   stringPtr("1234567890123"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("สมชาย"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("ใจดี"),
-			FirstNameEN:  stringPtr("Somchai"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Jaidee"),
-			DateOfBirth:  time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0891234567"),
-			Email:        stringPtr("somchai@email.com"),
-			Gender:       "M",
-			PatientHN:    "HN001",
-			Hospital:     "Hospital A",
-		},
-		"HN002": {
-			NationalID:   stringPtr("9876543210987"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("สมหญิง"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("รักดี"),
-			FirstNameEN:  stringPtr("Somying"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Rakdee"),
-			DateOfBirth:  time.Date(1990, 7, 20, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0899876543"),
-			Email:        stringPtr("somying@email.com"),
-			Gender:       "F",
-			PatientHN:    "HN002",
-			Hospital:     "Hospital A",
-		},
-		"HN003": {
-			NationalID:   nil,
-			PassportID:   stringPtr("AB1234567"),
-			FirstNameTH:  nil,
-			MiddleNameTH: nil,
-			LastNameTH:   nil,
-			FirstNameEN:  stringPtr("John"),
-			MiddleNameEN: stringPtr("William"),
-			LastNameEN:   stringPtr("Smith"),
-			DateOfBirth:  time.Date(1978, 11, 5, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("+1234567890"),
-			Email:        stringPtr("john.smith@email.com"),
-			Gender:       "M",
-			PatientHN:    "HN003",
-			Hospital:     "Hospital A",
-		},
-		"HN005": {
-			NationalID:   stringPtr("1122334455667"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("ประเสริฐ"),
-			MiddleNameTH: stringPtr("สุข"),
-			LastNameTH:   stringPtr("สมบูรณ์"),
-			FirstNameEN:  stringPtr("Prasert"),
-			MiddleNameEN: stringPtr("Suk"),
-			LastNameEN:   stringPtr("Sombun"),
-			DateOfBirth:  time.Date(1992, 2, 14, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0823456789"),
-			Email:        stringPtr("prasert@email.com"),
-			Gender:       "M",
-			PatientHN:    "HN005",
-			Hospital:     "Hospital A",
-		},
-		"HN006": {
-			NationalID:   stringPtr("2233445566778"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("มาลี"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("ดีใจ"),
-			FirstNameEN:  stringPtr("Malee"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Deejai"),
-			DateOfBirth:  time.Date(1988, 9, 30, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0834567890"),
-			Email:        stringPtr("malee@email.com"),
-			Gender:       "F",
-			PatientHN:    "HN006",
-			Hospital:     "Hospital A",
-		},
-		"HN007": {
-			NationalID:   stringPtr("3344556677889"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("สมศักดิ์"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("เก่งดี"),
-			FirstNameEN:  stringPtr("Somsak"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Kengdee"),
-			DateOfBirth:  time.Date(1980, 6, 25, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0845678901"),
-			Email:        nil,
-			Gender:       "M",
-			PatientHN:    "HN007",
-			Hospital:     "Hospital A",
-		},
-		"HN008": {
-			NationalID:   nil,
-			PassportID:   stringPtr("CD9876543"),
-			FirstNameTH:  nil,
-			MiddleNameTH: nil,
-			LastNameTH:   nil,
-			FirstNameEN:  stringPtr("Sarah"),
-			MiddleNameEN: stringPtr("Jane"),
-			LastNameEN:   stringPtr("Johnson"),
-			DateOfBirth:  time.Date(1995, 4, 12, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("+44123456789"),
-			Email:        stringPtr("sarah.j@email.com"),
-			Gender:       "F",
-			PatientHN:    "HN008",
-			Hospital:     "Hospital A",
-		},
-		"HN004": {
-			NationalID:   stringPtr("1111222233334"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("วิชัย"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("สุขใจ"),
-			FirstNameEN:  stringPtr("Wichai"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Sukjai"),
-			DateOfBirth:  time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0812345678"),
-			Email:        nil,
-			Gender:       "M",
-			PatientHN:    "HN004",
-			Hospital:     "Hospital B",
-		},
-		"HN009": {
-			NationalID:   stringPtr("4455667788990"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("นิดา"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("รุ่งเรือง"),
-			FirstNameEN:  stringPtr("Nida"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Rungruang"),
-			DateOfBirth:  time.Date(1993, 8, 18, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0856789012"),
-			Email:        stringPtr("nida@email.com"),
-			Gender:       "F",
-			PatientHN:    "HN009",
-			Hospital:     "Hospital B",
-		},
-		"HN010": {
-			NationalID:   stringPtr("5566778899001"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("วีระ"),
-			MiddleNameTH: stringPtr("ชัย"),
-			LastNameTH:   stringPtr("วัฒนา"),
-			FirstNameEN:  stringPtr("Weera"),
-			MiddleNameEN: stringPtr("Chai"),
-			LastNameEN:   stringPtr("Wattana"),
-			DateOfBirth:  time.Date(1987, 12, 3, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0867890123"),
-			Email:        stringPtr("weera@email.com"),
-			Gender:       "M",
-			PatientHN:    "HN010",
-			Hospital:     "Hospital B",
-		},
-		"HN011": {
-			NationalID:   stringPtr("6677889900112"),
-			PassportID:   nil,
-			FirstNameTH:  stringPtr("สุภาพ"),
-			MiddleNameTH: nil,
-			LastNameTH:   stringPtr("ใจดี"),
-			FirstNameEN:  stringPtr("Supap"),
-			MiddleNameEN: nil,
-			LastNameEN:   stringPtr("Jaidee"),
-			DateOfBirth:  time.Date(1991, 1, 22, 0, 0, 0, 0, time.UTC),
-			PhoneNumber:  stringPtr("0878901234"),
-			Email:        nil,
-			Gender:       "F",
-			PatientHN:    "HN011",
-			Hospital:     "Hospital B",
-		},
-	}
-
-	for _, p := range mockPatients {
-		if p.NationalID != nil && *p.NationalID == patientID {
-			return p
+		return nil, err
+	}
+	if exists {
+		return nil, ErrPatientExists
+	}
+
+	// The repository checks again under a lock, for registrations racing past this check
+	change := models.PatientChange{Source: models.ChangeSourceLocal, ActorID: &staffID}
+	if err := s.patientRepo.CreatePatient(patient, s.config.Patient.HNPrefix, change); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateIdentifier) {
+			return nil, ErrPatientExists
 		}
-		if p.PassportID != nil && *p.PassportID == patientID {
-			return p
+		return nil, err
+	}
+
+	s.indexPatient(patient)
+	s.writeBackToHIS(http.MethodPost, patient)
+
+	patient.Source = models.PatientSourceLocal
+	return patient, nil
+}
+
+// UpdatePatient applies a partial update. expectedUpdatedAt comes from the If-Match
+// header; when it is nil the updated_at in the request body is used instead.
+func (s *PatientService) UpdatePatient(hn string, req *models.UpdatePatientRequest, expectedUpdatedAt *time.Time, staffHospital string, staffID int) (*models.Patient, error) {
+	if expectedUpdatedAt == nil {
+		expectedUpdatedAt = req.UpdatedAt
+	}
+	if expectedUpdatedAt == nil {
+		return nil, ErrPreconditionRequired
+	}
+
+	patient, err := s.resolvePatient(hn, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	if !patient.UpdatedAt.Truncate(time.Microsecond).Equal(expectedUpdatedAt.Truncate(time.Microsecond)) {
+		return nil, ErrPatientModified
+	}
+	currentUpdatedAt := patient.UpdatedAt
+
+	// HIS owns the patients it sent: a local edit would be overwritten by the
+	// next refresh, so it is only allowed when it is written back to HIS
+	if patient.LastFetchedAt != nil && !s.writesBackToHIS(patient.Hospital) {
+		return nil, ErrPatientHISManaged
+	}
+
+	updates := map[string]interface{}{}
+	setOptional := func(column string, field **string, value *string) {
+		if value == nil {
+			return
+		}
+		*field = emptyToNil(value)
+		updates[column] = *field
+	}
+	setOptional("national_id", &patient.NationalID, req.NationalID)
+	setOptional("passport_id", &patient.PassportID, req.PassportID)
+	setOptional("first_name_th", &patient.FirstNameTH, req.FirstNameTH)
+	setOptional("middle_name_th", &patient.MiddleNameTH, req.MiddleNameTH)
+	setOptional("last_name_th", &patient.LastNameTH, req.LastNameTH)
+	setOptional("first_name_en", &patient.FirstNameEN, req.FirstNameEN)
+	setOptional("middle_name_en", &patient.MiddleNameEN, req.MiddleNameEN)
+	setOptional("last_name_en", &patient.LastNameEN, req.LastNameEN)
+	setOptional("phone_number", &patient.PhoneNumber, req.PhoneNumber)
+	setOptional("email", &patient.Email, req.Email)
+
+	if req.DateOfBirth != nil {
+		dateOfBirth, err := parseDateOfBirth(*req.DateOfBirth)
+		if err != nil {
+			return nil, err
+		}
+		patient.DateOfBirth = dateOfBirth
+		updates["date_of_birth"] = dateOfBirth
+	}
+	if req.Gender != nil {
+		patient.Gender = *req.Gender
+		updates["gender"] = *req.Gender
+	}
+
+	if len(updates) == 0 {
+		return patient, nil
+	}
+
+	if err := validatePatient(patient); err != nil {
+		return nil, err
+	}
+
+	if req.NationalID != nil || req.PassportID != nil {
+		exists, err := s.patientRepo.ExistsByIdentifier(patient.NationalID, patient.PassportID, staffHospital, patient.ID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrPatientExists
 		}
 	}
 
+	updates["updated_at"] = now()
+
+	change := models.PatientChange{Source: models.ChangeSourceLocal, ActorID: &staffID}
+	updated, err := s.patientRepo.UpdatePatient(patient, updates, currentUpdatedAt, change)
+	if err != nil {
+		if errors.Is(err, repositories.ErrDuplicateIdentifier) {
+			return nil, ErrPatientExists
+		}
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrPatientModified
+	}
+
+	s.indexPatient(patient)
+	s.writeBackToHIS(http.MethodPut, patient)
+
+	return patient, nil
+}
+
+// ValidateSearchRequest checks the q filter of a search so callers can reject it
+// before they start writing a response.
+func ValidateSearchRequest(req *models.PatientSearchRequest) error {
+	if req.Q == nil || strings.TrimSpace(*req.Q) == "" {
+		return nil
+	}
+	return wrapFilterError(repositories.ValidatePatientFilter(*req.Q))
+}
+
+// wrapFilterError marks q filter errors with ErrInvalidQuery. The
+// *utils.FilterError stays reachable through errors.As for its position.
+func wrapFilterError(err error) error {
+	var filterErr *utils.FilterError
+	if errors.As(err, &filterErr) {
+		return fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	return err
+}
+
+// indexPatient updates the master patient index. Failures are logged only; the
+// patient can be picked up later by a reindex.
+func (s *PatientService) indexPatient(patient *models.Patient) {
+	if s.mpiService == nil {
+		return
+	}
+
+	if err := s.mpiService.IndexPatient(patient); err != nil {
+		fmt.Printf("[MPI] Failed to index patient %s (%s): %v\n", patient.PatientHN, patient.Hospital, err)
+	}
+}
+
+func validatePatient(patient *models.Patient) error {
+	if patient.NationalID == nil && patient.PassportID == nil {
+		return fmt.Errorf("%w: national_id or passport_id is required", ErrInvalidPatient)
+	}
+
+	hasTHName := patient.FirstNameTH != nil && patient.LastNameTH != nil
+	hasENName := patient.FirstNameEN != nil && patient.LastNameEN != nil
+	if !hasTHName && !hasENName {
+		return fmt.Errorf("%w: first and last name are required in Thai or English", ErrInvalidPatient)
+	}
+
 	return nil
 }
 
+func parseDateOfBirth(value string) (time.Time, error) {
+	dateOfBirth, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: date_of_birth must be in YYYY-MM-DD format", ErrInvalidPatient)
+	}
+
+	if dateOfBirth.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("%w: date_of_birth cannot be in the future", ErrInvalidPatient)
+	}
+
+	return dateOfBirth, nil
+}
+
+func emptyToNil(value *string) *string {
+	if value == nil {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// now is truncated to microseconds so the value round-trips through PostgreSQL
+// unchanged and can be used as a concurrency token.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// writesBackToHIS reports whether patients changed locally are written back
+// to the HIS of the hospital.
+func (s *PatientService) writesBackToHIS(hospital string) bool {
+	adapter, err := s.hisAdapters.Adapter(hospital)
+	if err != nil {
+		return false
+	}
+	writer, ok := adapter.(HISWriter)
+	return ok && writer.WritesBack()
+}
+
+// writeBackToHIS pushes a locally registered or corrected patient to the HIS of
+// its hospital when write back is enabled there. It runs in the background so
+// registration never waits on HIS.
+func (s *PatientService) writeBackToHIS(method string, patient *models.Patient) {
+	if !s.writesBackToHIS(patient.Hospital) {
+		return
+	}
+	adapter, err := s.hisAdapters.Adapter(patient.Hospital)
+	if err != nil {
+		return
+	}
+
+	snapshot := *patient
+	go func() {
+		if err := adapter.PushPatient(context.Background(), &snapshot, method == http.MethodPost); err != nil {
+			fmt.Printf("[HIS API] Write back failed for patient %s: %v\n", snapshot.PatientHN, err)
+		}
+	}()
+}
+
+// searchPatientFromHIS looks the patient up in the HIS of a hospital, usually
+// the staff member's own. Cancelling ctx cancels the call.
+func (s *PatientService) searchPatientFromHIS(ctx context.Context, hospital string, patientID string) (*models.Patient, error) {
+	adapter, err := s.hisAdapters.Adapter(hospital)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, err := adapter.FetchPatient(ctx, patientID)
+	if err != nil {
+		if !errors.Is(err, ErrPatientNotFound) {
+			fmt.Printf("[HIS API] Lookup for %s failed: %v\n", hospital, err)
+		}
+		return nil, err
+	}
+
+	patient.Source = models.PatientSourceHIS
+	return patient, nil
+}
+
+// lookupHISPatient looks the patient up in HIS and applies HIS_FALLBACK_MODE
+// when HIS fails: strict returns the failure, cache-only returns
+// ErrNotFoundInCache and mock answers from the mock HIS fixtures. A hospital
+// without a HIS has nothing more to find.
+func (s *PatientService) lookupHISPatient(staffHospital string, patientID string) (*models.Patient, error) {
+	patient, err := s.searchPatientFromHIS(context.Background(), staffHospital, patientID)
+	switch {
+	case err == nil:
+		return patient, nil
+	case errors.Is(err, ErrPatientNotFound):
+		s.rememberNotFound(hisLookupKey(staffHospital, patientID))
+		return nil, err
+	case errors.Is(err, ErrNoHISAdapter):
+		return nil, fmt.Errorf("%w: %w", ErrPatientNotFound, err)
+	}
+
+	switch s.config.HISAPI.FallbackMode {
+	case configs.HISFallbackCacheOnly:
+		return nil, fmt.Errorf("%w: %w", ErrNotFoundInCache, err)
+	case configs.HISFallbackMock:
+		if s.mockHIS == nil {
+			return nil, err
+		}
+		patient, mockErr := s.mockHIS.FetchPatient(context.Background(), patientID)
+		if mockErr != nil {
+			return nil, mockErr
+		}
+		patient.Source = models.PatientSourceMock
+		fmt.Printf("[HIS API] Answered %s from mock HIS fixtures\n", patientID)
+		return patient, nil
+	default:
+		return nil, err
+	}
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -2,8 +2,13 @@ package services
 
 import (
 	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/mockhis"
 	"agnos-middleware/internal/models"
 	"agnos-middleware/internal/repositories"
+	"agnos-middleware/internal/utils"
+	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,7 +22,7 @@ func setupPatientTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	err = db.AutoMigrate(&models.Patient{})
+	err = db.AutoMigrate(&models.Patient{}, &models.HNSequence{}, &models.PatientHistory{})
 	if err != nil {
 		t.Fatalf("Failed to migrate: %v", err)
 	}
@@ -26,13 +31,22 @@ func setupPatientTestDB(t *testing.T) *gorm.DB {
 }
 
 func getTestConfig() *configs.ApplicationConfig {
-	return &configs.ApplicationConfig{
-		HISAPI: struct {
-			BaseURL string
-		}{
-			BaseURL: "https://hospital-a.api.co.th",
-		},
+	config := &configs.ApplicationConfig{}
+	config.HISAPI.BaseURL = "https://hospital-a.api.co.th"
+	config.Patient.HNPrefix = "HN"
+	return config
+}
+
+// startMockHIS serves the mock HIS fixtures and returns its base URL.
+func startMockHIS(t *testing.T) string {
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load mock HIS fixtures: %v", err)
 	}
+
+	server := httptest.NewServer(mockhis.NewServer(hospitals, mockhis.Options{}))
+	t.Cleanup(server.Close)
+	return server.URL
 }
 
 func TestSearchPatient_Positive_FoundInDB(t *testing.T) {
@@ -49,7 +63,7 @@ func TestSearchPatient_Positive_FoundInDB(t *testing.T) {
 		Gender:      "M",
 		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
-	err := repo.UpsertPatient(patient)
+	err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS})
 	if err != nil {
 		t.Fatalf("Failed to create patient: %v", err)
 	}
@@ -76,6 +90,7 @@ func TestSearchPatient_Positive_FoundInHIS(t *testing.T) {
 	db := setupPatientTestDB(t)
 	repo := repositories.NewPatientRepository(db)
 	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
 	service := NewPatientService(repo, config)
 
 	req := &models.PatientSearchRequest{
@@ -105,6 +120,7 @@ func TestSearchPatient_Negative_AccessDenied(t *testing.T) {
 	db := setupPatientTestDB(t)
 	repo := repositories.NewPatientRepository(db)
 	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
 	service := NewPatientService(repo, config)
 
 	req := &models.PatientSearchRequest{
@@ -125,6 +141,7 @@ func TestSearchPatient_Negative_NotFound(t *testing.T) {
 	db := setupPatientTestDB(t)
 	repo := repositories.NewPatientRepository(db)
 	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
 	service := NewPatientService(repo, config)
 
 	req := &models.PatientSearchRequest{
@@ -140,3 +157,538 @@ func TestSearchPatient_Negative_NotFound(t *testing.T) {
 		t.Fatalf("Expected 0 patients, got %d", len(patients))
 	}
 }
+
+func TestGetPatientByHN_Positive(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	patient := &models.Patient{
+		PatientHN:   "HN001",
+		Hospital:    "Hospital A",
+		FirstNameEN: stringPtr("John"),
+		Gender:      "M",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	found, err := service.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if found.PatientHN != "HN001" {
+		t.Errorf("Expected PatientHN 'HN001', got '%s'", found.PatientHN)
+	}
+}
+
+func TestGetPatientByHN_Negative_OtherHospital(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	patient := &models.Patient{
+		PatientHN:   "HN004",
+		Hospital:    "Hospital B",
+		Gender:      "M",
+		DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC),
+	}
+	if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	_, err := service.GetPatientByHN("HN004", "Hospital A")
+	if err == nil {
+		t.Fatal("Expected error for patient in another hospital, got nil")
+	}
+
+	if err.Error() != "patient not found" {
+		t.Errorf("Expected 'patient not found', got: %v", err)
+	}
+}
+
+func TestCreatePatient_Positive_AllocatesHN(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	first, err := service.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	second, err := service.CreatePatient(&models.CreatePatientRequest{
+		PassportID:  stringPtr("AB1234567"),
+		FirstNameEN: stringPtr("John"),
+		LastNameEN:  stringPtr("Smith"),
+		DateOfBirth: "1978-11-05",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if first.PatientHN != "HN000001" || second.PatientHN != "HN000002" {
+		t.Errorf("Expected HN000001 and HN000002, got '%s' and '%s'", first.PatientHN, second.PatientHN)
+	}
+
+	if first.Hospital != "Hospital A" {
+		t.Errorf("Expected hospital 'Hospital A', got '%s'", first.Hospital)
+	}
+}
+
+func TestCreatePatient_Negative_Duplicate(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	req := &models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}
+	if _, err := service.CreatePatient(req, "Hospital A", 1); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	_, err := service.CreatePatient(req, "Hospital A", 1)
+	if !errors.Is(err, ErrPatientExists) {
+		t.Errorf("Expected ErrPatientExists, got: %v", err)
+	}
+}
+
+func TestCreatePatient_Negative_DuplicateRacesPastCheck(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	change := models.PatientChange{Source: models.ChangeSourceLocal}
+
+	// Both registrations passed ExistsByIdentifier before either was inserted
+	for i, want := range []error{nil, repositories.ErrDuplicateIdentifier} {
+		patient := &models.Patient{
+			NationalID:  stringPtr("1234567890123"),
+			FirstNameEN: stringPtr("Somchai"),
+			LastNameEN:  stringPtr("Jaidee"),
+			Hospital:    "Hospital A",
+		}
+		err := repo.CreatePatient(patient, "HN", change)
+		if !errors.Is(err, want) {
+			t.Fatalf("Insert %d: expected %v, got: %v", i+1, want, err)
+		}
+	}
+
+	// Another hospital may register the same national ID
+	other := &models.Patient{NationalID: stringPtr("1234567890123"), Hospital: "Hospital B"}
+	if err := repo.CreatePatient(other, "HN", change); err != nil {
+		t.Errorf("Expected no error for another hospital, got: %v", err)
+	}
+}
+
+func TestUpsertPatient_Positive_HISDuplicateIdentifierSaved(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+
+	// HIS holds two HNs for one person; both are cached so they can be merged
+	for _, hn := range []string{"HN001", "HN002"} {
+		patient := &models.Patient{PatientHN: hn, Hospital: "Hospital A", NationalID: stringPtr("1234567890123"), Gender: "M"}
+		if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+			t.Fatalf("Expected %s to be saved, got: %v", hn, err)
+		}
+	}
+}
+
+func TestCreatePatient_Negative_MissingIdentifier(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	_, err := service.CreatePatient(&models.CreatePatientRequest{
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if !errors.Is(err, ErrInvalidPatient) {
+		t.Errorf("Expected ErrInvalidPatient, got: %v", err)
+	}
+}
+
+func TestUpdatePatient_Positive(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	patient, err := service.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		PhoneNumber: stringPtr("0891234567"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	updatedAt := patient.UpdatedAt
+	updated, err := service.UpdatePatient(patient.PatientHN, &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+		Email:       stringPtr(""),
+	}, &updatedAt, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if updated.PhoneNumber == nil || *updated.PhoneNumber != "0800000000" {
+		t.Errorf("Expected phone number '0800000000', got %v", updated.PhoneNumber)
+	}
+
+	if updated.Email != nil {
+		t.Errorf("Expected email to be cleared, got '%s'", *updated.Email)
+	}
+}
+
+func TestUpdatePatient_Negative_HISManaged(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	patient, err := repo.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Without write back the next refresh from HIS would undo the edit
+	updatedAt := patient.UpdatedAt
+	_, err = service.UpdatePatient("HN001", &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+	}, &updatedAt, "Hospital A", 1)
+	if !errors.Is(err, ErrPatientHISManaged) {
+		t.Errorf("Expected ErrPatientHISManaged, got: %v", err)
+	}
+}
+
+func TestUpdatePatient_Positive_HISManagedWithWriteBack(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	config.HISAPI.WriteBack = true
+	service := NewPatientService(repo, config)
+	cacheStalePatient(t, repo)
+
+	patient, err := repo.GetPatientByHN("HN001", "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	updatedAt := patient.UpdatedAt
+	updated, err := service.UpdatePatient("HN001", &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+	}, &updatedAt, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if updated.PhoneNumber == nil || *updated.PhoneNumber != "0800000000" {
+		t.Errorf("Expected phone number '0800000000', got %v", updated.PhoneNumber)
+	}
+}
+
+func TestUpdatePatient_Negative_Stale(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	service := NewPatientService(repo, config)
+
+	patient, err := service.CreatePatient(&models.CreatePatientRequest{
+		NationalID:  stringPtr("1234567890123"),
+		FirstNameEN: stringPtr("Somchai"),
+		LastNameEN:  stringPtr("Jaidee"),
+		DateOfBirth: "1985-03-15",
+		Gender:      "M",
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	stale := patient.UpdatedAt.Add(-time.Minute)
+	_, err = service.UpdatePatient(patient.PatientHN, &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+	}, &stale, "Hospital A", 1)
+	if !errors.Is(err, ErrPatientModified) {
+		t.Errorf("Expected ErrPatientModified, got: %v", err)
+	}
+
+	_, err = service.UpdatePatient(patient.PatientHN, &models.UpdatePatientRequest{
+		PhoneNumber: stringPtr("0800000000"),
+	}, nil, "Hospital A", 1)
+	if !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("Expected ErrPreconditionRequired, got: %v", err)
+	}
+}
+
+func TestBatchLookup_Positive_MixedResults(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	config.HISAPI.BatchConcurrency = 2
+	service := NewPatientService(repo, config)
+
+	patient := &models.Patient{
+		PatientHN:   "HN100",
+		NationalID:  stringPtr("5555555555555"),
+		Hospital:    "Hospital A",
+		FirstNameEN: stringPtr("John"),
+		LastNameEN:  stringPtr("Doe"),
+		Gender:      "M",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	ids := []string{"HN100", "5555555555555", "9876543210987", "1111222233334", "9999999999999", "HN100"}
+	results, err := service.BatchLookup(ids, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []struct {
+		id     string
+		status string
+	}{
+		{"HN100", models.BatchLookupFound},
+		{"5555555555555", models.BatchLookupFound},
+		{"9876543210987", models.BatchLookupFound},
+		{"1111222233334", models.BatchLookupForbidden},
+		{"9999999999999", models.BatchLookupNotFound},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+
+	for i, want := range expected {
+		if results[i].ID != want.id || results[i].Status != want.status {
+			t.Errorf("Result %d: expected %s %s, got %s %s", i, want.id, want.status, results[i].ID, results[i].Status)
+		}
+	}
+
+	if results[3].Patient != nil {
+		t.Error("Expected no patient data for a forbidden result")
+	}
+
+	if _, err := repo.GetPatientByHN("HN002", "Hospital A"); err != nil {
+		t.Errorf("Expected HIS patient to be cached, got error: %v", err)
+	}
+}
+
+func TestSearchPatient_Positive_Query(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	service := NewPatientService(repo, getTestConfig())
+
+	for _, patient := range []*models.Patient{
+		{PatientHN: "HN001", Hospital: "Hospital A", LastNameEN: stringPtr("Jaidee"), Gender: "M", DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN002", Hospital: "Hospital A", LastNameTH: stringPtr("ใจดี"), LastNameEN: stringPtr("Jaidee"), Gender: "F", DateOfBirth: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN003", Hospital: "Hospital A", LastNameEN: stringPtr("Rakdee"), Gender: "M", DateOfBirth: time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN004", Hospital: "Hospital A", LastNameEN: stringPtr("Jaiyen"), Gender: "M", DateOfBirth: time.Date(1992, 6, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+			t.Fatalf("Failed to create patient: %v", err)
+		}
+	}
+
+	tests := []struct {
+		q        string
+		expected []string
+	}{
+		{`last_name~"JAI" AND (gender=F OR dob>=1990-01-01)`, []string{"HN002", "HN004"}},
+		{`NOT last_name~jai`, []string{"HN003"}},
+		{`email != x@y.z AND hn != HN001 AND last_name ~ ใจ`, []string{"HN002"}},
+		{`dob < 1985-03-15 or hn = HN003`, []string{"HN002", "HN003"}},
+	}
+
+	for _, tt := range tests {
+		patients, err := service.SearchPatient(&models.PatientSearchRequest{Q: stringPtr(tt.q)}, "Hospital A")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tt.q, err)
+		}
+
+		var hns []string
+		for _, patient := range patients {
+			hns = append(hns, patient.PatientHN)
+		}
+		if strings.Join(hns, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.q, tt.expected, hns)
+		}
+	}
+}
+
+func TestSearchPatient_Positive_QueryAppliedToHISFallback(t *testing.T) {
+	baseURL := startMockHIS(t)
+
+	// HN002 in HIS is Somying, a woman
+	tests := []struct {
+		q    string
+		want int
+	}{
+		{"gender=M", 0},
+		{`first_name~"somy"`, 1},
+	}
+
+	for _, tt := range tests {
+		repo := repositories.NewPatientRepository(setupPatientTestDB(t))
+		config := getTestConfig()
+		config.HISAPI.BaseURL = baseURL
+		service := NewPatientService(repo, config)
+
+		patients, err := service.SearchPatient(&models.PatientSearchRequest{ID: stringPtr("9876543210987"), Q: stringPtr(tt.q)}, "Hospital A")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tt.q, err)
+		}
+		if len(patients) != tt.want {
+			t.Errorf("%s: expected %d patients from HIS, got %d", tt.q, tt.want, len(patients))
+		}
+		if len(patients) == 1 && patients[0].Source != models.PatientSourceHIS {
+			t.Errorf("%s: expected source %s, got %s", tt.q, models.PatientSourceHIS, patients[0].Source)
+		}
+	}
+}
+
+func TestSearchPatient_Negative_InvalidQuery(t *testing.T) {
+	db := setupPatientTestDB(t)
+	service := NewPatientService(repositories.NewPatientRepository(db), getTestConfig())
+
+	tests := []struct {
+		q        string
+		position int
+	}{
+		{`last_name~"jai" AND (gender=F`, 30},
+		{`salary > 100`, 1},
+		{`gender > F`, 8},
+		{`dob = 1990-13-01`, 7},
+		{`last_name = "jai`, 13},
+	}
+
+	for _, tt := range tests {
+		_, err := service.SearchPatient(&models.PatientSearchRequest{Q: stringPtr(tt.q)}, "Hospital A")
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%s: expected ErrInvalidQuery, got: %v", tt.q, err)
+		}
+
+		var filterErr *utils.FilterError
+		if !errors.As(err, &filterErr) || filterErr.Pos+1 != tt.position {
+			t.Errorf("%s: expected error at position %d, got: %v", tt.q, tt.position, err)
+		}
+	}
+}
+
+func TestSearchPatient_Negative_HISUnavailableFallbackModes(t *testing.T) {
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load mock HIS fixtures: %v", err)
+	}
+	his := httptest.NewServer(mockhis.NewServer(hospitals, mockhis.Options{ErrorRate: 1}))
+	defer his.Close()
+
+	tests := []struct {
+		mode    string
+		wantErr error
+	}{
+		{configs.HISFallbackStrict, ErrHISUnavailable},
+		{configs.HISFallbackCacheOnly, ErrNotFoundInCache},
+	}
+
+	for _, tt := range tests {
+		db := setupPatientTestDB(t)
+		config := getTestConfig()
+		config.HISAPI.BaseURL = his.URL
+		config.HISAPI.FallbackMode = tt.mode
+		service := NewPatientService(repositories.NewPatientRepository(db), config)
+
+		_, err := service.SearchPatient(&models.PatientSearchRequest{ID: stringPtr("9876543210987")}, "Hospital A")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected %v, got: %v", tt.mode, tt.wantErr, err)
+		}
+
+		results, err := service.BatchLookup([]string{"9876543210987"}, "Hospital A")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tt.mode, err)
+		}
+		if tt.mode == configs.HISFallbackCacheOnly {
+			if results[0].Status != models.BatchLookupNotFound || results[0].Source != models.PatientSourceStaleCache {
+				t.Errorf("%s: expected not_found from stale-cache, got %s %s", tt.mode, results[0].Status, results[0].Source)
+			}
+		} else if results[0].Status != models.BatchLookupError {
+			t.Errorf("%s: expected error status, got %s", tt.mode, results[0].Status)
+		}
+	}
+}
+
+func TestSearchPatient_Positive_MockFallbackIsNotCached(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = "http://127.0.0.1:1"
+	config.HISAPI.FallbackMode = configs.HISFallbackMock
+	service := NewPatientService(repo, config)
+	hospitals, err := mockhis.LoadFixtures("../../cmd/mockhis/fixtures")
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	service.SetMockHIS(mockhis.NewAdapter(hospitals, ErrPatientNotFound))
+
+	patients, err := service.SearchPatient(&models.PatientSearchRequest{ID: stringPtr("9876543210987")}, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(patients) != 1 || patients[0].PatientHN != "HN002" || patients[0].Source != models.PatientSourceMock {
+		t.Fatalf("Expected mock HN002, got %+v", patients)
+	}
+
+	if _, err := repo.GetPatientByHN("HN002", "Hospital A"); err == nil {
+		t.Error("Expected mock patient not to be cached")
+	}
+}
+
+func TestSearchPatient_Negative_ErasedPatientIsNotServedFromHIS(t *testing.T) {
+	db := setupPatientTestDB(t)
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.HISAPI.BaseURL = startMockHIS(t)
+	service := NewPatientService(repo, config)
+
+	erasedAt := time.Now()
+	if err := db.Create(&models.Patient{PatientHN: "HN002", Hospital: "Hospital A", Gender: "F", ErasedAt: &erasedAt}).Error; err != nil {
+		t.Fatalf("Failed to create erased patient: %v", err)
+	}
+
+	patients, err := service.SearchPatient(&models.PatientSearchRequest{ID: stringPtr("9876543210987")}, "Hospital A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(patients) != 0 {
+		t.Errorf("Expected the erased patient not to be returned, got %d patients", len(patients))
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"agnos-middleware/internal/configs"
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+	ErrSavedSearchExists   = errors.New("a saved search with this name already exists")
+	ErrSavedSearchLimit    = errors.New("saved search limit reached")
+	ErrSavedSearchName     = errors.New("saved search name must not be blank")
+	ErrSavedSearchCriteria = errors.New("at least one search criteria must be provided")
+)
+
+type StaffWorkspaceService struct {
+	workspaceRepo  *repositories.StaffWorkspaceRepository
+	patientRepo    *repositories.PatientRepository
+	patientService *PatientService
+	config         *configs.ApplicationConfig
+}
+
+func NewStaffWorkspaceService(workspaceRepo *repositories.StaffWorkspaceRepository, patientRepo *repositories.PatientRepository, patientService *PatientService, config *configs.ApplicationConfig) *StaffWorkspaceService {
+	return &StaffWorkspaceService{
+		workspaceRepo:  workspaceRepo,
+		patientRepo:    patientRepo,
+		patientService: patientService,
+		config:         config,
+	}
+}
+
+func (s *StaffWorkspaceService) SaveSearch(req *models.SaveSearchRequest, staffHospital string, staffID int) (*models.SavedSearch, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrSavedSearchName
+	}
+	if len(searchCriteriaNames(&req.Criteria)) == 0 {
+		return nil, ErrSavedSearchCriteria
+	}
+	if err := ValidateSearchRequest(&req.Criteria); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.workspaceRepo.SavedSearchNameExists(staffID, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrSavedSearchExists
+	}
+
+	if limit := s.config.Workspace.SavedSearchLimit; limit > 0 {
+		count, err := s.workspaceRepo.CountSavedSearches(staffID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(limit) {
+			return nil, fmt.Errorf("%w: at most %d saved searches", ErrSavedSearchLimit, limit)
+		}
+	}
+
+	search := &models.SavedSearch{
+		StaffID:  staffID,
+		Hospital: staffHospital,
+		Name:     name,
+		Criteria: req.Criteria,
+	}
+	if err := s.workspaceRepo.CreateSavedSearch(search); err != nil {
+		return nil, err
+	}
+
+	return search, nil
+}
+
+func (s *StaffWorkspaceService) ListSavedSearches(staffHospital string, staffID int) ([]*models.SavedSearch, error) {
+	return s.workspaceRepo.ListSavedSearches(staffID, staffHospital)
+}
+
+func (s *StaffWorkspaceService) DeleteSavedSearch(id int, staffHospital string, staffID int) error {
+	deleted, err := s.workspaceRepo.DeleteSavedSearch(id, staffID, staffHospital)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSavedSearchNotFound
+	}
+
+	return nil
+}
+
+// RunSavedSearch runs a saved search exactly like /patient/search would.
+func (s *StaffWorkspaceService) RunSavedSearch(id int, staffHospital string, staffID int) ([]*models.Patient, error) {
+	search, err := s.workspaceRepo.GetSavedSearch(id, staffID, staffHospital)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSavedSearchNotFound
+		}
+		return nil, err
+	}
+
+	return s.patientService.SearchPatient(&search.Criteria, staffHospital)
+}
+
+// RecordPatientView adds the patient to the staff member's recent patients.
+// Failures are logged only; viewing a patient must not fail because of it.
+func (s *StaffWorkspaceService) RecordPatientView(patient *models.Patient, staffID int) {
+	limit := s.config.Workspace.RecentPatientsLimit
+	if limit <= 0 || staffID == 0 {
+		return
+	}
+
+	if err := s.workspaceRepo.RecordPatientView(staffID, patient.Hospital, patient.ID, limit); err != nil {
+		fmt.Printf("[Workspace] Failed to record view of patient %s by staff %d: %v\n", patient.PatientHN, staffID, err)
+	}
+}
+
+// ListRecentPatients returns the patients the staff member opened most recently
+// in their hospital. Patients deleted or merged away since are left out.
+func (s *StaffWorkspaceService) ListRecentPatients(staffHospital string, staffID int) ([]*models.RecentPatientEntry, error) {
+	limit := s.config.Workspace.RecentPatientsLimit
+	if limit <= 0 {
+		return []*models.RecentPatientEntry{}, nil
+	}
+
+	recent, err := s.workspaceRepo.ListRecentPatients(staffID, staffHospital, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(recent) == 0 {
+		return []*models.RecentPatientEntry{}, nil
+	}
+
+	ids := make([]int, 0, len(recent))
+	for _, entry := range recent {
+		ids = append(ids, entry.PatientID)
+	}
+
+	patients, err := s.patientRepo.GetPatientsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.Patient, len(patients))
+	for _, patient := range patients {
+		byID[patient.ID] = patient
+	}
+
+	entries := make([]*models.RecentPatientEntry, 0, len(recent))
+	for _, entry := range recent {
+		patient := byID[entry.PatientID]
+		if patient == nil || patient.MergedIntoID != nil || patient.Hospital != staffHospital {
+			continue
+		}
+		entries = append(entries, &models.RecentPatientEntry{ViewedAt: entry.ViewedAt, Patient: patient})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"agnos-middleware/internal/models"
+	"agnos-middleware/internal/repositories"
+	"errors"
+	"testing"
+	"time"
+)
+
+func setupWorkspaceTest(t *testing.T) (*repositories.PatientRepository, *PatientService, *StaffWorkspaceService) {
+	db := setupPatientTestDB(t)
+	if err := db.AutoMigrate(&models.SavedSearch{}, &models.RecentPatient{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	repo := repositories.NewPatientRepository(db)
+	config := getTestConfig()
+	config.Workspace.RecentPatientsLimit = 2
+	config.Workspace.SavedSearchLimit = 2
+
+	patientService := NewPatientService(repo, config)
+	workspaceService := NewStaffWorkspaceService(repositories.NewStaffWorkspaceRepository(db), repo, patientService, config)
+	patientService.SetWorkspaceService(workspaceService)
+
+	for _, patient := range []*models.Patient{
+		{PatientHN: "HN001", Hospital: "Hospital A", LastNameEN: stringPtr("Jaidee"), Gender: "M", DateOfBirth: time.Date(1985, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN002", Hospital: "Hospital A", LastNameEN: stringPtr("Rakdee"), Gender: "F", DateOfBirth: time.Date(1990, 7, 20, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN003", Hospital: "Hospital A", LastNameEN: stringPtr("Jaiyen"), Gender: "F", DateOfBirth: time.Date(1992, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{PatientHN: "HN004", Hospital: "Hospital B", LastNameEN: stringPtr("Jaidee"), Gender: "M", DateOfBirth: time.Date(1982, 5, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := repo.UpsertPatient(patient, models.PatientChange{Source: models.ChangeSourceHIS}); err != nil {
+			t.Fatalf("Failed to create patient: %v", err)
+		}
+	}
+
+	return repo, patientService, workspaceService
+}
+
+func TestSavedSearch_Positive_SaveAndRun(t *testing.T) {
+	_, _, service := setupWorkspaceTest(t)
+
+	search, err := service.SaveSearch(&models.SaveSearchRequest{
+		Name:     "  Jai family  ",
+		Criteria: models.PatientSearchRequest{Q: stringPtr("last_name~jai AND gender=M")},
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if search.Name != "Jai family" {
+		t.Errorf("Expected name to be trimmed, got %q", search.Name)
+	}
+
+	patients, err := service.RunSavedSearch(search.ID, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(patients) != 1 || patients[0].PatientHN != "HN001" {
+		t.Fatalf("Expected only HN001, got %d patients", len(patients))
+	}
+
+	searches, err := service.ListSavedSearches("Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(searches) != 1 || searches[0].Criteria.Q == nil {
+		t.Fatal("Expected the saved search with its criteria to be listed")
+	}
+}
+
+func TestSavedSearch_Negative_OtherStaff(t *testing.T) {
+	_, _, service := setupWorkspaceTest(t)
+
+	search, err := service.SaveSearch(&models.SaveSearchRequest{
+		Name:     "Mine",
+		Criteria: models.PatientSearchRequest{LastName: stringPtr("Jai")},
+	}, "Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := service.RunSavedSearch(search.ID, "Hospital A", 2); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Errorf("Expected ErrSavedSearchNotFound for another staff member, got: %v", err)
+	}
+	if err := service.DeleteSavedSearch(search.ID, "Hospital A", 2); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Errorf("Expected ErrSavedSearchNotFound when deleting, got: %v", err)
+	}
+	if err := service.DeleteSavedSearch(search.ID, "Hospital A", 1); err != nil {
+		t.Errorf("Expected owner to delete the search, got: %v", err)
+	}
+}
+
+func TestSavedSearch_Negative_Validation(t *testing.T) {
+	_, _, service := setupWorkspaceTest(t)
+
+	save := func(name string, criteria models.PatientSearchRequest) error {
+		_, err := service.SaveSearch(&models.SaveSearchRequest{Name: name, Criteria: criteria}, "Hospital A", 1)
+		return err
+	}
+
+	if err := save("Empty", models.PatientSearchRequest{}); !errors.Is(err, ErrSavedSearchCriteria) {
+		t.Errorf("Expected ErrSavedSearchCriteria, got: %v", err)
+	}
+	if err := save("Bad", models.PatientSearchRequest{Q: stringPtr("shoe_size:42")}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery, got: %v", err)
+	}
+	if err := save("First", models.PatientSearchRequest{Gender: stringPtr("M")}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := save("First", models.PatientSearchRequest{Gender: stringPtr("F")}); !errors.Is(err, ErrSavedSearchExists) {
+		t.Errorf("Expected ErrSavedSearchExists, got: %v", err)
+	}
+	if err := save("Second", models.PatientSearchRequest{Gender: stringPtr("F")}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := save("Third", models.PatientSearchRequest{Gender: stringPtr("F")}); !errors.Is(err, ErrSavedSearchLimit) {
+		t.Errorf("Expected ErrSavedSearchLimit, got: %v", err)
+	}
+}
+
+func TestRecentPatients_Positive_NewestFirstAndTrimmed(t *testing.T) {
+	repo, patientService, service := setupWorkspaceTest(t)
+
+	for _, hn := range []string{"HN001", "HN002", "HN003", "HN001"} {
+		patient, err := repo.GetPatientByHN(hn, "Hospital A")
+		if err != nil {
+			t.Fatalf("Failed to load patient: %v", err)
+		}
+		patientService.RecordPatientView(patient, 1)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	entries, err := service.ListRecentPatients("Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected list trimmed to 2 entries, got %d", len(entries))
+	}
+	if entries[0].Patient.PatientHN != "HN001" || entries[1].Patient.PatientHN != "HN003" {
+		t.Errorf("Expected HN001 then HN003, got %s then %s", entries[0].Patient.PatientHN, entries[1].Patient.PatientHN)
+	}
+
+	others, err := service.ListRecentPatients("Hospital A", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(others) != 0 {
+		t.Errorf("Expected other staff to have no recent patients, got %d", len(others))
+	}
+}
+
+func TestRecentPatients_Positive_ScopedToHospital(t *testing.T) {
+	repo, patientService, service := setupWorkspaceTest(t)
+
+	patient, err := repo.GetPatientByHN("HN004", "Hospital B")
+	if err != nil {
+		t.Fatalf("Failed to load patient: %v", err)
+	}
+	patientService.RecordPatientView(patient, 1)
+
+	entries, err := service.ListRecentPatients("Hospital A", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected Hospital B patient to be hidden from Hospital A, got %d", len(entries))
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerStatus is a snapshot of a circuit breaker for status endpoints.
+type BreakerStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker stops calls to a failing dependency. After threshold
+// consecutive failures it opens and Allow fails fast for openTimeout. Then one
+// trial call is let through (half open): success closes the breaker, failure
+// opens it again.
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	state         string
+	failures      int
+	trialRunning  bool
+	openedAt      time.Time
+	lastFailureAt time.Time
+	lastError     string
+}
+
+// NewCircuitBreaker returns a closed breaker. A threshold of 0 or less never
+// opens.
+func NewCircuitBreaker(name string, threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+		state:       BreakerClosed,
+	}
+}
+
+// Allow reports whether a call may go ahead. It returns ErrCircuitOpen while the
+// breaker is open, or while the half open trial call is still running.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.openTimeout)) {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trialRunning = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trialRunning {
+			return ErrCircuitOpen
+		}
+		b.trialRunning = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success records a call that reached the dependency and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trialRunning = false
+}
+
+// Failure records a call that failed because the dependency is unhealthy.
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastFailureAt = b.now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	if b.state == BreakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = BreakerOpen
+		b.openedAt = b.lastFailureAt
+	}
+	b.trialRunning = false
+}
+
+// Skip records a call that ended without showing whether the dependency is
+// healthy, e.g. because the caller gave up. It only frees the half open trial.
+func (b *CircuitBreaker) Skip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialRunning = false
+}
+
+// Status returns a snapshot of the breaker.
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Name:                b.name,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if !b.lastFailureAt.IsZero() {
+		lastFailureAt := b.lastFailureAt
+		status.LastFailureAt = &lastFailureAt
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.openTimeout)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
@@ -42,6 +42,18 @@ func ConnectDatabase(config *configs.ApplicationConfig) (*gorm.DB, error) {
 	err = db.AutoMigrate(
 		&models.Staff{},
 		&models.Patient{},
+		&models.HNSequence{},
+		&models.PatientMatchCandidate{},
+		&models.PatientMerge{},
+		&models.PatientHistory{},
+		&models.PatientErasureRequest{},
+		&models.AuditLog{},
+		&models.HL7DeadLetter{},
+		&models.SavedSearch{},
+		&models.RecentPatient{},
+		&models.HISSyncCheckpoint{},
+		&models.HISEventRecord{},
+		&models.HISEventDelivery{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
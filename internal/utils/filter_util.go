@@ -0,0 +1,419 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits that keep a filter cheap to parse and to run.
+const (
+	filterMaxLength = 1000
+	filterMaxDepth  = 16
+	filterMaxTerms  = 32
+)
+
+type FilterFieldType int
+
+const (
+	FilterString FilterFieldType = iota
+	FilterDate
+)
+
+// FilterField describes a field that may be used in a filter. A field backed by
+// more than one column matches when any of them does.
+type FilterField struct {
+	Columns []string
+	Type    FilterFieldType
+}
+
+// FilterError is a filter syntax or validation error. Pos is the 0-based byte
+// offset in the input where the problem was found.
+type FilterError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// FilterNode is a node of the parsed filter expression.
+type FilterNode interface {
+	filterNode()
+}
+
+type FilterAnd struct{ Left, Right FilterNode }
+
+type FilterOr struct{ Left, Right FilterNode }
+
+type FilterNot struct{ Expr FilterNode }
+
+type FilterComparison struct {
+	Field    string
+	Op       string
+	Value    string
+	FieldPos int
+	OpPos    int
+	ValuePos int
+}
+
+func (FilterAnd) filterNode()        {}
+func (FilterOr) filterNode()         {}
+func (FilterNot) filterNode()        {}
+func (FilterComparison) filterNode() {}
+
+// CompileFilter parses a filter such as
+//
+//	last_name~"jai" AND (gender=F OR dob>=1990-01-01)
+//
+// and compiles it to a parameterized SQL condition over the whitelisted fields.
+// Operators: = != for every field, ~ !~ (case-insensitive contains) for strings
+// and > >= < <= for dates; terms combine with AND, OR, NOT and parentheses.
+func CompileFilter(input string, fields map[string]FilterField) (string, []interface{}, error) {
+	node, err := ParseFilter(input)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var args []interface{}
+	sql, err := compileFilterNode(node, fields, &args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sql, args, nil
+}
+
+func ParseFilter(input string) (FilterNode, error) {
+	if len(input) > filterMaxLength {
+		return nil, &FilterError{Pos: filterMaxLength, Msg: fmt.Sprintf("query is longer than %d characters", filterMaxLength)}
+	}
+
+	tokens, err := tokenizeFilter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != filterTokenEOF {
+		return nil, &FilterError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok.describe())}
+	}
+
+	return node, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenEOF filterTokenKind = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenOp
+	filterTokenLParen
+	filterTokenRParen
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	text  string
+	pos   int
+	upper string
+}
+
+func (t filterToken) describe() string {
+	switch t.kind {
+	case filterTokenEOF:
+		return "end of query"
+	case filterTokenString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (t filterToken) isKeyword(keyword string) bool {
+	return t.kind == filterTokenIdent && t.upper == keyword
+}
+
+func tokenizeFilter(input string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenRParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			start := i
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, &FilterError{Pos: start, Msg: "unterminated string"}
+				}
+				if input[i] == '\\' && i+1 < len(input) && (input[i+1] == '"' || input[i+1] == '\\') {
+					value.WriteByte(input[i+1])
+					i += 2
+					continue
+				}
+				if input[i] == '"' {
+					i++
+					break
+				}
+				value.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: value.String(), pos: start})
+		case strings.ContainsRune("=!~<>", rune(c)):
+			start := i
+			op := string(c)
+			if i+1 < len(input) && (input[i+1] == '=' || (c == '!' && input[i+1] == '~')) {
+				op += string(input[i+1])
+			}
+			switch op {
+			case "=", "!=", "~", "!~", "<", "<=", ">", ">=":
+			default:
+				return nil, &FilterError{Pos: start, Msg: fmt.Sprintf("unknown operator %q", op)}
+			}
+			i += len(op)
+			tokens = append(tokens, filterToken{kind: filterTokenOp, text: op, pos: start})
+		default:
+			start := i
+			for i < len(input) && isFilterWordByte(input[i]) {
+				i++
+			}
+			if i == start {
+				return nil, &FilterError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", rune(c))}
+			}
+			word := input[start:i]
+			tokens = append(tokens, filterToken{kind: filterTokenIdent, text: word, pos: start, upper: strings.ToUpper(word)})
+		}
+	}
+
+	return append(tokens, filterToken{kind: filterTokenEOF, pos: len(input)}), nil
+}
+
+// isFilterWordByte accepts bytes of bare words: field names and unquoted values
+// such as dates, IDs and email addresses. Non-ASCII bytes are accepted so Thai
+// names can be written without quotes.
+func isFilterWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || c == '-' || c == '.' || c == '@' || c == '+' ||
+		unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+type filterParser struct {
+	tokens []filterToken
+	next   int
+	terms  int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.next]
+}
+
+func (p *filterParser) advance() filterToken {
+	tok := p.tokens[p.next]
+	if tok.kind != filterTokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *filterParser) parseOr(depth int) (FilterNode, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().isKeyword("OR") {
+		p.advance()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &FilterOr{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (FilterNode, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().isKeyword("AND") {
+		p.advance()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &FilterAnd{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (FilterNode, error) {
+	tok := p.peek()
+	if depth > filterMaxDepth {
+		return nil, &FilterError{Pos: tok.pos, Msg: "query is nested too deeply"}
+	}
+
+	if tok.isKeyword("NOT") {
+		p.advance()
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &FilterNot{Expr: expr}, nil
+	}
+
+	if tok.kind == filterTokenLParen {
+		p.advance()
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != filterTokenRParen {
+			return nil, &FilterError{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\", found %s", closing.describe())}
+		}
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (FilterNode, error) {
+	field := p.advance()
+	if field.kind != filterTokenIdent || field.isKeyword("AND") || field.isKeyword("OR") {
+		return nil, &FilterError{Pos: field.pos, Msg: fmt.Sprintf("expected a field name, found %s", field.describe())}
+	}
+
+	op := p.advance()
+	if op.kind != filterTokenOp {
+		return nil, &FilterError{Pos: op.pos, Msg: fmt.Sprintf("expected an operator after %q, found %s", field.text, op.describe())}
+	}
+
+	value := p.advance()
+	if value.kind != filterTokenIdent && value.kind != filterTokenString {
+		return nil, &FilterError{Pos: value.pos, Msg: fmt.Sprintf("expected a value after %q, found %s", op.text, value.describe())}
+	}
+
+	p.terms++
+	if p.terms > filterMaxTerms {
+		return nil, &FilterError{Pos: field.pos, Msg: fmt.Sprintf("query has more than %d conditions", filterMaxTerms)}
+	}
+
+	return &FilterComparison{
+		Field:    strings.ToLower(field.text),
+		Op:       op.text,
+		Value:    value.text,
+		FieldPos: field.pos,
+		OpPos:    op.pos,
+		ValuePos: value.pos,
+	}, nil
+}
+
+// compileFilterNode turns the AST into SQL. Negations are wrapped in COALESCE so
+// NULL columns count as not matching instead of dropping the row.
+func compileFilterNode(node FilterNode, fields map[string]FilterField, args *[]interface{}) (string, error) {
+	switch n := node.(type) {
+	case *FilterAnd:
+		return compileFilterBinary(n.Left, n.Right, "AND", fields, args)
+	case *FilterOr:
+		return compileFilterBinary(n.Left, n.Right, "OR", fields, args)
+	case *FilterNot:
+		inner, err := compileFilterNode(n.Expr, fields, args)
+		if err != nil {
+			return "", err
+		}
+		return "NOT COALESCE((" + inner + "), FALSE)", nil
+	case *FilterComparison:
+		return compileFilterComparison(n, fields, args)
+	default:
+		return "", fmt.Errorf("unknown filter node %T", node)
+	}
+}
+
+func compileFilterBinary(left FilterNode, right FilterNode, op string, fields map[string]FilterField, args *[]interface{}) (string, error) {
+	l, err := compileFilterNode(left, fields, args)
+	if err != nil {
+		return "", err
+	}
+	r, err := compileFilterNode(right, fields, args)
+	if err != nil {
+		return "", err
+	}
+	return "(" + l + " " + op + " " + r + ")", nil
+}
+
+func compileFilterComparison(c *FilterComparison, fields map[string]FilterField, args *[]interface{}) (string, error) {
+	field, ok := fields[c.Field]
+	if !ok {
+		return "", &FilterError{Pos: c.FieldPos, Msg: fmt.Sprintf("unknown field %q", c.Field)}
+	}
+
+	var value interface{} = c.Value
+	var condition string
+
+	switch field.Type {
+	case FilterDate:
+		date, err := time.Parse("2006-01-02", c.Value)
+		if err != nil {
+			return "", &FilterError{Pos: c.ValuePos, Msg: fmt.Sprintf("%q is not a date (YYYY-MM-DD)", c.Value)}
+		}
+		value = date
+		switch c.Op {
+		case "=", "!=", "<", "<=", ">", ">=":
+			op := c.Op
+			if op == "!=" {
+				op = "="
+			}
+			condition = "%s " + op + " ?"
+		default:
+			return "", &FilterError{Pos: c.OpPos, Msg: fmt.Sprintf("operator %q cannot be used with date field %q", c.Op, c.Field)}
+		}
+	default:
+		switch c.Op {
+		case "=", "!=":
+			condition = "%s = ?"
+		case "~", "!~":
+			condition = "LOWER(%s) LIKE ? ESCAPE '\\'"
+			value = "%" + escapeLike(strings.ToLower(c.Value)) + "%"
+		default:
+			return "", &FilterError{Pos: c.OpPos, Msg: fmt.Sprintf("operator %q cannot be used with text field %q", c.Op, c.Field)}
+		}
+	}
+
+	parts := make([]string, 0, len(field.Columns))
+	for _, column := range field.Columns {
+		parts = append(parts, fmt.Sprintf(condition, column))
+		*args = append(*args, value)
+	}
+	sql := "(" + strings.Join(parts, " OR ") + ")"
+
+	if c.Op == "!=" || c.Op == "!~" {
+		sql = "NOT COALESCE(" + sql + ", FALSE)"
+	}
+
+	return sql, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
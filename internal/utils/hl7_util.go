@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MLLP framing bytes
+const (
+	mllpStartBlock   = 0x0b
+	mllpEndBlock     = 0x1c
+	mllpCarriageRet  = 0x0d
+	mllpMaxFrameSize = 1 << 20
+	// mllpMaxNoise is how many bytes before a start block are skipped before the
+	// connection is given up on.
+	mllpMaxNoise = 4 << 10
+)
+
+// HL7 acknowledgment codes
+const (
+	HL7AckAccept = "AA"
+	HL7AckError  = "AE"
+	HL7AckReject = "AR"
+)
+
+var (
+	ErrMLLPFrameTooLarge = errors.New("MLLP frame too large")
+	ErrMLLPNoStartBlock  = errors.New("MLLP start block not found")
+)
+
+// HL7Message is a parsed HL7 v2 message. Segments keep their raw fields;
+// Component decodes escape sequences.
+type HL7Message struct {
+	Segments [][]string
+
+	fieldSep        string
+	componentSep    string
+	repetitionSep   string
+	escapeChar      string
+	subcomponentSep string
+}
+
+// ParseHL7 parses an HL7 v2 message in ER7 (pipe) encoding. Segments may be
+// separated by CR, LF or CRLF.
+func ParseHL7(raw string) (*HL7Message, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\r")
+	raw = strings.ReplaceAll(raw, "\n", "\r")
+	raw = strings.Trim(raw, "\r")
+
+	if !strings.HasPrefix(raw, "MSH") || len(raw) < 8 {
+		return nil, errors.New("message must start with an MSH segment")
+	}
+
+	msg := &HL7Message{
+		fieldSep:        raw[3:4],
+		componentSep:    raw[4:5],
+		repetitionSep:   raw[5:6],
+		escapeChar:      raw[6:7],
+		subcomponentSep: raw[7:8],
+	}
+
+	for _, line := range strings.Split(raw, "\r") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, msg.fieldSep)
+		if len(fields[0]) != 3 {
+			return nil, fmt.Errorf("invalid segment name %q", fields[0])
+		}
+		msg.Segments = append(msg.Segments, fields)
+	}
+
+	if msg.ControlID() == "" {
+		return nil, errors.New("MSH-10 message control ID is required")
+	}
+	if code, _ := msg.MessageType(); code == "" {
+		return nil, errors.New("MSH-9 message type is required")
+	}
+
+	return msg, nil
+}
+
+// Field returns the raw value of field n (1-based, as in the HL7 spec) of the
+// first segment with the name, or "" when it is missing.
+func (m *HL7Message) Field(segment string, n int) string {
+	for _, fields := range m.Segments {
+		if fields[0] != segment {
+			continue
+		}
+		// MSH-1 is the field separator itself, so MSH fields are shifted by one
+		if segment == "MSH" {
+			n--
+		}
+		if n <= 0 || n >= len(fields) {
+			return ""
+		}
+		return fields[n]
+	}
+	return ""
+}
+
+func (m *HL7Message) Repetitions(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, m.repetitionSep)
+}
+
+// Component returns component n (1-based) of a field value with escape
+// sequences decoded.
+func (m *HL7Message) Component(value string, n int) string {
+	components := strings.Split(value, m.componentSep)
+	if n <= 0 || n > len(components) {
+		return ""
+	}
+	return m.unescape(components[n-1])
+}
+
+// MessageType returns the message code and trigger event from MSH-9, e.g. ADT and A04.
+func (m *HL7Message) MessageType() (string, string) {
+	value := m.Field("MSH", 9)
+	return m.Component(value, 1), m.Component(value, 2)
+}
+
+func (m *HL7Message) ControlID() string {
+	return m.Field("MSH", 10)
+}
+
+func (m *HL7Message) unescape(value string) string {
+	if !strings.Contains(value, m.escapeChar) {
+		return value
+	}
+
+	e := m.escapeChar
+	return strings.NewReplacer(
+		e+"F"+e, m.fieldSep,
+		e+"S"+e, m.componentSep,
+		e+"R"+e, m.repetitionSep,
+		e+"T"+e, m.subcomponentSep,
+		e+"E"+e, m.escapeChar,
+	).Replace(value)
+}
+
+// BuildHL7ACK builds the ACK for msg with the given acknowledgment code. msg may
+// be nil when the message could not be parsed; controlID is then echoed as is.
+func BuildHL7ACK(msg *HL7Message, controlID string, ackCode string, text string) string {
+	sendingApp, sendingFacility, receivingApp, receivingFacility, event, version := "", "", "", "", "", "2.5"
+	if msg != nil {
+		sendingApp, sendingFacility = msg.Field("MSH", 3), msg.Field("MSH", 4)
+		receivingApp, receivingFacility = msg.Field("MSH", 5), msg.Field("MSH", 6)
+		_, event = msg.MessageType()
+		if v := msg.Field("MSH", 12); v != "" {
+			version = v
+		}
+		controlID = msg.ControlID()
+	}
+
+	text = strings.NewReplacer("|", " ", "^", " ", "~", " ", "\\", " ", "&", " ", "\r", " ", "\n", " ").Replace(text)
+	timestamp := time.Now().Format("20060102150405")
+
+	msh := strings.Join([]string{
+		"MSH", "^~\\&", receivingApp, receivingFacility, sendingApp, sendingFacility,
+		timestamp, "", "ACK^" + event + "^ACK", "ACK" + timestamp, "P", version,
+	}, "|")
+	msa := strings.Join([]string{"MSA", ackCode, controlID, text}, "|")
+
+	return msh + "\r" + msa + "\r"
+}
+
+// ReadMLLPFrame reads one MLLP framed message, skipping up to mllpMaxNoise
+// bytes before the start block.
+func ReadMLLPFrame(r *bufio.Reader) (string, error) {
+	for skipped := 0; ; skipped++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == mllpStartBlock {
+			break
+		}
+		if skipped >= mllpMaxNoise {
+			return "", ErrMLLPNoStartBlock
+		}
+	}
+
+	var frame []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+
+		if b == mllpEndBlock {
+			next, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if next == mllpCarriageRet {
+				return string(frame), nil
+			}
+			frame = append(frame, b, next)
+		} else {
+			frame = append(frame, b)
+		}
+
+		if len(frame) > mllpMaxFrameSize {
+			return "", ErrMLLPFrameTooLarge
+		}
+	}
+}
+
+func WriteMLLPFrame(w io.Writer, message string) error {
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, mllpStartBlock)
+	frame = append(frame, message...)
+	frame = append(frame, mllpEndBlock, mllpCarriageRet)
+
+	_, err := w.Write(frame)
+	return err
+}
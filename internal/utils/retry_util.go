@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls Retry. MaxAttempts counts the first call; 1 or less
+// means no retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Retry calls fn until it succeeds, returns an error retryable rejects, or
+// MaxAttempts is reached. Waits grow exponentially from BaseDelay up to
+// MaxDelay with full jitter so clients recovering together do not retry in
+// step. It stops early, returning the last error, when ctx is done.
+func Retry(ctx context.Context, policy RetryPolicy, retryable func(err error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoffDelay(policy, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay is a random wait between 0 and BaseDelay*2^(attempt-1), capped
+// at MaxDelay.
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	if policy.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobPaused   = errors.New("job is paused")
+)
+
+// JobStatus is a snapshot of a scheduled job for status endpoints.
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	Paused       bool       `json:"paused"`
+	Runs         int        `json:"runs"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+	trigger  chan struct{}
+	status   JobStatus
+	// cancelRun cancels the run in progress, if any
+	cancelRun context.CancelFunc
+}
+
+// Scheduler runs background jobs inside the service. Each job runs on its own
+// interval and never overlaps with itself.
+type Scheduler struct {
+	mu     sync.Mutex
+	jobs   map[string]*scheduledJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: map[string]*scheduledJob{},
+	}
+}
+
+// Register adds a job. A zero interval registers the job without a schedule so it
+// only runs when triggered. Jobs must be registered before Start.
+func (s *Scheduler) Register(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[name] = &scheduledJob{
+		name:     name,
+		interval: interval,
+		run:      run,
+		trigger:  make(chan struct{}, 1),
+		status:   JobStatus{Name: name, Interval: interval.String()},
+	}
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.cancel = cancel
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// Trigger runs a job as soon as it is idle, outside of its schedule. Paused
+// jobs return ErrJobPaused.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	paused := ok && job.status.Paused
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if paused {
+		return fmt.Errorf("%w: %s", ErrJobPaused, name)
+	}
+
+	select {
+	case job.trigger <- struct{}{}:
+	default:
+		// Already queued
+	}
+	return nil
+}
+
+// Pause stops a job from running on its schedule or when triggered until it is
+// resumed. A run in progress has its context cancelled, so jobs that check it
+// stop early.
+func (s *Scheduler) Pause(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	job.status.Paused = true
+	if job.cancelRun != nil {
+		job.cancelRun()
+	}
+	return nil
+}
+
+// Resume lets a paused job run again from its next tick or trigger.
+func (s *Scheduler) Resume(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	job.status.Paused = false
+	return nil
+}
+
+// JobStatus returns the status of one job.
+func (s *Scheduler) JobStatus(name string) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	return job.status, nil
+}
+
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	return statuses
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	defer s.wg.Done()
+
+	var tick <-chan time.Time
+	if job.interval > 0 {
+		ticker := time.NewTicker(job.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-job.trigger:
+		}
+
+		s.runJob(ctx, job)
+	}
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job *scheduledJob) {
+	startedAt := time.Now()
+	s.mu.Lock()
+	if job.status.Paused {
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	job.cancelRun = cancel
+	job.status.Running = true
+	s.mu.Unlock()
+
+	err := job.run(ctx)
+
+	s.mu.Lock()
+	if job.status.Paused && errors.Is(err, context.Canceled) {
+		// Stopped by Pause, not a failure
+		err = nil
+	}
+	job.cancelRun = nil
+	job.status.Running = false
+	job.status.Runs++
+	job.status.LastRunAt = &startedAt
+	job.status.LastDuration = time.Since(startedAt).String()
+	job.status.LastError = ""
+	if err != nil {
+		job.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[Scheduler] Job %s failed: %v", job.name, err)
+	}
+}